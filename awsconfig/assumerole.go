@@ -2,85 +2,207 @@ package awsconfig
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/aws/arn"
 	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
-	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/aws/aws-sdk-go-v2/service/sts/types"
 )
 
-const (
-	errParseIAMRoleArn      = "Cannot parse passed IAM Role ARN"
-	errStsGetCallerIdentity = "Cannot determine caller identity of passed aws.Config"
+// AssumeRoleProviderName prefixes the Source of credentials of NewAssumeRoleConf
+// configs, followed by the role ARN unless WithRedactedSource is set
+const AssumeRoleProviderName = SourcePrefix + "assumerole"
+
+var (
+	// ErrInvalidRoleArn is returned when a role ARN cannot be parsed
+	ErrInvalidRoleArn = errors.New("Cannot parse passed IAM Role ARN")
+	// ErrIdentityCheckFailed is returned when the GetCallerIdentity preflight fails
+	ErrIdentityCheckFailed = errors.New("Cannot determine caller identity of passed aws.Config")
 )
 
 // NewAssumeRoleConf returns an aws.Config configured to assume the given roleArn
-// using auto-refreshing credentials and optional AssumeRoleOptions.
+// using auto-refreshing credentials and optional AssumeRoleOptions and ConfOptions.
 func NewAssumeRoleConf(
 	ctx context.Context,
 	cfg aws.Config,
 	roleArn string,
-	opts ...func(*stscreds.AssumeRoleOptions),
+	opts ...Option,
 ) (aws.Config, error) {
-	// Validate role ARN
-	if _, err := arn.Parse(roleArn); err != nil {
-		return aws.Config{}, fmt.Errorf("%v: %w", errParseIAMRoleArn, err)
+	cached, err := NewAssumeRoleProvider(ctx, cfg, roleArn, opts...)
+	if err != nil {
+		return aws.Config{}, err
 	}
 
-	// Create STS client from base config
-	stsClient := sts.NewFromConfig(cfg)
-	_, err := stsClient.GetCallerIdentity(ctx, nil)
+	// Return a copy of the config with assumed credentials
+	newCfg := cfg.Copy()
+	newCfg.Credentials = cached
+	return newCfg, nil
+}
+
+// NewAssumeRoleProvider performs the same validation as NewAssumeRoleConf but returns
+// the auto-refreshing assume-role credentials provider instead of a config copy.
+func NewAssumeRoleProvider(
+	ctx context.Context,
+	cfg aws.Config,
+	roleArn string,
+	opts ...Option,
+) (aws.CredentialsProvider, error) {
+	conf := newConfOptions(opts)
+
+	// Validate role ARN
+	parsed, err := parseRoleArn(roleArn)
 	if err != nil {
-		return aws.Config{}, fmt.Errorf("%v: %w", errStsGetCallerIdentity, err)
+		return nil, err
+	}
+	if err := checkPartition(cfg, conf, parsed); err != nil {
+		return nil, err
+	}
+	if err := checkExpectedAccount(conf, parsed.AccountID); err != nil {
+		return nil, err
+	}
+
+	// Merge scope-down statements into the session policy
+	if err := applyScopeDownPolicy(roleArn, conf); err != nil {
+		return nil, err
+	}
+
+	// Validate the AssumeRole request as stscreds will see it
+	effective := effectiveAssumeRoleOptions(roleArn, conf)
+	duration := effective.Duration
+	if err := validateDuration(duration); err != nil {
+		return nil, err
+	}
+	if err := validateSessionName(effective.RoleSessionName); err != nil {
+		return nil, err
+	}
+	if err := validatePolicy(aws.ToString(effective.Policy)); err != nil {
+		return nil, err
+	}
+	if err := validatePolicyArns(effective.PolicyARNs); err != nil {
+		return nil, err
+	}
+	if err := validateSTSEndpoint(conf); err != nil {
+		return nil, err
+	}
+
+	// Create STS client from base config, unless one was injected
+	stsClient := newSTSClient(cfg, conf)
+	var caller *CallerIdentity
+	if !conf.skipIdentityCheck {
+		out, err := cachedCheckIdentity(ctx, cfg, stsClient, conf)
+		if err != nil {
+			if conf.decodeAuthErrors {
+				err = decodeAuthError(ctx, stsClient, err)
+			}
+			return nil, err
+		}
+		identity := parseCallerIdentity(out)
+		caller = &identity
+	}
+	chained := caller != nil && caller.Type == IdentityTypeAssumedRole
+
+	assumeRoleOpts := conf.assumeRoleOpts
+	if conf.sessionNameFromCaller && effective.RoleSessionName == "" {
+		if name := callerSessionName(caller); name != "" {
+			// The derived name is only known now, after the options were validated
+			if err := validateSessionName(name); err != nil {
+				return nil, err
+			}
+			assumeRoleOpts = append(assumeRoleOpts[:len(assumeRoleOpts):len(assumeRoleOpts)], WithRoleSessionName(name))
+		}
+	}
+	if conf.maxAvailableDuration {
+		if maxDuration, ok := discoverMaxSessionDuration(ctx, cfg, conf, parsed, caller); ok {
+			duration = maxDuration
+			if chained {
+				duration = min(duration, maxChainedDuration)
+			}
+			assumeRoleOpts = append(assumeRoleOpts[:len(assumeRoleOpts):len(assumeRoleOpts)], WithDuration(duration))
+		}
+	}
+
+	// STS caps sessions assumed from assumed-role credentials at one hour
+	if chained {
+		if assumeRoleOpts, err = chainedDurationOptions(conf, duration, assumeRoleOpts); err != nil {
+			return nil, err
+		}
 	}
 
 	// Construct assume-role provider
-	provider := stscreds.NewAssumeRoleProvider(stsClient, roleArn, opts...)
+	var provider aws.CredentialsProvider = stscreds.NewAssumeRoleProvider(stsClient, roleArn, assumeRoleOpts...)
+	if conf.decodeAuthErrors {
+		provider = &authDecodingProvider{client: stsClient, provider: provider}
+	}
 
-	// Wrap in auto-refreshing cache
-	cached := aws.NewCredentialsCache(provider)
+	provider = withCircuitBreaker(conf, provider)
+	provider = withLogging(conf, "AssumeRole", roleArn, provider)
+	provider = withMetrics(conf, roleArn, provider)
+	provider = withRefreshCallback(conf, "AssumeRole", roleArn, provider)
 
-	// Return a copy of the config with assumed credentials
-	newCfg := cfg.Copy()
-	newCfg.Credentials = cached
-	return newCfg, nil
+	// Wrap in auto-refreshing cache, tracing backend hits as well as refreshes
+	if provider, err = withRoleCacheBackends(conf, roleArn, assumeRoleOpts, withStats(withStaleIfError(conf, provider))); err != nil {
+		return nil, err
+	}
+	provider = withAssumeRoleTracing(conf, roleArn, assumeRoleOpts, provider)
+	provider = withSource(roleSourceLabel(conf, AssumeRoleProviderName, roleArn), provider)
+	cached := aws.NewCredentialsCache(provider, conf.cacheOpts...)
+	if conf.postAssumeVerify {
+		assumed, err := verifyAssumedCredentials(ctx, stsClient, cached)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkExpectedAccount(conf, assumed.AccountID); err != nil {
+			return nil, err
+		}
+	}
+
+	// Retrieve now so the first call made with the config hits warm credentials
+	if conf.preWarm {
+		if _, err := cached.Retrieve(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return cached, nil
 }
 
 // WithRoleSessionName sets the session name
-func WithRoleSessionName(name string) func(*stscreds.AssumeRoleOptions) {
+func WithRoleSessionName(name string) AssumeRoleOption {
 	return func(o *stscreds.AssumeRoleOptions) {
 		o.RoleSessionName = name
 	}
 }
 
 // WithDuration sets the session duration
-func WithDuration(duration time.Duration) func(*stscreds.AssumeRoleOptions) {
+func WithDuration(duration time.Duration) AssumeRoleOption {
 	return func(o *stscreds.AssumeRoleOptions) {
 		o.Duration = duration
 	}
 }
 
 // WithExternalID sets the external ID
-func WithExternalID(externalID string) func(*stscreds.AssumeRoleOptions) {
+func WithExternalID(externalID string) AssumeRoleOption {
 	return func(o *stscreds.AssumeRoleOptions) {
 		o.ExternalID = aws.String(externalID)
 	}
 }
 
 // WithPolicy sets an inline session policy
-func WithPolicy(policy string) func(*stscreds.AssumeRoleOptions) {
+func WithPolicy(policy string) AssumeRoleOption {
 	return func(o *stscreds.AssumeRoleOptions) {
 		o.Policy = aws.String(policy)
 	}
 }
 
-// WithPolicyArns sets managed policy ARNs
-func WithPolicyArns(arns []string) func(*stscreds.AssumeRoleOptions) {
+// WithPolicyArns sets managed policy ARNs, replacing any set earlier and skipping empty strings
+func WithPolicyArns(arns []string) AssumeRoleOption {
 	var inputPolicyARNs []types.PolicyDescriptorType
 	for _, arn := range arns {
+		if arn == "" {
+			continue
+		}
 		inputPolicyARNs = append(
 			inputPolicyARNs,
 			types.PolicyDescriptorType{
@@ -93,36 +215,104 @@ func WithPolicyArns(arns []string) func(*stscreds.AssumeRoleOptions) {
 	}
 }
 
+// WithPolicyArn appends a single managed policy ARN; unlike WithPolicyArns, which
+// replaces any set earlier, repeated calls accumulate and duplicates are dropped
+func WithPolicyArn(arnStr string) AssumeRoleOption {
+	return func(o *stscreds.AssumeRoleOptions) {
+		for _, existing := range o.PolicyARNs {
+			if aws.ToString(existing.Arn) == arnStr {
+				return
+			}
+		}
+		// Cap the slice so appending never writes into a slice shared by another option
+		o.PolicyARNs = append(o.PolicyARNs[:len(o.PolicyARNs):len(o.PolicyARNs)], types.PolicyDescriptorType{
+			Arn: aws.String(arnStr),
+		})
+	}
+}
+
 // WithSourceIdentity sets the source identity
-func WithSourceIdentity(id string) func(*stscreds.AssumeRoleOptions) {
+func WithSourceIdentity(id string) AssumeRoleOption {
 	return func(o *stscreds.AssumeRoleOptions) {
 		o.SourceIdentity = aws.String(id)
 	}
 }
 
-// WithTags attaches session tags
-func WithTags(tags map[string]string) func(*stscreds.AssumeRoleOptions) {
-	var inputTags []types.Tag
-	for key, value := range tags {
+// WithTags attaches session tags, ordered by key so signed requests are stable
+func WithTags(tags map[string]string) AssumeRoleOption {
+	inputTags := sortedTags(tags)
+	return func(o *stscreds.AssumeRoleOptions) {
+		o.Tags = inputTags
+	}
+}
+
+// WithTag appends a single session tag; unlike WithTags it never replaces earlier tags
+func WithTag(key, value string) AssumeRoleOption {
+	tag := types.Tag{
+		Key:   aws.String(key),
+		Value: aws.String(value),
+	}
+	return func(o *stscreds.AssumeRoleOptions) {
+		// Cap the slice so appending never writes into a slice shared by another option
+		o.Tags = append(o.Tags[:len(o.Tags):len(o.Tags)], tag)
+	}
+}
+
+// WithMergedTags merges session tags into any already set, rather than replacing
+// them as WithTags does. On duplicate keys, compared case-insensitively as STS
+// does, the last value wins and the key keeps its original position.
+func WithMergedTags(tags map[string]string) AssumeRoleOption {
+	inputTags := sortedTags(tags)
+	return func(o *stscreds.AssumeRoleOptions) {
+		o.Tags = mergeTags(o.Tags, inputTags)
+	}
+}
+
+// mergeTags returns the tags of base overlaid with those of overlay, deduplicated by key
+func mergeTags(base, overlay []types.Tag) []types.Tag {
+	merged := make([]types.Tag, 0, len(base)+len(overlay))
+	index := make(map[string]int, len(base)+len(overlay))
+	for _, tag := range append(base[:len(base):len(base)], overlay...) {
+		key := strings.ToLower(aws.ToString(tag.Key))
+		if i, ok := index[key]; ok {
+			merged[i].Value = tag.Value
+			continue
+		}
+		index[key] = len(merged)
+		merged = append(merged, tag)
+	}
+	return merged
+}
+
+// sortedTags converts tags to STS session tags ordered by key
+func sortedTags(tags map[string]string) []types.Tag {
+	if len(tags) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	inputTags := make([]types.Tag, 0, len(tags))
+	for _, key := range keys {
 		inputTags = append(inputTags, types.Tag{
 			Key:   aws.String(key),
-			Value: aws.String(value),
+			Value: aws.String(tags[key]),
 		})
 	}
-	return func(o *stscreds.AssumeRoleOptions) {
-		o.Tags = inputTags
-	}
+	return inputTags
 }
 
 // WithTransitiveTagKeys specifies transitive tag keys
-func WithTransitiveTagKeys(keys []string) func(*stscreds.AssumeRoleOptions) {
+func WithTransitiveTagKeys(keys []string) AssumeRoleOption {
 	return func(o *stscreds.AssumeRoleOptions) {
 		o.TransitiveTagKeys = keys
 	}
 }
 
 // WithMFA sets the MFA serial number and token provider
-func WithMFA(serial string, tokenProvider func() (string, error)) func(*stscreds.AssumeRoleOptions) {
+func WithMFA(serial string, tokenProvider func() (string, error)) AssumeRoleOption {
 	return func(o *stscreds.AssumeRoleOptions) {
 		o.SerialNumber = aws.String(serial)
 		o.TokenProvider = tokenProvider
@@ -0,0 +1,58 @@
+package awsconfig
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// NewAssumeRoleConfByName returns an aws.Config configured to assume roleName in
+// accountID, building the role ARN in the partition of the base config's region,
+// or of the caller identity when no region is set.
+func NewAssumeRoleConfByName(
+	ctx context.Context,
+	cfg aws.Config,
+	accountID string,
+	roleName string,
+	opts ...Option,
+) (aws.Config, error) {
+	conf := newConfOptions(opts)
+
+	// Derive partition from the STS region, falling back to the caller identity
+	partition := ""
+	if region := stsRegion(cfg, conf); region != "" {
+		partition = regionPartition(region)
+	} else if !conf.skipIdentityCheck {
+		identity, err := cachedCheckIdentity(ctx, cfg, newSTSClient(cfg, conf), conf)
+		if err != nil {
+			return aws.Config{}, err
+		}
+		if callerArn, err := arn.Parse(aws.ToString(identity.Arn)); err == nil {
+			partition = callerArn.Partition
+		}
+		// NewAssumeRoleConf reuses the identity rather than repeating the preflight
+		opts = append(opts[:len(opts):len(opts)], withCallerIdentity(identity))
+	}
+
+	roleArn, err := BuildRoleArn(partition, accountID, conf.rolePath, roleName)
+	if err != nil {
+		return aws.Config{}, err
+	}
+	return NewAssumeRoleConf(ctx, cfg, roleArn, opts...)
+}
+
+// withCallerIdentity supplies the result of a preflight already made against the base config
+func withCallerIdentity(identity *sts.GetCallerIdentityOutput) ConfOption {
+	return func(c *confOptions) {
+		c.callerIdentity = identity
+	}
+}
+
+// WithRolePath sets the IAM path of the role assumed by NewAssumeRoleConfByName
+func WithRolePath(path string) ConfOption {
+	return func(c *confOptions) {
+		c.rolePath = path
+	}
+}
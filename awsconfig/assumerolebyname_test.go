@@ -0,0 +1,64 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigtest"
+)
+
+func TestNewAssumeRoleConfByName(t *testing.T) {
+	tests := []struct {
+		name      string
+		region    string
+		callerArn string
+		accountID string
+		opts      []Option
+		want      string
+		wantErr   error
+	}{
+		{name: "Aws", region: "us-east-1", want: "arn:aws:iam::123456789012:role/Deploy"},
+		{name: "China", region: "cn-north-1", want: "arn:aws-cn:iam::123456789012:role/Deploy"},
+		{name: "GovCloud", region: "us-gov-west-1", want: "arn:aws-us-gov:iam::123456789012:role/Deploy"},
+		{name: "STSRegion", region: "us-east-1", opts: []Option{WithSTSRegion("cn-northwest-1")},
+			want: "arn:aws-cn:iam::123456789012:role/Deploy"},
+		{name: "CallerAws", callerArn: "arn:aws:iam::111111111111:user/Alice", want: "arn:aws:iam::123456789012:role/Deploy"},
+		{name: "CallerChina", callerArn: "arn:aws-cn:iam::111111111111:user/Alice", want: "arn:aws-cn:iam::123456789012:role/Deploy"},
+		{name: "CallerGovCloud", callerArn: "arn:aws-us-gov:sts::111111111111:assumed-role/Base/s",
+			want: "arn:aws-us-gov:iam::123456789012:role/Deploy"},
+		{name: "Path", region: "us-east-1", opts: []Option{WithRolePath("/service-role/")},
+			want: "arn:aws:iam::123456789012:role/service-role/Deploy"},
+		{name: "BadAccountID", region: "us-east-1", accountID: "1234", wantErr: ErrNotARoleArn},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &awsconfigtest.FakeSTS{}
+			fake.Identity.Arn = aws.String(tt.callerArn)
+			accountID := tt.accountID
+			if accountID == "" {
+				accountID = "123456789012"
+			}
+			opts := append([]Option{WithSTSClient(fake)}, tt.opts...)
+			cfg, err := NewAssumeRoleConfByName(context.Background(), aws.Config{Region: tt.region}, accountID, "Deploy", opts...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewAssumeRoleConfByName error %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			// The partition lookup and NewAssumeRoleConf share one preflight
+			if got := fake.CallerIdentityCalls(); got != 1 {
+				t.Errorf("GetCallerIdentity called %d times, want 1", got)
+			}
+			if _, err := cfg.Credentials.Retrieve(context.Background()); err != nil {
+				t.Fatalf("Retrieve: %v", err)
+			}
+			if got := aws.ToString(fake.AssumeRoleInputs()[0].RoleArn); got != tt.want {
+				t.Errorf("AssumeRole role ARN %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+)
+
+var (
+	// ErrEmptyRoleChain is returned when NewAssumeRoleChainConf is passed no role ARNs
+	ErrEmptyRoleChain = errors.New("Cannot assume an empty chain of IAM Roles")
+	// ErrChainedDurationExceeded is returned, or passed to the warning handler when
+	// clamping, if a session longer than the role chaining limit is requested from
+	// assumed-role credentials
+	ErrChainedDurationExceeded = errors.New("Session duration exceeds the 1h0m0s role chaining limit")
+)
+
+const (
+	// AssumeRoleChainProviderName prefixes the Source of credentials of
+	// NewAssumeRoleChainConf configs, followed by the final role ARN unless
+	// WithRedactedSource is set
+	AssumeRoleChainProviderName = SourcePrefix + "assumerolechain"
+
+	// maxChainedDuration is the longest session STS issues when the calling
+	// credentials are themselves from an assumed role.
+	maxChainedDuration = time.Hour
+)
+
+// ChainedDurationPolicy decides what happens when a session longer than the
+// role chaining limit is requested from assumed-role credentials
+type ChainedDurationPolicy int
+
+const (
+	// ChainedDurationClamp limits the session to one hour and warns; the default
+	ChainedDurationClamp ChainedDurationPolicy = iota
+	// ChainedDurationError fails construction with ErrChainedDurationExceeded
+	ChainedDurationError
+)
+
+// WithChainedDurationPolicy sets what happens when the requested session duration
+// exceeds the one hour STS allows when chaining roles
+func WithChainedDurationPolicy(policy ChainedDurationPolicy) ConfOption {
+	return func(c *confOptions) {
+		c.chainedDurationPolicy = policy
+	}
+}
+
+// NewAssumeRoleChainConf returns an aws.Config configured to assume each of the
+// given roleArns in order, using the credentials of each hop to assume the next.
+// Only the final hop is cached; a refresh re-walks the whole chain.
+func NewAssumeRoleChainConf(
+	ctx context.Context,
+	cfg aws.Config,
+	roleArns []string,
+	opts ...Option,
+) (aws.Config, error) {
+	conf := newConfOptions(opts)
+
+	if len(roleArns) == 0 {
+		return aws.Config{}, ErrEmptyRoleChain
+	}
+
+	// Validate every role ARN before touching STS
+	for i, roleArn := range roleArns {
+		parsed, err := parseRoleArn(roleArn)
+		if err != nil {
+			return aws.Config{}, fmt.Errorf("hop %d: %w", i+1, err)
+		}
+		if err := checkPartition(cfg, conf, parsed); err != nil {
+			return aws.Config{}, fmt.Errorf("hop %d: %w", i+1, err)
+		}
+		// Credentials are only used in the account of the final hop
+		if i == len(roleArns)-1 {
+			if err := checkExpectedAccount(conf, parsed.AccountID); err != nil {
+				return aws.Config{}, fmt.Errorf("hop %d: %w", i+1, err)
+			}
+		}
+	}
+
+	// Merge scope-down statements into the session policy
+	if err := applyScopeDownPolicy(roleArns[0], conf); err != nil {
+		return aws.Config{}, err
+	}
+
+	// Validate the AssumeRole request as stscreds will see it
+	effective := effectiveAssumeRoleOptions(roleArns[0], conf)
+	duration := effective.Duration
+	if err := validateDuration(duration); err != nil {
+		return aws.Config{}, err
+	}
+	if err := validateSessionName(effective.RoleSessionName); err != nil {
+		return aws.Config{}, err
+	}
+	if err := validatePolicy(aws.ToString(effective.Policy)); err != nil {
+		return aws.Config{}, err
+	}
+	if err := validatePolicyArns(effective.PolicyARNs); err != nil {
+		return aws.Config{}, err
+	}
+	if err := validateSTSEndpoint(conf); err != nil {
+		return aws.Config{}, err
+	}
+
+	// Every hop after the first is assumed from assumed-role credentials
+	firstHopOpts, chainedOpts := conf.assumeRoleOpts, conf.assumeRoleOpts
+	if len(roleArns) > 1 {
+		var err error
+		if chainedOpts, err = chainedDurationOptions(conf, duration, chainedOpts); err != nil {
+			return aws.Config{}, err
+		}
+	}
+
+	// Verify the base config before building the chain
+	baseClient := newSTSClient(cfg, conf)
+	if !conf.skipIdentityCheck {
+		identity, err := cachedCheckIdentity(ctx, cfg, baseClient, conf)
+		if err != nil {
+			if conf.decodeAuthErrors {
+				err = decodeAuthError(ctx, baseClient, err)
+			}
+			return aws.Config{}, err
+		}
+		if parseCallerIdentity(identity).Type == IdentityTypeAssumedRole {
+			if firstHopOpts, err = chainedDurationOptions(conf, duration, firstHopOpts); err != nil {
+				return aws.Config{}, err
+			}
+		}
+	}
+
+	// Each hop signs its AssumeRole call with the previous hop's provider; an
+	// injected STS client only serves the first hop, which uses the base config
+	hopCfg := cfg.Copy()
+	var provider aws.CredentialsProvider
+	for i, roleArn := range roleArns {
+		var hopClient stscreds.AssumeRoleAPIClient
+		hopOpts := firstHopOpts
+		if i == 0 {
+			hopClient = baseClient
+		} else {
+			client := newSTSFromConfig(hopCfg, conf.stsOpts...)
+			hopClient = limitSTSClient(conf, withSTSFallback(hopCfg, conf, client))
+			hopOpts = chainedOpts
+		}
+		provider = &chainHopProvider{
+			hop:      i + 1,
+			roleArn:  roleArn,
+			provider: stscreds.NewAssumeRoleProvider(hopClient, roleArn, hopOpts...),
+		}
+		hopCfg = hopCfg.Copy()
+		hopCfg.Credentials = provider
+	}
+
+	// Decode authorization failures of any hop with the base credentials
+	if conf.decodeAuthErrors {
+		provider = &authDecodingProvider{client: baseClient, provider: provider}
+	}
+
+	provider = withCircuitBreaker(conf, provider)
+	provider = withLogging(conf, "AssumeRoleChain", roleArns[len(roleArns)-1], provider)
+	provider = withMetrics(conf, roleArns[len(roleArns)-1], provider)
+	provider = withRefreshCallback(conf, "AssumeRoleChain", roleArns[len(roleArns)-1], provider)
+
+	lastHopOpts := firstHopOpts
+	if len(roleArns) > 1 {
+		lastHopOpts = chainedOpts
+	}
+	provider, err := withRoleCacheBackends(conf, roleArns[len(roleArns)-1], lastHopOpts, withStats(withStaleIfError(conf, provider)))
+	if err != nil {
+		return aws.Config{}, err
+	}
+	provider = withAssumeRoleTracing(conf, roleArns[len(roleArns)-1], lastHopOpts, provider)
+	provider = withSource(roleSourceLabel(conf, AssumeRoleChainProviderName, roleArns[len(roleArns)-1]), provider)
+	cached := aws.NewCredentialsCache(provider, conf.cacheOpts...)
+	if conf.postAssumeVerify {
+		assumed, err := verifyAssumedCredentials(ctx, baseClient, cached)
+		if err != nil {
+			return aws.Config{}, err
+		}
+		if err := checkExpectedAccount(conf, assumed.AccountID); err != nil {
+			return aws.Config{}, err
+		}
+	}
+
+	// Retrieve now so the first call made with the config hits warm credentials
+	if conf.preWarm {
+		if _, err := cached.Retrieve(ctx); err != nil {
+			return aws.Config{}, err
+		}
+	}
+
+	newCfg := cfg.Copy()
+	newCfg.Credentials = cached
+	return newCfg, nil
+}
+
+// chainedDurationOptions applies the chained duration policy to a session of the
+// requested duration, returning opts with the clamp appended when needed.
+func chainedDurationOptions(
+	conf *confOptions,
+	duration time.Duration,
+	opts []func(*stscreds.AssumeRoleOptions),
+) ([]func(*stscreds.AssumeRoleOptions), error) {
+	if duration <= maxChainedDuration {
+		return opts, nil
+	}
+	err := fmt.Errorf("%w, got %s", ErrChainedDurationExceeded, duration)
+	if conf.chainedDurationPolicy == ChainedDurationError {
+		return nil, err
+	}
+	if conf.warn != nil {
+		conf.warn(err)
+	}
+	return append(opts[:len(opts):len(opts)], clampChainedDuration), nil
+}
+
+// clampChainedDuration limits the session duration to the role-chaining maximum
+func clampChainedDuration(o *stscreds.AssumeRoleOptions) {
+	if o.Duration > maxChainedDuration {
+		o.Duration = maxChainedDuration
+	}
+}
+
+// chainHopProvider annotates errors with the hop of the chain that failed
+type chainHopProvider struct {
+	hop      int
+	roleArn  string
+	provider aws.CredentialsProvider
+}
+
+// Retrieve implements the aws.CredentialsProvider interface method. The error of
+// an earlier hop, met while signing this hop's AssumeRole call, is returned
+// unchanged so only the hop that failed is named.
+func (p *chainHopProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	creds, err := p.provider.Retrieve(ctx)
+	if err != nil {
+		var hopErr *chainHopError
+		if errors.As(err, &hopErr) {
+			return aws.Credentials{}, hopErr
+		}
+		return aws.Credentials{}, &chainHopError{hop: p.hop, roleArn: p.roleArn, err: err}
+	}
+	return creds, nil
+}
+
+// chainHopError is the error of the hop of a role chain that failed
+type chainHopError struct {
+	hop     int
+	roleArn string
+	err     error
+}
+
+// Error implements the error interface method
+func (e *chainHopError) Error() string {
+	return fmt.Sprintf("assume role chain hop %d (%s): %v", e.hop, e.roleArn, e.err)
+}
+
+// Unwrap returns the error of the hop
+func (e *chainHopError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,108 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigtest"
+)
+
+func TestNewAssumeRoleChainConf(t *testing.T) {
+	roleArns := []string{
+		"arn:aws:iam::111111111111:role/Bastion",
+		"arn:aws:iam::222222222222:role/Jump",
+		"arn:aws:iam::333333333333:role/Target",
+	}
+	base := &awsconfigtest.FakeSTS{}
+	hops := &awsconfigtest.FakeSTS{}
+	stubSTSFromConfig(t, hops)
+
+	cfg, err := NewAssumeRoleChainConf(context.Background(), aws.Config{Region: "us-east-1"}, roleArns, WithSTSClient(base))
+	if err != nil {
+		t.Fatalf("NewAssumeRoleChainConf: %v", err)
+	}
+	creds, err := cfg.Credentials.Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("Retrieve: %v", err)
+	}
+	if !creds.HasKeys() || !creds.CanExpire {
+		t.Errorf("Retrieve returned %+v, want expiring credentials", creds)
+	}
+
+	// The first hop is assumed with the base client, the others with clients
+	// signing with the credentials of the hop before
+	var got []string
+	for _, input := range append(base.AssumeRoleInputs(), hops.AssumeRoleInputs()...) {
+		got = append(got, aws.ToString(input.RoleArn))
+	}
+	if strings.Join(got, " ") != strings.Join(roleArns, " ") {
+		t.Errorf("AssumeRole calls for %v, want %v", got, roleArns)
+	}
+}
+
+func TestNewAssumeRoleChainConfInvalidArn(t *testing.T) {
+	tests := []struct {
+		name     string
+		roleArns []string
+		wantErr  error
+		wantHop  string
+	}{
+		{"Empty", nil, ErrEmptyRoleChain, ""},
+		{"FirstHop", []string{"not-an-arn", "arn:aws:iam::222222222222:role/Target"}, ErrInvalidRoleArn, "hop 1"},
+		{"LastHop", []string{"arn:aws:iam::111111111111:role/Bastion", "arn:aws:iam::222222222222:user/Bob"}, ErrNotARoleArn, "hop 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &awsconfigtest.FakeSTS{}
+			_, err := NewAssumeRoleChainConf(context.Background(), aws.Config{}, tt.roleArns, WithSTSClient(fake))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewAssumeRoleChainConf error %v, want %v", err, tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantHop) {
+				t.Errorf("NewAssumeRoleChainConf error %q does not name %q", err, tt.wantHop)
+			}
+			if calls := fake.CallerIdentityCalls() + len(fake.AssumeRoleInputs()); calls != 0 {
+				t.Errorf("STS was called %d times before ARN validation failed", calls)
+			}
+		})
+	}
+}
+
+func TestNewAssumeRoleChainConfHopError(t *testing.T) {
+	roleArns := []string{
+		"arn:aws:iam::111111111111:role/Bastion",
+		"arn:aws:iam::222222222222:role/Jump",
+		"arn:aws:iam::333333333333:role/Target",
+	}
+	denied := errors.New("AccessDenied")
+	tests := []struct {
+		name    string
+		baseErr error
+		hopErr  error
+		wantHop string
+	}{
+		{"FirstHop", denied, nil, "hop 1 (" + roleArns[0] + ")"},
+		{"LaterHop", nil, denied, "hop 2 (" + roleArns[1] + ")"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubSTSFromConfig(t, &awsconfigtest.FakeSTS{AssumeRoleErr: tt.hopErr})
+			cfg, err := NewAssumeRoleChainConf(context.Background(), aws.Config{}, roleArns,
+				WithSTSClient(&awsconfigtest.FakeSTS{AssumeRoleErr: tt.baseErr}))
+			if err != nil {
+				t.Fatalf("NewAssumeRoleChainConf: %v", err)
+			}
+			_, err = cfg.Credentials.Retrieve(context.Background())
+			if !errors.Is(err, denied) {
+				t.Fatalf("Retrieve error %v, want %v", err, denied)
+			}
+			if msg := err.Error(); !strings.Contains(msg, tt.wantHop) || strings.Count(msg, "assume role chain hop") != 1 {
+				t.Errorf("Retrieve error %q, want it to name only %q", msg, tt.wantHop)
+			}
+		})
+	}
+}
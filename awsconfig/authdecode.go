@@ -0,0 +1,62 @@
+package awsconfig
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// encodedAuthMessageRegexp extracts the encoded message from an authorization failure
+var encodedAuthMessageRegexp = regexp.MustCompile(`Encoded authorization failure message: (\S+)`)
+
+// decodeAuthorizationMessageAPIClient is a client capable of the STS DecodeAuthorizationMessage operation
+type decodeAuthorizationMessageAPIClient interface {
+	DecodeAuthorizationMessage(ctx context.Context, params *sts.DecodeAuthorizationMessageInput, optFns ...func(*sts.Options)) (*sts.DecodeAuthorizationMessageOutput, error)
+}
+
+// WithDecodedAuthErrors decodes the encoded authorization failure message of
+// access denied errors with sts:DecodeAuthorizationMessage, using the base
+// credentials, and includes the decoded JSON in the returned error
+func WithDecodedAuthErrors() ConfOption {
+	return func(c *confOptions) {
+		c.decodeAuthErrors = true
+	}
+}
+
+// decodeAuthError returns err with its encoded authorization message decoded, or
+// err unchanged when it has none; decoding failures are noted but not fatal
+func decodeAuthError(ctx context.Context, client STSClient, err error) error {
+	match := encodedAuthMessageRegexp.FindStringSubmatch(err.Error())
+	if match == nil {
+		return err
+	}
+	decoder, ok := client.(decodeAuthorizationMessageAPIClient)
+	if !ok {
+		return err
+	}
+	out, decodeErr := decoder.DecodeAuthorizationMessage(ctx, &sts.DecodeAuthorizationMessageInput{
+		EncodedMessage: aws.String(match[1]),
+	})
+	if decodeErr != nil {
+		return fmt.Errorf("%w (cannot decode authorization message: %v)", err, decodeErr)
+	}
+	return fmt.Errorf("%w: decoded authorization message: %s", err, aws.ToString(out.DecodedMessage))
+}
+
+// authDecodingProvider decodes the authorization failure messages of its provider's errors
+type authDecodingProvider struct {
+	client   STSClient
+	provider aws.CredentialsProvider
+}
+
+// Retrieve implements the aws.CredentialsProvider interface method
+func (p *authDecodingProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	creds, err := p.provider.Retrieve(ctx)
+	if err != nil {
+		return aws.Credentials{}, decodeAuthError(ctx, p.client, err)
+	}
+	return creds, nil
+}
@@ -0,0 +1,76 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/smithy-go"
+
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigtest"
+)
+
+func TestWithDecodedAuthErrors(t *testing.T) {
+	const decoded = `{"allowed":false,"explicitDeny":true,"matchedStatements":{"items":[{"statementId":"DenyProd"}]}}`
+	encoded := &smithy.GenericAPIError{
+		Code:    "AccessDenied",
+		Message: "User is not authorized to perform: sts:AssumeRole. Encoded authorization failure message: AbCdEf123",
+	}
+	plain := &smithy.GenericAPIError{Code: "AccessDenied", Message: "User is not authorized to perform: sts:AssumeRole"}
+
+	tests := []struct {
+		name      string
+		preflight bool
+		err       error
+		decodeErr error
+		noOption  bool
+		want      string
+		notWant   string
+	}{
+		{name: "Provider", err: encoded, want: "decoded authorization message: " + decoded},
+		{name: "Preflight", preflight: true, err: encoded, want: "decoded authorization message: " + decoded},
+		{
+			name:      "DecodeDenied",
+			err:       encoded,
+			decodeErr: &smithy.GenericAPIError{Code: "AccessDenied", Message: "not allowed to decode"},
+			want:      "cannot decode authorization message: api error AccessDenied: not allowed to decode",
+		},
+		{name: "NoEncodedMessage", err: plain, notWant: "authorization message"},
+		{name: "OptionNotSet", err: encoded, noOption: true, notWant: decoded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &awsconfigtest.FakeSTS{DecodedMessage: decoded, DecodeErr: tt.decodeErr}
+			opts := []Option{WithSTSClient(fake)}
+			if !tt.noOption {
+				opts = append(opts, WithDecodedAuthErrors())
+			}
+
+			var err error
+			if tt.preflight {
+				fake.CallerIdentityErr = tt.err
+				_, err = NewAssumeRoleConf(context.Background(), aws.Config{}, testRoleArn, opts...)
+			} else {
+				fake.AssumeRoleErr = tt.err
+				var cfg aws.Config
+				cfg, err = NewAssumeRoleConf(context.Background(), aws.Config{}, testRoleArn, append(opts, WithSkipIdentityCheck())...)
+				if err != nil {
+					t.Fatalf("NewAssumeRoleConf: %v", err)
+				}
+				_, err = cfg.Credentials.Retrieve(context.Background())
+			}
+
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error %v does not wrap %v", err, tt.err)
+			}
+			if tt.want != "" && !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %q does not contain %q", err, tt.want)
+			}
+			if tt.notWant != "" && strings.Contains(err.Error(), tt.notWant) {
+				t.Errorf("error %q contains %q", err, tt.notWant)
+			}
+		})
+	}
+}
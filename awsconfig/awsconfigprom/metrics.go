@@ -0,0 +1,110 @@
+// Package awsconfigprom provides a Prometheus awsconfig.MetricsCollector.
+package awsconfigprom
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Label values of the result label
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+// Metrics is an awsconfig.MetricsCollector exporting a counter of refreshes by
+// result and role, and a histogram of refresh latency by role:
+//
+//	awsconfig_credential_refreshes_total{result, role}
+//	awsconfig_credential_refresh_duration_seconds{role}
+type Metrics struct {
+	refreshes *prometheus.CounterVec
+	latency   *prometheus.HistogramVec
+	roleLabel bool
+}
+
+// Option configures Metrics
+type Option func(*metricsOptions)
+
+type metricsOptions struct {
+	namespace string
+	buckets   []float64
+	roleLabel bool
+}
+
+// WithNamespace prefixes the metric names with namespace
+func WithNamespace(namespace string) Option {
+	return func(o *metricsOptions) {
+		o.namespace = namespace
+	}
+}
+
+// WithBuckets sets the latency histogram buckets, in seconds
+func WithBuckets(buckets []float64) Option {
+	return func(o *metricsOptions) {
+		o.buckets = buckets
+	}
+}
+
+// WithoutRoleLabel drops the role label, for fleets assuming many roles
+func WithoutRoleLabel() Option {
+	return func(o *metricsOptions) {
+		o.roleLabel = false
+	}
+}
+
+// NewMetrics creates Metrics and registers them with reg
+func NewMetrics(reg prometheus.Registerer, opts ...Option) (*Metrics, error) {
+	o := metricsOptions{
+		buckets:   prometheus.DefBuckets,
+		roleLabel: true,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var roleLabels []string
+	if o.roleLabel {
+		roleLabels = []string{"role"}
+	}
+	m := &Metrics{
+		refreshes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: o.namespace,
+			Subsystem: "awsconfig",
+			Name:      "credential_refreshes_total",
+			Help:      "Credential refreshes by result.",
+		}, append([]string{"result"}, roleLabels...)),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: o.namespace,
+			Subsystem: "awsconfig",
+			Name:      "credential_refresh_duration_seconds",
+			Help:      "Latency of credential refreshes.",
+			Buckets:   o.buckets,
+		}, roleLabels),
+		roleLabel: o.roleLabel,
+	}
+	if err := reg.Register(m.refreshes); err != nil {
+		return nil, err
+	}
+	if err := reg.Register(m.latency); err != nil {
+		reg.Unregister(m.refreshes)
+		return nil, err
+	}
+	return m, nil
+}
+
+// ObserveRefresh implements the awsconfig.MetricsCollector interface method
+func (m *Metrics) ObserveRefresh(role string, d time.Duration, err error) {
+	result := ResultSuccess
+	if err != nil {
+		result = ResultFailure
+	}
+	if m.roleLabel {
+		m.refreshes.WithLabelValues(result, role).Inc()
+		m.latency.WithLabelValues(role).Observe(d.Seconds())
+		return
+	}
+	m.refreshes.WithLabelValues(result).Inc()
+	m.latency.WithLabelValues().Observe(d.Seconds())
+}
@@ -0,0 +1,143 @@
+package awsconfigprom_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/smithy-go"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"tkalus.dev/mostly-harmless/awsconfig"
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigprom"
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigtest"
+)
+
+const testRoleArn = "arn:aws:iam::123456789012:role/Target"
+
+// scrape gathers reg, returning each counter value and histogram sample count
+// keyed by metric name and labels, as in `name{label="value"}`
+func scrape(t *testing.T, reg *prometheus.Registry) map[string]float64 {
+	t.Helper()
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	samples := map[string]float64{}
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			var labels []string
+			for _, label := range m.GetLabel() {
+				labels = append(labels, label.GetName()+`="`+label.GetValue()+`"`)
+			}
+			key := family.GetName() + "{" + strings.Join(labels, ",") + "}"
+			switch {
+			case m.GetCounter() != nil:
+				samples[key] = m.GetCounter().GetValue()
+			case m.GetHistogram() != nil:
+				samples[key] = float64(m.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+	return samples
+}
+
+func TestMetrics(t *testing.T) {
+	denied := &smithy.GenericAPIError{Code: "AccessDenied", Message: "denied"}
+	tests := []struct {
+		name string
+		opts []awsconfigprom.Option
+		want map[string]float64
+	}{
+		{
+			name: "RoleLabel",
+			want: map[string]float64{
+				`awsconfig_credential_refreshes_total{result="success",role=""}`:                    1,
+				`awsconfig_credential_refreshes_total{result="success",role="` + testRoleArn + `"}`: 1,
+				`awsconfig_credential_refreshes_total{result="failure",role="` + testRoleArn + `"}`: 1,
+				`awsconfig_credential_refresh_duration_seconds{role=""}`:                            1,
+				`awsconfig_credential_refresh_duration_seconds{role="` + testRoleArn + `"}`:         2,
+			},
+		},
+		{
+			name: "WithoutRoleLabel",
+			opts: []awsconfigprom.Option{awsconfigprom.WithoutRoleLabel(), awsconfigprom.WithNamespace("app")},
+			want: map[string]float64{
+				`app_awsconfig_credential_refreshes_total{result="success"}`: 2,
+				`app_awsconfig_credential_refreshes_total{result="failure"}`: 1,
+				`app_awsconfig_credential_refresh_duration_seconds{}`:        3,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			metrics, err := awsconfigprom.NewMetrics(reg, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewMetrics: %v", err)
+			}
+			if got := scrape(t, reg); len(got) != 0 {
+				t.Fatalf("scraped %v before any refresh, want nothing", got)
+			}
+
+			// One successful and one failed assume-role refresh
+			fake := &awsconfigtest.FakeSTS{}
+			cfg, err := awsconfig.NewAssumeRoleConf(context.Background(), aws.Config{}, testRoleArn,
+				awsconfig.WithSTSClient(fake), awsconfig.WithSkipIdentityCheck(), awsconfig.WithMetrics(metrics))
+			if err != nil {
+				t.Fatalf("NewAssumeRoleConf: %v", err)
+			}
+			if _, err := cfg.Credentials.Retrieve(context.Background()); err != nil {
+				t.Fatalf("Retrieve: %v", err)
+			}
+			fake.AssumeRoleErr = denied
+			if err := awsconfig.InvalidateCredentials(cfg); err != nil {
+				t.Fatalf("InvalidateCredentials: %v", err)
+			}
+			if _, err := cfg.Credentials.Retrieve(context.Background()); !errors.Is(err, denied) {
+				t.Fatalf("Retrieve error %v, want %v", err, denied)
+			}
+
+			// One successful custom function refresh
+			cfg, err = awsconfig.NewCustomFunctionConf(context.Background(), aws.Config{},
+				func(context.Context) (aws.Credentials, error) {
+					return aws.Credentials{
+						AccessKeyID:     "ASIAFAKECUSTOMACCESSKEY",
+						SecretAccessKey: "fake-secret-access-key-of-forty-characters",
+						CanExpire:       true,
+						Expires:         time.Now().Add(time.Hour),
+					}, nil
+				}, awsconfig.WithMetrics(metrics))
+			if err != nil {
+				t.Fatalf("NewCustomFunctionConf: %v", err)
+			}
+			if _, err := cfg.Credentials.Retrieve(context.Background()); err != nil {
+				t.Fatalf("Retrieve: %v", err)
+			}
+
+			got := scrape(t, reg)
+			for key, want := range tt.want {
+				if got[key] != want {
+					t.Errorf("%s = %v, want %v", key, got[key], want)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("scraped %v, want only %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewMetricsRegisterTwice(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	if _, err := awsconfigprom.NewMetrics(reg); err != nil {
+		t.Fatalf("NewMetrics: %v", err)
+	}
+	var already prometheus.AlreadyRegisteredError
+	if _, err := awsconfigprom.NewMetrics(reg); !errors.As(err, &already) {
+		t.Errorf("second NewMetrics error %v, want %T", err, already)
+	}
+}
@@ -0,0 +1,138 @@
+package awsconfigtest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// CredentialCache has the method set of awsconfig.CredentialCache
+type CredentialCache interface {
+	Get(ctx context.Context, key string) (aws.Credentials, bool)
+	Put(ctx context.Context, key string, creds aws.Credentials) error
+	Invalidate(ctx context.Context, key string) error
+}
+
+// TestCredentialCache checks that caches returned by newCache honour the
+// awsconfig.CredentialCache contract. Each check gets a fresh, empty cache.
+// Expiry is compared to the second, the precision of the AWS CLI cache format.
+// Keys are hex SHA-1 digests, as awsconfig uses.
+func TestCredentialCache(t *testing.T, newCache func() CredentialCache) {
+	ctx := context.Background()
+	const (
+		key      = "0123456789abcdef0123456789abcdef01234567"
+		otherKey = "fedcba9876543210fedcba9876543210fedcba98"
+	)
+	creds := aws.Credentials{
+		AccessKeyID:     "ASIAFAKECACHEACCESSKEY",
+		SecretAccessKey: "fake-secret-access-key",
+		SessionToken:    "fake-session-token",
+		CanExpire:       true,
+		Expires:         time.Now().Add(time.Hour).Truncate(time.Second),
+	}
+
+	t.Run("EmptyMiss", func(t *testing.T) {
+		if _, ok := newCache().Get(ctx, key); ok {
+			t.Error("Get on an empty cache reported a hit")
+		}
+	})
+
+	t.Run("PutGet", func(t *testing.T) {
+		cache := newCache()
+		if err := cache.Put(ctx, key, creds); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		got, ok := cache.Get(ctx, key)
+		if !ok {
+			t.Fatal("Get after Put reported a miss")
+		}
+		if got.AccessKeyID != creds.AccessKeyID || got.SecretAccessKey != creds.SecretAccessKey ||
+			got.SessionToken != creds.SessionToken {
+			t.Errorf("Get returned keys %q/%q, want %q/%q",
+				got.AccessKeyID, got.SessionToken, creds.AccessKeyID, creds.SessionToken)
+		}
+		if !got.CanExpire || !got.Expires.Equal(creds.Expires) {
+			t.Errorf("Get returned expiry %v (CanExpire %t), want %v", got.Expires, got.CanExpire, creds.Expires)
+		}
+	})
+
+	t.Run("PutReplaces", func(t *testing.T) {
+		cache := newCache()
+		older := creds
+		older.AccessKeyID = "ASIAFAKEOLDERACCESSKEY"
+		if err := cache.Put(ctx, key, older); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		if err := cache.Put(ctx, key, creds); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		if got, _ := cache.Get(ctx, key); got.AccessKeyID != creds.AccessKeyID {
+			t.Errorf("Get returned %q after a second Put, want %q", got.AccessKeyID, creds.AccessKeyID)
+		}
+	})
+
+	t.Run("Invalidate", func(t *testing.T) {
+		cache := newCache()
+		if err := cache.Invalidate(ctx, key); err != nil {
+			t.Errorf("Invalidate on an empty cache: %v", err)
+		}
+		if err := cache.Put(ctx, key, creds); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		if err := cache.Invalidate(ctx, key); err != nil {
+			t.Fatalf("Invalidate: %v", err)
+		}
+		if _, ok := cache.Get(ctx, key); ok {
+			t.Error("Get after Invalidate reported a hit")
+		}
+	})
+
+	t.Run("KeysIsolated", func(t *testing.T) {
+		cache := newCache()
+		other := creds
+		other.AccessKeyID = "ASIAFAKEOTHERACCESSKEY"
+		if err := cache.Put(ctx, key, creds); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		if _, ok := cache.Get(ctx, otherKey); ok {
+			t.Fatal("Get of another key reported a hit")
+		}
+		if err := cache.Put(ctx, otherKey, other); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		if got, _ := cache.Get(ctx, key); got.AccessKeyID != creds.AccessKeyID {
+			t.Errorf("Get returned %q after a Put of another key, want %q", got.AccessKeyID, creds.AccessKeyID)
+		}
+		if err := cache.Invalidate(ctx, otherKey); err != nil {
+			t.Fatalf("Invalidate: %v", err)
+		}
+		if _, ok := cache.Get(ctx, key); !ok {
+			t.Error("Get reported a miss after an Invalidate of another key")
+		}
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		cache := newCache()
+		var wg sync.WaitGroup
+		for range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range 10 {
+					if err := cache.Put(ctx, key, creds); err != nil {
+						t.Errorf("Put: %v", err)
+						return
+					}
+					if got, ok := cache.Get(ctx, key); ok && got.AccessKeyID != creds.AccessKeyID {
+						t.Errorf("Get returned %q, want %q", got.AccessKeyID, creds.AccessKeyID)
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	})
+}
@@ -0,0 +1,30 @@
+package awsconfigtest_test
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"tkalus.dev/mostly-harmless/awsconfig"
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigtest"
+)
+
+func ExampleFakeSTS() {
+	fake := &awsconfigtest.FakeSTS{}
+	cfg, err := awsconfig.NewAssumeRoleConf(context.Background(), aws.Config{},
+		"arn:aws:iam::123456789012:role/Deploy",
+		awsconfig.WithSTSClient(fake),
+		awsconfig.WithRoleSessionName("deploy-job"),
+	)
+	if err != nil {
+		panic(err)
+	}
+	if _, err := cfg.Credentials.Retrieve(context.Background()); err != nil {
+		panic(err)
+	}
+
+	input := fake.AssumeRoleInputs()[0]
+	fmt.Println(fake.CallerIdentityCalls(), aws.ToString(input.RoleArn), aws.ToString(input.RoleSessionName))
+	// Output: 1 arn:aws:iam::123456789012:role/Deploy deploy-job
+}
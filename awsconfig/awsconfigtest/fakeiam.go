@@ -0,0 +1,49 @@
+package awsconfigtest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// FakeIAM is an in-memory awsconfig.GetRoleAPIClient reporting MaxSessionDuration for every role
+type FakeIAM struct {
+	// MaxSessionDuration is returned for every role
+	MaxSessionDuration time.Duration
+	// GetRoleErr, if set, is returned by GetRole
+	GetRoleErr error
+
+	mu        sync.Mutex
+	roleNames []string
+}
+
+// GetRole implements the awsconfig.GetRoleAPIClient interface method
+func (f *FakeIAM) GetRole(
+	_ context.Context,
+	params *iam.GetRoleInput,
+	_ ...func(*iam.Options),
+) (*iam.GetRoleOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.roleNames = append(f.roleNames, aws.ToString(params.RoleName))
+	if f.GetRoleErr != nil {
+		return nil, f.GetRoleErr
+	}
+	return &iam.GetRoleOutput{
+		Role: &types.Role{
+			RoleName:           params.RoleName,
+			MaxSessionDuration: aws.Int32(int32(f.MaxSessionDuration / time.Second)),
+		},
+	}, nil
+}
+
+// RoleNames returns the name of every role looked up, in order
+func (f *FakeIAM) RoleNames() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.roleNames...)
+}
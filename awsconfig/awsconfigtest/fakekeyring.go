@@ -0,0 +1,55 @@
+package awsconfigtest
+
+import "sync"
+
+// FakeKeyring is an in-memory awsconfig.Keyring. Set Err to make every call fail,
+// as a keyring that isn't available does.
+type FakeKeyring struct {
+	// Err, if set, is returned by Get and Set
+	Err error
+
+	mu      sync.Mutex
+	secrets map[[2]string]string
+}
+
+// Get implements the awsconfig.Keyring interface method
+func (f *FakeKeyring) Get(service, user string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return "", f.Err
+	}
+	return f.secrets[[2]string{service, user}], nil
+}
+
+// Set implements the awsconfig.Keyring interface method
+func (f *FakeKeyring) Set(service, user, secret string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return f.Err
+	}
+	if f.secrets == nil {
+		f.secrets = make(map[[2]string]string)
+	}
+	f.secrets[[2]string{service, user}] = secret
+	return nil
+}
+
+// Delete implements the awsconfig.Keyring interface method
+func (f *FakeKeyring) Delete(service, user string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return f.Err
+	}
+	delete(f.secrets, [2]string{service, user})
+	return nil
+}
+
+// Len returns the number of secrets stored
+func (f *FakeKeyring) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.secrets)
+}
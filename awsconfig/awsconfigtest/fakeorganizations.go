@@ -0,0 +1,80 @@
+package awsconfigtest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	"github.com/aws/aws-sdk-go-v2/service/organizations/types"
+)
+
+// FakeOrganizations is an in-memory awsconfig.OrganizationsAPIClient listing
+// Accounts PageSize at a time
+type FakeOrganizations struct {
+	// Accounts are returned by ListAccounts, in order
+	Accounts []types.Account
+	// PageSize is how many accounts each ListAccounts page holds; defaults to 20
+	PageSize int
+	// ManagementAccountID is returned as the MasterAccountId of DescribeOrganization
+	ManagementAccountID string
+	// ListAccountsErr, if set, is returned by ListAccounts
+	ListAccountsErr error
+
+	mu        sync.Mutex
+	pageCalls int
+}
+
+// ListAccounts implements the awsconfig.OrganizationsAPIClient interface method
+func (f *FakeOrganizations) ListAccounts(
+	_ context.Context,
+	params *organizations.ListAccountsInput,
+	_ ...func(*organizations.Options),
+) (*organizations.ListAccountsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pageCalls++
+	if f.ListAccountsErr != nil {
+		return nil, f.ListAccountsErr
+	}
+
+	pageSize := f.PageSize
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	start := 0
+	if params.NextToken != nil {
+		for i, account := range f.Accounts {
+			if aws.ToString(account.Id) == *params.NextToken {
+				start = i
+				break
+			}
+		}
+	}
+	end := min(start+pageSize, len(f.Accounts))
+	out := &organizations.ListAccountsOutput{Accounts: f.Accounts[start:end]}
+	if end < len(f.Accounts) {
+		out.NextToken = f.Accounts[end].Id
+	}
+	return out, nil
+}
+
+// DescribeOrganization implements the awsconfig.OrganizationsAPIClient interface method
+func (f *FakeOrganizations) DescribeOrganization(
+	_ context.Context,
+	_ *organizations.DescribeOrganizationInput,
+	_ ...func(*organizations.Options),
+) (*organizations.DescribeOrganizationOutput, error) {
+	return &organizations.DescribeOrganizationOutput{
+		Organization: &types.Organization{
+			MasterAccountId: aws.String(f.ManagementAccountID),
+		},
+	}, nil
+}
+
+// PageCalls returns how many times ListAccounts was called
+func (f *FakeOrganizations) PageCalls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.pageCalls
+}
@@ -0,0 +1,97 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+var (
+	// ErrBackgroundRefresh is passed to the warning handler when a background refresh fails
+	ErrBackgroundRefresh = errors.New("Background credentials refresh failed")
+)
+
+const (
+	// DefaultRefreshWindow is how long before expiry StartBackgroundRefresh refreshes credentials
+	DefaultRefreshWindow = 5 * time.Minute
+
+	// refreshJitterFrac is the fraction of the refresh window randomly added to it
+	refreshJitterFrac = 0.1
+	// refreshRetryDelay is the least time between background refreshes, and the wait after a failure
+	refreshRetryDelay = 30 * time.Second
+)
+
+// refreshWait waits between background refreshes; replaced in tests
+var refreshWait = sleepContext
+
+// WithRefreshWindow sets how long before expiry StartBackgroundRefresh refreshes credentials
+func WithRefreshWindow(window time.Duration) ConfOption {
+	return func(c *confOptions) {
+		c.refreshWindow = window
+	}
+}
+
+// StartBackgroundRefresh keeps the credentials of cfg warm by refreshing them in a
+// goroutine shortly before they expire, so callers rarely wait on a refresh. The
+// credentials provider must be invalidatable, like those of the configs built by
+// this package; it is invalidated as by InvalidateCredentials, CredentialCache
+// backends included. Refresh failures are passed to the WithWarningHandler handler.
+// The goroutine exits when ctx is done or stop is called; stop waits for it.
+func StartBackgroundRefresh(ctx context.Context, cfg aws.Config, opts ...Option) (stop func(), err error) {
+	if cfg.Credentials == nil {
+		return nil, ErrNilCredentials
+	}
+	if _, ok := cfg.Credentials.(interface{ Invalidate() }); !ok {
+		return nil, fmt.Errorf("%w: %T", ErrNotInvalidatable, cfg.Credentials)
+	}
+	conf := newConfOptions(opts)
+	window := conf.refreshWindow
+	if window <= 0 {
+		window = DefaultRefreshWindow
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			creds, err := cfg.Credentials.Retrieve(ctx)
+			var wait time.Duration
+			switch {
+			case ctx.Err() != nil:
+				return
+			case err != nil:
+				if conf.warn != nil {
+					conf.warn(fmt.Errorf("%w: %w", ErrBackgroundRefresh, err))
+				}
+				wait = refreshRetryDelay
+			case !creds.CanExpire:
+				// Nothing will ever need refreshing
+				return
+			default:
+				// Jitter keeps a fleet started together from refreshing in lockstep
+				jitter := time.Duration(rand.Float64() * refreshJitterFrac * float64(window))
+				wait = max(creds.Expires.Sub(timeNow())-window-jitter, refreshRetryDelay)
+			}
+
+			if refreshWait(ctx, wait) != nil {
+				return
+			}
+			// Backends such as WithCache would otherwise serve the same credentials
+			if err == nil {
+				if err := InvalidateCredentials(cfg); err != nil && conf.warn != nil {
+					conf.warn(fmt.Errorf("%w: %w", ErrBackgroundRefresh, err))
+				}
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}, nil
+}
@@ -0,0 +1,213 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+// scriptedProvider hands out each of its results in turn, then blocks until ctx is done
+type scriptedProvider struct {
+	mu      sync.Mutex
+	results []func() (aws.Credentials, error)
+	calls   int
+}
+
+// Retrieve implements the aws.CredentialsProvider interface method
+func (p *scriptedProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	p.mu.Lock()
+	call := p.calls
+	p.calls++
+	p.mu.Unlock()
+	if call < len(p.results) {
+		return p.results[call]()
+	}
+	<-ctx.Done()
+	return aws.Credentials{}, ctx.Err()
+}
+
+// waitForCalls waits until p has been called n times
+func (p *scriptedProvider) waitForCalls(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		p.mu.Lock()
+		calls := p.calls
+		p.mu.Unlock()
+		if calls >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("provider called %d times, want %d", calls, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// expiringIn returns a result of credentials expiring d after the (fake) time it is retrieved
+func expiringIn(d time.Duration) func() (aws.Credentials, error) {
+	return func() (aws.Credentials, error) {
+		creds := testCredentials(0)
+		creds.CanExpire = true
+		creds.Expires = timeNow().Add(d)
+		return creds, nil
+	}
+}
+
+// stubRefreshWait records the waits between background refreshes without
+// sleeping, advancing the clock instead
+func stubRefreshWait(t *testing.T) func() []time.Duration {
+	t.Helper()
+	now := time.Now()
+	origWait, origNow := refreshWait, timeNow
+	t.Cleanup(func() { refreshWait, timeNow = origWait, origNow })
+	timeNow = func() time.Time { return now }
+
+	var mu sync.Mutex
+	var waits []time.Duration
+	refreshWait = func(ctx context.Context, d time.Duration) error {
+		mu.Lock()
+		waits = append(waits, d)
+		now = now.Add(d)
+		mu.Unlock()
+		return ctx.Err()
+	}
+	return func() []time.Duration {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]time.Duration(nil), waits...)
+	}
+}
+
+func TestStartBackgroundRefreshCadence(t *testing.T) {
+	waits := stubRefreshWait(t)
+	brokerDown := errors.New("broker down")
+	provider := &scriptedProvider{results: []func() (aws.Credentials, error){
+		expiringIn(time.Hour),
+		func() (aws.Credentials, error) { return aws.Credentials{}, brokerDown },
+		expiringIn(2 * time.Minute),
+		expiringIn(20 * time.Minute),
+		// Credentials that cannot expire end the refreshing
+		func() (aws.Credentials, error) { return testCredentials(0), nil },
+	}}
+	var warnings []error
+	stop, err := StartBackgroundRefresh(context.Background(), aws.Config{Credentials: aws.NewCredentialsCache(provider)},
+		WithRefreshWindow(10*time.Minute), WithWarningHandler(func(err error) { warnings = append(warnings, err) }))
+	if err != nil {
+		t.Fatalf("StartBackgroundRefresh: %v", err)
+	}
+
+	// stop returns only once the goroutine has exited
+	provider.waitForCalls(t, 5)
+	stop()
+
+	// Each wait is the time to expiry less the window and up to 10% jitter, at least refreshRetryDelay
+	want := []struct{ lo, hi time.Duration }{
+		{time.Hour - 11*time.Minute, time.Hour - 10*time.Minute},
+		{refreshRetryDelay, refreshRetryDelay},
+		{refreshRetryDelay, refreshRetryDelay},
+		{9 * time.Minute, 10 * time.Minute},
+	}
+	got := waits()
+	if len(got) != len(want) {
+		t.Fatalf("waited %v, want %d waits", got, len(want))
+	}
+	for i, w := range want {
+		if got[i] < w.lo || got[i] > w.hi {
+			t.Errorf("wait %d is %v, want between %v and %v", i, got[i], w.lo, w.hi)
+		}
+	}
+	if provider.calls != 5 {
+		t.Errorf("provider called %d times, want 5", provider.calls)
+	}
+	if len(warnings) != 1 || !errors.Is(warnings[0], ErrBackgroundRefresh) || !errors.Is(warnings[0], brokerDown) {
+		t.Errorf("warnings %v, want one %v wrapping %v", warnings, ErrBackgroundRefresh, brokerDown)
+	}
+}
+
+func TestStartBackgroundRefreshInvalidatesBackends(t *testing.T) {
+	waits := stubRefreshWait(t)
+	// The third Retrieve blocks until the refresher is stopped
+	provider := &scriptedProvider{results: []func() (aws.Credentials, error){expiringIn(time.Hour), expiringIn(time.Hour)}}
+	cfg, err := NewCustomFunctionConf(context.Background(), aws.Config{}, provider.Retrieve, WithCache(NewMemoryCache()))
+	if err != nil {
+		t.Fatalf("NewCustomFunctionConf: %v", err)
+	}
+
+	// Refreshing 30 minutes before expiry, the backend entry is still fresh
+	// enough to serve unless the backend is invalidated too
+	stop, err := StartBackgroundRefresh(context.Background(), cfg, WithRefreshWindow(30*time.Minute))
+	if err != nil {
+		t.Fatalf("StartBackgroundRefresh: %v", err)
+	}
+	provider.waitForCalls(t, 3)
+	stop()
+	if got := waits(); len(got) != 2 {
+		t.Errorf("waited %v before the third refresh, want two waits", got)
+	}
+}
+
+func TestStartBackgroundRefreshShutdown(t *testing.T) {
+	tests := []struct {
+		name         string
+		cancelParent bool
+	}{
+		{name: "Stop"},
+		{name: "ContextCancelled", cancelParent: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			waits := stubRefreshWait(t)
+			// The second Retrieve blocks until the refresher is shut down
+			provider := &scriptedProvider{results: []func() (aws.Credentials, error){expiringIn(time.Hour)}}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			stop, err := StartBackgroundRefresh(ctx, aws.Config{Credentials: aws.NewCredentialsCache(provider)})
+			if err != nil {
+				t.Fatalf("StartBackgroundRefresh: %v", err)
+			}
+			provider.waitForCalls(t, 2)
+
+			if tt.cancelParent {
+				cancel()
+			}
+			stopped := make(chan struct{})
+			go func() {
+				stop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+			case <-time.After(5 * time.Second):
+				t.Fatal("background refresh did not stop")
+			}
+			if got := waits(); len(got) != 1 {
+				t.Errorf("waited %v, want one wait", got)
+			}
+		})
+	}
+}
+
+func TestStartBackgroundRefreshErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		creds   aws.CredentialsProvider
+		wantErr error
+	}{
+		{name: "NilCredentials", wantErr: ErrNilCredentials},
+		{name: "NotInvalidatable", creds: credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", ""), wantErr: ErrNotInvalidatable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := StartBackgroundRefresh(context.Background(), aws.Config{Credentials: tt.creds})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("StartBackgroundRefresh error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
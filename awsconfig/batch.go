@@ -0,0 +1,82 @@
+package awsconfig
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// AssumeRoleSpec is one role to assume with AssumeRoles
+type AssumeRoleSpec struct {
+	RoleArn string
+	Options []Option
+}
+
+// Result is the outcome of assuming the role of Spec
+type Result struct {
+	Spec   AssumeRoleSpec
+	Config aws.Config
+	Err    error
+}
+
+// AssumeRoles assumes the role of each spec with NewAssumeRoleConf, using at most
+// concurrency workers, or DefaultFanOutConcurrency when it isn't positive. Results
+// are in the order of specs. Once ctx is done no further specs are started; their
+// results, and the returned error, are the error of ctx.
+func AssumeRoles(
+	ctx context.Context,
+	cfg aws.Config,
+	specs []AssumeRoleSpec,
+	concurrency int,
+) ([]Result, error) {
+	results := make([]Result, len(specs))
+	for i, spec := range specs {
+		results[i].Spec = spec
+	}
+	err := runBatch(ctx, len(specs), concurrency, func(i int) {
+		results[i].Config, results[i].Err = NewAssumeRoleConf(ctx, cfg, specs[i].RoleArn, specs[i].Options...)
+	}, func(i int, err error) {
+		results[i].Err = err
+	})
+	return results, err
+}
+
+// runBatch calls work for each index below n from a pool of concurrency workers.
+// Once ctx is done, skip is called with its error for every index not started.
+func runBatch(ctx context.Context, n, concurrency int, work func(i int), skip func(i int, err error)) error {
+	if concurrency <= 0 {
+		concurrency = DefaultFanOutConcurrency
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range min(concurrency, n) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				work(i)
+			}
+		}()
+	}
+
+	var err error
+	for i := 0; i < n; i++ {
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err == nil {
+			select {
+			case indexes <- i:
+				continue
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+		}
+		skip(i, err)
+	}
+	close(indexes)
+	wg.Wait()
+	return err
+}
@@ -0,0 +1,104 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigtest"
+)
+
+func TestAssumeRoles(t *testing.T) {
+	fake := &awsconfigtest.FakeSTS{}
+	common := []Option{WithSTSClient(fake), WithSkipIdentityCheck(), WithPreWarm()}
+	specs := []AssumeRoleSpec{
+		{RoleArn: "arn:aws:iam::111111111111:role/Reader", Options: append(common, WithRoleSessionName("reader"))},
+		{RoleArn: "arn:aws:iam::222222222222:role/Writer", Options: append(common, WithDuration(2*time.Hour))},
+		{RoleArn: "arn:aws:iam::333333333333:role/Vendor", Options: append(common, WithExternalID("vendor-id"))},
+		{RoleArn: "not-an-arn", Options: common},
+	}
+	results, err := AssumeRoles(context.Background(), aws.Config{}, specs, 2)
+	if err != nil {
+		t.Fatalf("AssumeRoles: %v", err)
+	}
+	if len(results) != len(specs) {
+		t.Fatalf("%d results for %d specs", len(results), len(specs))
+	}
+	for i, result := range results {
+		if i < 3 && result.Err != nil {
+			t.Errorf("result for %s: %v", result.Spec.RoleArn, result.Err)
+		}
+		if result.Spec.RoleArn != specs[i].RoleArn {
+			t.Errorf("result %d is for %s, want %s", i, result.Spec.RoleArn, specs[i].RoleArn)
+		}
+	}
+	if !errors.Is(results[3].Err, ErrInvalidRoleArn) {
+		t.Errorf("result for an invalid ARN: %v, want %v", results[3].Err, ErrInvalidRoleArn)
+	}
+
+	// Each role was assumed with its own options
+	inputs := map[string]sts.AssumeRoleInput{}
+	for _, in := range fake.AssumeRoleInputs() {
+		inputs[aws.ToString(in.RoleArn)] = in
+	}
+	if got := aws.ToString(inputs[specs[0].RoleArn].RoleSessionName); got != "reader" {
+		t.Errorf("AssumeRole of %s with session name %q, want reader", specs[0].RoleArn, got)
+	}
+	if got := aws.ToInt32(inputs[specs[1].RoleArn].DurationSeconds); got != 7200 {
+		t.Errorf("AssumeRole of %s for %ds, want 7200", specs[1].RoleArn, got)
+	}
+	if got := aws.ToString(inputs[specs[2].RoleArn].ExternalId); got != "vendor-id" {
+		t.Errorf("AssumeRole of %s with external ID %q, want vendor-id", specs[2].RoleArn, got)
+	}
+	for _, spec := range specs[:2] {
+		if got := aws.ToString(inputs[spec.RoleArn].ExternalId); got != "" {
+			t.Errorf("AssumeRole of %s with external ID %q, want none", spec.RoleArn, got)
+		}
+	}
+}
+
+func TestAssumeRolesCancelledMidway(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var assumed int
+	// Cancel the batch while the second role is being assumed
+	client := &accountSTS{FakeSTS: &awsconfigtest.FakeSTS{}, onAssume: func(string) {
+		if assumed++; assumed == 2 {
+			cancel()
+		}
+	}}
+	var specs []AssumeRoleSpec
+	for i := range 5 {
+		specs = append(specs, AssumeRoleSpec{
+			RoleArn: fmt.Sprintf("arn:aws:iam::%012d:role/Audit", i+1),
+			Options: []Option{WithSTSClient(client), WithSkipIdentityCheck(), WithPreWarm()},
+		})
+	}
+
+	results, err := AssumeRoles(ctx, aws.Config{}, specs, 1)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("AssumeRoles error %v, want %v", err, context.Canceled)
+	}
+	if len(results) != len(specs) {
+		t.Fatalf("%d results for %d specs", len(results), len(specs))
+	}
+	if results[0].Err != nil {
+		t.Errorf("result 0: %v", results[0].Err)
+	}
+	for i, result := range results[2:] {
+		if !errors.Is(result.Err, context.Canceled) {
+			t.Errorf("result %d: %v, want %v", i+2, result.Err, context.Canceled)
+		}
+		if result.Spec.RoleArn != specs[i+2].RoleArn {
+			t.Errorf("result %d is for %s, want %s", i+2, result.Spec.RoleArn, specs[i+2].RoleArn)
+		}
+	}
+	if got := len(client.AssumeRoleInputs()); got != 2 {
+		t.Errorf("AssumeRole called %d times, want 2 before cancellation", got)
+	}
+}
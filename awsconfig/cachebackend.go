@@ -0,0 +1,264 @@
+package awsconfig
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+)
+
+const (
+	// persistedExpiryWindow is how long before expiry credentials in a
+	// CredentialCache stop being reused, the same window the AWS CLI applies
+	persistedExpiryWindow = 15 * time.Minute
+)
+
+// CredentialCache is a store of credentials consulted before a provider is
+// refreshed and written through after, such as NewMemoryCache, NewFileCache, or
+// NewKeyringCache. Entries are keyed, so one store can be shared by the configs
+// of many roles: the key of assumed credentials is the hex SHA-1 of the
+// CLICacheKey of the AssumeRole request and its session name, and that of
+// other credentials the hex SHA-1 of their Source. Get reports a miss with false; credentials within 15 minutes of
+// expiry, or the WithExpiryWindow window if longer, are treated as a miss by
+// the caller. Implementations must be safe for concurrent use.
+type CredentialCache interface {
+	Get(ctx context.Context, key string) (aws.Credentials, bool)
+	Put(ctx context.Context, key string, creds aws.Credentials) error
+	Invalidate(ctx context.Context, key string) error
+}
+
+// cacheLocker is implemented by a CredentialCache shared between processes, so
+// only one of them refreshes the entry of key at a time
+type cacheLocker interface {
+	Lock(ctx context.Context, key string) (unlock func(), err error)
+}
+
+// WithCache consults backend before refreshing credentials and stores them in
+// it after. Credentials that cannot expire are never stored. Repeated use
+// layers backends, the first one passed being consulted first.
+func WithCache(backend CredentialCache) ConfOption {
+	return func(c *confOptions) {
+		c.cacheBackends = append(c.cacheBackends, backend)
+	}
+}
+
+// NewMemoryCache returns a CredentialCache holding credentials in memory
+func NewMemoryCache() CredentialCache {
+	return &memoryCache{entries: make(map[string]aws.Credentials)}
+}
+
+// memoryCache is the in-memory CredentialCache
+type memoryCache struct {
+	mu      sync.Mutex
+	entries map[string]aws.Credentials
+}
+
+// Get implements the CredentialCache interface method
+func (c *memoryCache) Get(_ context.Context, key string) (aws.Credentials, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	creds, ok := c.entries[key]
+	return creds, ok
+}
+
+// Put implements the CredentialCache interface method
+func (c *memoryCache) Put(_ context.Context, key string, creds aws.Credentials) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = creds
+	return nil
+}
+
+// Invalidate implements the CredentialCache interface method
+func (c *memoryCache) Invalidate(_ context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+	return nil
+}
+
+// sourceCacheKey returns the CredentialCache key of credentials with source
+func sourceCacheKey(source string) string {
+	hash := sha1.Sum([]byte(source))
+	return hex.EncodeToString(hash[:])
+}
+
+// roleCacheKey returns the CredentialCache key of an AssumeRole request: its
+// CLICacheKey, combined with the session name that the CLI leaves out because
+// its session names are random
+func roleCacheKey(o stscreds.AssumeRoleOptions) (string, error) {
+	key, err := CLICacheKey(o)
+	if err != nil || o.RoleSessionName == "" {
+		return key, err
+	}
+	return sourceCacheKey(key + "|" + o.RoleSessionName), nil
+}
+
+// withRoleCacheBackends wraps provider of the assumed credentials of roleArn in
+// the backends configured by WithFileCache, WithKeyringCache, and WithCache.
+// The files of WithFileCache are named by the CLICacheKey, so the CLI shares
+// them; the other backends are keyed by roleCacheKey.
+func withRoleCacheBackends(
+	conf *confOptions,
+	roleArn string,
+	assumeRoleOpts []func(*stscreds.AssumeRoleOptions),
+	provider aws.CredentialsProvider,
+) (aws.CredentialsProvider, error) {
+	if len(conf.cacheBackends) == 0 && !conf.fileCache && conf.keyringService == "" {
+		return provider, nil
+	}
+	o := stscreds.AssumeRoleOptions{RoleARN: roleArn}
+	for _, fn := range assumeRoleOpts {
+		fn(&o)
+	}
+	key, err := roleCacheKey(o)
+	if err != nil {
+		return nil, err
+	}
+
+	var caches []keyedCache
+	if conf.fileCache {
+		cliKey, err := CLICacheKey(o)
+		if err != nil {
+			return nil, err
+		}
+		backend := NewFileCache(conf.fileCacheDir)
+		if conf.fileCacheShared {
+			backend = NewSharedFileCache(conf.fileCacheDir, conf.fileCacheLockTimeout)
+		}
+		caches = append(caches, keyedCache{backend: backend, key: cliKey})
+	}
+	if conf.keyringService != "" {
+		kr := conf.keyring
+		if kr == nil {
+			kr = osKeyring{}
+		}
+		caches = append(caches, keyedCache{backend: NewKeyringCache(kr, conf.keyringService), key: key})
+	}
+	return withCacheBackends(conf, key, provider, caches...), nil
+}
+
+// keyedCache is a CredentialCache and the key of the entry of a provider in it
+type keyedCache struct {
+	backend CredentialCache
+	key     string
+}
+
+// withCacheBackends wraps provider in caches, then in the backends configured
+// by WithCache under key
+func withCacheBackends(
+	conf *confOptions,
+	key string,
+	provider aws.CredentialsProvider,
+	caches ...keyedCache,
+) aws.CredentialsProvider {
+	for _, backend := range conf.cacheBackends {
+		caches = append(caches, keyedCache{backend: backend, key: key})
+	}
+
+	// Entries the aws.CredentialsCache in front would refresh are a miss too
+	var cacheOpts aws.CredentialsCacheOptions
+	for _, fn := range conf.cacheOpts {
+		fn(&cacheOpts)
+	}
+	window := max(persistedExpiryWindow, cacheOpts.ExpiryWindow)
+
+	// Wrap innermost first so the first backend is consulted first
+	for i := len(caches) - 1; i >= 0; i-- {
+		provider = &cacheBackendProvider{keyedCache: caches[i], window: window, warn: conf.warn, provider: provider}
+	}
+	return provider
+}
+
+// cacheBackendProvider serves credentials from a CredentialCache while they are fresh
+type cacheBackendProvider struct {
+	keyedCache
+	window   time.Duration
+	warn     func(error)
+	provider aws.CredentialsProvider
+}
+
+// Retrieve implements the aws.CredentialsProvider interface method
+func (p *cacheBackendProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	if creds, ok := p.get(ctx); ok {
+		traceServedFromBackend(ctx)
+		return creds, nil
+	}
+
+	// Refresh under the backend's lock, unless another process refreshed while
+	// this one waited for it
+	if locker, ok := p.backend.(cacheLocker); ok {
+		unlock, err := locker.Lock(ctx, p.key)
+		switch {
+		case err == nil:
+			defer unlock()
+			if creds, ok := p.get(ctx); ok {
+				traceServedFromBackend(ctx)
+				return creds, nil
+			}
+		case ctx.Err() != nil:
+			return aws.Credentials{}, err
+		case p.warn != nil:
+			// Refresh without the lock rather than fail on filesystems without locks
+			p.warn(err)
+		}
+	}
+
+	creds, err := p.provider.Retrieve(ctx)
+	if err != nil {
+		return creds, err
+	}
+	if creds.CanExpire {
+		if err := p.backend.Put(ctx, p.key, creds); err != nil && p.warn != nil {
+			p.warn(err)
+		}
+	}
+	return creds, nil
+}
+
+// get returns the credentials of the backend unless they are missing or expire within the window
+func (p *cacheBackendProvider) get(ctx context.Context) (aws.Credentials, bool) {
+	creds, ok := p.backend.Get(ctx, p.key)
+	if !ok || !creds.HasKeys() || (creds.CanExpire && creds.Expires.Sub(timeNow()) < p.window) {
+		return aws.Credentials{}, false
+	}
+	return creds, true
+}
+
+// IsCredentialsProvider reports whether the wrapped provider is of the type of
+// target, or collects the backend when target is a cacheBackendProbe
+func (p *cacheBackendProvider) IsCredentialsProvider(target aws.CredentialsProvider) bool {
+	if probe, ok := target.(*cacheBackendProbe); ok {
+		probe.caches = append(probe.caches, p.keyedCache)
+		aws.IsCredentialsProvider(p.provider, target)
+		return true
+	}
+	return aws.IsCredentialsProvider(p.provider, target)
+}
+
+// cacheBackendProbe is passed to aws.IsCredentialsProvider to find the
+// CredentialCache backends behind an aws.CredentialsCache
+type cacheBackendProbe struct {
+	caches []keyedCache
+}
+
+// Retrieve implements the aws.CredentialsProvider interface method
+func (*cacheBackendProbe) Retrieve(context.Context) (aws.Credentials, error) {
+	return aws.Credentials{}, nil
+}
+
+// invalidateCacheBackends invalidates the CredentialCache backends of provider
+func invalidateCacheBackends(ctx context.Context, provider aws.CredentialsProvider) error {
+	probe := &cacheBackendProbe{}
+	aws.IsCredentialsProvider(provider, probe)
+	for _, c := range probe.caches {
+		if err := c.backend.Invalidate(ctx, c.key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package awsconfig
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigtest"
+)
+
+func TestCredentialCacheConformance(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+
+	tests := []struct {
+		name     string
+		newCache func(t *testing.T) CredentialCache
+	}{
+		{name: "Memory", newCache: func(*testing.T) CredentialCache { return NewMemoryCache() }},
+		{name: "File", newCache: func(t *testing.T) CredentialCache {
+			return NewFileCache(t.TempDir())
+		}},
+		{name: "SharedFile", newCache: func(t *testing.T) CredentialCache {
+			return NewSharedFileCache(t.TempDir(), 0)
+		}},
+		{name: "Keyring", newCache: func(*testing.T) CredentialCache {
+			return NewKeyringCache(&awsconfigtest.FakeKeyring{}, "tool")
+		}},
+		{name: "Encrypted", newCache: func(*testing.T) CredentialCache {
+			return NewEncryptedCache(NewMemoryCache(), StaticCacheKeys(key))
+		}},
+		{name: "EncryptedFile", newCache: func(t *testing.T) CredentialCache {
+			return NewEncryptedCache(NewFileCache(t.TempDir()), StaticCacheKeys(key))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			awsconfigtest.TestCredentialCache(t, func() awsconfigtest.CredentialCache { return tt.newCache(t) })
+		})
+	}
+}
+
+func TestInvalidateRefreshes(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(t *testing.T) (aws.Config, func() int)
+	}{
+		{
+			name: "AssumeRole",
+			build: func(t *testing.T) (aws.Config, func() int) {
+				fake := &awsconfigtest.FakeSTS{Duration: time.Hour}
+				cfg, err := NewAssumeRoleConf(context.Background(), aws.Config{}, testRoleArn, WithSTSClient(fake), WithSkipIdentityCheck())
+				if err != nil {
+					t.Fatalf("NewAssumeRoleConf: %v", err)
+				}
+				return cfg, func() int { return len(fake.AssumeRoleInputs()) }
+			},
+		},
+		{
+			name: "CustomFunction",
+			build: func(t *testing.T) (aws.Config, func() int) {
+				retrieve, calls := countingRetrieve(time.Hour)
+				cfg, err := NewCustomFunctionConf(context.Background(), aws.Config{}, retrieve)
+				if err != nil {
+					t.Fatalf("NewCustomFunctionConf: %v", err)
+				}
+				return cfg, func() int { return int(calls.Load()) }
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, refreshes := tt.build(t)
+			for i := 1; i <= 3; i++ {
+				if _, err := cfg.Credentials.Retrieve(context.Background()); err != nil {
+					t.Fatalf("Retrieve: %v", err)
+				}
+				if got := refreshes(); got != i {
+					t.Fatalf("refreshed %d times after %d Invalidate calls, want %d", got, i-1, i)
+				}
+				cfg.Credentials.(*aws.CredentialsCache).Invalidate()
+			}
+		})
+	}
+}
+
+func TestCacheBackendWindow(t *testing.T) {
+	tests := []struct {
+		name        string
+		duration    time.Duration
+		opts        []Option
+		wantAssumes int
+	}{
+		{name: "Fresh", duration: time.Hour, wantAssumes: 1},
+		{name: "WithinPersistedWindow", duration: 10 * time.Minute, wantAssumes: 3},
+		{name: "WithinExpiryWindow", duration: 20 * time.Minute, opts: []Option{WithExpiryWindow(30 * time.Minute)}, wantAssumes: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := NewMemoryCache()
+			fake := &awsconfigtest.FakeSTS{Duration: tt.duration}
+			opts := append([]Option{WithSTSClient(fake), WithSkipIdentityCheck(), WithCache(backend)}, tt.opts...)
+			cfg, err := NewAssumeRoleConf(context.Background(), aws.Config{}, testRoleArn, opts...)
+			if err != nil {
+				t.Fatalf("NewAssumeRoleConf: %v", err)
+			}
+			for range 3 {
+				cfg.Credentials.(*aws.CredentialsCache).Invalidate()
+				if _, err := cfg.Credentials.Retrieve(context.Background()); err != nil {
+					t.Fatalf("Retrieve: %v", err)
+				}
+			}
+			if got := len(fake.AssumeRoleInputs()); got != tt.wantAssumes {
+				t.Errorf("AssumeRole called %d times, want %d", got, tt.wantAssumes)
+			}
+		})
+	}
+}
+
+func TestCacheBackendSkipsNonExpiring(t *testing.T) {
+	tests := []struct {
+		name      string
+		lifetime  time.Duration
+		wantStore bool
+	}{
+		{name: "Expiring", lifetime: time.Hour, wantStore: true},
+		{name: "NonExpiring", wantStore: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := NewMemoryCache()
+			retrieve := func(context.Context) (aws.Credentials, error) { return testCredentials(tt.lifetime), nil }
+			cfg, err := NewCustomFunctionConf(context.Background(), aws.Config{}, retrieve, WithCache(backend))
+			if err != nil {
+				t.Fatalf("NewCustomFunctionConf: %v", err)
+			}
+			creds, err := cfg.Credentials.Retrieve(context.Background())
+			if err != nil {
+				t.Fatalf("Retrieve: %v", err)
+			}
+			if _, stored := backend.Get(context.Background(), sourceCacheKey(creds.Source)); stored != tt.wantStore {
+				t.Errorf("backend stored the credentials %t, want %t", stored, tt.wantStore)
+			}
+		})
+	}
+}
+
+func TestCacheBackendSharedByRoles(t *testing.T) {
+	const otherRoleArn = "arn:aws:iam::210987654321:role/Other"
+	backend := NewMemoryCache()
+	fake := &awsconfigtest.FakeSTS{Duration: time.Hour}
+	opts := []Option{WithSTSClient(fake), WithSkipIdentityCheck(), WithCache(backend)}
+	specs := []AssumeRoleSpec{
+		{RoleArn: testRoleArn, Options: opts},
+		{RoleArn: otherRoleArn, Options: opts},
+	}
+
+	// The second round of configs is served from the backend
+	for range 2 {
+		results, err := AssumeRoles(context.Background(), aws.Config{}, specs, 1)
+		if err != nil {
+			t.Fatalf("AssumeRoles: %v", err)
+		}
+		for _, result := range results {
+			if result.Err != nil {
+				t.Fatalf("AssumeRoles %s: %v", result.Spec.RoleArn, result.Err)
+			}
+			if _, err := result.Config.Credentials.Retrieve(context.Background()); err != nil {
+				t.Fatalf("Retrieve %s: %v", result.Spec.RoleArn, err)
+			}
+		}
+	}
+
+	var assumed []string
+	for _, input := range fake.AssumeRoleInputs() {
+		assumed = append(assumed, aws.ToString(input.RoleArn))
+	}
+	if want := []string{testRoleArn, otherRoleArn}; !slices.Equal(assumed, want) {
+		t.Errorf("assumed %v, want %v", assumed, want)
+	}
+	for _, roleArn := range []string{testRoleArn, otherRoleArn} {
+		key, err := roleCacheKey(effectiveAssumeRoleOptions(roleArn, newConfOptions(opts)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := backend.Get(context.Background(), key); !ok {
+			t.Errorf("backend has no entry for %s", roleArn)
+		}
+	}
+}
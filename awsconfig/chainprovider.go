@@ -0,0 +1,101 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+var (
+	// ErrNoChainProviders is returned by a ChainProvider without providers
+	ErrNoChainProviders = errors.New("Credentials chain has no providers")
+	// ErrChainFailed is returned when every provider of a ChainProvider fails,
+	// joined with the error of each
+	ErrChainFailed = errors.New("All providers of credentials chain failed")
+)
+
+// WithStickyChain makes NewChainConf try the provider that last succeeded first
+func WithStickyChain() ConfOption {
+	return func(c *confOptions) {
+		c.stickyChain = true
+	}
+}
+
+// ChainProvider implements the aws.CredentialsProvider interface by trying a
+// list of providers in order on every Retrieve, returning the credentials of
+// the first that succeeds
+type ChainProvider struct {
+	providers []aws.CredentialsProvider
+	sticky    bool
+	last      atomic.Int32
+}
+
+// NewChainProvider initializes a new ChainProvider trying providers in order
+func NewChainProvider(providers ...aws.CredentialsProvider) *ChainProvider {
+	return &ChainProvider{providers: providers}
+}
+
+// Retrieve implements the aws.CredentialsProvider interface method. When all
+// providers fail, the error wraps ErrChainFailed and the error of each
+// provider, labelled with its index and type.
+func (p *ChainProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	if len(p.providers) == 0 {
+		return aws.Credentials{}, ErrNoChainProviders
+	}
+
+	first := 0
+	if p.sticky {
+		first = int(p.last.Load())
+	}
+	errs := []error{ErrChainFailed}
+	for n := range p.providers {
+		// A sticky chain starts at the last success and wraps around
+		i := (first + n) % len(p.providers)
+		provider := p.providers[i]
+		creds, err := provider.Retrieve(ctx)
+		if err == nil {
+			p.last.Store(int32(i))
+			return creds, nil
+		}
+		errs = append(errs, fmt.Errorf("provider %d (%T): %w", i, provider, err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return aws.Credentials{}, errors.Join(errs...)
+}
+
+// NewChainConf returns an aws.Config using credentials from the first of
+// providers to succeed, cached until they expire; see ChainProvider. The
+// Credentials of the base config can be passed last as a fallback.
+func NewChainConf(
+	_ context.Context,
+	cfg aws.Config,
+	providers []aws.CredentialsProvider,
+	opts ...Option,
+) (aws.Config, error) {
+	conf := newConfOptions(opts)
+	if len(providers) == 0 {
+		return aws.Config{}, ErrNoChainProviders
+	}
+	provider := NewChainProvider(providers...)
+	provider.sticky = conf.stickyChain
+
+	newCfg := cfg.Copy()
+	newCfg.Credentials = aws.NewCredentialsCache(withStats(provider), conf.cacheOpts...)
+	return newCfg, nil
+}
+
+// String implements the fmt.Stringer interface method, printing only the number
+// of providers, which are not formatted
+func (p *ChainProvider) String() string {
+	return fmt.Sprintf("ChainProvider{Providers: %d, Sticky: %t}", len(p.providers), p.sticky)
+}
+
+// GoString implements the fmt.GoStringer interface method, so %#v prints no more than String
+func (p *ChainProvider) GoString() string {
+	return "&awsconfig." + p.String()
+}
@@ -0,0 +1,224 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// chainLink is a provider of a ChainProvider under test, failing with err
+// while it is set
+type chainLink struct {
+	name string
+
+	mu    sync.Mutex
+	err   error
+	calls int
+}
+
+// Retrieve implements the aws.CredentialsProvider interface method
+func (l *chainLink) Retrieve(context.Context) (aws.Credentials, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls++
+	if l.err != nil {
+		return aws.Credentials{}, l.err
+	}
+	return aws.Credentials{AccessKeyID: l.name, SecretAccessKey: "secret", Source: l.name}, nil
+}
+
+// fail sets the error of the link
+func (l *chainLink) fail(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.err = err
+}
+
+// Calls returns the number of Retrieve calls
+func (l *chainLink) Calls() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.calls
+}
+
+func TestChainProvider(t *testing.T) {
+	errBroker := errors.New("broker unreachable")
+	errWebIdentity := errors.New("token file missing")
+	errBase := errors.New("no base credentials")
+
+	tests := []struct {
+		name      string
+		errs      []error
+		wantKeyID string
+		wantCalls []int
+		wantErrs  []error
+	}{
+		{
+			name:      "FirstWorks",
+			errs:      []error{nil, nil, nil},
+			wantKeyID: "broker",
+			wantCalls: []int{1, 0, 0},
+		},
+		{
+			name:      "MiddleWorks",
+			errs:      []error{errBroker, nil, nil},
+			wantKeyID: "webidentity",
+			wantCalls: []int{1, 1, 0},
+		},
+		{
+			name:      "LastWorks",
+			errs:      []error{errBroker, errWebIdentity, nil},
+			wantKeyID: "base",
+			wantCalls: []int{1, 1, 1},
+		},
+		{
+			name:      "AllFail",
+			errs:      []error{errBroker, errWebIdentity, errBase},
+			wantCalls: []int{1, 1, 1},
+			wantErrs:  []error{ErrChainFailed, errBroker, errWebIdentity, errBase},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			links := []*chainLink{{name: "broker"}, {name: "webidentity"}, {name: "base"}}
+			providers := make([]aws.CredentialsProvider, len(links))
+			for i, link := range links {
+				link.fail(tt.errs[i])
+				providers[i] = link
+			}
+
+			creds, err := NewChainProvider(providers...).Retrieve(context.Background())
+			for _, want := range tt.wantErrs {
+				if !errors.Is(err, want) {
+					t.Errorf("Retrieve error %v, want %v", err, want)
+				}
+			}
+			if tt.wantErrs == nil && err != nil {
+				t.Fatalf("Retrieve: %v", err)
+			}
+			if creds.AccessKeyID != tt.wantKeyID {
+				t.Errorf("AccessKeyID %q, want %q", creds.AccessKeyID, tt.wantKeyID)
+			}
+			for i, link := range links {
+				if link.Calls() != tt.wantCalls[i] {
+					t.Errorf("provider %d called %d times, want %d", i, link.Calls(), tt.wantCalls[i])
+				}
+			}
+		})
+	}
+}
+
+func TestChainProviderErrorLabels(t *testing.T) {
+	p := NewChainProvider(
+		aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{}, errors.New("first failed")
+		}),
+		&chainLink{name: "second", err: errors.New("second failed")},
+	)
+	_, err := p.Retrieve(context.Background())
+	for _, want := range []string{
+		"provider 0 (aws.CredentialsProviderFunc): first failed",
+		"provider 1 (*awsconfig.chainLink): second failed",
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Retrieve error %v, want it to contain %q", err, want)
+		}
+	}
+}
+
+func TestChainProviderCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	first := &chainLink{name: "first", err: context.Canceled}
+	second := &chainLink{name: "second"}
+	cancel()
+	if _, err := NewChainProvider(first, second).Retrieve(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Retrieve error %v, want %v", err, context.Canceled)
+	}
+	if second.Calls() != 0 {
+		t.Errorf("providers after a cancelled context were called %d times", second.Calls())
+	}
+}
+
+func TestChainProviderEmpty(t *testing.T) {
+	if _, err := NewChainProvider().Retrieve(context.Background()); !errors.Is(err, ErrNoChainProviders) {
+		t.Errorf("Retrieve error %v, want %v", err, ErrNoChainProviders)
+	}
+	if _, err := NewChainConf(context.Background(), aws.Config{}, nil); !errors.Is(err, ErrNoChainProviders) {
+		t.Errorf("NewChainConf error %v, want %v", err, ErrNoChainProviders)
+	}
+}
+
+func TestNewChainConfSticky(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		// wantKeyID is the provider used once the broker recovers
+		wantKeyID string
+		wantCalls []int
+	}{
+		{name: "Ordered", wantKeyID: "broker", wantCalls: []int{3, 2, 0}},
+		{name: "Sticky", opts: []Option{WithStickyChain()}, wantKeyID: "webidentity", wantCalls: []int{1, 3, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker, webIdentity, base := &chainLink{name: "broker"}, &chainLink{name: "webidentity"}, &chainLink{name: "base"}
+			cfg, err := NewChainConf(context.Background(), aws.Config{}, []aws.CredentialsProvider{broker, webIdentity, base}, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewChainConf: %v", err)
+			}
+			refresh := func() string {
+				t.Helper()
+				cfg.Credentials.(*aws.CredentialsCache).Invalidate()
+				creds, err := cfg.Credentials.Retrieve(context.Background())
+				if err != nil {
+					t.Fatalf("Retrieve: %v", err)
+				}
+				return creds.AccessKeyID
+			}
+
+			broker.fail(errors.New("broker down"))
+			for range 2 {
+				if got := refresh(); got != "webidentity" {
+					t.Fatalf("credentials from %q, want webidentity", got)
+				}
+			}
+			// Once the broker recovers, an ordered chain switches back to it
+			// while a sticky chain keeps using web identity
+			broker.fail(nil)
+			if got := refresh(); got != tt.wantKeyID {
+				t.Errorf("credentials from %q, want %q", got, tt.wantKeyID)
+			}
+			if calls := fmt.Sprint([]int{broker.Calls(), webIdentity.Calls(), base.Calls()}); calls != fmt.Sprint(tt.wantCalls) {
+				t.Errorf("provider calls %s, want %v", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestChainProviderConcurrent(t *testing.T) {
+	broker, base := &chainLink{name: "broker"}, &chainLink{name: "base"}
+	p := NewChainProvider(broker, base)
+	p.sticky = true
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if i%5 == 0 {
+				broker.fail(errors.New("flapping"))
+			} else if i%5 == 1 {
+				broker.fail(nil)
+			}
+			if _, err := p.Retrieve(context.Background()); err != nil {
+				t.Errorf("Retrieve: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
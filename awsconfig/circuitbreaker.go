@@ -0,0 +1,160 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+var (
+	// ErrCircuitOpen is returned without calling STS while the circuit breaker is open
+	ErrCircuitOpen = errors.New("STS circuit breaker is open")
+)
+
+// CircuitState is the state of a CircuitBreaker
+type CircuitState int
+
+const (
+	// CircuitClosed lets every call through
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails every call fast until the cooldown elapses
+	CircuitOpen
+	// CircuitHalfOpen lets a single probe call through to test recovery
+	CircuitHalfOpen
+)
+
+// String implements the fmt.Stringer interface method
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("CircuitState(%d)", int(s))
+}
+
+// CircuitBreaker fails credential refreshes fast after threshold consecutive
+// failures, until cooldown has elapsed and a probe refresh succeeds. It is safe
+// for concurrent use and may be shared by several configs.
+type CircuitBreaker struct {
+	threshold     int
+	cooldown      time.Duration
+	onStateChange func(from, to CircuitState)
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	lastErr  error
+	probing  bool
+}
+
+// NewCircuitBreaker returns a CircuitBreaker; onStateChange, if not nil, is
+// called on every state transition and must not call back into the breaker
+func NewCircuitBreaker(threshold int, cooldown time.Duration, onStateChange func(from, to CircuitState)) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold:     max(threshold, 1),
+		cooldown:      cooldown,
+		onStateChange: onStateChange,
+	}
+}
+
+// State returns the current state of the breaker
+func (b *CircuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// allow reports whether a call may proceed, or the error to fail fast with
+func (b *CircuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitOpen && !timeNow().Before(b.openedAt.Add(b.cooldown)) {
+		b.setState(CircuitHalfOpen)
+	}
+	switch {
+	case b.state == CircuitOpen, b.state == CircuitHalfOpen && b.probing:
+		return fmt.Errorf("%w: %w", ErrCircuitOpen, b.lastErr)
+	case b.state == CircuitHalfOpen:
+		b.probing = true
+	}
+	return nil
+}
+
+// record updates the breaker with the outcome of an allowed call
+func (b *CircuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if err == nil {
+		b.failures = 0
+		b.setState(CircuitClosed)
+		return
+	}
+	b.failures++
+	b.lastErr = err
+	if b.state == CircuitHalfOpen || b.failures >= b.threshold {
+		b.openedAt = timeNow()
+		b.setState(CircuitOpen)
+	}
+}
+
+// setState transitions the breaker, notifying onStateChange; b.mu must be held
+func (b *CircuitBreaker) setState(state CircuitState) {
+	if b.state == state {
+		return
+	}
+	from := b.state
+	b.state = state
+	if b.onStateChange != nil {
+		b.onStateChange(from, state)
+	}
+}
+
+// WithSTSCircuitBreaker gives the config its own circuit breaker around credential
+// refreshes, opening after threshold consecutive failures for cooldown
+func WithSTSCircuitBreaker(threshold int, cooldown time.Duration) ConfOption {
+	return func(c *confOptions) {
+		c.circuitBreaker = NewCircuitBreaker(threshold, cooldown, nil)
+	}
+}
+
+// WithSharedSTSCircuitBreaker puts credential refreshes behind breaker, which may
+// be shared with other configs and observed through its state change callback
+func WithSharedSTSCircuitBreaker(breaker *CircuitBreaker) ConfOption {
+	return func(c *confOptions) {
+		c.circuitBreaker = breaker
+	}
+}
+
+// withCircuitBreaker wraps provider in the configured circuit breaker, if any
+func withCircuitBreaker(conf *confOptions, provider aws.CredentialsProvider) aws.CredentialsProvider {
+	if conf.circuitBreaker == nil {
+		return provider
+	}
+	return &circuitBreakerProvider{breaker: conf.circuitBreaker, provider: provider}
+}
+
+// circuitBreakerProvider fails fast while its breaker is open
+type circuitBreakerProvider struct {
+	breaker  *CircuitBreaker
+	provider aws.CredentialsProvider
+}
+
+// Retrieve implements the aws.CredentialsProvider interface method
+func (p *circuitBreakerProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	if err := p.breaker.allow(); err != nil {
+		return aws.Credentials{}, err
+	}
+	creds, err := p.provider.Retrieve(ctx)
+	p.breaker.record(err)
+	return creds, err
+}
@@ -0,0 +1,102 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/smithy-go"
+
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigtest"
+)
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	advance := stubClock(t)
+	stsDown := errors.New("sts down")
+	inner := &switchProvider{}
+	var transitions []string
+	breaker := NewCircuitBreaker(3, time.Minute, func(from, to CircuitState) {
+		transitions = append(transitions, fmt.Sprintf("%s->%s", from, to))
+	})
+	provider := withCircuitBreaker(newConfOptions([]Option{WithSharedSTSCircuitBreaker(breaker)}), inner)
+
+	steps := []struct {
+		name      string
+		advance   time.Duration
+		fail      bool
+		wantErr   error
+		wantState CircuitState
+	}{
+		{name: "Failure1", fail: true, wantErr: stsDown, wantState: CircuitClosed},
+		{name: "Failure2", fail: true, wantErr: stsDown, wantState: CircuitClosed},
+		{name: "Failure3Opens", fail: true, wantErr: stsDown, wantState: CircuitOpen},
+		{name: "FailsFast", wantErr: ErrCircuitOpen, wantState: CircuitOpen},
+		{name: "StillCoolingDown", advance: 59 * time.Second, wantErr: ErrCircuitOpen, wantState: CircuitOpen},
+		{name: "FailedProbeReopens", advance: time.Second, fail: true, wantErr: stsDown, wantState: CircuitOpen},
+		{name: "FailsFastAgain", advance: 30 * time.Second, wantErr: ErrCircuitOpen, wantState: CircuitOpen},
+		{name: "ProbeCloses", advance: 30 * time.Second, wantState: CircuitClosed},
+		{name: "Closed", wantState: CircuitClosed},
+	}
+	for _, step := range steps {
+		advance(step.advance)
+		if step.fail {
+			inner.set(aws.Credentials{}, stsDown)
+		} else {
+			inner.set(testCredentials(time.Hour), nil)
+		}
+		_, err := provider.Retrieve(context.Background())
+		if !errors.Is(err, step.wantErr) {
+			t.Errorf("%s: Retrieve error %v, want %v", step.name, err, step.wantErr)
+		}
+		if errors.Is(err, ErrCircuitOpen) && !errors.Is(err, stsDown) {
+			t.Errorf("%s: Retrieve error %v does not wrap the last failure", step.name, err)
+		}
+		if got := breaker.State(); got != step.wantState {
+			t.Errorf("%s: breaker %s, want %s", step.name, got, step.wantState)
+		}
+	}
+
+	want := "closed->open open->half-open half-open->open open->half-open half-open->closed"
+	if got := strings.Join(transitions, " "); got != want {
+		t.Errorf("transitions %q, want %q", got, want)
+	}
+}
+
+func TestCircuitBreakerSingleProbe(t *testing.T) {
+	advance := stubClock(t)
+	breaker := NewCircuitBreaker(1, time.Minute, nil)
+	breaker.record(errors.New("sts down"))
+	advance(time.Minute)
+
+	if err := breaker.allow(); err != nil {
+		t.Fatalf("probe not allowed: %v", err)
+	}
+	// Other calls fail fast while the probe is in flight
+	if err := breaker.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("second call during probe: %v, want %v", err, ErrCircuitOpen)
+	}
+}
+
+func TestWithSTSCircuitBreaker(t *testing.T) {
+	stubClock(t)
+	throttled := &smithy.GenericAPIError{Code: "Throttling", Message: "Rate exceeded"}
+	fake := &awsconfigtest.FakeSTS{AssumeRoleErr: throttled}
+	cfg, err := NewAssumeRoleConf(context.Background(), aws.Config{}, testRoleArn,
+		WithSTSClient(fake), WithSkipIdentityCheck(), WithSTSCircuitBreaker(2, time.Minute))
+	if err != nil {
+		t.Fatalf("NewAssumeRoleConf: %v", err)
+	}
+	for range 5 {
+		_, err = cfg.Credentials.Retrieve(context.Background())
+	}
+	if !errors.Is(err, ErrCircuitOpen) || !IsThrottled(err) {
+		t.Errorf("Retrieve error %v, want %v wrapping the throttle", err, ErrCircuitOpen)
+	}
+	if got := len(fake.AssumeRoleInputs()); got != 2 {
+		t.Errorf("AssumeRole called %d times, want 2 before the breaker opened", got)
+	}
+}
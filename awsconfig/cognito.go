@@ -0,0 +1,226 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentity"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// CognitoIdentityProviderName prefixes the Source of credentials returned by
+// CognitoIdentityProvider, followed by the identity pool ID
+const CognitoIdentityProviderName = SourcePrefix + "cognito"
+
+var (
+	// ErrCognitoLogins is returned when the logins callback of a Cognito identity fails
+	ErrCognitoLogins = errors.New("Cannot get logins for Cognito identity")
+	// ErrGetCognitoIdentity is returned when the Cognito identity cannot be resolved
+	ErrGetCognitoIdentity = errors.New("Cannot get Cognito identity")
+	// ErrGetCognitoCredentials is returned when credentials for a Cognito identity cannot be obtained
+	ErrGetCognitoCredentials = errors.New("Cannot get credentials for Cognito identity")
+)
+
+// CognitoIdentityAPIClient is a client capable of the Cognito Identity operations
+// of the enhanced and classic authentication flows
+type CognitoIdentityAPIClient interface {
+	GetId(ctx context.Context, params *cognitoidentity.GetIdInput, optFns ...func(*cognitoidentity.Options)) (*cognitoidentity.GetIdOutput, error)
+	GetCredentialsForIdentity(ctx context.Context, params *cognitoidentity.GetCredentialsForIdentityInput, optFns ...func(*cognitoidentity.Options)) (*cognitoidentity.GetCredentialsForIdentityOutput, error)
+	GetOpenIdToken(ctx context.Context, params *cognitoidentity.GetOpenIdTokenInput, optFns ...func(*cognitoidentity.Options)) (*cognitoidentity.GetOpenIdTokenOutput, error)
+}
+
+// CognitoLogins returns the logins of a Cognito identity, identity provider
+// names mapped to their tokens, for every refresh so renewed tokens are used;
+// nil logins select an unauthenticated identity
+type CognitoLogins func(ctx context.Context) (map[string]string, error)
+
+// StaticCognitoLogins returns CognitoLogins always returning logins
+func StaticCognitoLogins(logins map[string]string) CognitoLogins {
+	return func(context.Context) (map[string]string, error) {
+		return logins, nil
+	}
+}
+
+// WithCognitoClient makes NewCognitoIdentityConf use client instead of building one from the base config
+func WithCognitoClient(client CognitoIdentityAPIClient) ConfOption {
+	return func(c *confOptions) {
+		c.cognitoClient = client
+	}
+}
+
+// WithCognitoClassicFlow makes NewCognitoIdentityConf use the classic (basic)
+// authentication flow, exchanging the OpenID token of the identity for
+// credentials of roleArn with AssumeRoleWithWebIdentity, instead of the
+// enhanced flow's GetCredentialsForIdentity
+func WithCognitoClassicFlow(roleArn string) ConfOption {
+	return func(c *confOptions) {
+		c.cognitoRoleArn = roleArn
+	}
+}
+
+// CognitoIdentityProvider implements the aws.CredentialsProvider interface by
+// getting credentials for the Cognito identity of an identity pool
+type CognitoIdentityProvider struct {
+	client         CognitoIdentityAPIClient
+	stsClient      stscreds.AssumeRoleWithWebIdentityAPIClient
+	identityPoolID string
+	logins         CognitoLogins
+	roleArn        string
+
+	mu         sync.Mutex
+	identityID string
+}
+
+// NewCognitoIdentityProvider initializes a new CognitoIdentityProvider using the
+// enhanced flow, or the classic flow with stsClient when roleArn is set
+func NewCognitoIdentityProvider(
+	client CognitoIdentityAPIClient,
+	stsClient stscreds.AssumeRoleWithWebIdentityAPIClient,
+	identityPoolID string,
+	logins CognitoLogins,
+	roleArn string,
+) *CognitoIdentityProvider {
+	if logins == nil {
+		logins = StaticCognitoLogins(nil)
+	}
+	return &CognitoIdentityProvider{
+		client:         client,
+		stsClient:      stsClient,
+		identityPoolID: identityPoolID,
+		logins:         logins,
+		roleArn:        roleArn,
+	}
+}
+
+// Retrieve implements the aws.CredentialsProvider interface method
+func (p *CognitoIdentityProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	logins, err := p.logins(ctx)
+	if err != nil {
+		return aws.Credentials{}, fmt.Errorf("%w: %w", ErrCognitoLogins, err)
+	}
+	identityID, err := p.identity(ctx, logins)
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+	if p.roleArn != "" {
+		return p.retrieveClassic(ctx, identityID, logins)
+	}
+
+	resp, err := p.client.GetCredentialsForIdentity(ctx, &cognitoidentity.GetCredentialsForIdentityInput{
+		IdentityId: aws.String(identityID),
+		Logins:     logins,
+	})
+	if err != nil {
+		return aws.Credentials{}, fmt.Errorf("%w %q: %w", ErrGetCognitoCredentials, identityID, err)
+	}
+	if resp.Credentials == nil {
+		return aws.Credentials{}, fmt.Errorf("%w %q: response has no credentials", ErrGetCognitoCredentials, identityID)
+	}
+	return aws.Credentials{
+		AccessKeyID:     aws.ToString(resp.Credentials.AccessKeyId),
+		SecretAccessKey: aws.ToString(resp.Credentials.SecretKey),
+		SessionToken:    aws.ToString(resp.Credentials.SessionToken),
+		Source:          sourceLabel(CognitoIdentityProviderName, p.identityPoolID),
+		CanExpire:       true,
+		Expires:         aws.ToTime(resp.Credentials.Expiration),
+	}, nil
+}
+
+// identity returns the identity ID of logins in the pool, resolving it with GetId once
+func (p *CognitoIdentityProvider) identity(ctx context.Context, logins map[string]string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.identityID != "" {
+		return p.identityID, nil
+	}
+	resp, err := p.client.GetId(ctx, &cognitoidentity.GetIdInput{
+		IdentityPoolId: aws.String(p.identityPoolID),
+		Logins:         logins,
+	})
+	if err != nil {
+		return "", fmt.Errorf("%w in pool %q: %w", ErrGetCognitoIdentity, p.identityPoolID, err)
+	}
+	p.identityID = aws.ToString(resp.IdentityId)
+	return p.identityID, nil
+}
+
+// retrieveClassic exchanges the OpenID token of the identity for role credentials
+func (p *CognitoIdentityProvider) retrieveClassic(
+	ctx context.Context,
+	identityID string,
+	logins map[string]string,
+) (aws.Credentials, error) {
+	token, err := p.client.GetOpenIdToken(ctx, &cognitoidentity.GetOpenIdTokenInput{
+		IdentityId: aws.String(identityID),
+		Logins:     logins,
+	})
+	if err != nil {
+		return aws.Credentials{}, fmt.Errorf("%w %q: %w", ErrGetCognitoCredentials, identityID, err)
+	}
+	resp, err := p.stsClient.AssumeRoleWithWebIdentity(ctx, &sts.AssumeRoleWithWebIdentityInput{
+		RoleArn:          aws.String(p.roleArn),
+		RoleSessionName:  aws.String(SanitizeSessionName(identityID)),
+		WebIdentityToken: token.Token,
+	})
+	if err != nil {
+		return aws.Credentials{}, fmt.Errorf("%w %q: %w", ErrGetCognitoCredentials, identityID, err)
+	}
+	return aws.Credentials{
+		AccessKeyID:     aws.ToString(resp.Credentials.AccessKeyId),
+		SecretAccessKey: aws.ToString(resp.Credentials.SecretAccessKey),
+		SessionToken:    aws.ToString(resp.Credentials.SessionToken),
+		Source:          sourceLabel(CognitoIdentityProviderName, p.identityPoolID),
+		CanExpire:       true,
+		Expires:         aws.ToTime(resp.Credentials.Expiration),
+	}, nil
+}
+
+// NewCognitoIdentityConf returns an aws.Config using auto-refreshing credentials
+// of the Cognito identity of logins in identityPoolID, obtained with the
+// enhanced authentication flow unless WithCognitoClassicFlow is used. logins is
+// called on every refresh; nil selects an unauthenticated identity.
+func NewCognitoIdentityConf(
+	_ context.Context,
+	cfg aws.Config,
+	identityPoolID string,
+	logins CognitoLogins,
+	opts ...Option,
+) (aws.Config, error) {
+	conf := newConfOptions(opts)
+
+	client := conf.cognitoClient
+	if client == nil {
+		client = cognitoidentity.NewFromConfig(cfg)
+	}
+	var stsClient stscreds.AssumeRoleWithWebIdentityAPIClient
+	if conf.cognitoRoleArn != "" {
+		if _, err := parseRoleArn(conf.cognitoRoleArn); err != nil {
+			return aws.Config{}, err
+		}
+		if webIdentityClient, ok := conf.stsClient.(stscreds.AssumeRoleWithWebIdentityAPIClient); ok {
+			stsClient = webIdentityClient
+		} else {
+			stsClient = sts.NewFromConfig(cfg, conf.stsOpts...)
+		}
+	}
+	provider := NewCognitoIdentityProvider(client, stsClient, identityPoolID, logins, conf.cognitoRoleArn)
+
+	newCfg := cfg.Copy()
+	newCfg.Credentials = aws.NewCredentialsCache(withStats(provider), conf.cacheOpts...)
+	return newCfg, nil
+}
+
+// String implements the fmt.Stringer interface method, printing only the
+// identity pool and classic flow role, never the logins
+func (p *CognitoIdentityProvider) String() string {
+	return fmt.Sprintf("CognitoIdentityProvider{Source: %q, RoleARN: %q}", sourceLabel(CognitoIdentityProviderName, p.identityPoolID), p.roleArn)
+}
+
+// GoString implements the fmt.GoStringer interface method, so %#v prints no more than String
+func (p *CognitoIdentityProvider) GoString() string {
+	return "&awsconfig." + p.String()
+}
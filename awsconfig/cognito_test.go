@@ -0,0 +1,233 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentity"
+	cognitotypes "github.com/aws/aws-sdk-go-v2/service/cognitoidentity/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigtest"
+)
+
+const (
+	testIdentityPoolID = "eu-west-1:11111111-2222-3333-4444-555555555555"
+	testIdentityID     = "eu-west-1:aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee"
+)
+
+func TestNewCognitoIdentityConf(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      []Option
+		wantKeyID string
+		wantCalls string
+	}{
+		{
+			name:      "Enhanced",
+			wantKeyID: "ASIACOGNITO",
+			wantCalls: "GetId GetCredentialsForIdentity GetCredentialsForIdentity",
+		},
+		{
+			name:      "Classic",
+			opts:      []Option{WithCognitoClassicFlow(testWebIdentityRoleArn)},
+			wantKeyID: "ASIAWEBIDENTITY",
+			wantCalls: "GetId GetOpenIdToken GetOpenIdToken",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &stubCognito{}
+			stsClient := &stubWebIdentitySTS{FakeSTS: &awsconfigtest.FakeSTS{}}
+			var tokens []string
+			logins := func(context.Context) (map[string]string, error) {
+				tokens = append(tokens, fmt.Sprintf("token-%d", len(tokens)+1))
+				return map[string]string{"accounts.google.com": tokens[len(tokens)-1]}, nil
+			}
+			opts := append([]Option{WithCognitoClient(client), WithSTSClient(stsClient)}, tt.opts...)
+
+			cfg, err := NewCognitoIdentityConf(context.Background(), aws.Config{}, testIdentityPoolID, logins, opts...)
+			if err != nil {
+				t.Fatalf("NewCognitoIdentityConf: %v", err)
+			}
+			creds, err := cfg.Credentials.Retrieve(context.Background())
+			if err != nil {
+				t.Fatalf("Retrieve: %v", err)
+			}
+			if creds.AccessKeyID != tt.wantKeyID {
+				t.Errorf("AccessKeyID %q, want %q", creds.AccessKeyID, tt.wantKeyID)
+			}
+			if want := sourceLabel(CognitoIdentityProviderName, testIdentityPoolID); creds.Source != want {
+				t.Errorf("Source %q, want %q", creds.Source, want)
+			}
+
+			// A refresh calls logins again and sends the renewed token
+			cfg.Credentials.(*aws.CredentialsCache).Invalidate()
+			if _, err := cfg.Credentials.Retrieve(context.Background()); err != nil {
+				t.Fatalf("Retrieve after refresh: %v", err)
+			}
+			if len(tokens) != 2 {
+				t.Fatalf("logins called %d times, want 2", len(tokens))
+			}
+			if got := strings.Join(client.calls, " "); got != tt.wantCalls {
+				t.Errorf("Cognito calls %q, want %q", got, tt.wantCalls)
+			}
+			if got := client.lastLogins["accounts.google.com"]; got != tokens[1] {
+				t.Errorf("refresh sent token %q, want %q", got, tokens[1])
+			}
+			if tt.name == "Classic" {
+				if len(stsClient.inputs) != 2 || aws.ToString(stsClient.inputs[1].WebIdentityToken) != "openid-"+tokens[1] {
+					t.Errorf("AssumeRoleWithWebIdentity inputs %+v", stsClient.inputs)
+				}
+			}
+		})
+	}
+}
+
+func TestCognitoIdentityProviderErrors(t *testing.T) {
+	errBoom := errors.New("boom")
+	tests := []struct {
+		name    string
+		client  *stubCognito
+		logins  CognitoLogins
+		wantErr error
+	}{
+		{
+			name:    "Logins",
+			client:  &stubCognito{},
+			logins:  func(context.Context) (map[string]string, error) { return nil, errBoom },
+			wantErr: ErrCognitoLogins,
+		},
+		{
+			name:    "GetId",
+			client:  &stubCognito{getIDErr: errBoom},
+			wantErr: ErrGetCognitoIdentity,
+		},
+		{
+			name:    "GetCredentialsForIdentity",
+			client:  &stubCognito{credentialsErr: errBoom},
+			wantErr: ErrGetCognitoCredentials,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewCognitoIdentityProvider(tt.client, nil, testIdentityPoolID, tt.logins, "")
+			_, err := p.Retrieve(context.Background())
+			if !errors.Is(err, tt.wantErr) || !errors.Is(err, errBoom) {
+				t.Errorf("Retrieve error %v, want %v wrapping %v", err, tt.wantErr, errBoom)
+			}
+		})
+	}
+}
+
+func TestCognitoIdentityProviderUnauthenticated(t *testing.T) {
+	client := &stubCognito{}
+	p := NewCognitoIdentityProvider(client, nil, testIdentityPoolID, nil, "")
+	if _, err := p.Retrieve(context.Background()); err != nil {
+		t.Fatalf("Retrieve: %v", err)
+	}
+	if client.lastLogins != nil {
+		t.Errorf("unauthenticated identity sent logins %v", client.lastLogins)
+	}
+}
+
+func TestCognitoIdentityProviderString(t *testing.T) {
+	p := NewCognitoIdentityProvider(&stubCognito{}, nil, testIdentityPoolID, StaticCognitoLogins(map[string]string{"idp": "secret-token"}), "")
+	for _, s := range []string{p.String(), p.GoString()} {
+		if strings.Contains(s, "secret-token") || !strings.Contains(s, testIdentityPoolID) {
+			t.Errorf("String() = %q", s)
+		}
+	}
+}
+
+// stubCognito is a CognitoIdentityAPIClient recording the operations called
+// and the logins last sent
+type stubCognito struct {
+	getIDErr       error
+	credentialsErr error
+
+	mu         sync.Mutex
+	calls      []string
+	lastLogins map[string]string
+}
+
+func (s *stubCognito) record(op string, logins map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, op)
+	s.lastLogins = logins
+}
+
+func (s *stubCognito) GetId(
+	_ context.Context,
+	params *cognitoidentity.GetIdInput,
+	_ ...func(*cognitoidentity.Options),
+) (*cognitoidentity.GetIdOutput, error) {
+	s.record("GetId", params.Logins)
+	if s.getIDErr != nil {
+		return nil, s.getIDErr
+	}
+	return &cognitoidentity.GetIdOutput{IdentityId: aws.String(testIdentityID)}, nil
+}
+
+func (s *stubCognito) GetCredentialsForIdentity(
+	_ context.Context,
+	params *cognitoidentity.GetCredentialsForIdentityInput,
+	_ ...func(*cognitoidentity.Options),
+) (*cognitoidentity.GetCredentialsForIdentityOutput, error) {
+	s.record("GetCredentialsForIdentity", params.Logins)
+	if s.credentialsErr != nil {
+		return nil, s.credentialsErr
+	}
+	return &cognitoidentity.GetCredentialsForIdentityOutput{
+		IdentityId: params.IdentityId,
+		Credentials: &cognitotypes.Credentials{
+			AccessKeyId:  aws.String("ASIACOGNITO"),
+			SecretKey:    aws.String("secret"),
+			SessionToken: aws.String("token"),
+			Expiration:   aws.Time(time.Now().Add(time.Hour)),
+		},
+	}, nil
+}
+
+func (s *stubCognito) GetOpenIdToken(
+	_ context.Context,
+	params *cognitoidentity.GetOpenIdTokenInput,
+	_ ...func(*cognitoidentity.Options),
+) (*cognitoidentity.GetOpenIdTokenOutput, error) {
+	s.record("GetOpenIdToken", params.Logins)
+	return &cognitoidentity.GetOpenIdTokenOutput{
+		IdentityId: params.IdentityId,
+		Token:      aws.String("openid-" + params.Logins["accounts.google.com"]),
+	}, nil
+}
+
+// stubWebIdentitySTS adds AssumeRoleWithWebIdentity, recording its inputs, to FakeSTS
+type stubWebIdentitySTS struct {
+	*awsconfigtest.FakeSTS
+
+	inputs []sts.AssumeRoleWithWebIdentityInput
+}
+
+func (s *stubWebIdentitySTS) AssumeRoleWithWebIdentity(
+	_ context.Context,
+	params *sts.AssumeRoleWithWebIdentityInput,
+	_ ...func(*sts.Options),
+) (*sts.AssumeRoleWithWebIdentityOutput, error) {
+	s.inputs = append(s.inputs, *params)
+	return &sts.AssumeRoleWithWebIdentityOutput{
+		Credentials: &ststypes.Credentials{
+			AccessKeyId:     aws.String("ASIAWEBIDENTITY"),
+			SecretAccessKey: aws.String("secret"),
+			SessionToken:    aws.String("token"),
+			Expiration:      aws.Time(time.Now().Add(time.Hour)),
+		},
+	}, nil
+}
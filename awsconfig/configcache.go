@@ -0,0 +1,193 @@
+package awsconfig
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+// ConfigCache memoizes the configs built by NewAssumeRoleConf per role ARN, base
+// config and AssumeRole request, so every caller of a role shares one credentials
+// cache and one refresh schedule. It is safe for concurrent use.
+type ConfigCache struct {
+	idleTTL time.Duration
+
+	mu      sync.Mutex
+	entries map[configCacheKey]*configCacheEntry
+}
+
+// configCacheKey identifies the configs a ConfigCache shares
+type configCacheKey struct {
+	roleArn string
+	// credentials and stsClient are the base credentials provider and injected
+	// STS client, compared by identity
+	credentials aws.CredentialsProvider
+	stsClient   STSClient
+	// request is the encoded configCacheRequest
+	request string
+}
+
+// configCacheRequest is what else a ConfigCache config depends on: where STS
+// calls go and the AssumeRole request made
+type configCacheRequest struct {
+	Region                string
+	STSRegion             string
+	BaseEndpoint          string
+	RoleSessionName       string
+	SessionNameFromCaller bool
+	Duration              time.Duration
+	ExternalID            *string
+	Policy                *string
+	PolicyARNs            []types.PolicyDescriptorType
+	SerialNumber          *string
+	SourceIdentity        *string
+	Tags                  []types.Tag
+	TransitiveTagKeys     []string
+	DenyStatements        []policyStatement
+}
+
+// newConfigCacheKey returns the key of the config of roleArn built from cfg with
+// conf, or false when the base credentials or STS client can't be compared
+func newConfigCacheKey(cfg aws.Config, roleArn string, conf *confOptions) (configCacheKey, bool) {
+	if !comparableValue(cfg.Credentials) || !comparableValue(conf.stsClient) {
+		return configCacheKey{}, false
+	}
+	effective := effectiveAssumeRoleOptions(roleArn, conf)
+	request, err := json.Marshal(configCacheRequest{
+		Region:                cfg.Region,
+		STSRegion:             stsRegion(cfg, conf),
+		BaseEndpoint:          aws.ToString(cfg.BaseEndpoint),
+		RoleSessionName:       effective.RoleSessionName,
+		SessionNameFromCaller: conf.sessionNameFromCaller,
+		Duration:              effective.Duration,
+		ExternalID:            effective.ExternalID,
+		Policy:                effective.Policy,
+		PolicyARNs:            effective.PolicyARNs,
+		SerialNumber:          effective.SerialNumber,
+		SourceIdentity:        effective.SourceIdentity,
+		Tags:                  effective.Tags,
+		TransitiveTagKeys:     effective.TransitiveTagKeys,
+		DenyStatements:        conf.denyStatements,
+	})
+	if err != nil {
+		return configCacheKey{}, false
+	}
+	return configCacheKey{
+		roleArn:     roleArn,
+		credentials: cfg.Credentials,
+		stsClient:   conf.stsClient,
+		request:     string(request),
+	}, true
+}
+
+// comparableValue reports whether v, such as a pointer, can be used in a map key
+func comparableValue(v any) bool {
+	return v == nil || reflect.TypeOf(v).Comparable()
+}
+
+// configCacheEntry is a memoized config, or the build of one in progress
+type configCacheEntry struct {
+	cfg      *aws.Config
+	lastUsed time.Time
+	inflight *buildCall
+}
+
+// NewConfigCache returns a ConfigCache evicting configs not used for idleTTL;
+// zero keeps them forever
+func NewConfigCache(idleTTL time.Duration) *ConfigCache {
+	return &ConfigCache{
+		idleTTL: idleTTL,
+		entries: make(map[configCacheKey]*configCacheEntry),
+	}
+}
+
+// Get returns the memoized config of roleArn, building it with NewAssumeRoleConf
+// on first use. Calls share a config when they pass the same base credentials
+// provider and STS client, the same base region and endpoint, and options making
+// the same AssumeRole request; options that only affect how the config behaves,
+// such as logging or metrics, are those of the call that built it. Base
+// credentials providers that can't be compared, such as an
+// aws.CredentialsProviderFunc, are not memoized. Concurrent callers share a
+// single build and failed builds are not memoized.
+func (c *ConfigCache) Get(ctx context.Context, cfg aws.Config, roleArn string, opts ...Option) (aws.Config, error) {
+	key, ok := newConfigCacheKey(cfg, roleArn, newConfOptions(opts))
+	if !ok {
+		return NewAssumeRoleConf(ctx, cfg, roleArn, opts...)
+	}
+
+	c.mu.Lock()
+	now := timeNow()
+	c.evictIdle(now)
+	entry, ok := c.entries[key]
+	if !ok {
+		entry = &configCacheEntry{}
+		c.entries[key] = entry
+	}
+	entry.lastUsed = now
+	if entry.cfg != nil {
+		cached := *entry.cfg
+		c.mu.Unlock()
+		return cached, nil
+	}
+	call := entry.inflight
+	if call == nil {
+		call = &buildCall{done: make(chan struct{})}
+		entry.inflight = call
+		go c.build(context.WithoutCancel(ctx), key, entry, call, cfg, opts)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.cfg, call.err
+	case <-ctx.Done():
+		return aws.Config{}, ctx.Err()
+	}
+}
+
+// build runs NewAssumeRoleConf for call, memoizing the result unless it failed
+func (c *ConfigCache) build(
+	ctx context.Context,
+	key configCacheKey,
+	entry *configCacheEntry,
+	call *buildCall,
+	cfg aws.Config,
+	opts []Option,
+) {
+	call.cfg, call.err = NewAssumeRoleConf(ctx, cfg, key.roleArn, opts...)
+
+	c.mu.Lock()
+	entry.inflight = nil
+	if call.err == nil {
+		built := call.cfg
+		entry.cfg = &built
+	} else if c.entries[key] == entry {
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+	close(call.done)
+}
+
+// evictIdle drops the configs not used for the idle TTL; c.mu must be held
+func (c *ConfigCache) evictIdle(now time.Time) {
+	if c.idleTTL <= 0 {
+		return
+	}
+	for key, entry := range c.entries {
+		if entry.inflight == nil && now.Sub(entry.lastUsed) > c.idleTTL {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// Len returns the number of configs memoized or being built
+func (c *ConfigCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
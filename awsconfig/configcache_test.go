@@ -0,0 +1,192 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/smithy-go"
+
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigtest"
+)
+
+func TestConfigCacheConcurrentGet(t *testing.T) {
+	fake := &awsconfigtest.FakeSTS{}
+	cache := NewConfigCache(0)
+	base := aws.Config{Region: "us-east-1", Credentials: credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", "")}
+
+	var wg sync.WaitGroup
+	configs := make([]aws.Config, 10)
+	for i := range configs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cfg, err := cache.Get(context.Background(), base, testRoleArn, WithSTSClient(fake), WithRoleSessionName("shared"))
+			if err != nil {
+				t.Errorf("Get: %v", err)
+			}
+			configs[i] = cfg
+		}()
+	}
+	wg.Wait()
+
+	if got := fake.CallerIdentityCalls(); got != 1 {
+		t.Errorf("GetCallerIdentity called %d times for 10 concurrent Gets, want 1", got)
+	}
+	for i, cfg := range configs {
+		if cfg.Credentials != configs[0].Credentials {
+			t.Fatalf("Get %d returned a config with its own credentials cache", i)
+		}
+	}
+	if got := cache.Len(); got != 1 {
+		t.Errorf("cache holds %d configs, want 1", got)
+	}
+}
+
+func TestConfigCacheKey(t *testing.T) {
+	fake := &awsconfigtest.FakeSTS{}
+	creds := credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", "")
+	base := aws.Config{Region: "us-east-1", Credentials: creds}
+	otherCreds := base.Copy()
+	otherCreds.Credentials = credentials.NewStaticCredentialsProvider("AKIDOTHER", "secret", "")
+	otherRegion := base.Copy()
+	otherRegion.Region = "us-west-2"
+	baseOpts := []Option{WithSTSClient(fake), WithRoleSessionName("shared")}
+
+	tests := []struct {
+		name     string
+		cfg      aws.Config
+		roleArn  string
+		opts     []Option
+		wantSame bool
+	}{
+		{name: "Same", cfg: base, wantSame: true},
+		{name: "SameOptionsAgain", cfg: base, opts: []Option{WithRoleSessionName("shared")}, wantSame: true},
+		{name: "OtherRole", cfg: base, roleArn: "arn:aws:iam::123456789012:role/Other"},
+		{name: "OtherSessionName", cfg: base, opts: []Option{WithRoleSessionName("other")}},
+		{name: "OtherBaseCredentials", cfg: otherCreds},
+		{name: "OtherRegion", cfg: otherRegion},
+		{name: "OtherSTSRegion", cfg: base, opts: []Option{WithSTSRegion("us-west-2")}},
+		{name: "Policy", cfg: base, opts: []Option{WithPolicy(testPolicy)}},
+		{name: "Duration", cfg: base, opts: []Option{WithDuration(2 * time.Hour)}},
+		{name: "Tags", cfg: base, opts: []Option{WithTag("team", "core")}},
+		{name: "ExternalID", cfg: base, opts: []Option{WithExternalID("vendor-id")}},
+		{name: "ReadOnly", cfg: base, opts: []Option{WithReadOnlyPolicy()}},
+		{name: "OtherSTSClient", cfg: base, opts: []Option{WithSTSClient(&awsconfigtest.FakeSTS{})}},
+	}
+	cache := NewConfigCache(0)
+	first, err := cache.Get(context.Background(), base, testRoleArn, baseOpts...)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roleArn := tt.roleArn
+			if roleArn == "" {
+				roleArn = testRoleArn
+			}
+			cfg, err := cache.Get(context.Background(), tt.cfg, roleArn, append(baseOpts, tt.opts...)...)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if same := cfg.Credentials == first.Credentials; same != tt.wantSame {
+				t.Errorf("shares the first config %t, want %t", same, tt.wantSame)
+			}
+		})
+	}
+}
+
+func TestConfigCacheSessionNameFromCaller(t *testing.T) {
+	// Each base identity derives its own session name from its caller ARN
+	alice := &awsconfigtest.FakeSTS{}
+	alice.Identity.Arn = aws.String("arn:aws:iam::111111111111:user/alice")
+	bob := &awsconfigtest.FakeSTS{}
+	bob.Identity.Arn = aws.String("arn:aws:iam::111111111111:user/bob")
+	base := aws.Config{Region: "us-east-1", Credentials: credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", "")}
+
+	cache := NewConfigCache(0)
+	sessionNames := map[string]bool{}
+	for _, fake := range []*awsconfigtest.FakeSTS{alice, bob} {
+		cfg, err := cache.Get(context.Background(), base, testRoleArn, WithSTSClient(fake), WithSessionNameFromCaller())
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if _, err := cfg.Credentials.Retrieve(context.Background()); err != nil {
+			t.Fatalf("Retrieve: %v", err)
+		}
+		sessionNames[aws.ToString(fake.AssumeRoleInputs()[0].RoleSessionName)] = true
+	}
+	if len(sessionNames) != 2 {
+		t.Errorf("callers assumed the role as %v, want a session name each", sessionNames)
+	}
+}
+
+func TestConfigCacheErrorsNotCached(t *testing.T) {
+	denied := &smithy.GenericAPIError{Code: "AccessDenied", Message: "denied"}
+	fake := &awsconfigtest.FakeSTS{CallerIdentityErr: denied}
+	cache := NewConfigCache(0)
+	if _, err := cache.Get(context.Background(), aws.Config{}, testRoleArn, WithSTSClient(fake)); !errors.Is(err, denied) {
+		t.Fatalf("Get error %v, want %v", err, denied)
+	}
+	if got := cache.Len(); got != 0 {
+		t.Errorf("cache holds %d configs after a failed build, want 0", got)
+	}
+	fake.CallerIdentityErr = nil
+	if _, err := cache.Get(context.Background(), aws.Config{}, testRoleArn, WithSTSClient(fake)); err != nil {
+		t.Fatalf("Get after the failure: %v", err)
+	}
+	if got := fake.CallerIdentityCalls(); got != 2 {
+		t.Errorf("GetCallerIdentity called %d times, want 2", got)
+	}
+}
+
+func TestConfigCacheIdleEviction(t *testing.T) {
+	advance := stubClock(t)
+	fake := &awsconfigtest.FakeSTS{}
+	cache := NewConfigCache(10 * time.Minute)
+	other := "arn:aws:iam::123456789012:role/Other"
+
+	steps := []struct {
+		name    string
+		advance time.Duration
+		roleArn string
+		wantLen int
+	}{
+		{name: "Built", roleArn: testRoleArn, wantLen: 1},
+		{name: "OtherBuilt", advance: 5 * time.Minute, roleArn: other, wantLen: 2},
+		{name: "UsedKeepsAlive", advance: 6 * time.Minute, roleArn: other, wantLen: 1},
+		{name: "IdleEvicted", advance: 11 * time.Minute, roleArn: testRoleArn, wantLen: 1},
+	}
+	for _, step := range steps {
+		advance(step.advance)
+		if _, err := cache.Get(context.Background(), aws.Config{}, step.roleArn, WithSTSClient(fake), WithSkipIdentityCheck()); err != nil {
+			t.Fatalf("%s: Get: %v", step.name, err)
+		}
+		if got := cache.Len(); got != step.wantLen {
+			t.Errorf("%s: cache holds %d configs, want %d", step.name, got, step.wantLen)
+		}
+	}
+}
+
+func TestConfigCacheUncomparableCredentials(t *testing.T) {
+	fake := &awsconfigtest.FakeSTS{}
+	base := aws.Config{Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return aws.Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret"}, nil
+	})}
+	cache := NewConfigCache(0)
+	for range 2 {
+		if _, err := cache.Get(context.Background(), base, testRoleArn, WithSTSClient(fake)); err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+	}
+	if got := cache.Len(); got != 0 {
+		t.Errorf("cache holds %d configs, want 0", got)
+	}
+	if got := fake.CallerIdentityCalls(); got != 2 {
+		t.Errorf("GetCallerIdentity called %d times, want 2", got)
+	}
+}
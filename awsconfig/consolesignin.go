@@ -0,0 +1,157 @@
+package awsconfig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+var (
+	// ErrConsoleRequiresSession is returned when console sign-in is attempted with long-term credentials
+	ErrConsoleRequiresSession = errors.New("Console sign-in requires temporary session credentials")
+	// ErrConsoleUnsupportedPartition is returned for a region whose partition has no federation endpoint
+	ErrConsoleUnsupportedPartition = errors.New("No console federation endpoint for partition")
+	// ErrConsoleSignInToken is returned when the federation endpoint doesn't return a sign-in token
+	ErrConsoleSignInToken = errors.New("Cannot get console sign-in token")
+)
+
+// consoleEndpoints are the federation and console URLs of each partition
+var consoleEndpoints = map[string]struct {
+	federation string
+	console    string
+}{
+	"aws":        {"https://signin.aws.amazon.com/federation", "https://console.aws.amazon.com/"},
+	"aws-us-gov": {"https://signin.amazonaws-us-gov.com/federation", "https://console.amazonaws-us-gov.com/"},
+	"aws-cn":     {"https://signin.amazonaws.cn/federation", "https://console.amazonaws.cn/"},
+}
+
+// WithConsoleIssuer sets the Issuer of console sign-in URLs, the page users are
+// sent back to when their console session expires
+func WithConsoleIssuer(issuer string) ConfOption {
+	return func(c *confOptions) {
+		c.consoleIssuer = issuer
+	}
+}
+
+// WithFederationEndpoint sends ConsoleSignInURL to endpoint instead of the
+// federation endpoint of the partition of the config's region
+func WithFederationEndpoint(endpoint string) ConfOption {
+	return func(c *confOptions) {
+		c.federationEndpoint = endpoint
+	}
+}
+
+// ConsoleSignInURL exchanges the session credentials of cfg for a sign-in token
+// at the federation endpoint of the partition of cfg's region, and returns the
+// URL signing into the AWS console as the same session, landing on
+// destinationURL, or the console home page when empty. sessionDuration sets
+// how long the console session lasts, 15m to 12h; zero leaves it to the
+// federation endpoint. It must be zero for credentials from role chaining.
+func ConsoleSignInURL(
+	ctx context.Context,
+	cfg aws.Config,
+	destinationURL string,
+	sessionDuration time.Duration,
+	opts ...Option,
+) (string, error) {
+	conf := newConfOptions(opts)
+	if err := validateDuration(sessionDuration); err != nil {
+		return "", err
+	}
+	partition := regionPartition(cfg.Region)
+	endpoints, ok := consoleEndpoints[partition]
+	if !ok {
+		return "", fmt.Errorf("%w %s", ErrConsoleUnsupportedPartition, partition)
+	}
+	federation := endpoints.federation
+	if conf.federationEndpoint != "" {
+		federation = conf.federationEndpoint
+	}
+	if destinationURL == "" {
+		destinationURL = endpoints.console
+	}
+
+	if cfg.Credentials == nil {
+		return "", ErrNilCredentials
+	}
+	creds, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrRetrieveCredentials, err)
+	}
+	if creds.SessionToken == "" {
+		return "", ErrConsoleRequiresSession
+	}
+
+	session, err := json.Marshal(map[string]string{
+		"sessionId":    creds.AccessKeyID,
+		"sessionKey":   creds.SecretAccessKey,
+		"sessionToken": creds.SessionToken,
+	})
+	if err != nil {
+		return "", err
+	}
+	query := url.Values{
+		"Action":  {"getSigninToken"},
+		"Session": {string(session)},
+	}
+	if sessionDuration != 0 {
+		query.Set("SessionDuration", strconv.Itoa(int(sessionDuration/time.Second)))
+	}
+	token, err := getSigninToken(ctx, cfg, federation+"?"+query.Encode())
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrConsoleSignInToken, err)
+	}
+
+	query = url.Values{
+		"Action":      {"login"},
+		"Destination": {destinationURL},
+		"SigninToken": {token},
+	}
+	if conf.consoleIssuer != "" {
+		query.Set("Issuer", conf.consoleIssuer)
+	}
+	return federation + "?" + query.Encode(), nil
+}
+
+// getSigninToken calls the getSigninToken federation URL with the HTTP client of cfg
+func getSigninToken(ctx context.Context, cfg aws.Config, federationURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, federationURL, nil)
+	if err != nil {
+		return "", err
+	}
+	var client aws.HTTPClient = http.DefaultClient
+	if cfg.HTTPClient != nil {
+		client = cfg.HTTPClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("federation endpoint returned %s", resp.Status)
+	}
+
+	var out struct {
+		SigninToken string
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return "", err
+	}
+	if out.SigninToken == "" {
+		return "", errors.New("response has no SigninToken")
+	}
+	return out.SigninToken, nil
+}
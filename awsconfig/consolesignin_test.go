@@ -0,0 +1,189 @@
+package awsconfig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+func TestConsoleSignInURL(t *testing.T) {
+	session := credentials.NewStaticCredentialsProvider("ASIAEXAMPLE", "secret", "token")
+
+	tests := []struct {
+		name            string
+		region          string
+		destination     string
+		duration        time.Duration
+		opts            []Option
+		wantFederation  string
+		wantDestination string
+		wantDuration    string
+		wantIssuer      string
+	}{
+		{
+			name:            "Commercial",
+			region:          "eu-west-1",
+			wantFederation:  "https://signin.aws.amazon.com/federation",
+			wantDestination: "https://console.aws.amazon.com/",
+		},
+		{
+			name:            "GovCloud",
+			region:          "us-gov-west-1",
+			wantFederation:  "https://signin.amazonaws-us-gov.com/federation",
+			wantDestination: "https://console.amazonaws-us-gov.com/",
+		},
+		{
+			name:            "China",
+			region:          "cn-north-1",
+			wantFederation:  "https://signin.amazonaws.cn/federation",
+			wantDestination: "https://console.amazonaws.cn/",
+		},
+		{
+			name:            "DestinationDurationIssuer",
+			region:          "us-east-1",
+			destination:     "https://console.aws.amazon.com/s3/home",
+			duration:        2 * time.Hour,
+			opts:            []Option{WithConsoleIssuer("https://tools.example.com/")},
+			wantFederation:  "https://signin.aws.amazon.com/federation",
+			wantDestination: "https://console.aws.amazon.com/s3/home",
+			wantDuration:    "7200",
+			wantIssuer:      "https://tools.example.com/",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &federationClient{token: "signin-token"}
+			cfg := aws.Config{Region: tt.region, Credentials: session, HTTPClient: client}
+			got, err := ConsoleSignInURL(context.Background(), cfg, tt.destination, tt.duration, tt.opts...)
+			if err != nil {
+				t.Fatalf("ConsoleSignInURL: %v", err)
+			}
+
+			// The token request carries the session and duration
+			if len(client.requests) != 1 {
+				t.Fatalf("federation endpoint called %d times, want 1", len(client.requests))
+			}
+			req := client.requests[0]
+			if endpoint := req.Scheme + "://" + req.Host + req.Path; endpoint != tt.wantFederation {
+				t.Errorf("token requested from %s, want %s", endpoint, tt.wantFederation)
+			}
+			query := req.Query()
+			var session map[string]string
+			if err := json.Unmarshal([]byte(query.Get("Session")), &session); err != nil {
+				t.Fatalf("Session parameter: %v", err)
+			}
+			if session["sessionId"] != "ASIAEXAMPLE" || session["sessionKey"] != "secret" || session["sessionToken"] != "token" {
+				t.Errorf("Session = %v", session)
+			}
+			if query.Get("Action") != "getSigninToken" || query.Get("SessionDuration") != tt.wantDuration {
+				t.Errorf("token request query %v", query)
+			}
+
+			// The login URL carries the token
+			login, err := url.Parse(got)
+			if err != nil {
+				t.Fatalf("login URL: %v", err)
+			}
+			if endpoint := login.Scheme + "://" + login.Host + login.Path; endpoint != tt.wantFederation {
+				t.Errorf("login URL endpoint %s, want %s", endpoint, tt.wantFederation)
+			}
+			want := url.Values{"Action": {"login"}, "Destination": {tt.wantDestination}, "SigninToken": {"signin-token"}}
+			if tt.wantIssuer != "" {
+				want.Set("Issuer", tt.wantIssuer)
+			}
+			if login.Query().Encode() != want.Encode() {
+				t.Errorf("login URL query %v, want %v", login.Query(), want)
+			}
+		})
+	}
+}
+
+func TestConsoleSignInURLFederationEndpoint(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"SigninToken":"stub-token"}`)
+	}))
+	defer srv.Close()
+	cfg := aws.Config{Credentials: credentials.NewStaticCredentialsProvider("ASIAEXAMPLE", "secret", "token")}
+
+	got, err := ConsoleSignInURL(context.Background(), cfg, "", 0, WithFederationEndpoint(srv.URL+"/federation"))
+	if err != nil {
+		t.Fatalf("ConsoleSignInURL: %v", err)
+	}
+	if !strings.HasPrefix(got, srv.URL+"/federation?") || !strings.Contains(got, "SigninToken=stub-token") {
+		t.Errorf("ConsoleSignInURL = %s", got)
+	}
+}
+
+func TestConsoleSignInURLErrors(t *testing.T) {
+	session := credentials.NewStaticCredentialsProvider("ASIAEXAMPLE", "secret", "token")
+
+	tests := []struct {
+		name     string
+		region   string
+		provider aws.CredentialsProvider
+		duration time.Duration
+		client   *federationClient
+		wantErr  error
+	}{
+		{name: "LongTermCredentials", provider: credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", ""), wantErr: ErrConsoleRequiresSession},
+		{name: "NilCredentials", wantErr: ErrNilCredentials},
+		{name: "ISOPartition", region: "us-iso-east-1", provider: session, wantErr: ErrConsoleUnsupportedPartition},
+		{name: "DurationTooShort", provider: session, duration: time.Minute, wantErr: ErrInvalidDuration},
+		{name: "Rejected", provider: session, client: &federationClient{status: http.StatusBadRequest}, wantErr: ErrConsoleSignInToken},
+		{name: "NoToken", provider: session, client: &federationClient{}, wantErr: ErrConsoleSignInToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := tt.client
+			if client == nil {
+				client = &federationClient{token: "signin-token"}
+			}
+			cfg := aws.Config{Region: tt.region, Credentials: tt.provider, HTTPClient: client}
+			if _, err := ConsoleSignInURL(context.Background(), cfg, "", tt.duration); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ConsoleSignInURL error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// federationClient answers getSigninToken requests with token, or fails them
+// with status, recording their URLs
+type federationClient struct {
+	token  string
+	status int
+
+	mu       sync.Mutex
+	requests []*url.URL
+}
+
+// Do implements the aws.HTTPClient interface method
+func (c *federationClient) Do(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	c.requests = append(c.requests, req.URL)
+	c.mu.Unlock()
+
+	status, body := http.StatusOK, "{}"
+	switch {
+	case c.status != 0:
+		status = c.status
+	case c.token != "":
+		body = `{"SigninToken":"` + c.token + `"}`
+	}
+	return &http.Response{
+		StatusCode: status,
+		Status:     http.StatusText(status),
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}, nil
+}
@@ -0,0 +1,100 @@
+package awsconfig
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// Environment variables read by the SDKs to find a container credentials endpoint
+const (
+	EnvContainerCredentialsFullURI = "AWS_CONTAINER_CREDENTIALS_FULL_URI"
+	EnvContainerAuthorizationToken = "AWS_CONTAINER_AUTHORIZATION_TOKEN"
+)
+
+// containerCredentials is the credentials document of the ECS container
+// credentials endpoint; Expiration is left out for credentials that cannot expire
+type containerCredentials struct {
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string
+	Token           string `json:",omitempty"`
+	Expiration      string `json:",omitempty"`
+}
+
+// NewContainerCredentialsServer returns an http.Handler serving the credentials
+// of cfg at "/" as the ECS container credentials endpoint does, so processes
+// pointed at it with ContainerCredentialsEnv get them without files. The
+// credentials are retrieved for each request. When authToken is set, requests
+// must carry it in their Authorization header.
+func NewContainerCredentialsServer(cfg aws.Config, authToken string) http.Handler {
+	return &containerCredentialsServer{credentials: cfg.Credentials, authToken: authToken}
+}
+
+// ContainerCredentialsEnv returns the environment variables making the SDKs of
+// a child process get credentials from the NewContainerCredentialsServer at url
+func ContainerCredentialsEnv(url, authToken string) map[string]string {
+	env := map[string]string{
+		EnvContainerCredentialsFullURI: url,
+	}
+	if authToken != "" {
+		env[EnvContainerAuthorizationToken] = authToken
+	}
+	return env
+}
+
+// containerCredentialsServer is the http.Handler of NewContainerCredentialsServer
+type containerCredentialsServer struct {
+	credentials aws.CredentialsProvider
+	authToken   string
+}
+
+// ServeHTTP implements the http.Handler interface method
+func (s *containerCredentialsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeContainerError(w, http.StatusMethodNotAllowed)
+		return
+	}
+	if s.authToken != "" &&
+		subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(s.authToken)) != 1 {
+		writeContainerError(w, http.StatusUnauthorized)
+		return
+	}
+	if s.credentials == nil {
+		writeContainerError(w, http.StatusInternalServerError)
+		return
+	}
+	creds, err := s.credentials.Retrieve(r.Context())
+	if err != nil {
+		writeContainerError(w, http.StatusInternalServerError)
+		return
+	}
+
+	doc := containerCredentials{
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		Token:           creds.SessionToken,
+	}
+	if creds.CanExpire {
+		doc.Expiration = creds.Expires.UTC().Format(time.RFC3339)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(doc)
+}
+
+// writeContainerError writes an error in the JSON shape the SDK container
+// credentials clients parse
+func writeContainerError(w http.ResponseWriter, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"code":    http.StatusText(status),
+		"message": http.StatusText(status),
+	})
+}
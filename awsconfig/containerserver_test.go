@@ -0,0 +1,137 @@
+package awsconfig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/endpointcreds"
+)
+
+func TestContainerCredentialsServer(t *testing.T) {
+	session := credentials.StaticCredentialsProvider{Value: aws.Credentials{
+		AccessKeyID: "ASIAEXAMPLE", SecretAccessKey: "secret", SessionToken: "token",
+		CanExpire: true, Expires: time.Date(2030, 1, 2, 4, 4, 5, 0, time.FixedZone("CET", 3600)),
+	}}
+	static := credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", "")
+
+	tests := []struct {
+		name       string
+		provider   aws.CredentialsProvider
+		authToken  string
+		method     string
+		path       string
+		header     string
+		wantStatus int
+		want       map[string]string
+	}{
+		{
+			name: "Authorized", provider: session, authToken: "s3cret", header: "s3cret", wantStatus: http.StatusOK,
+			want: map[string]string{
+				"AccessKeyId": "ASIAEXAMPLE", "SecretAccessKey": "secret", "Token": "token",
+				"Expiration": "2030-01-02T03:04:05Z",
+			},
+		},
+		{
+			name: "NoTokenConfigured", provider: static, wantStatus: http.StatusOK,
+			want: map[string]string{"AccessKeyId": "AKIDEXAMPLE", "SecretAccessKey": "secret"},
+		},
+		{
+			name: "MissingAuthorization", provider: session, authToken: "s3cret", wantStatus: http.StatusUnauthorized,
+			want: map[string]string{"code": "Unauthorized", "message": "Unauthorized"},
+		},
+		{
+			name: "WrongAuthorization", provider: session, authToken: "s3cret", header: "guess", wantStatus: http.StatusUnauthorized,
+			want: map[string]string{"code": "Unauthorized", "message": "Unauthorized"},
+		},
+		{name: "OtherPath", provider: session, path: "/v2/credentials", wantStatus: http.StatusNotFound},
+		{name: "Post", provider: session, method: http.MethodPost, wantStatus: http.StatusMethodNotAllowed},
+		{name: "NilCredentials", wantStatus: http.StatusInternalServerError},
+		{
+			name: "RetrieveFails", wantStatus: http.StatusInternalServerError,
+			provider: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+				return aws.Credentials{}, errors.New("no credentials")
+			}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method, path := tt.method, tt.path
+			if method == "" {
+				method = http.MethodGet
+			}
+			if path == "" {
+				path = "/"
+			}
+			req := httptest.NewRequest(method, path, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			NewContainerCredentialsServer(aws.Config{Credentials: tt.provider}, tt.authToken).ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.want == nil {
+				return
+			}
+			var got map[string]string
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("body %q: %v", rec.Body.String(), err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("body %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContainerCredentialsServerSDKClient(t *testing.T) {
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	session := credentials.StaticCredentialsProvider{Value: aws.Credentials{
+		AccessKeyID: "ASIAEXAMPLE", SecretAccessKey: "secret", SessionToken: "token",
+		CanExpire: true, Expires: expires,
+	}}
+	srv := httptest.NewServer(NewContainerCredentialsServer(aws.Config{Credentials: session}, "s3cret"))
+	defer srv.Close()
+
+	env := ContainerCredentialsEnv(srv.URL+"/", "s3cret")
+	provider := endpointcreds.New(env[EnvContainerCredentialsFullURI], func(o *endpointcreds.Options) {
+		o.AuthorizationToken = env[EnvContainerAuthorizationToken]
+	})
+	creds, err := provider.Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("SDK client Retrieve: %v", err)
+	}
+	if creds.AccessKeyID != "ASIAEXAMPLE" || creds.SessionToken != "token" || !creds.Expires.Equal(expires) {
+		t.Errorf("SDK client got %+v", creds)
+	}
+}
+
+func TestContainerCredentialsEnv(t *testing.T) {
+	tests := []struct {
+		name      string
+		authToken string
+		want      map[string]string
+	}{
+		{
+			name: "WithToken", authToken: "s3cret",
+			want: map[string]string{EnvContainerCredentialsFullURI: "http://127.0.0.1:8080/", EnvContainerAuthorizationToken: "s3cret"},
+		},
+		{name: "WithoutToken", want: map[string]string{EnvContainerCredentialsFullURI: "http://127.0.0.1:8080/"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ContainerCredentialsEnv("http://127.0.0.1:8080/", tt.authToken); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ContainerCredentialsEnv = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
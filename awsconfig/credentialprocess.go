@@ -0,0 +1,60 @@
+package awsconfig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+var (
+	// ErrNilCredentials is returned when an aws.Config has no credentials provider
+	ErrNilCredentials = errors.New("Passed aws.Config has no credentials provider")
+	// ErrRetrieveCredentials is returned when the credentials of an aws.Config cannot be retrieved
+	ErrRetrieveCredentials = errors.New("Cannot retrieve credentials from passed aws.Config")
+)
+
+const credentialProcessVersion = 1
+
+// credentialProcessOutput is the documented credential_process JSON shape
+type credentialProcessOutput struct {
+	Version         int
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string
+	SessionToken    string `json:",omitempty"`
+	Expiration      string `json:",omitempty"`
+}
+
+// WriteCredentialProcessJSON retrieves the credentials of cfg and writes them to w
+// in the JSON format expected by the AWS CLI credential_process setting.
+func WriteCredentialProcessJSON(ctx context.Context, cfg aws.Config, w io.Writer) error {
+	if cfg.Credentials == nil {
+		return ErrNilCredentials
+	}
+	creds, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrRetrieveCredentials, err)
+	}
+
+	out := credentialProcessOutput{
+		Version:         credentialProcessVersion,
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+	}
+	if creds.CanExpire {
+		out.Expiration = creds.Expires.UTC().Format(time.RFC3339)
+	}
+
+	// Marshal fully before writing so w never sees partial JSON
+	b, err := json.Marshal(out)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
@@ -0,0 +1,101 @@
+package awsconfig
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestWriteCredentialProcessJSON(t *testing.T) {
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name           string
+		creds          aws.Credentials
+		wantExpiration string
+	}{
+		{
+			"Expiring",
+			aws.Credentials{AccessKeyID: "ASIAFAKE", SecretAccessKey: "secret", SessionToken: "token", CanExpire: true, Expires: expires},
+			"2030-01-02T03:04:05Z",
+		},
+		{
+			"NonExpiring",
+			aws.Credentials{AccessKeyID: "AKIAFAKE", SecretAccessKey: "secret"},
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := aws.Config{Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+				return tt.creds, nil
+			})}
+			var buf bytes.Buffer
+			if err := WriteCredentialProcessJSON(context.Background(), cfg, &buf); err != nil {
+				t.Fatalf("WriteCredentialProcessJSON: %v", err)
+			}
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("output %q is not JSON: %v", buf.String(), err)
+			}
+			if got["Version"] != float64(1) || got["AccessKeyId"] != tt.creds.AccessKeyID ||
+				got["SecretAccessKey"] != tt.creds.SecretAccessKey {
+				t.Errorf("output %s does not match %+v", buf.String(), tt.creds)
+			}
+			if token, ok := got["SessionToken"]; ok != (tt.creds.SessionToken != "") || ok && token != tt.creds.SessionToken {
+				t.Errorf("output %s has SessionToken %v, want %q", buf.String(), token, tt.creds.SessionToken)
+			}
+			expiration, ok := got["Expiration"]
+			if tt.wantExpiration == "" && ok || tt.wantExpiration != "" && expiration != tt.wantExpiration {
+				t.Errorf("output %s has Expiration %v, want %q", buf.String(), expiration, tt.wantExpiration)
+			}
+
+			// Round trip through the documented shape
+			var parsed credentialProcessOutput
+			if err := json.Unmarshal(buf.Bytes(), &parsed); err != nil {
+				t.Fatalf("parsing output back: %v", err)
+			}
+			want := credentialProcessOutput{
+				Version:         1,
+				AccessKeyID:     tt.creds.AccessKeyID,
+				SecretAccessKey: tt.creds.SecretAccessKey,
+				SessionToken:    tt.creds.SessionToken,
+				Expiration:      tt.wantExpiration,
+			}
+			if parsed != want {
+				t.Errorf("round trip returned %+v, want %+v", parsed, want)
+			}
+		})
+	}
+}
+
+func TestWriteCredentialProcessJSONErrors(t *testing.T) {
+	failing := aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return aws.Credentials{}, errors.New("refresh failed")
+	})
+	tests := []struct {
+		name    string
+		cfg     aws.Config
+		wantErr error
+	}{
+		{"NilCredentials", aws.Config{}, ErrNilCredentials},
+		{"RetrieveError", aws.Config{Credentials: failing}, ErrRetrieveCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := WriteCredentialProcessJSON(context.Background(), tt.cfg, &buf)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("WriteCredentialProcessJSON error %v, want %v", err, tt.wantErr)
+			}
+			if buf.Len() != 0 {
+				t.Errorf("WriteCredentialProcessJSON wrote %q on error", buf.String())
+			}
+		})
+	}
+}
@@ -2,50 +2,300 @@ package awsconfig
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 )
 
+// CustomFunctionProviderName prefixes the Source of credentials returned by
+// CustomFunctionProvider, followed by the name set with WithCustomFunctionName
+const CustomFunctionProviderName = SourcePrefix + "customfunction"
+
+var (
+	// ErrRetrieveTimeout is returned when a custom retrieve function exceeds WithRetrieveTimeout
+	ErrRetrieveTimeout = errors.New("CustomFunctionProvider retrieve timed out")
+	// ErrRetrieveFailed is returned when a custom retrieve function fails all WithRetrieveRetries attempts
+	ErrRetrieveFailed = errors.New("CustomFunctionProvider retrieve failed")
+	// ErrNilRetrieveFunc is returned when a CustomFunctionProvider has no retrieve function
+	ErrNilRetrieveFunc = errors.New("Custom function provider requires a non-nil retrieve function")
+	// ErrCredentialsExpired is returned when a retrieve function returns already-expired credentials
+	ErrCredentialsExpired = errors.New("Retrieved credentials are already expired")
+	// ErrNonExpiringCredentials is passed to the warning handler when a retrieve
+	// function returns credentials that cannot expire, so they are cached forever.
+	ErrNonExpiringCredentials = errors.New("Retrieved credentials cannot expire and will be cached permanently")
+	// ErrPermanent may be wrapped by a retrieve function's error to stop further retries
+	ErrPermanent = errors.New("Permanent credential retrieval failure")
+	// ErrRetrievePanic is returned when a retrieve function panics
+	ErrRetrievePanic = errors.New("Custom function provider retrieve function panicked")
+)
+
 // CustomFunctionProvider implements the aws.CredentialsProvider interface
 type CustomFunctionProvider struct {
-	retrieve func(ctx context.Context) (aws.Credentials, error)
+	retrieve       func(ctx context.Context) (aws.Credentials, error)
+	forcedTTL      time.Duration
+	warn           func(error)
+	propagatePanic bool
+	timeout        time.Duration
+	retries        int
+	backoff        func(attempt int) time.Duration
+	validate       bool
+	source         string
+
+	// inflight is the retrieval concurrent callers share, if one is running
+	mu       sync.Mutex
+	inflight *retrieveCall
+}
+
+// retrieveCall is a retrieval shared by concurrent callers of Retrieve
+type retrieveCall struct {
+	done  chan struct{}
+	creds aws.Credentials
+	err   error
+
+	// cancel stops the retrieval once no caller is waiting on it, guarded by the provider's mu
+	cancel  context.CancelFunc
+	waiters int
 }
 
-// NewCustomFunctionProvider
 // NewCustomFunctionProvider initializes a new CustomFunctionProviderinstance and returns aws.CredentialsProvider interface.
 func NewCustomFunctionProvider(
 	retrieve func(ctx context.Context) (aws.Credentials, error),
+	opts ...Option,
 ) (aws.CredentialsProvider, error) {
+	return newCustomFunctionProvider(retrieve, newConfOptions(opts))
+}
+
+// newCustomFunctionProvider builds a CustomFunctionProvider from resolved options
+func newCustomFunctionProvider(
+	retrieve func(ctx context.Context) (aws.Credentials, error),
+	conf *confOptions,
+) (*CustomFunctionProvider, error) {
+	if retrieve == nil {
+		return nil, ErrNilRetrieveFunc
+	}
 	provider := &CustomFunctionProvider{
-		retrieve: retrieve,
+		retrieve:       retrieve,
+		forcedTTL:      conf.forcedTTL,
+		warn:           conf.warn,
+		propagatePanic: conf.propagatePanic,
+		timeout:        conf.retrieveTimeout,
+		retries:        conf.retrieveRetries,
+		backoff:        conf.retrieveBackoff,
+		validate:       conf.validateCredentials,
+		source:         sourceLabel(CustomFunctionProviderName, conf.customFunctionName),
 	}
 	return provider, nil
 }
 
-// Retrieve implements the aws.CredentialsProvider interface method
+// Retrieve implements the aws.CredentialsProvider interface method. Concurrent
+// callers share a single call of the retrieve function; a caller whose ctx is
+// done stops waiting, and the shared call is cancelled once no caller is left.
 func (p *CustomFunctionProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	// Guard against a zero-value or directly constructed provider
+	if p == nil || p.retrieve == nil {
+		return aws.Credentials{}, ErrNilRetrieveFunc
+	}
+
+	p.mu.Lock()
+	call := p.inflight
+	if call == nil {
+		// The shared call keeps the values of ctx but not its cancellation
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &retrieveCall{done: make(chan struct{}), cancel: cancel}
+		p.inflight = call
+		go func() {
+			call.creds, call.err = p.retrieveWithRetries(callCtx)
+			p.mu.Lock()
+			if p.inflight == call {
+				p.inflight = nil
+			}
+			p.mu.Unlock()
+			cancel()
+			close(call.done)
+		}()
+	}
+	call.waiters++
+	p.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.creds, call.err
+	case <-ctx.Done():
+		p.mu.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			// Nobody is left to use the result; later callers start afresh
+			call.cancel()
+			if p.inflight == call {
+				p.inflight = nil
+			}
+		}
+		p.mu.Unlock()
+		return aws.Credentials{}, ctx.Err()
+	}
+}
+
+// retrieveWithRetries calls the retrieve function, retrying failures as configured,
+// and checks the expiry of the credentials it returns
+func (p *CustomFunctionProvider) retrieveWithRetries(ctx context.Context) (aws.Credentials, error) {
+	var creds aws.Credentials
+	var err error
+	attempts := 0
+	for {
+		attempts++
+		creds, err = p.retrieveOnce(ctx)
+		if err == nil || attempts > p.retries || ctx.Err() != nil ||
+			errors.Is(err, ErrPermanent) || errors.Is(err, ErrRetrievePanic) {
+			break
+		}
+
+		// Back off before the next attempt, giving up early if ctx is done
+		var delay time.Duration
+		if p.backoff != nil {
+			delay = p.backoff(attempts)
+		}
+		if ctxErr := sleepContext(ctx, delay); ctxErr != nil {
+			err = fmt.Errorf("%w: %w", ctxErr, err)
+			break
+		}
+	}
+	if err != nil {
+		if p.retries > 0 {
+			err = fmt.Errorf("%w after %d attempt(s): %w", ErrRetrieveFailed, attempts, err)
+		}
+		return aws.Credentials{}, err
+	}
+
+	// Stamp a synthetic expiry on credentials that don't carry one
+	creds = withForcedTTL(creds, p.forcedTTL)
+	creds.Source = p.source
+
+	if creds.CanExpire && !creds.Expires.After(timeNow()) {
+		// Returning these would send the credentials cache into a refresh loop
+		return aws.Credentials{}, fmt.Errorf("%w: expired at %s", ErrCredentialsExpired, creds.Expires)
+	}
+	if p.validate {
+		if err := ValidateCredentials(creds); err != nil {
+			return aws.Credentials{}, err
+		}
+	}
+	if !creds.CanExpire && p.warn != nil {
+		p.warn(ErrNonExpiringCredentials)
+	}
+	return creds, nil
+}
+
+// retrieveOnce makes a single, optionally time-bounded, call to the retrieve function
+func (p *CustomFunctionProvider) retrieveOnce(ctx context.Context) (aws.Credentials, error) {
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+	creds, err := p.safeRetrieve(ctx)
+	if err != nil && p.timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		if !errors.Is(err, context.DeadlineExceeded) {
+			err = fmt.Errorf("%w: %w", ctx.Err(), err)
+		}
+		return aws.Credentials{}, fmt.Errorf("%w after %s: %w", ErrRetrieveTimeout, p.timeout, err)
+	}
+	return creds, err
+}
+
+// safeRetrieve calls the retrieve function, converting panics into errors unless disabled
+func (p *CustomFunctionProvider) safeRetrieve(ctx context.Context) (creds aws.Credentials, err error) {
+	if !p.propagatePanic {
+		defer func() {
+			if r := recover(); r != nil {
+				creds = aws.Credentials{}
+				err = fmt.Errorf("%w: %v\n%s", ErrRetrievePanic, r, debug.Stack())
+			}
+		}()
+	}
 	return p.retrieve(ctx)
 }
 
+// WithCustomFunctionName names a CustomFunctionProvider in the Source of its
+// credentials, which also keys them in backends shared through WithCache
+func WithCustomFunctionName(name string) ConfOption {
+	return func(c *confOptions) {
+		c.customFunctionName = name
+	}
+}
+
 // NewCustomFunctionConf initializes a new CustomFunctionConf instance and returns aws.Config interface.
+// Cache options such as WithExpiryWindow override the default 5-minute expiry window.
 func NewCustomFunctionConf(
-	_ context.Context,
+	ctx context.Context,
 	cfg aws.Config,
 	retrieve func(ctx context.Context) (aws.Credentials, error),
+	opts ...Option,
 ) (aws.Config, error) {
-	credProvider, err := NewCustomFunctionProvider(retrieve)
+	conf := newConfOptions(opts)
+
+	credProvider, err := newCustomFunctionProvider(retrieve, conf)
 	if err != nil {
 		return aws.Config{}, err
 	}
-	credentials := aws.NewCredentialsCache(
-		credProvider,
-		func(options *aws.CredentialsCacheOptions) {
-			options.ExpiryWindow = 5 * time.Minute
+	cacheOpts := append(
+		[]func(*aws.CredentialsCacheOptions){
+			func(options *aws.CredentialsCacheOptions) {
+				options.ExpiryWindow = 5 * time.Minute
+			},
 		},
+		conf.cacheOpts...,
 	)
+	provider := withMetrics(conf, "", withLogging(conf, "CustomFunction", "", credProvider))
+	provider = withRefreshCallback(conf, "CustomFunction", "", provider)
+	provider = withCacheBackends(conf, sourceCacheKey(credProvider.source), withStats(withStaleIfError(conf, provider)))
+	provider = withCustomRetrieveTracing(conf, provider)
+	credentials := aws.NewCredentialsCache(withSource(credProvider.source, provider), cacheOpts...)
+
+	// Retrieve now so the first call made with the config hits warm credentials
+	if conf.preWarm {
+		if _, err := credentials.Retrieve(ctx); err != nil {
+			return aws.Config{}, err
+		}
+	}
 
 	config := cfg.Copy()
 	config.Credentials = credentials
 	return config, nil
 }
+
+// sleepContext waits for d, returning early with the context error if ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// withForcedTTL stamps an expiry of ttl from now on creds that have none
+func withForcedTTL(creds aws.Credentials, ttl time.Duration) aws.Credentials {
+	if ttl > 0 && (!creds.CanExpire || creds.Expires.IsZero()) {
+		creds.CanExpire = true
+		creds.Expires = timeNow().Add(ttl)
+	}
+	return creds
+}
+
+// String implements the fmt.Stringer interface method, printing only the source
+// label, whether a retrieve function is set, and the retry settings, never the
+// closure of the retrieve function
+func (p *CustomFunctionProvider) String() string {
+	return fmt.Sprintf("CustomFunctionProvider{Source: %q, Retrieve: %t, Timeout: %s, Retries: %d}", p.source, p.retrieve != nil, p.timeout, p.retries)
+}
+
+// GoString implements the fmt.GoStringer interface method, so %#v prints no more than String
+func (p *CustomFunctionProvider) GoString() string {
+	return "&awsconfig." + p.String()
+}
@@ -0,0 +1,538 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// testCredentials returns credentials valid for lifetime, or that cannot
+// expire when lifetime is zero
+func testCredentials(lifetime time.Duration) aws.Credentials {
+	creds := aws.Credentials{
+		AccessKeyID:     "ASIAFAKECUSTOMACCESSKEY",
+		SecretAccessKey: "fake-secret-access-key-of-forty-characters",
+		SessionToken:    "fake-session-token",
+	}
+	if lifetime != 0 {
+		creds.CanExpire = true
+		creds.Expires = time.Now().Add(lifetime)
+	}
+	return creds
+}
+
+// countingRetrieve returns a retrieve function handing out creds of lifetime and the number of calls made to it
+func countingRetrieve(lifetime time.Duration) (func(context.Context) (aws.Credentials, error), *atomic.Int32) {
+	var calls atomic.Int32
+	return func(context.Context) (aws.Credentials, error) {
+		calls.Add(1)
+		return testCredentials(lifetime), nil
+	}, &calls
+}
+
+func TestNewCustomFunctionConfCacheOptions(t *testing.T) {
+	tests := []struct {
+		name      string
+		lifetime  time.Duration
+		opts      []Option
+		wantCalls int32
+	}{
+		{"DefaultWindow", 10 * time.Minute, nil, 1},
+		{"DefaultWindowApplies", 4 * time.Minute, nil, 3},
+		{"NarrowWindow", 4 * time.Minute, []Option{WithExpiryWindow(time.Minute)}, 1},
+		{"WideWindow", 10 * time.Minute, []Option{WithExpiryWindow(11 * time.Minute)}, 3},
+		{"CacheOptions", 4 * time.Minute, []Option{WithCacheOptions(func(o *aws.CredentialsCacheOptions) {
+			o.ExpiryWindow = 0
+		})}, 1},
+		{"NoJitter", 10 * time.Minute, []Option{WithExpiryWindowJitterFrac(0)}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retrieve, calls := countingRetrieve(tt.lifetime)
+			cfg, err := NewCustomFunctionConf(context.Background(), aws.Config{}, retrieve, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewCustomFunctionConf: %v", err)
+			}
+			for range 3 {
+				if _, err := cfg.Credentials.Retrieve(context.Background()); err != nil {
+					t.Fatalf("Retrieve: %v", err)
+				}
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("retrieve called %d times for 3 Retrieves, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestCustomFunctionNilRetrieve(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func() error
+	}{
+		{"Provider", func() error {
+			_, err := NewCustomFunctionProvider(nil)
+			return err
+		}},
+		{"Conf", func() error {
+			_, err := NewCustomFunctionConf(context.Background(), aws.Config{}, nil)
+			return err
+		}},
+		{"ZeroValueStruct", func() error {
+			_, err := (&CustomFunctionProvider{}).Retrieve(context.Background())
+			return err
+		}},
+		{"NilPointer", func() error {
+			_, err := (*CustomFunctionProvider)(nil).Retrieve(context.Background())
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.build(); !errors.Is(err, ErrNilRetrieveFunc) {
+				t.Errorf("error %v, want %v", err, ErrNilRetrieveFunc)
+			}
+		})
+	}
+}
+
+func TestCustomFunctionProviderExpiry(t *testing.T) {
+	tests := []struct {
+		name        string
+		creds       aws.Credentials
+		opts        []Option
+		wantErr     error
+		wantWarning error
+		wantTTL     time.Duration
+	}{
+		{name: "Expiring", creds: testCredentials(time.Hour), wantTTL: time.Hour},
+		{name: "PastExpiry", creds: testCredentials(-time.Minute), wantErr: ErrCredentialsExpired},
+		{name: "NoExpiry", creds: testCredentials(0), wantWarning: ErrNonExpiringCredentials},
+		{name: "ForcedTTL", creds: testCredentials(0), opts: []Option{WithForcedTTL(15 * time.Minute)}, wantTTL: 15 * time.Minute},
+		{name: "ForcedTTLKeepsExpiry", creds: testCredentials(time.Hour), opts: []Option{WithForcedTTL(15 * time.Minute)}, wantTTL: time.Hour},
+		{name: "ForcedTTLZeroExpires", creds: aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret", CanExpire: true},
+			opts: []Option{WithForcedTTL(15 * time.Minute)}, wantTTL: 15 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var warnings []error
+			opts := append([]Option{WithWarningHandler(func(err error) { warnings = append(warnings, err) })}, tt.opts...)
+			provider, err := NewCustomFunctionProvider(func(context.Context) (aws.Credentials, error) {
+				return tt.creds, nil
+			}, opts...)
+			if err != nil {
+				t.Fatalf("NewCustomFunctionProvider: %v", err)
+			}
+			creds, err := provider.Retrieve(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Retrieve error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantWarning != nil && (len(warnings) != 1 || !errors.Is(warnings[0], tt.wantWarning)) {
+				t.Errorf("warnings %v, want %v", warnings, tt.wantWarning)
+			}
+			if tt.wantWarning == nil && len(warnings) != 0 {
+				t.Errorf("unexpected warnings %v", warnings)
+			}
+			if tt.wantTTL == 0 {
+				return
+			}
+			if ttl := time.Until(creds.Expires); !creds.CanExpire || ttl > tt.wantTTL || ttl < tt.wantTTL-time.Minute {
+				t.Errorf("credentials expire in %s (CanExpire %t), want %s", ttl, creds.CanExpire, tt.wantTTL)
+			}
+		})
+	}
+}
+
+func TestCustomFunctionProviderPanic(t *testing.T) {
+	var calls int
+	provider, err := NewCustomFunctionProvider(func(context.Context) (aws.Credentials, error) {
+		calls++
+		if calls == 1 {
+			var parsed map[string]string
+			parsed["broker"] = "response" // nil map write
+		}
+		return testCredentials(time.Hour), nil
+	}, WithRetrieveRetries(3, nil))
+	if err != nil {
+		t.Fatalf("NewCustomFunctionProvider: %v", err)
+	}
+
+	_, err = provider.Retrieve(context.Background())
+	if !errors.Is(err, ErrRetrievePanic) {
+		t.Fatalf("Retrieve error %v, want %v", err, ErrRetrievePanic)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "assignment to entry in nil map") || !strings.Contains(msg, "goroutine") {
+		t.Errorf("Retrieve error %q lacks the panic value and stack", msg)
+	}
+	if calls != 1 {
+		t.Errorf("retrieve function called %d times, panics must not be retried", calls)
+	}
+
+	if _, err := provider.Retrieve(context.Background()); err != nil {
+		t.Errorf("Retrieve after panic: %v", err)
+	}
+}
+
+func TestCustomFunctionProviderWithoutPanicRecovery(t *testing.T) {
+	provider, err := newCustomFunctionProvider(func(context.Context) (aws.Credentials, error) {
+		panic("broker exploded")
+	}, newConfOptions([]Option{WithoutPanicRecovery()}))
+	if err != nil {
+		t.Fatalf("newCustomFunctionProvider: %v", err)
+	}
+	defer func() {
+		if r := recover(); r != "broker exploded" {
+			t.Errorf("recovered %v, want the retrieve function's panic", r)
+		}
+	}()
+	_, _ = provider.safeRetrieve(context.Background())
+	t.Error("safeRetrieve returned instead of panicking")
+}
+
+// blockingRetrieve waits for its context to be done and returns the context error
+func blockingRetrieve(ctx context.Context) (aws.Credentials, error) {
+	<-ctx.Done()
+	return aws.Credentials{}, ctx.Err()
+}
+
+func TestCustomFunctionProviderTimeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		retrieve func(context.Context) (aws.Credentials, error)
+		timeout  time.Duration
+		wantErr  error
+	}{
+		{"Blocks", blockingRetrieve, 20 * time.Millisecond, ErrRetrieveTimeout},
+		{"IgnoresContextError", func(ctx context.Context) (aws.Credentials, error) {
+			<-ctx.Done()
+			return aws.Credentials{}, errors.New("broker connection reset")
+		}, 20 * time.Millisecond, ErrRetrieveTimeout},
+		{"InTime", func(context.Context) (aws.Credentials, error) { return testCredentials(time.Hour), nil }, time.Second, nil},
+		{"ZeroIsNoTimeout", func(context.Context) (aws.Credentials, error) {
+			time.Sleep(20 * time.Millisecond)
+			return testCredentials(time.Hour), nil
+		}, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := NewCustomFunctionProvider(tt.retrieve, WithRetrieveTimeout(tt.timeout))
+			if err != nil {
+				t.Fatalf("NewCustomFunctionProvider: %v", err)
+			}
+			_, err = provider.Retrieve(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Retrieve error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil && !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("Retrieve error %v does not wrap %v", err, context.DeadlineExceeded)
+			}
+		})
+	}
+}
+
+func TestCustomFunctionProviderRetries(t *testing.T) {
+	transient := errors.New("broker 503")
+	tests := []struct {
+		name         string
+		failures     int
+		err          error
+		retries      int
+		wantErr      error
+		wantCalls    int
+		wantAttempts string
+	}{
+		{name: "NoRetries", failures: 1, err: transient, wantErr: transient, wantCalls: 1},
+		{name: "RecoversWithinRetries", failures: 2, err: transient, retries: 2, wantCalls: 3},
+		{name: "Exhausted", failures: 5, err: transient, retries: 2, wantErr: ErrRetrieveFailed, wantCalls: 3, wantAttempts: "3 attempt(s)"},
+		{name: "Permanent", failures: 5, err: fmt.Errorf("%w: bad broker token", ErrPermanent), retries: 2,
+			wantErr: ErrPermanent, wantCalls: 1, wantAttempts: "1 attempt(s)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			var backoffs []int
+			provider, err := NewCustomFunctionProvider(func(context.Context) (aws.Credentials, error) {
+				calls++
+				if calls <= tt.failures {
+					return aws.Credentials{}, tt.err
+				}
+				return testCredentials(time.Hour), nil
+			}, WithRetrieveRetries(tt.retries, func(attempt int) time.Duration {
+				backoffs = append(backoffs, attempt)
+				return time.Millisecond
+			}))
+			if err != nil {
+				t.Fatalf("NewCustomFunctionProvider: %v", err)
+			}
+			_, err = provider.Retrieve(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Retrieve error %v, want %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("Retrieve error %v does not wrap the last failure", err)
+			}
+			if tt.wantAttempts != "" && !strings.Contains(err.Error(), tt.wantAttempts) {
+				t.Errorf("Retrieve error %q does not mention %q", err, tt.wantAttempts)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("retrieve function called %d times, want %d", calls, tt.wantCalls)
+			}
+			if len(backoffs) != calls-1 {
+				t.Errorf("backoff called for attempts %v over %d calls", backoffs, calls)
+			}
+			for i, attempt := range backoffs {
+				if attempt != i+1 {
+					t.Errorf("backoff called for attempts %v, want 1..n", backoffs)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestNewCustomFunctionConfErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		retrieve func(context.Context) (aws.Credentials, error)
+		opts     []Option
+		wantErr  error
+		wantMsg  string
+	}{
+		{name: "NilRetrieve", wantErr: ErrNilRetrieveFunc},
+		{
+			name:     "Timeout",
+			retrieve: blockingRetrieve,
+			opts:     []Option{WithRetrieveTimeout(10 * time.Millisecond)},
+			wantErr:  ErrRetrieveTimeout,
+			wantMsg:  "CustomFunctionProvider retrieve timed out",
+		},
+		{
+			name:     "RetriesExhausted",
+			retrieve: func(context.Context) (aws.Credentials, error) { return aws.Credentials{}, errors.New("broker 503") },
+			opts:     []Option{WithRetrieveRetries(1, nil)},
+			wantErr:  ErrRetrieveFailed,
+			wantMsg:  "CustomFunctionProvider retrieve failed",
+		},
+		{
+			name:     "Expired",
+			retrieve: func(context.Context) (aws.Credentials, error) { return testCredentials(-time.Minute), nil },
+			wantErr:  ErrCredentialsExpired,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]Option{WithPreWarm()}, tt.opts...)
+			_, err := NewCustomFunctionConf(context.Background(), aws.Config{}, tt.retrieve, opts...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewCustomFunctionConf error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantMsg != "" && !strings.Contains(err.Error(), tt.wantMsg) {
+				t.Errorf("NewCustomFunctionConf error %q does not contain %q", err, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestNewCustomFunctionConfPreWarm(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      []Option
+		wantCalls int32
+	}{
+		{name: "PreWarm", opts: []Option{WithPreWarm()}, wantCalls: 1},
+		{name: "Lazy", wantCalls: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retrieve, calls := countingRetrieve(time.Hour)
+			cfg, err := NewCustomFunctionConf(context.Background(), aws.Config{}, retrieve, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewCustomFunctionConf: %v", err)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("retrieve called %d times during construction, want %d", got, tt.wantCalls)
+			}
+			if _, err := cfg.Credentials.Retrieve(context.Background()); err != nil {
+				t.Fatalf("Retrieve: %v", err)
+			}
+			if got := calls.Load(); got != 1 {
+				t.Errorf("retrieve called %d times after the first Retrieve, want 1", got)
+			}
+		})
+	}
+}
+
+func TestNewCustomFunctionConfPreWarmDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	// The credentials cache keeps the retrieval running past the deadline, so
+	// wait for it to have read the clock before a later test stubs it
+	started := make(chan struct{})
+	_, err := NewCustomFunctionConf(ctx, aws.Config{}, func(ctx context.Context) (aws.Credentials, error) {
+		close(started)
+		return blockingRetrieve(ctx)
+	}, WithPreWarm())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("NewCustomFunctionConf error %v, want %v", err, context.DeadlineExceeded)
+	}
+	<-started
+}
+
+func TestCustomFunctionProviderSharedRetrieve(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+	provider, err := NewCustomFunctionProvider(func(context.Context) (aws.Credentials, error) {
+		calls.Add(1)
+		<-release
+		return testCredentials(time.Hour), nil
+	})
+	if err != nil {
+		t.Fatalf("NewCustomFunctionProvider: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+	for range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := provider.Retrieve(context.Background()); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	// Let every caller join the retrieval before it completes
+	for calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Retrieve: %v", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("retrieve function called %d times for 100 concurrent Retrieves, want 1", got)
+	}
+}
+
+func TestCustomFunctionProviderSharedRetrieveCancellation(t *testing.T) {
+	tests := []struct {
+		name string
+		// otherWaiter keeps a second caller waiting on the shared retrieval
+		otherWaiter  bool
+		wantCanceled bool
+	}{
+		{name: "OtherWaiterKeepsRetrieval", otherWaiter: true},
+		{name: "LastWaiterCancelsRetrieval", wantCanceled: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			started := make(chan struct{})
+			release := make(chan struct{})
+			retrieveErr := make(chan error, 1)
+			provider, err := NewCustomFunctionProvider(func(ctx context.Context) (aws.Credentials, error) {
+				close(started)
+				select {
+				case <-ctx.Done():
+					retrieveErr <- ctx.Err()
+					return aws.Credentials{}, ctx.Err()
+				case <-release:
+					retrieveErr <- nil
+					return testCredentials(time.Hour), nil
+				}
+			})
+			if err != nil {
+				t.Fatalf("NewCustomFunctionProvider: %v", err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancelledErr := make(chan error, 1)
+			go func() {
+				_, err := provider.Retrieve(ctx)
+				cancelledErr <- err
+			}()
+			<-started
+			otherErr := make(chan error, 1)
+			if tt.otherWaiter {
+				go func() {
+					_, err := provider.Retrieve(context.Background())
+					otherErr <- err
+				}()
+				// Give the second caller time to join the retrieval
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			cancel()
+			if err := <-cancelledErr; !errors.Is(err, context.Canceled) {
+				t.Errorf("cancelled caller's Retrieve error %v, want %v", err, context.Canceled)
+			}
+			if !tt.wantCanceled {
+				close(release)
+			}
+			select {
+			case err := <-retrieveErr:
+				if tt.wantCanceled != errors.Is(err, context.Canceled) {
+					t.Errorf("retrieve function ended with %v, want cancelled %t", err, tt.wantCanceled)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("retrieve function was not cancelled")
+			}
+			if tt.otherWaiter {
+				if err := <-otherErr; err != nil {
+					t.Errorf("waiting caller's Retrieve: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestCustomFunctionProviderCancelBetweenRetries(t *testing.T) {
+	var calls atomic.Int32
+	provider, err := NewCustomFunctionProvider(func(context.Context) (aws.Credentials, error) {
+		calls.Add(1)
+		return aws.Credentials{}, errors.New("broker 503")
+	}, WithRetrieveRetries(5, func(int) time.Duration { return time.Hour }))
+	if err != nil {
+		t.Fatalf("NewCustomFunctionProvider: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	retrieveErr := make(chan error, 1)
+	go func() {
+		_, err := provider.Retrieve(ctx)
+		retrieveErr <- err
+	}()
+	for calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	p := provider.(*CustomFunctionProvider)
+	p.mu.Lock()
+	call := p.inflight
+	p.mu.Unlock()
+
+	cancel()
+	if err := <-retrieveErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Retrieve error %v, want %v", err, context.Canceled)
+	}
+	// The abandoned retrieval stops backing off instead of retrying an hour later
+	select {
+	case <-call.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("retrieval still backing off after its only caller left")
+	}
+	if !errors.Is(call.err, context.Canceled) {
+		t.Errorf("shared retrieval error %v, want %v", call.err, context.Canceled)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("retrieve function called %d times, want 1", got)
+	}
+}
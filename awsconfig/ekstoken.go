@@ -0,0 +1,73 @@
+package awsconfig
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+const (
+	// EKSTokenPrefix starts the bearer tokens of aws-iam-authenticator
+	EKSTokenPrefix = "k8s-aws-v1."
+	// EKSClusterIDHeader is the signed header naming the cluster a token is for
+	EKSClusterIDHeader = "x-k8s-aws-id"
+	// EKSTokenValidity is how long EKS accepts a token after it is signed
+	EKSTokenValidity = 15 * time.Minute
+
+	// eksTokenExpirySkew makes tokens expire a little before EKS stops accepting them
+	eksTokenExpirySkew = time.Minute
+	// eksPresignExpires is the X-Amz-Expires aws-iam-authenticator signs
+	eksPresignExpires = "60"
+)
+
+// EKSToken returns a bearer token authenticating the credentials of cfg to the
+// EKS cluster clusterName, as aws-iam-authenticator and `aws eks get-token`
+// do: a GetCallerIdentity URL presigned with the cluster name in a signed
+// header, base64 encoded after the EKSTokenPrefix. The token expires a minute
+// before the EKSTokenValidity to allow for clock skew. STS is called in the
+// region of cfg, or us-east-1 when it has none.
+func EKSToken(ctx context.Context, cfg aws.Config, clusterName string) (token string, expiresAt time.Time, err error) {
+	if cfg.Credentials == nil {
+		return "", time.Time{}, ErrNilCredentials
+	}
+	signedAt := timeNow()
+	client := sts.NewPresignClient(sts.NewFromConfig(cfg, func(o *sts.Options) {
+		if o.Region == "" {
+			o.Region = "us-east-1"
+		}
+	}))
+	req, err := client.PresignGetCallerIdentity(ctx, &sts.GetCallerIdentityInput{}, func(o *sts.PresignOptions) {
+		o.ClientOptions = append(o.ClientOptions, func(o *sts.Options) {
+			o.APIOptions = append(o.APIOptions,
+				smithyhttp.AddHeaderValue(EKSClusterIDHeader, clusterName),
+				addPresignExpires,
+			)
+		})
+	})
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("%w: %w", ErrRetrieveCredentials, err)
+	}
+	token = EKSTokenPrefix + base64.RawURLEncoding.EncodeToString([]byte(req.URL))
+	return token, signedAt.Add(EKSTokenValidity - eksTokenExpirySkew), nil
+}
+
+// addPresignExpires adds the X-Amz-Expires query parameter of EKS tokens before
+// the request is signed
+func addPresignExpires(stack *middleware.Stack) error {
+	return stack.Build.Add(middleware.BuildMiddlewareFunc("EKSPresignExpires", func(
+		ctx context.Context, in middleware.BuildInput, next middleware.BuildHandler,
+	) (middleware.BuildOutput, middleware.Metadata, error) {
+		if req, ok := in.Request.(*smithyhttp.Request); ok {
+			query := req.URL.Query()
+			query.Set("X-Amz-Expires", eksPresignExpires)
+			req.URL.RawQuery = query.Encode()
+		}
+		return next.HandleBuild(ctx, in)
+	}), middleware.After)
+}
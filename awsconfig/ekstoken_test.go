@@ -0,0 +1,102 @@
+package awsconfig
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+func TestEKSToken(t *testing.T) {
+	stubClock(t)
+	creds := credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", "token")
+
+	tests := []struct {
+		name     string
+		region   string
+		wantHost string
+	}{
+		{name: "Region", region: "eu-west-1", wantHost: "sts.eu-west-1.amazonaws.com"},
+		{name: "DefaultRegion", wantHost: "sts.us-east-1.amazonaws.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, expiresAt, err := EKSToken(context.Background(), aws.Config{Region: tt.region, Credentials: creds}, "my-cluster")
+			if err != nil {
+				t.Fatalf("EKSToken: %v", err)
+			}
+			if want := timeNow().Add(14 * time.Minute); !expiresAt.Equal(want) {
+				t.Errorf("expiresAt = %v, want %v", expiresAt, want)
+			}
+			encoded, ok := strings.CutPrefix(token, EKSTokenPrefix)
+			if !ok {
+				t.Fatalf("token %q lacks the %q prefix", token, EKSTokenPrefix)
+			}
+			raw, err := base64.RawURLEncoding.DecodeString(encoded)
+			if err != nil {
+				t.Fatalf("token is not unpadded base64url: %v", err)
+			}
+			u, err := url.Parse(string(raw))
+			if err != nil {
+				t.Fatalf("token is not a URL: %v", err)
+			}
+
+			if u.Scheme != "https" || u.Host != tt.wantHost {
+				t.Errorf("token URL %s://%s, want https://%s", u.Scheme, u.Host, tt.wantHost)
+			}
+			query := u.Query()
+			wantRegion := tt.region
+			if wantRegion == "" {
+				wantRegion = "us-east-1"
+			}
+			checks := map[string]*regexp.Regexp{
+				"Action":               regexp.MustCompile(`^GetCallerIdentity$`),
+				"Version":              regexp.MustCompile(`^2011-06-15$`),
+				"X-Amz-Algorithm":      regexp.MustCompile(`^AWS4-HMAC-SHA256$`),
+				"X-Amz-Credential":     regexp.MustCompile(`^AKIDEXAMPLE/\d{8}/` + wantRegion + `/sts/aws4_request$`),
+				"X-Amz-Date":           regexp.MustCompile(`^\d{8}T\d{6}Z$`),
+				"X-Amz-Expires":        regexp.MustCompile(`^60$`),
+				"X-Amz-Security-Token": regexp.MustCompile(`^token$`),
+				"X-Amz-SignedHeaders":  regexp.MustCompile(`^host;x-k8s-aws-id$`),
+				"X-Amz-Signature":      regexp.MustCompile(`^[0-9a-f]{64}$`),
+			}
+			for param, re := range checks {
+				if got := query.Get(param); !re.MatchString(got) {
+					t.Errorf("%s = %q, want match of %s", param, got, re)
+				}
+			}
+		})
+	}
+}
+
+func TestEKSTokenErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider aws.CredentialsProvider
+		wantErr  error
+	}{
+		{name: "NilCredentials", wantErr: ErrNilCredentials},
+		{
+			name: "RetrieveFails",
+			provider: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+				return aws.Credentials{}, errors.New("no credentials")
+			}),
+			wantErr: ErrRetrieveCredentials,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := EKSToken(context.Background(), aws.Config{Credentials: tt.provider}, "my-cluster")
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("EKSToken error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package awsconfig
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+var (
+	// ErrInvalidCacheKey is returned when an EncryptedCache key isn't 32 bytes
+	ErrInvalidCacheKey = errors.New("Credential cache encryption key must be 32 bytes")
+	// ErrNoCacheKeys is returned when an EncryptedCache key source returns no keys
+	ErrNoCacheKeys = errors.New("Credential cache key source returned no keys")
+)
+
+const (
+	// encryptedCacheMarker is the access key ID of the entries of an EncryptedCache
+	encryptedCacheMarker = "awsconfig-aes256gcm-v1"
+)
+
+// CacheKeySource returns the AES-256 keys of an EncryptedCache. The first key
+// encrypts; all of them are tried when decrypting, so keys can be rotated.
+type CacheKeySource func(ctx context.Context) ([][]byte, error)
+
+// StaticCacheKeys returns a CacheKeySource of fixed keys
+func StaticCacheKeys(keys ...[]byte) CacheKeySource {
+	return func(context.Context) ([][]byte, error) {
+		return keys, nil
+	}
+}
+
+// NewEncryptedCache returns a CredentialCache encrypting credentials with
+// AES-256-GCM before storing them in backend, such as a file cache. The
+// expiry is stored in clear, authenticated along with the entry's cache key so
+// entries cannot be moved between roles. Entries that fail to
+// decrypt, because they were tampered with or the key was retired, are misses.
+func NewEncryptedCache(backend CredentialCache, keys CacheKeySource) CredentialCache {
+	return &encryptedCache{backend: backend, keys: keys}
+}
+
+// encryptedCache is a CredentialCache encrypting the entries of another
+type encryptedCache struct {
+	backend CredentialCache
+	keys    CacheKeySource
+}
+
+// sealedCredentials is the plaintext of an encrypted entry
+type sealedCredentials struct {
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	SessionToken    string `json:"SessionToken"`
+}
+
+// Get implements the CredentialCache interface method
+func (c *encryptedCache) Get(ctx context.Context, key string) (aws.Credentials, bool) {
+	entry, ok := c.backend.Get(ctx, key)
+	if !ok || entry.AccessKeyID != encryptedCacheMarker {
+		return aws.Credentials{}, false
+	}
+	sealed, err := base64.StdEncoding.DecodeString(entry.SessionToken)
+	if err != nil {
+		return aws.Credentials{}, false
+	}
+	keys, err := c.keys(ctx)
+	if err != nil {
+		return aws.Credentials{}, false
+	}
+
+	for _, aesKey := range keys {
+		aead, err := newCacheAEAD(aesKey)
+		if err != nil || len(sealed) < aead.NonceSize() {
+			continue
+		}
+		nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+		plaintext, err := aead.Open(nil, nonce, ciphertext, cacheAAD(key, entry))
+		if err != nil {
+			continue
+		}
+		var creds sealedCredentials
+		if err := json.Unmarshal(plaintext, &creds); err != nil {
+			return aws.Credentials{}, false
+		}
+		return aws.Credentials{
+			AccessKeyID:     creds.AccessKeyID,
+			SecretAccessKey: creds.SecretAccessKey,
+			SessionToken:    creds.SessionToken,
+			Source:          entry.Source,
+			CanExpire:       entry.CanExpire,
+			Expires:         entry.Expires,
+		}, true
+	}
+	return aws.Credentials{}, false
+}
+
+// Put implements the CredentialCache interface method
+func (c *encryptedCache) Put(ctx context.Context, key string, creds aws.Credentials) error {
+	keys, err := c.keys(ctx)
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return ErrNoCacheKeys
+	}
+	aead, err := newCacheAEAD(keys[0])
+	if err != nil {
+		return err
+	}
+	plaintext, err := json.Marshal(sealedCredentials{
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+	})
+	if err != nil {
+		return err
+	}
+
+	entry := aws.Credentials{
+		AccessKeyID:     encryptedCacheMarker,
+		SecretAccessKey: encryptedCacheMarker,
+		Source:          creds.Source,
+		CanExpire:       creds.CanExpire,
+		// Stores such as the AWS CLI format keep whole seconds
+		Expires: creds.Expires.Truncate(time.Second),
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, cacheAAD(key, entry))
+	entry.SessionToken = base64.StdEncoding.EncodeToString(sealed)
+	return c.backend.Put(ctx, key, entry)
+}
+
+// Invalidate implements the CredentialCache interface method
+func (c *encryptedCache) Invalidate(ctx context.Context, key string) error {
+	return c.backend.Invalidate(ctx, key)
+}
+
+// Lock takes the lock of the backend, if it has one
+func (c *encryptedCache) Lock(ctx context.Context, key string) (unlock func(), err error) {
+	if locker, ok := c.backend.(cacheLocker); ok {
+		return locker.Lock(ctx, key)
+	}
+	return func() {}, nil
+}
+
+// newCacheAEAD returns AES-256-GCM with key
+func newCacheAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("%w, got %d", ErrInvalidCacheKey, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// cacheAAD binds the key and the expiry stored in clear to the ciphertext
+func cacheAAD(key string, entry aws.Credentials) []byte {
+	if !entry.CanExpire {
+		return []byte(encryptedCacheMarker + "|" + key)
+	}
+	return []byte(encryptedCacheMarker + "|" + key + "|" + entry.Expires.UTC().Format(time.RFC3339))
+}
@@ -0,0 +1,89 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// EncryptedFileProviderName prefixes the Source of credentials returned by
+// EncryptedFileProvider, followed by the file path
+const EncryptedFileProviderName = SourcePrefix + "encryptedfile"
+
+var (
+	// ErrReadEncryptedFile is returned when an encrypted credentials file cannot be
+	// read; it also wraps fs.ErrNotExist when the file is missing
+	ErrReadEncryptedFile = errors.New("Cannot read encrypted credentials file")
+	// ErrDecryptCredentials is returned when an encrypted credentials file cannot be decrypted
+	ErrDecryptCredentials = errors.New("Cannot decrypt credentials file")
+)
+
+// EncryptedFileProvider implements the aws.CredentialsProvider interface by
+// reading an encrypted file, such as one encrypted with age or KMS, and
+// parsing the credentials JSON documented on SecretsManagerProvider once
+// decrypted. The file is checked on every Retrieve so rotated credentials are
+// picked up, but only read and decrypted again when its size or modification
+// time changed.
+type EncryptedFileProvider struct {
+	path    string
+	decrypt func(ciphertext []byte) ([]byte, error)
+
+	mu      sync.Mutex
+	size    int64
+	modTime time.Time
+	creds   aws.Credentials
+}
+
+// NewEncryptedFileProvider initializes a new EncryptedFileProvider reading path
+// and decrypting it with decrypt
+func NewEncryptedFileProvider(path string, decrypt func(ciphertext []byte) ([]byte, error)) *EncryptedFileProvider {
+	return &EncryptedFileProvider{path: path, decrypt: decrypt}
+}
+
+// Retrieve implements the aws.CredentialsProvider interface method
+func (p *EncryptedFileProvider) Retrieve(context.Context) (aws.Credentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return aws.Credentials{}, fmt.Errorf("%w %q: %w", ErrReadEncryptedFile, p.path, err)
+	}
+	if p.creds.HasKeys() && info.Size() == p.size && info.ModTime().Equal(p.modTime) {
+		return p.creds, nil
+	}
+
+	ciphertext, err := os.ReadFile(p.path)
+	if err != nil {
+		return aws.Credentials{}, fmt.Errorf("%w %q: %w", ErrReadEncryptedFile, p.path, err)
+	}
+	plaintext, err := p.decrypt(ciphertext)
+	if err != nil {
+		return aws.Credentials{}, fmt.Errorf("%w %q: %w", ErrDecryptCredentials, p.path, err)
+	}
+	creds, err := parseCredentialsJSON(plaintext)
+	if err != nil {
+		return aws.Credentials{}, fmt.Errorf("%w in file %q: %w", ErrParseCredentialsJSON, p.path, err)
+	}
+	creds.Source = sourceLabel(EncryptedFileProviderName, p.path)
+
+	p.creds, p.size, p.modTime = creds, info.Size(), info.ModTime()
+	return creds, nil
+}
+
+// String implements the fmt.Stringer interface method, printing only the file
+// path and whether a decrypt function is set, never the decrypted credentials
+// it holds
+func (p *EncryptedFileProvider) String() string {
+	return fmt.Sprintf("EncryptedFileProvider{Source: %q, Decrypt: %t}", sourceLabel(EncryptedFileProviderName, p.path), p.decrypt != nil)
+}
+
+// GoString implements the fmt.GoStringer interface method, so %#v prints no more than String
+func (p *EncryptedFileProvider) GoString() string {
+	return "&awsconfig." + p.String()
+}
@@ -0,0 +1,172 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// xorKey is the key of the xor test cipher
+const xorKey = 0x5a
+
+// xor is a trivial symmetric test cipher
+func xor(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i := range b {
+		out[i] = b[i] ^ xorKey
+	}
+	return out
+}
+
+// writeEncryptedFile writes plaintext encrypted with xor to path, with the
+// modification time mtime
+func writeEncryptedFile(t *testing.T, path, plaintext string, mtime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, xor([]byte(plaintext)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEncryptedFileProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.enc")
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	writeEncryptedFile(t, path, `{"AccessKeyID": "AKIAFIRST", "SecretAccessKey": "secret"}`, mtime)
+
+	var decrypts int
+	p := NewEncryptedFileProvider(path, func(ciphertext []byte) ([]byte, error) {
+		decrypts++
+		return xor(ciphertext), nil
+	})
+
+	tests := []struct {
+		name         string
+		rewrite      string
+		mtime        time.Time
+		wantKeyID    string
+		wantDecrypts int
+	}{
+		{name: "FirstRead", wantKeyID: "AKIAFIRST", wantDecrypts: 1},
+		{name: "Unchanged", wantKeyID: "AKIAFIRST", wantDecrypts: 1},
+		{
+			name:         "Rotated",
+			rewrite:      `{"AccessKeyID": "AKIASECOND", "SecretAccessKey": "secret"}`,
+			mtime:        mtime.Add(time.Minute),
+			wantKeyID:    "AKIASECOND",
+			wantDecrypts: 2,
+		},
+		{
+			name:         "RotatedSameModTime",
+			rewrite:      `{"AccessKeyID": "AKIATHIRD", "SecretAccessKey": "longer-secret"}`,
+			mtime:        mtime.Add(time.Minute),
+			wantKeyID:    "AKIATHIRD",
+			wantDecrypts: 3,
+		},
+		{name: "UnchangedAgain", wantKeyID: "AKIATHIRD", wantDecrypts: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.rewrite != "" {
+				writeEncryptedFile(t, path, tt.rewrite, tt.mtime)
+			}
+			creds, err := p.Retrieve(context.Background())
+			if err != nil {
+				t.Fatalf("Retrieve: %v", err)
+			}
+			if creds.AccessKeyID != tt.wantKeyID {
+				t.Errorf("AccessKeyID %q, want %q", creds.AccessKeyID, tt.wantKeyID)
+			}
+			if want := sourceLabel(EncryptedFileProviderName, path); creds.Source != want {
+				t.Errorf("Source %q, want %q", creds.Source, want)
+			}
+			if decrypts != tt.wantDecrypts {
+				t.Errorf("decrypted %d times, want %d", decrypts, tt.wantDecrypts)
+			}
+		})
+	}
+}
+
+func TestEncryptedFileProviderErrors(t *testing.T) {
+	errDecrypt := errors.New("wrong key")
+	tests := []struct {
+		name      string
+		plaintext string
+		missing   bool
+		decrypt   func([]byte) ([]byte, error)
+		wantErr   []error
+	}{
+		{
+			name:    "Missing",
+			missing: true,
+			wantErr: []error{ErrReadEncryptedFile, fs.ErrNotExist},
+		},
+		{
+			name:      "Decrypt",
+			plaintext: `{"AccessKeyID": "AKIAEXAMPLE", "SecretAccessKey": "secret"}`,
+			decrypt:   func([]byte) ([]byte, error) { return nil, errDecrypt },
+			wantErr:   []error{ErrDecryptCredentials, errDecrypt},
+		},
+		{
+			name:      "Parse",
+			plaintext: `AKIAEXAMPLE:secret`,
+			wantErr:   []error{ErrParseCredentialsJSON},
+		},
+		{
+			name:      "WrongKey",
+			plaintext: `{"AccessKeyID": "AKIAEXAMPLE", "SecretAccessKey": "secret"}`,
+			decrypt:   func(b []byte) ([]byte, error) { return b, nil },
+			wantErr:   []error{ErrParseCredentialsJSON},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "credentials.enc")
+			if !tt.missing {
+				writeEncryptedFile(t, path, tt.plaintext, time.Now())
+			}
+			decrypt := tt.decrypt
+			if decrypt == nil {
+				decrypt = func(b []byte) ([]byte, error) { return xor(b), nil }
+			}
+			_, err := NewEncryptedFileProvider(path, decrypt).Retrieve(context.Background())
+			for _, want := range tt.wantErr {
+				if !errors.Is(err, want) {
+					t.Errorf("Retrieve error %v, want %v", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestEncryptedFileProviderPartialRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.enc")
+	writeEncryptedFile(t, path, `{"AccessKeyID": "AKIAEXAMPLE", "SecretAccessKey": "secret"}`, time.Now().Add(-time.Hour))
+	p := NewEncryptedFileProvider(path, func(b []byte) ([]byte, error) { return xor(b), nil })
+	if _, err := p.Retrieve(context.Background()); err != nil {
+		t.Fatalf("Retrieve: %v", err)
+	}
+
+	// A half-written rotation fails to parse, then succeeds once complete
+	writeEncryptedFile(t, path, `{"AccessKeyID": "AKIANEW"`, time.Now().Add(-time.Minute))
+	if _, err := p.Retrieve(context.Background()); !errors.Is(err, ErrParseCredentialsJSON) {
+		t.Fatalf("Retrieve error %v, want %v", err, ErrParseCredentialsJSON)
+	}
+	writeEncryptedFile(t, path, `{"AccessKeyID": "AKIANEW", "SecretAccessKey": "secret"}`, time.Now())
+	creds, err := p.Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("Retrieve: %v", err)
+	}
+	if creds.AccessKeyID != "AKIANEW" {
+		t.Errorf("AccessKeyID %q, want AKIANEW", creds.AccessKeyID)
+	}
+	if s := p.String(); strings.Contains(s, "AKIANEW") {
+		t.Errorf("String() = %q prints the credentials", s)
+	}
+}
@@ -0,0 +1,61 @@
+package awsconfig
+
+import (
+	"errors"
+
+	"github.com/aws/smithy-go"
+)
+
+var (
+	// accessDeniedErrorCodes are API error codes for missing permissions
+	accessDeniedErrorCodes = map[string]bool{
+		"AccessDenied":          true,
+		"AccessDeniedException": true,
+		"UnauthorizedOperation": true,
+	}
+	// expiredCredentialsErrorCodes are API error codes for expired calling credentials
+	expiredCredentialsErrorCodes = map[string]bool{
+		"ExpiredToken":          true,
+		"ExpiredTokenException": true,
+	}
+	// throttleErrorCodes are API error codes for rate limited requests
+	throttleErrorCodes = map[string]bool{
+		"Throttling":               true,
+		"ThrottlingException":      true,
+		"RequestLimitExceeded":     true,
+		"TooManyRequestsException": true,
+	}
+	// invalidIdentityTokenErrorCodes are API error codes for rejected web identity or SAML tokens
+	invalidIdentityTokenErrorCodes = map[string]bool{
+		"InvalidIdentityToken": true,
+		"IDPRejectedClaim":     true,
+	}
+)
+
+// IsAccessDenied reports whether err, or any error it wraps, is an AWS access denied error
+func IsAccessDenied(err error) bool {
+	return hasErrorCode(err, accessDeniedErrorCodes)
+}
+
+// IsExpiredCredentials reports whether err, or any error it wraps, is caused by
+// expired credentials, either rejected by AWS or returned expired by a provider
+func IsExpiredCredentials(err error) bool {
+	return errors.Is(err, ErrCredentialsExpired) || hasErrorCode(err, expiredCredentialsErrorCodes)
+}
+
+// IsThrottled reports whether err, or any error it wraps, is an AWS throttling error
+func IsThrottled(err error) bool {
+	return hasErrorCode(err, throttleErrorCodes)
+}
+
+// IsInvalidIdentityToken reports whether err, or any error it wraps, is a rejected
+// web identity or SAML token
+func IsInvalidIdentityToken(err error) bool {
+	return hasErrorCode(err, invalidIdentityTokenErrorCodes)
+}
+
+// hasErrorCode reports whether the first smithy.APIError in the chain of err has one of codes
+func hasErrorCode(err error, codes map[string]bool) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && codes[apiErr.ErrorCode()]
+}
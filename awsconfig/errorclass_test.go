@@ -0,0 +1,95 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/aws/smithy-go"
+
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigtest"
+)
+
+func TestErrorClassification(t *testing.T) {
+	type class struct{ denied, expired, throttled, badToken bool }
+	tests := []struct {
+		name string
+		err  error
+		want class
+	}{
+		{name: "Nil"},
+		{name: "Plain", err: errors.New("AccessDenied")},
+		{name: "AccessDenied", err: &smithy.GenericAPIError{Code: "AccessDenied"}, want: class{denied: true}},
+		{name: "AccessDeniedException", err: &smithy.GenericAPIError{Code: "AccessDeniedException"}, want: class{denied: true}},
+		{name: "ExpiredTokenException", err: &types.ExpiredTokenException{}, want: class{expired: true}},
+		{name: "ExpiredToken", err: &smithy.GenericAPIError{Code: "ExpiredToken"}, want: class{expired: true}},
+		{name: "CredentialsExpired", err: ErrCredentialsExpired, want: class{expired: true}},
+		{name: "Throttling", err: &smithy.GenericAPIError{Code: "Throttling"}, want: class{throttled: true}},
+		{name: "RequestLimitExceeded", err: &smithy.GenericAPIError{Code: "RequestLimitExceeded"}, want: class{throttled: true}},
+		{name: "InvalidIdentityToken", err: &types.InvalidIdentityTokenException{}, want: class{badToken: true}},
+		{name: "IDPRejectedClaim", err: &types.IDPRejectedClaimException{}, want: class{badToken: true}},
+		{name: "OtherAPIError", err: &types.MalformedPolicyDocumentException{}},
+		{
+			name: "NestedWrapping",
+			err:  fmt.Errorf("outer: %w", fmt.Errorf("%w: %w", ErrIdentityCheckFailed, &smithy.GenericAPIError{Code: "Throttling"})),
+			want: class{throttled: true},
+		},
+		{
+			name: "OperationError",
+			err:  &smithy.OperationError{ServiceID: "STS", OperationName: "AssumeRole", Err: &types.ExpiredTokenException{}},
+			want: class{expired: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := class{
+				denied:    IsAccessDenied(tt.err),
+				expired:   IsExpiredCredentials(tt.err),
+				throttled: IsThrottled(tt.err),
+				badToken:  IsInvalidIdentityToken(tt.err),
+			}
+			if got != tt.want {
+				t.Errorf("classified %v as %+v, want %+v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorClassificationThroughPackage(t *testing.T) {
+	tests := []struct {
+		name      string
+		preflight bool
+		err       error
+		is        func(error) bool
+	}{
+		{name: "PreflightAccessDenied", preflight: true, err: &smithy.GenericAPIError{Code: "AccessDenied"}, is: IsAccessDenied},
+		{name: "PreflightExpired", preflight: true, err: &types.ExpiredTokenException{}, is: IsExpiredCredentials},
+		{name: "AssumeRoleAccessDenied", err: &smithy.GenericAPIError{Code: "AccessDenied"}, is: IsAccessDenied},
+		{name: "AssumeRoleThrottled", err: &smithy.GenericAPIError{Code: "Throttling"}, is: IsThrottled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &awsconfigtest.FakeSTS{}
+			opts := []Option{WithSTSClient(fake), WithIdentityCheckAttempts(1)}
+			var err error
+			if tt.preflight {
+				fake.CallerIdentityErr = tt.err
+				_, err = NewAssumeRoleConf(context.Background(), aws.Config{}, testRoleArn, opts...)
+			} else {
+				fake.AssumeRoleErr = tt.err
+				var cfg aws.Config
+				cfg, err = NewAssumeRoleConf(context.Background(), aws.Config{}, testRoleArn, append(opts, WithSkipIdentityCheck())...)
+				if err != nil {
+					t.Fatalf("NewAssumeRoleConf: %v", err)
+				}
+				_, err = cfg.Credentials.Retrieve(context.Background())
+			}
+			if err == nil || !tt.is(err) {
+				t.Errorf("error %v not classified as %s", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// credentialEnvVars are the inherited variables always replaced by those of
+// ExportEnv, so a stale session token cannot pair with fresh keys
+var credentialEnvVars = map[string]bool{
+	EnvAccessKeyID:          true,
+	EnvSecretAccessKey:      true,
+	EnvSessionToken:         true,
+	"AWS_SECURITY_TOKEN":    true,
+	EnvCredentialExpiration: true,
+}
+
+// ExitError is returned when a command run with credentials exits unsuccessfully
+type ExitError struct {
+	// Code is the exit code of the command, or -1 when it was killed by a signal
+	Code int
+	// Err is the underlying *exec.ExitError
+	Err error
+}
+
+// Error implements the error interface method
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("Command exited with code %d: %v", e.Code, e.Err)
+}
+
+// Unwrap returns the underlying *exec.ExitError
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// WithoutInheritedAWSEnv drops every AWS_* variable of the current process from
+// the environment of commands run by ExecWithCredentials and RunWithCredentials,
+// such as AWS_PROFILE, so nothing but the exported credentials reaches them
+func WithoutInheritedAWSEnv() ConfOption {
+	return func(c *confOptions) {
+		c.scrubAWSEnv = true
+	}
+}
+
+// ExecWithCredentials runs the command name with args, with the environment of
+// the current process plus the credentials and region of cfg as exported by
+// ExportEnv; see RunWithCredentials
+func ExecWithCredentials(ctx context.Context, cfg aws.Config, name string, args []string, opts ...Option) error {
+	return RunWithCredentials(ctx, cfg, exec.Command(name, args...), opts...)
+}
+
+// RunWithCredentials runs cmd with the credentials and region of cfg added to
+// its environment, or to that of the current process when cmd.Env is nil. The
+// standard streams of cmd left nil are those of the current process. SIGINT and
+// SIGTERM received while it runs are forwarded to cmd, and it is killed when
+// ctx is done. An unsuccessful exit is returned as an *ExitError.
+func RunWithCredentials(ctx context.Context, cfg aws.Config, cmd *exec.Cmd, opts ...Option) error {
+	conf := newConfOptions(opts)
+	env, err := ExportEnv(ctx, cfg, opts...)
+	if err != nil {
+		return err
+	}
+
+	inherited := cmd.Env
+	if inherited == nil {
+		inherited = os.Environ()
+	}
+	cmd.Env = make([]string, 0, len(inherited)+len(env))
+	for _, kv := range inherited {
+		key, _, _ := strings.Cut(kv, "=")
+		if _, ok := env[key]; ok || credentialEnvVars[key] || (conf.scrubAWSEnv && strings.HasPrefix(key, "AWS_")) {
+			continue
+		}
+		cmd.Env = append(cmd.Env, kv)
+	}
+	cmd.Env = append(cmd.Env, envSlice(env)...)
+
+	if cmd.Stdin == nil {
+		cmd.Stdin = os.Stdin
+	}
+	if cmd.Stdout == nil {
+		cmd.Stdout = os.Stdout
+	}
+	if cmd.Stderr == nil {
+		cmd.Stderr = os.Stderr
+	}
+
+	// Start listening before the command starts so no signal kills this process instead
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				_ = cmd.Process.Signal(sig)
+			case <-ctx.Done():
+				_ = cmd.Process.Kill()
+				return
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err = cmd.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return &ExitError{Code: exitErr.ExitCode(), Err: exitErr}
+	}
+	return err
+}
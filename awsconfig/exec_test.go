@@ -0,0 +1,143 @@
+//go:build unix
+
+package awsconfig
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+func TestRunWithCredentialsEnv(t *testing.T) {
+	t.Setenv("AWS_PROFILE", "stale-profile")
+	t.Setenv("AWS_SESSION_TOKEN", "stale-token")
+	t.Setenv("AWS_SECURITY_TOKEN", "stale-token")
+	t.Setenv("AWSCONFIG_TEST_INHERITED", "kept")
+	cfg := aws.Config{
+		Region:      "eu-west-1",
+		Credentials: credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", ""),
+	}
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "Inherited",
+			want:    []string{"AWS_ACCESS_KEY_ID=AKIDEXAMPLE", "AWS_REGION=eu-west-1", "AWS_PROFILE=stale-profile", "AWSCONFIG_TEST_INHERITED=kept"},
+			notWant: []string{"AWS_SESSION_TOKEN=", "AWS_SECURITY_TOKEN="},
+		},
+		{
+			name:    "WithoutInheritedAWSEnv",
+			opts:    []Option{WithoutInheritedAWSEnv()},
+			want:    []string{"AWS_ACCESS_KEY_ID=AKIDEXAMPLE", "AWS_REGION=eu-west-1", "AWSCONFIG_TEST_INHERITED=kept"},
+			notWant: []string{"AWS_PROFILE=", "AWS_SESSION_TOKEN=", "AWS_SECURITY_TOKEN="},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			cmd := exec.Command("env")
+			cmd.Stdout = &stdout
+			if err := RunWithCredentials(context.Background(), cfg, cmd, tt.opts...); err != nil {
+				t.Fatalf("RunWithCredentials: %v", err)
+			}
+			env := strings.Split(stdout.String(), "\n")
+			for _, want := range tt.want {
+				if !containsPrefix(env, want) {
+					t.Errorf("environment lacks %q", want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if containsPrefix(env, notWant) {
+					t.Errorf("environment has %q", notWant)
+				}
+			}
+		})
+	}
+}
+
+func TestExecWithCredentials(t *testing.T) {
+	t.Setenv("AWS_PROFILE", "stale-profile")
+	cfg := aws.Config{Credentials: credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", "")}
+
+	tests := []struct {
+		name     string
+		script   string
+		opts     []Option
+		timeout  time.Duration
+		wantCode int
+	}{
+		{name: "Success", script: `test "$AWS_ACCESS_KEY_ID" = AKIDEXAMPLE`},
+		{name: "ExitCode", script: "exit 3", wantCode: 3},
+		{name: "InheritsAWSEnv", script: `test -z "$AWS_PROFILE"`, wantCode: 1},
+		{name: "ScrubsAWSEnv", script: `test -z "$AWS_PROFILE"`, opts: []Option{WithoutInheritedAWSEnv()}},
+		{name: "KilledWhenDone", script: "exec sleep 10", timeout: 100 * time.Millisecond, wantCode: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+			err := ExecWithCredentials(ctx, cfg, "sh", []string{"-c", tt.script}, tt.opts...)
+			if tt.wantCode == 0 {
+				if err != nil {
+					t.Errorf("ExecWithCredentials: %v", err)
+				}
+				return
+			}
+			var exitErr *ExitError
+			if !errors.As(err, &exitErr) || exitErr.Code != tt.wantCode {
+				t.Errorf("ExecWithCredentials error %v, want exit code %d", err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestRunWithCredentialsForwardsSignals(t *testing.T) {
+	cfg := aws.Config{Credentials: credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", "")}
+	stdout, w := io.Pipe()
+	cmd := exec.Command("sh", "-c", `trap "exit 7" TERM; echo ready; while :; do sleep 0.01; done`)
+	cmd.Stdout = w
+
+	go func() {
+		// Signal this process once the command is ready to trap the signal
+		if _, err := bufio.NewReader(stdout).ReadString('\n'); err == nil {
+			_ = syscall.Kill(os.Getpid(), syscall.SIGTERM)
+		}
+		_, _ = io.Copy(io.Discard, stdout)
+	}()
+	err := RunWithCredentials(context.Background(), cfg, cmd)
+	w.Close()
+
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 7 {
+		t.Errorf("RunWithCredentials error %v, want exit code 7", err)
+	}
+}
+
+// containsPrefix reports whether an element of list starts with prefix
+func containsPrefix(list []string, prefix string) bool {
+	for _, s := range list {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
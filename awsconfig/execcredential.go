@@ -0,0 +1,79 @@
+package awsconfig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+var (
+	// ErrUnsupportedExecCredentialVersion is returned for an ExecCredential API version other than v1 and v1beta1
+	ErrUnsupportedExecCredentialVersion = errors.New("Unsupported ExecCredential API version")
+)
+
+// Kubernetes client authentication API versions accepted by WriteExecCredential
+const (
+	ExecCredentialV1      = "client.authentication.k8s.io/v1"
+	ExecCredentialV1beta1 = "client.authentication.k8s.io/v1beta1"
+)
+
+// execCredential is the envelope read by client-go from exec credential plugins
+type execCredential struct {
+	Kind       string               `json:"kind"`
+	APIVersion string               `json:"apiVersion"`
+	Spec       struct{}             `json:"spec"`
+	Status     execCredentialStatus `json:"status"`
+}
+
+// execCredentialStatus is the status of an execCredential
+type execCredentialStatus struct {
+	ExpirationTimestamp string `json:"expirationTimestamp,omitempty"`
+	Token               string `json:"token"`
+}
+
+// WriteExecCredential retrieves the credentials of cfg, mints a token from them
+// with tokenFn, such as one calling EKSToken, and writes it to w as the
+// ExecCredential JSON that kubectl and client-go expect on the standard output
+// of exec credential plugins. apiVersion is ExecCredentialV1, the default when
+// empty, or ExecCredentialV1beta1. A zero token expiry is left out.
+func WriteExecCredential(
+	ctx context.Context,
+	cfg aws.Config,
+	w io.Writer,
+	apiVersion string,
+	tokenFn func(aws.Credentials) (token string, expiry time.Time, err error),
+) error {
+	switch apiVersion {
+	case "":
+		apiVersion = ExecCredentialV1
+	case ExecCredentialV1, ExecCredentialV1beta1:
+	default:
+		return fmt.Errorf("%w, got %q", ErrUnsupportedExecCredentialVersion, apiVersion)
+	}
+	if cfg.Credentials == nil {
+		return ErrNilCredentials
+	}
+	creds, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrRetrieveCredentials, err)
+	}
+	token, expiry, err := tokenFn(creds)
+	if err != nil {
+		return err
+	}
+
+	cred := execCredential{
+		Kind:       "ExecCredential",
+		APIVersion: apiVersion,
+		Status:     execCredentialStatus{Token: token},
+	}
+	if !expiry.IsZero() {
+		cred.Status.ExpirationTimestamp = expiry.UTC().Format(time.RFC3339)
+	}
+	return json.NewEncoder(w).Encode(cred)
+}
@@ -0,0 +1,94 @@
+package awsconfig
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+func TestWriteExecCredential(t *testing.T) {
+	cfg := aws.Config{Credentials: credentials.NewStaticCredentialsProvider("ASIAEXAMPLE", "secret", "token")}
+	expiry := time.Date(2030, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600)).Add(time.Hour)
+	tokenFn := func(expiry time.Time) func(aws.Credentials) (string, time.Time, error) {
+		return func(creds aws.Credentials) (string, time.Time, error) {
+			return EKSTokenPrefix + "token-for-" + creds.AccessKeyID, expiry, nil
+		}
+	}
+
+	tests := []struct {
+		name       string
+		apiVersion string
+		expiry     time.Time
+		golden     string
+	}{
+		{name: "DefaultVersion", expiry: expiry, golden: "execcredential_v1.golden"},
+		{name: "V1", apiVersion: ExecCredentialV1, expiry: expiry, golden: "execcredential_v1.golden"},
+		{name: "V1beta1NoExpiry", apiVersion: ExecCredentialV1beta1, golden: "execcredential_v1beta1_noexpiry.golden"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := WriteExecCredential(context.Background(), cfg, &out, tt.apiVersion, tokenFn(tt.expiry)); err != nil {
+				t.Fatalf("WriteExecCredential: %v", err)
+			}
+			want, err := os.ReadFile(filepath.Join("testdata", tt.golden))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(out.Bytes(), want) {
+				t.Errorf("WriteExecCredential wrote\n%s\nwant\n%s", out.Bytes(), want)
+			}
+		})
+	}
+}
+
+func TestWriteExecCredentialErrors(t *testing.T) {
+	tokenErr := errors.New("cannot sign")
+	static := credentials.NewStaticCredentialsProvider("ASIAEXAMPLE", "secret", "token")
+	okToken := func(aws.Credentials) (string, time.Time, error) { return "token", time.Time{}, nil }
+
+	tests := []struct {
+		name       string
+		provider   aws.CredentialsProvider
+		apiVersion string
+		tokenFn    func(aws.Credentials) (string, time.Time, error)
+		wantErr    error
+	}{
+		{name: "Alpha", provider: static, apiVersion: "client.authentication.k8s.io/v1alpha1", tokenFn: okToken, wantErr: ErrUnsupportedExecCredentialVersion},
+		{name: "NotKubernetes", provider: static, apiVersion: "v1", tokenFn: okToken, wantErr: ErrUnsupportedExecCredentialVersion},
+		{name: "NilCredentials", tokenFn: okToken, wantErr: ErrNilCredentials},
+		{
+			name: "RetrieveFails",
+			provider: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+				return aws.Credentials{}, errors.New("no credentials")
+			}),
+			tokenFn: okToken,
+			wantErr: ErrRetrieveCredentials,
+		},
+		{
+			name:     "TokenFails",
+			provider: static,
+			tokenFn:  func(aws.Credentials) (string, time.Time, error) { return "", time.Time{}, tokenErr },
+			wantErr:  tokenErr,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := WriteExecCredential(context.Background(), aws.Config{Credentials: tt.provider}, &out, tt.apiVersion, tt.tokenFn)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("WriteExecCredential error %v, want %v", err, tt.wantErr)
+			}
+			if out.Len() > 0 {
+				t.Errorf("WriteExecCredential wrote %q after failing", out.String())
+			}
+		})
+	}
+}
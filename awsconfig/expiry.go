@@ -0,0 +1,36 @@
+package awsconfig
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// CredentialExpiry retrieves the credentials of cfg, from its cache when it has
+// one, and reports when they expire. canExpire is false for credentials that
+// never expire, such as static keys, in which case expiresAt is zero.
+func CredentialExpiry(ctx context.Context, cfg aws.Config) (expiresAt time.Time, canExpire bool, err error) {
+	if cfg.Credentials == nil {
+		return time.Time{}, false, ErrNilCredentials
+	}
+	creds, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("%w: %w", ErrRetrieveCredentials, err)
+	}
+	if !creds.CanExpire {
+		return time.Time{}, false, nil
+	}
+	return creds.Expires, true, nil
+}
+
+// TimeToExpiry is CredentialExpiry as the time left until expiry, which is
+// negative once the credentials have expired
+func TimeToExpiry(ctx context.Context, cfg aws.Config) (remaining time.Duration, canExpire bool, err error) {
+	expiresAt, canExpire, err := CredentialExpiry(ctx, cfg)
+	if err != nil || !canExpire {
+		return 0, canExpire, err
+	}
+	return expiresAt.Sub(timeNow()), true, nil
+}
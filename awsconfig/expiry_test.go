@@ -0,0 +1,70 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigtest"
+)
+
+func TestCredentialExpiry(t *testing.T) {
+	stubClock(t)
+	fake := &awsconfigtest.FakeSTS{Duration: time.Hour}
+	assumed, err := NewAssumeRoleConf(context.Background(), aws.Config{}, testRoleArn, WithSTSClient(fake), WithSkipIdentityCheck())
+	if err != nil {
+		t.Fatalf("NewAssumeRoleConf: %v", err)
+	}
+	tests := []struct {
+		name          string
+		cfg           aws.Config
+		wantCanExpire bool
+		wantErr       error
+	}{
+		{name: "Assumed", cfg: assumed, wantCanExpire: true},
+		{name: "Static", cfg: aws.Config{Credentials: credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", "")}},
+		{name: "NilCredentials", wantErr: ErrNilCredentials},
+		{
+			name: "RetrieveFails",
+			cfg: aws.Config{Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+				return aws.Credentials{}, errors.New("no credentials")
+			})},
+			wantErr: ErrRetrieveCredentials,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expiresAt, canExpire, err := CredentialExpiry(context.Background(), tt.cfg)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CredentialExpiry error %v, want %v", err, tt.wantErr)
+			}
+			if canExpire != tt.wantCanExpire || canExpire == expiresAt.IsZero() {
+				t.Errorf("CredentialExpiry %v, %t, want canExpire %t", expiresAt, canExpire, tt.wantCanExpire)
+			}
+
+			remaining, canExpire, err := TimeToExpiry(context.Background(), tt.cfg)
+			if !errors.Is(err, tt.wantErr) || canExpire != tt.wantCanExpire {
+				t.Fatalf("TimeToExpiry %v, %t, %v", remaining, canExpire, err)
+			}
+			var want time.Duration
+			if canExpire {
+				want = expiresAt.Sub(timeNow())
+			}
+			if remaining != want {
+				t.Errorf("TimeToExpiry %v, want %v", remaining, want)
+			}
+			if canExpire && (remaining < 59*time.Minute || remaining > 61*time.Minute) {
+				t.Errorf("TimeToExpiry %v for hour-long credentials", remaining)
+			}
+		})
+	}
+
+	// The expiry is read from the cache rather than forcing a refresh
+	if got := len(fake.AssumeRoleInputs()); got != 1 {
+		t.Errorf("AssumeRole called %d times, want 1", got)
+	}
+}
@@ -0,0 +1,93 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+var (
+	// ErrInsufficientValidity is returned when credentials expire sooner than WithMinValidity requires
+	ErrInsufficientValidity = errors.New("Credentials expire sooner than the required minimum validity")
+)
+
+// Environment variables set by ExportEnv
+const (
+	EnvAccessKeyID          = "AWS_ACCESS_KEY_ID"
+	EnvSecretAccessKey      = "AWS_SECRET_ACCESS_KEY"
+	EnvSessionToken         = "AWS_SESSION_TOKEN"
+	EnvCredentialExpiration = "AWS_CREDENTIAL_EXPIRATION"
+	EnvRegion               = "AWS_REGION"
+	EnvDefaultRegion        = "AWS_DEFAULT_REGION"
+)
+
+// WithMinValidity makes ExportEnv fail with ErrInsufficientValidity when the
+// credentials expire in less than d
+func WithMinValidity(d time.Duration) ConfOption {
+	return func(c *confOptions) {
+		c.minValidity = d
+	}
+}
+
+// ExportEnv retrieves the credentials of cfg and returns them as the AWS_*
+// environment variables understood by the SDKs and CLI, along with the region
+// when cfg has one. AWS_SESSION_TOKEN and AWS_CREDENTIAL_EXPIRATION are only
+// set for temporary credentials.
+func ExportEnv(ctx context.Context, cfg aws.Config, opts ...Option) (map[string]string, error) {
+	conf := newConfOptions(opts)
+	if cfg.Credentials == nil {
+		return nil, ErrNilCredentials
+	}
+	creds, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrRetrieveCredentials, err)
+	}
+	if creds.CanExpire && conf.minValidity > 0 {
+		if remaining := creds.Expires.Sub(timeNow()); remaining < conf.minValidity {
+			return nil, fmt.Errorf("%w of %s, got %s", ErrInsufficientValidity, conf.minValidity, remaining.Round(time.Second))
+		}
+	}
+
+	env := map[string]string{
+		EnvAccessKeyID:     creds.AccessKeyID,
+		EnvSecretAccessKey: creds.SecretAccessKey,
+	}
+	if creds.SessionToken != "" {
+		env[EnvSessionToken] = creds.SessionToken
+	}
+	if creds.CanExpire {
+		env[EnvCredentialExpiration] = creds.Expires.UTC().Format(time.RFC3339)
+	}
+	if cfg.Region != "" {
+		env[EnvRegion] = cfg.Region
+		env[EnvDefaultRegion] = cfg.Region
+	}
+	return env, nil
+}
+
+// ExportEnvSlice is ExportEnv in the "KEY=value" form of exec.Cmd.Env, sorted by key
+func ExportEnvSlice(ctx context.Context, cfg aws.Config, opts ...Option) ([]string, error) {
+	env, err := ExportEnv(ctx, cfg, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return envSlice(env), nil
+}
+
+// envSlice returns env in the "KEY=value" form, sorted by key
+func envSlice(env map[string]string) []string {
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	kvs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		kvs = append(kvs, key+"="+env[key])
+	}
+	return kvs
+}
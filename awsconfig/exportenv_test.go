@@ -0,0 +1,125 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+func TestExportEnv(t *testing.T) {
+	stubClock(t)
+	expires := timeNow().Add(time.Hour)
+	session := aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return aws.Credentials{
+			AccessKeyID: "ASIAEXAMPLE", SecretAccessKey: "secret", SessionToken: "token",
+			CanExpire: true, Expires: expires,
+		}, nil
+	})
+	retrieveErr := errors.New("no credentials")
+
+	tests := []struct {
+		name    string
+		cfg     aws.Config
+		opts    []Option
+		want    map[string]string
+		wantErr error
+	}{
+		{
+			name: "Static",
+			cfg:  aws.Config{Credentials: credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", "")},
+			want: map[string]string{EnvAccessKeyID: "AKIDEXAMPLE", EnvSecretAccessKey: "secret"},
+		},
+		{
+			name: "SessionWithRegion",
+			cfg:  aws.Config{Region: "eu-west-1", Credentials: session},
+			want: map[string]string{
+				EnvAccessKeyID:          "ASIAEXAMPLE",
+				EnvSecretAccessKey:      "secret",
+				EnvSessionToken:         "token",
+				EnvCredentialExpiration: expires.UTC().Format(time.RFC3339),
+				EnvRegion:               "eu-west-1",
+				EnvDefaultRegion:        "eu-west-1",
+			},
+		},
+		{
+			name: "MinValidityMet",
+			cfg:  aws.Config{Credentials: session},
+			opts: []Option{WithMinValidity(time.Hour)},
+			want: map[string]string{
+				EnvAccessKeyID:          "ASIAEXAMPLE",
+				EnvSecretAccessKey:      "secret",
+				EnvSessionToken:         "token",
+				EnvCredentialExpiration: expires.UTC().Format(time.RFC3339),
+			},
+		},
+		{
+			name:    "MinValidityNotMet",
+			cfg:     aws.Config{Credentials: session},
+			opts:    []Option{WithMinValidity(61 * time.Minute)},
+			wantErr: ErrInsufficientValidity,
+		},
+		{
+			name: "MinValidityIgnoredForStatic",
+			cfg:  aws.Config{Credentials: credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", "")},
+			opts: []Option{WithMinValidity(time.Hour)},
+			want: map[string]string{EnvAccessKeyID: "AKIDEXAMPLE", EnvSecretAccessKey: "secret"},
+		},
+		{name: "NilCredentials", wantErr: ErrNilCredentials},
+		{
+			name: "RetrieveFails",
+			cfg: aws.Config{Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+				return aws.Credentials{}, retrieveErr
+			})},
+			wantErr: retrieveErr,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExportEnv(context.Background(), tt.cfg, tt.opts...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ExportEnv error %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExportEnv = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExportEnvSlice(t *testing.T) {
+	cfg := aws.Config{
+		Region:      "eu-west-1",
+		Credentials: credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", ""),
+	}
+	got, err := ExportEnvSlice(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("ExportEnvSlice: %v", err)
+	}
+	want := []string{
+		"AWS_ACCESS_KEY_ID=AKIDEXAMPLE",
+		"AWS_DEFAULT_REGION=eu-west-1",
+		"AWS_REGION=eu-west-1",
+		"AWS_SECRET_ACCESS_KEY=secret",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ExportEnvSlice = %q, want %q", got, want)
+	}
+}
+
+func TestExportEnvRetrievesEachCall(t *testing.T) {
+	retrieve, calls := countingRetrieve(time.Hour)
+	cfg := aws.Config{Credentials: aws.CredentialsProviderFunc(retrieve)}
+	for range 2 {
+		if _, err := ExportEnv(context.Background(), cfg); err != nil {
+			t.Fatalf("ExportEnv: %v", err)
+		}
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("Retrieve called %d times, want 2", got)
+	}
+}
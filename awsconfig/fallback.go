@@ -0,0 +1,189 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+var (
+	// ErrFallbackFailed is returned when the secondary provider of a FallbackProvider fails too
+	ErrFallbackFailed = errors.New("Primary and fallback credentials providers failed")
+)
+
+const (
+	// DefaultFallbackThreshold is how many consecutive primary failures switch a FallbackProvider to its secondary
+	DefaultFallbackThreshold = 1
+	// DefaultRecoveryProbeInterval is how often a FallbackProvider on its secondary retries the primary
+	DefaultRecoveryProbeInterval = time.Minute
+)
+
+// FallbackEvent describes a FallbackProvider switching between its providers
+type FallbackEvent struct {
+	// OnFallback is true when switching to the secondary, false when switching back
+	OnFallback bool
+	// Err is the primary failure causing a switch to the secondary
+	Err error
+}
+
+// WithFallbackThreshold sets how many consecutive failures of the primary
+// provider switch a FallbackProvider to its secondary; until then the failure
+// is returned
+func WithFallbackThreshold(failures int) ConfOption {
+	return func(c *confOptions) {
+		c.fallbackThreshold = failures
+	}
+}
+
+// WithRecoveryProbeInterval sets how often a FallbackProvider running on its
+// secondary tries the primary again
+func WithRecoveryProbeInterval(interval time.Duration) ConfOption {
+	return func(c *confOptions) {
+		c.recoveryProbeInterval = interval
+	}
+}
+
+// WithFallbackCallback calls fn when a FallbackProvider switches between its
+// providers, e.g. to alert while running on the secondary. fn is called
+// synchronously from Retrieve, and must not block.
+func WithFallbackCallback(fn func(FallbackEvent)) ConfOption {
+	return func(c *confOptions) {
+		c.fallbackCallback = fn
+	}
+}
+
+// FallbackProvider implements the aws.CredentialsProvider interface with a
+// primary provider and a secondary one used only while the primary fails.
+// Once the primary fails the threshold number of consecutive times, the
+// secondary is used, and the primary is tried again every probe interval;
+// the first success switches back to it.
+type FallbackProvider struct {
+	primary       aws.CredentialsProvider
+	secondary     aws.CredentialsProvider
+	threshold     int
+	probeInterval time.Duration
+	callback      func(FallbackEvent)
+
+	mu         sync.Mutex
+	failures   int
+	onFallback bool
+	nextProbe  time.Time
+}
+
+// NewFallbackProvider initializes a new FallbackProvider, with the
+// DefaultFallbackThreshold and DefaultRecoveryProbeInterval unless set by opts
+func NewFallbackProvider(primary, secondary aws.CredentialsProvider, opts ...Option) *FallbackProvider {
+	conf := newConfOptions(opts)
+	p := &FallbackProvider{
+		primary:       primary,
+		secondary:     secondary,
+		threshold:     DefaultFallbackThreshold,
+		probeInterval: DefaultRecoveryProbeInterval,
+		callback:      conf.fallbackCallback,
+	}
+	if conf.fallbackThreshold > 0 {
+		p.threshold = conf.fallbackThreshold
+	}
+	if conf.recoveryProbeInterval > 0 {
+		p.probeInterval = conf.recoveryProbeInterval
+	}
+	return p
+}
+
+// OnFallback reports whether the secondary provider is in use
+func (p *FallbackProvider) OnFallback() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.onFallback
+}
+
+// Retrieve implements the aws.CredentialsProvider interface method
+func (p *FallbackProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	p.mu.Lock()
+	probe := !p.onFallback || !timeNow().Before(p.nextProbe)
+	p.mu.Unlock()
+
+	var primaryErr error
+	if probe {
+		creds, err := p.primary.Retrieve(ctx)
+		if p.recordPrimary(err) {
+			return creds, nil
+		}
+		if !p.OnFallback() {
+			return aws.Credentials{}, err
+		}
+		primaryErr = err
+	}
+
+	creds, err := p.secondary.Retrieve(ctx)
+	if err != nil {
+		if primaryErr != nil {
+			return aws.Credentials{}, fmt.Errorf("%w: %w", ErrFallbackFailed, errors.Join(primaryErr, err))
+		}
+		return aws.Credentials{}, fmt.Errorf("%w: %w", ErrFallbackFailed, err)
+	}
+	return creds, nil
+}
+
+// recordPrimary updates the state with the outcome of a primary Retrieve,
+// switching providers as needed, and reports whether it succeeded
+func (p *FallbackProvider) recordPrimary(err error) bool {
+	p.mu.Lock()
+	var event *FallbackEvent
+	if err == nil {
+		p.failures = 0
+		if p.onFallback {
+			p.onFallback = false
+			event = &FallbackEvent{OnFallback: false}
+		}
+	} else {
+		p.failures++
+		p.nextProbe = timeNow().Add(p.probeInterval)
+		if !p.onFallback && p.failures >= p.threshold {
+			p.onFallback = true
+			event = &FallbackEvent{OnFallback: true, Err: err}
+		}
+	}
+	p.mu.Unlock()
+
+	if event != nil && p.callback != nil {
+		p.callback(*event)
+	}
+	return err == nil
+}
+
+// NewFallbackConf returns an aws.Config using credentials from primary, or
+// secondary while primary fails, cached until they expire; see
+// FallbackProvider. The legs may be the Credentials of configs built by this
+// package.
+func NewFallbackConf(
+	_ context.Context,
+	cfg aws.Config,
+	primary, secondary aws.CredentialsProvider,
+	opts ...Option,
+) (aws.Config, error) {
+	conf := newConfOptions(opts)
+	if primary == nil || secondary == nil {
+		return aws.Config{}, fmt.Errorf("%w: primary and secondary providers are required", ErrNilCredentials)
+	}
+	provider := NewFallbackProvider(primary, secondary, opts...)
+
+	newCfg := cfg.Copy()
+	newCfg.Credentials = aws.NewCredentialsCache(withStats(provider), conf.cacheOpts...)
+	return newCfg, nil
+}
+
+// String implements the fmt.Stringer interface method, printing only its
+// settings and state, not the wrapped providers
+func (p *FallbackProvider) String() string {
+	return fmt.Sprintf("FallbackProvider{Threshold: %d, RecoveryProbeInterval: %s, OnFallback: %t}", p.threshold, p.probeInterval, p.OnFallback())
+}
+
+// GoString implements the fmt.GoStringer interface method, so %#v prints no more than String
+func (p *FallbackProvider) GoString() string {
+	return "&awsconfig." + p.String()
+}
@@ -0,0 +1,155 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestFallbackProvider(t *testing.T) {
+	errPrimary := errors.New("broker down")
+
+	// fallbackStep fails the primary with err, advances the clock, then retrieves
+	type fallbackStep struct {
+		err          error
+		advance      time.Duration
+		wantKeyID    string
+		wantErr      error
+		wantFallback bool
+	}
+	tests := []struct {
+		name       string
+		opts       []Option
+		steps      []fallbackStep
+		wantEvents []bool
+	}{
+		{
+			name: "DefaultThreshold",
+			steps: []fallbackStep{
+				{wantKeyID: "primary"},
+				{err: errPrimary, wantKeyID: "secondary", wantFallback: true},
+				{wantKeyID: "secondary", wantFallback: true},
+				{advance: time.Minute, wantKeyID: "primary"},
+			},
+			wantEvents: []bool{true, false},
+		},
+		{
+			name: "Threshold",
+			opts: []Option{WithFallbackThreshold(3)},
+			steps: []fallbackStep{
+				{err: errPrimary, wantErr: errPrimary},
+				{err: errPrimary, wantErr: errPrimary},
+				{err: errPrimary, wantKeyID: "secondary", wantFallback: true},
+			},
+			wantEvents: []bool{true},
+		},
+		{
+			name: "ThresholdResetBySuccess",
+			opts: []Option{WithFallbackThreshold(2)},
+			steps: []fallbackStep{
+				{err: errPrimary, wantErr: errPrimary},
+				{wantKeyID: "primary"},
+				{err: errPrimary, wantErr: errPrimary},
+			},
+		},
+		{
+			name: "ProbeStillFailing",
+			opts: []Option{WithRecoveryProbeInterval(5 * time.Minute)},
+			steps: []fallbackStep{
+				{err: errPrimary, wantKeyID: "secondary", wantFallback: true},
+				{advance: 4 * time.Minute, wantKeyID: "secondary", wantFallback: true},
+				{err: errPrimary, advance: time.Minute, wantKeyID: "secondary", wantFallback: true},
+				// The failed probe postponed the next one by the interval
+				{advance: 4 * time.Minute, wantKeyID: "secondary", wantFallback: true},
+				{advance: time.Minute, wantKeyID: "primary"},
+			},
+			wantEvents: []bool{true, false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			advance := stubClock(t)
+			primary, secondary := &chainLink{name: "primary"}, &chainLink{name: "secondary"}
+			var events []bool
+			opts := append([]Option{WithFallbackCallback(func(e FallbackEvent) {
+				if e.OnFallback && !errors.Is(e.Err, errPrimary) {
+					t.Errorf("FallbackEvent error %v, want %v", e.Err, errPrimary)
+				}
+				events = append(events, e.OnFallback)
+			})}, tt.opts...)
+			p := NewFallbackProvider(primary, secondary, opts...)
+
+			for i, step := range tt.steps {
+				primary.fail(step.err)
+				advance(step.advance)
+				creds, err := p.Retrieve(context.Background())
+				if !errors.Is(err, step.wantErr) {
+					t.Fatalf("step %d: Retrieve error %v, want %v", i, err, step.wantErr)
+				}
+				if creds.AccessKeyID != step.wantKeyID {
+					t.Errorf("step %d: credentials from %q, want %q", i, creds.AccessKeyID, step.wantKeyID)
+				}
+				if p.OnFallback() != step.wantFallback {
+					t.Errorf("step %d: OnFallback %t, want %t", i, p.OnFallback(), step.wantFallback)
+				}
+			}
+			if len(events) != len(tt.wantEvents) {
+				t.Fatalf("events %v, want %v", events, tt.wantEvents)
+			}
+			for i := range events {
+				if events[i] != tt.wantEvents[i] {
+					t.Errorf("events %v, want %v", events, tt.wantEvents)
+				}
+			}
+		})
+	}
+}
+
+func TestFallbackProviderBothFail(t *testing.T) {
+	errPrimary, errSecondary := errors.New("broker down"), errors.New("no base credentials")
+	advance := stubClock(t)
+	primary := &chainLink{name: "primary", err: errPrimary}
+	secondary := &chainLink{name: "secondary", err: errSecondary}
+	p := NewFallbackProvider(primary, secondary)
+
+	_, err := p.Retrieve(context.Background())
+	for _, want := range []error{ErrFallbackFailed, errPrimary, errSecondary} {
+		if !errors.Is(err, want) {
+			t.Errorf("Retrieve error %v, want %v", err, want)
+		}
+	}
+
+	// Between probes only the secondary is tried
+	advance(time.Second)
+	_, err = p.Retrieve(context.Background())
+	if !errors.Is(err, ErrFallbackFailed) || !errors.Is(err, errSecondary) || errors.Is(err, errPrimary) {
+		t.Errorf("Retrieve error %v, want %v wrapping only %v", err, ErrFallbackFailed, errSecondary)
+	}
+	if primary.Calls() != 1 {
+		t.Errorf("primary called %d times, want 1", primary.Calls())
+	}
+}
+
+func TestNewFallbackConf(t *testing.T) {
+	stubClock(t)
+	primary := &chainLink{name: "primary", err: errors.New("broker down")}
+	secondary := aws.NewCredentialsCache(&chainLink{name: "secondary"})
+	cfg, err := NewFallbackConf(context.Background(), aws.Config{}, primary, secondary)
+	if err != nil {
+		t.Fatalf("NewFallbackConf: %v", err)
+	}
+	creds, err := cfg.Credentials.Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("Retrieve: %v", err)
+	}
+	if creds.AccessKeyID != "secondary" {
+		t.Errorf("credentials from %q, want secondary", creds.AccessKeyID)
+	}
+
+	if _, err := NewFallbackConf(context.Background(), aws.Config{}, primary, nil); !errors.Is(err, ErrNilCredentials) {
+		t.Errorf("NewFallbackConf error %v, want %v", err, ErrNilCredentials)
+	}
+}
@@ -0,0 +1,63 @@
+package awsconfig
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+const (
+	// DefaultFanOutConcurrency is how many accounts AssumeRoleInAccounts assumes into at once
+	DefaultFanOutConcurrency = 8
+)
+
+// WithConcurrency limits how many roles AssumeRoleInAccounts assumes at once
+func WithConcurrency(n int) ConfOption {
+	return func(c *confOptions) {
+		c.concurrency = n
+	}
+}
+
+// AssumeRoleInAccounts assumes roleName in each of accountIDs, as
+// NewAssumeRoleConfByName does, and returns the configs of the accounts that
+// succeeded and the errors of those that failed, each keyed by account ID. One
+// account failing doesn't stop the others; once ctx is done, accounts not yet
+// started fail with its error.
+func AssumeRoleInAccounts(
+	ctx context.Context,
+	cfg aws.Config,
+	accountIDs []string,
+	roleName string,
+	opts ...Option,
+) (map[string]aws.Config, map[string]error) {
+	conf := newConfOptions(opts)
+
+	// Assume into each account once, in the order given
+	var unique []string
+	seen := make(map[string]bool, len(accountIDs))
+	for _, accountID := range accountIDs {
+		if !seen[accountID] {
+			seen[accountID] = true
+			unique = append(unique, accountID)
+		}
+	}
+
+	// Accounts not started once ctx is done get its error, so the batch error is redundant
+	results := make([]Result, len(unique))
+	runBatch(ctx, len(unique), conf.concurrency, func(i int) {
+		results[i].Config, results[i].Err = NewAssumeRoleConfByName(ctx, cfg, unique[i], roleName, opts...)
+	}, func(i int, err error) {
+		results[i].Err = err
+	})
+
+	configs := make(map[string]aws.Config, len(unique))
+	errs := make(map[string]error)
+	for i, result := range results {
+		if result.Err != nil {
+			errs[unique[i]] = result.Err
+			continue
+		}
+		configs[unique[i]] = result.Config
+	}
+	return configs, errs
+}
@@ -0,0 +1,148 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigtest"
+)
+
+// accountSTS fails AssumeRole into the accounts in deny, and otherwise defers to
+// FakeSTS after calling onAssume, if set, with the account assumed into
+type accountSTS struct {
+	*awsconfigtest.FakeSTS
+	deny     map[string]error
+	onAssume func(accountID string)
+}
+
+// AssumeRole implements the STSClient interface method
+func (s *accountSTS) AssumeRole(
+	ctx context.Context,
+	params *sts.AssumeRoleInput,
+	optFns ...func(*sts.Options),
+) (*sts.AssumeRoleOutput, error) {
+	accountID := strings.Split(aws.ToString(params.RoleArn), ":")[4]
+	if s.onAssume != nil {
+		s.onAssume(accountID)
+	}
+	if err := s.deny[accountID]; err != nil {
+		return nil, err
+	}
+	return s.FakeSTS.AssumeRole(ctx, params, optFns...)
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys[V any](m map[string]V) string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, " ")
+}
+
+func TestAssumeRoleInAccounts(t *testing.T) {
+	denied := &smithy.GenericAPIError{Code: "AccessDenied", Message: "denied"}
+	tests := []struct {
+		name        string
+		accountIDs  []string
+		deny        map[string]error
+		wantConfigs string
+		wantErrs    string
+	}{
+		{
+			name:        "PartialSuccess",
+			accountIDs:  []string{"111111111111", "222222222222", "333333333333"},
+			deny:        map[string]error{"222222222222": denied},
+			wantConfigs: "111111111111 333333333333",
+			wantErrs:    "222222222222",
+		},
+		{
+			name:        "Duplicates",
+			accountIDs:  []string{"111111111111", "111111111111"},
+			wantConfigs: "111111111111",
+		},
+		{
+			name:        "BadAccountID",
+			accountIDs:  []string{"1234", "111111111111"},
+			wantConfigs: "111111111111",
+			wantErrs:    "1234",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &accountSTS{FakeSTS: &awsconfigtest.FakeSTS{}, deny: tt.deny}
+			configs, errs := AssumeRoleInAccounts(context.Background(), aws.Config{Region: "us-east-1"},
+				tt.accountIDs, "Audit", WithSTSClient(client), WithPreWarm())
+			if got := sortedKeys(configs); got != tt.wantConfigs {
+				t.Errorf("configs for %q, want %q", got, tt.wantConfigs)
+			}
+			if got := sortedKeys(errs); got != tt.wantErrs {
+				t.Errorf("errors for %q, want %q: %v", got, tt.wantErrs, errs)
+			}
+			for accountID, err := range tt.deny {
+				if !errors.Is(errs[accountID], err) {
+					t.Errorf("error for %s %v, want %v", accountID, errs[accountID], err)
+				}
+			}
+		})
+	}
+}
+
+func TestAssumeRoleInAccountsConcurrency(t *testing.T) {
+	var mu sync.Mutex
+	inflight, peak := 0, 0
+	client := &accountSTS{FakeSTS: &awsconfigtest.FakeSTS{}, onAssume: func(string) {
+		mu.Lock()
+		inflight++
+		peak = max(peak, inflight)
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		inflight--
+		mu.Unlock()
+	}}
+	var accountIDs []string
+	for i := range 8 {
+		accountIDs = append(accountIDs, fmt.Sprintf("%012d", i+1))
+	}
+	configs, errs := AssumeRoleInAccounts(context.Background(), aws.Config{Region: "us-east-1"},
+		accountIDs, "Audit", WithSTSClient(client), WithPreWarm(), WithConcurrency(2))
+	if len(configs) != len(accountIDs) || len(errs) != 0 {
+		t.Fatalf("%d configs and errors %v, want %d configs", len(configs), errs, len(accountIDs))
+	}
+	if peak != 2 {
+		t.Errorf("%d roles assumed at once, want 2", peak)
+	}
+}
+
+func TestAssumeRoleInAccountsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// Cancel the batch while the first account is being assumed
+	client := &accountSTS{FakeSTS: &awsconfigtest.FakeSTS{}, onAssume: func(string) { cancel() }}
+	accountIDs := []string{"111111111111", "222222222222", "333333333333"}
+	configs, errs := AssumeRoleInAccounts(ctx, aws.Config{Region: "us-east-1"},
+		accountIDs, "Audit", WithSTSClient(client), WithPreWarm(), WithConcurrency(1))
+	if len(configs)+len(errs) != len(accountIDs) {
+		t.Fatalf("results for %d accounts, want %d", len(configs)+len(errs), len(accountIDs))
+	}
+	for _, accountID := range accountIDs[1:] {
+		if !errors.Is(errs[accountID], context.Canceled) {
+			t.Errorf("error for %s %v, want %v", accountID, errs[accountID], context.Canceled)
+		}
+	}
+	if got := len(client.AssumeRoleInputs()); got != 1 {
+		t.Errorf("AssumeRole called %d times, want 1", got)
+	}
+}
@@ -0,0 +1,219 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/aws/smithy-go"
+)
+
+// FederationTokenProviderName is the Source of credentials returned by FederationTokenProvider
+const FederationTokenProviderName = SourcePrefix + "federationtoken"
+
+var (
+	// ErrGetFederationToken is returned when GetFederationToken fails
+	ErrGetFederationToken = errors.New("Cannot get federation token for passed aws.Config")
+	// ErrInvalidFederatedUserName is returned for names GetFederationToken would reject
+	ErrInvalidFederatedUserName = errors.New("Federated user name must be 2-32 characters of [\\w+=,.@-]")
+	// ErrFederationRequiresIAMUser is returned when the base credentials are not
+	// long-term IAM user credentials, which GetFederationToken requires.
+	ErrFederationRequiresIAMUser = errors.New("GetFederationToken requires long-term IAM user credentials")
+)
+
+// federatedUserNamePattern is the STS constraint on GetFederationToken Name
+var federatedUserNamePattern = regexp.MustCompile(`^[\w+=,.@-]{2,32}$`)
+
+// GetFederationTokenAPIClient is a client capable of the STS GetFederationToken operation.
+type GetFederationTokenAPIClient interface {
+	GetFederationToken(ctx context.Context, params *sts.GetFederationTokenInput, optFns ...func(*sts.Options)) (*sts.GetFederationTokenOutput, error)
+}
+
+// FederationTokenOptions is the configurable options for FederationTokenProvider
+type FederationTokenOptions struct {
+	// Client implementation of the GetFederationToken operation
+	Client GetFederationTokenAPIClient
+
+	// Name of the federated user
+	Name string
+
+	// Expiry duration of the STS credentials; STS assigns a default if unset
+	Duration time.Duration
+
+	// Optional inline session policy
+	Policy *string
+
+	// Optional managed session policy ARNs
+	PolicyARNs []types.PolicyDescriptorType
+
+	// Optional session tags
+	Tags []types.Tag
+}
+
+// FederationTokenProvider implements the aws.CredentialsProvider interface
+type FederationTokenProvider struct {
+	options FederationTokenOptions
+
+	// baseCreds signs the GetFederationToken calls, if known, and is checked
+	// for a session token when STS denies access
+	baseCreds aws.CredentialsProvider
+}
+
+// NewFederationTokenProvider initializes a new FederationTokenProvider instance
+func NewFederationTokenProvider(
+	client GetFederationTokenAPIClient,
+	name string,
+	opts ...func(*FederationTokenOptions),
+) *FederationTokenProvider {
+	o := FederationTokenOptions{
+		Client: client,
+		Name:   name,
+	}
+	for _, fn := range opts {
+		fn(&o)
+	}
+	return &FederationTokenProvider{options: o}
+}
+
+// Retrieve implements the aws.CredentialsProvider interface method
+func (p *FederationTokenProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	input := &sts.GetFederationTokenInput{
+		Name:       aws.String(p.options.Name),
+		Policy:     p.options.Policy,
+		PolicyArns: p.options.PolicyARNs,
+		Tags:       p.options.Tags,
+	}
+	if p.options.Duration != 0 {
+		input.DurationSeconds = aws.Int32(int32(p.options.Duration / time.Second))
+	}
+
+	resp, err := p.options.Client.GetFederationToken(ctx, input)
+	if err != nil {
+		// Base credentials may have become temporary since construction
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "AccessDenied" && p.baseCreds != nil {
+			if baseErr := checkLongTermCredentials(ctx, p.baseCreds); errors.Is(baseErr, ErrFederationRequiresIAMUser) {
+				return aws.Credentials{}, fmt.Errorf("%w: %w", baseErr, err)
+			}
+		}
+		return aws.Credentials{}, fmt.Errorf("%w: %w", ErrGetFederationToken, err)
+	}
+	if resp == nil || resp.Credentials == nil {
+		return aws.Credentials{}, fmt.Errorf("%w: response has no credentials", ErrGetFederationToken)
+	}
+
+	return aws.Credentials{
+		AccessKeyID:     aws.ToString(resp.Credentials.AccessKeyId),
+		SecretAccessKey: aws.ToString(resp.Credentials.SecretAccessKey),
+		SessionToken:    aws.ToString(resp.Credentials.SessionToken),
+		Source:          FederationTokenProviderName,
+		CanExpire:       true,
+		Expires:         aws.ToTime(resp.Credentials.Expiration),
+	}, nil
+}
+
+// NewFederationTokenConf returns an aws.Config using credentials for federated
+// user name obtained via GetFederationToken, with auto-refreshing credentials
+// and optional FederationTokenOptions and ConfOptions, such as WithSTSRegion.
+func NewFederationTokenConf(
+	ctx context.Context,
+	cfg aws.Config,
+	name string,
+	opts ...Option,
+) (aws.Config, error) {
+	conf := newConfOptions(opts)
+	if !federatedUserNamePattern.MatchString(name) {
+		return aws.Config{}, fmt.Errorf("%w: %q", ErrInvalidFederatedUserName, name)
+	}
+	if err := validateSTSEndpoint(conf); err != nil {
+		return aws.Config{}, err
+	}
+
+	// Session credentials cannot call GetFederationToken
+	if cfg.Credentials != nil {
+		if err := checkLongTermCredentials(ctx, cfg.Credentials); err != nil {
+			return aws.Config{}, err
+		}
+	}
+
+	stsClient, ok := newSTSClient(cfg, conf).(GetFederationTokenAPIClient)
+	if !ok {
+		return aws.Config{}, fmt.Errorf("%w: STS client does not support GetFederationToken", ErrGetFederationToken)
+	}
+	provider := NewFederationTokenProvider(stsClient, name, conf.federationTokenOpts...)
+	provider.baseCreds = cfg.Credentials
+
+	newCfg := cfg.Copy()
+	newCfg.Credentials = aws.NewCredentialsCache(
+		withSource(FederationTokenProviderName, withStats(provider)),
+		conf.cacheOpts...,
+	)
+	return newCfg, nil
+}
+
+// checkLongTermCredentials returns ErrFederationRequiresIAMUser if creds
+// retrieves temporary credentials
+func checkLongTermCredentials(ctx context.Context, creds aws.CredentialsProvider) error {
+	base, err := creds.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrRetrieveCredentials, err)
+	}
+	if base.SessionToken != "" {
+		return fmt.Errorf("%w: base credentials from %q are temporary", ErrFederationRequiresIAMUser, base.Source)
+	}
+	return nil
+}
+
+// WithFederationDuration sets the federation token duration
+func WithFederationDuration(duration time.Duration) FederationTokenOption {
+	return func(o *FederationTokenOptions) {
+		o.Duration = duration
+	}
+}
+
+// WithFederationPolicy sets an inline session policy for the federated user
+func WithFederationPolicy(policy string) FederationTokenOption {
+	return func(o *FederationTokenOptions) {
+		o.Policy = aws.String(policy)
+	}
+}
+
+// WithFederationPolicyArns sets managed policy ARNs for the federated user
+func WithFederationPolicyArns(arns []string) FederationTokenOption {
+	var inputPolicyARNs []types.PolicyDescriptorType
+	for _, arn := range arns {
+		inputPolicyARNs = append(
+			inputPolicyARNs,
+			types.PolicyDescriptorType{
+				Arn: aws.String(arn),
+			},
+		)
+	}
+	return func(o *FederationTokenOptions) {
+		o.PolicyARNs = inputPolicyARNs
+	}
+}
+
+// WithFederationTags attaches session tags to the federated user
+func WithFederationTags(tags map[string]string) FederationTokenOption {
+	inputTags := sortedTags(tags)
+	return func(o *FederationTokenOptions) {
+		o.Tags = inputTags
+	}
+}
+
+// String implements the fmt.Stringer interface method, printing only the
+// federated user name, never the STS client
+func (p *FederationTokenProvider) String() string {
+	return fmt.Sprintf("FederationTokenProvider{Source: %q, Name: %q}", FederationTokenProviderName, p.options.Name)
+}
+
+// GoString implements the fmt.GoStringer interface method, so %#v prints no more than String
+func (p *FederationTokenProvider) GoString() string {
+	return "&awsconfig." + p.String()
+}
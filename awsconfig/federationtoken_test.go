@@ -0,0 +1,137 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigtest"
+)
+
+// rotatingCredentials returns long-term credentials first, then session credentials
+type rotatingCredentials struct {
+	calls int
+}
+
+// Retrieve implements the aws.CredentialsProvider interface method
+func (r *rotatingCredentials) Retrieve(context.Context) (aws.Credentials, error) {
+	r.calls++
+	creds := aws.Credentials{AccessKeyID: "AKIAFAKEBASEACCESSKEY", SecretAccessKey: "secret", Source: "rotating"}
+	if r.calls > 1 {
+		creds.AccessKeyID, creds.SessionToken = "ASIAFAKEBASEACCESSKEY", "token"
+	}
+	return creds, nil
+}
+
+func TestNewFederationTokenConf(t *testing.T) {
+	tests := []struct {
+		name      string
+		userName  string
+		creds     aws.CredentialsProvider
+		opts      []Option
+		wantErr   error
+		wantCalls int
+	}{
+		{name: "Valid", userName: "plugin@example.com", wantCalls: 1},
+		{name: "NameTooShort", userName: "p", wantErr: ErrInvalidFederatedUserName},
+		{name: "NameTooLong", userName: strings.Repeat("p", 33), wantErr: ErrInvalidFederatedUserName},
+		{name: "NameBadCharacter", userName: "plugin/1", wantErr: ErrInvalidFederatedUserName},
+		{
+			name:     "STSClientWithoutFederation",
+			userName: "plugin",
+			opts:     []Option{WithSTSClient(&awsconfigtest.FakeSTS{})},
+			wantErr:  ErrGetFederationToken,
+		},
+		{
+			name:     "SessionCredentials",
+			userName: "plugin",
+			creds:    credentials.NewStaticCredentialsProvider("ASIAFAKEBASEACCESSKEY", "secret", "token"),
+			wantErr:  ErrFederationRequiresIAMUser,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, cfg := newSTSServer(t)
+			if tt.creds != nil {
+				cfg.Credentials = tt.creds
+			}
+			opts := append([]Option{
+				WithFederationDuration(time.Hour),
+				WithFederationPolicy(`{"Version":"2012-10-17"}`),
+				WithFederationPolicyArns([]string{"arn:aws:iam::aws:policy/ReadOnlyAccess"}),
+				WithFederationTags(map[string]string{"b": "2", "a": "1"}),
+			}, tt.opts...)
+			newCfg, err := NewFederationTokenConf(context.Background(), cfg, tt.userName, opts...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewFederationTokenConf error %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			creds, err := newCfg.Credentials.Retrieve(context.Background())
+			if err != nil {
+				t.Fatalf("Retrieve: %v", err)
+			}
+			if !strings.HasPrefix(creds.AccessKeyID, "ASIAFAKE") || !creds.CanExpire || creds.Source != FederationTokenProviderName {
+				t.Errorf("Retrieve returned %+v", creds)
+			}
+
+			reqs := srv.Requests()
+			if len(reqs) != tt.wantCalls {
+				t.Fatalf("STS called %d times, want %d", len(reqs), tt.wantCalls)
+			}
+			req := reqs[0]
+			for key, want := range map[string]string{
+				"Action":                  "GetFederationToken",
+				"Name":                    tt.userName,
+				"DurationSeconds":         "3600",
+				"Policy":                  `{"Version":"2012-10-17"}`,
+				"PolicyArns.member.1.arn": "arn:aws:iam::aws:policy/ReadOnlyAccess",
+				"Tags.member.1.Key":       "a",
+				"Tags.member.2.Key":       "b",
+			} {
+				if got := req.Get(key); got != want {
+					t.Errorf("GetFederationToken %s = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFederationTokenProviderErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    string
+		creds   aws.CredentialsProvider
+		wantErr error
+	}{
+		{name: "AccessDenied", code: "AccessDenied", wantErr: ErrGetFederationToken},
+		{name: "BaseBecameTemporary", code: "AccessDenied", creds: &rotatingCredentials{}, wantErr: ErrFederationRequiresIAMUser},
+		{name: "OtherError", code: "MalformedPolicyDocument", creds: &rotatingCredentials{}, wantErr: ErrGetFederationToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, cfg := newSTSServer(t)
+			srv.Errors = map[string]string{"GetFederationToken": tt.code}
+			if tt.creds != nil {
+				cfg.Credentials = tt.creds
+			}
+			newCfg, err := NewFederationTokenConf(context.Background(), cfg, "plugin")
+			if err != nil {
+				t.Fatalf("NewFederationTokenConf: %v", err)
+			}
+			_, err = newCfg.Credentials.Retrieve(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Retrieve error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != ErrFederationRequiresIAMUser && errors.Is(err, ErrFederationRequiresIAMUser) {
+				t.Errorf("Retrieve error %v blames the base credentials", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,293 @@
+package awsconfig
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+)
+
+var (
+	// ErrFileCache is returned, or passed to the warning handler, when a file cache
+	// cannot be written or locked
+	ErrFileCache = errors.New("Cannot update credential cache file")
+)
+
+// WithFileCache persists assumed credentials in dir, or the AWS CLI cache
+// directory when empty, in the AWS CLI format and file naming, so short-lived
+// processes and the CLI reuse each other's sessions until near expiry
+func WithFileCache(dir string) ConfOption {
+	return func(c *confOptions) {
+		c.fileCache = true
+		c.fileCacheDir = dir
+		c.fileCacheShared = false
+	}
+}
+
+// DefaultCLICacheDir returns the directory the AWS CLI caches assumed credentials in
+func DefaultCLICacheDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".aws", "cli", "cache"), nil
+}
+
+// CLICacheKey returns the AWS CLI cache file name, without the .json extension,
+// of an AssumeRole request: the SHA-1 of its parameters serialized as Python's
+// json.dumps(sort_keys=True) does, leaving out the session name as the CLI does.
+func CLICacheKey(o stscreds.AssumeRoleOptions) (string, error) {
+	args := map[string]any{
+		"RoleArn": o.RoleARN,
+	}
+	if o.Duration != 0 {
+		args["DurationSeconds"] = json.Number(strconv.Itoa(int(o.Duration / time.Second)))
+	}
+	if o.ExternalID != nil {
+		args["ExternalId"] = *o.ExternalID
+	}
+	if o.SerialNumber != nil {
+		args["SerialNumber"] = *o.SerialNumber
+	}
+	if o.SourceIdentity != nil {
+		args["SourceIdentity"] = *o.SourceIdentity
+	}
+	if o.Policy != nil {
+		// The CLI hashes the parsed policy, so its keys are sorted too
+		dec := json.NewDecoder(strings.NewReader(*o.Policy))
+		dec.UseNumber()
+		var policy any
+		if err := dec.Decode(&policy); err != nil {
+			return "", fmt.Errorf("%w: %w", ErrInvalidSessionPolicy, err)
+		}
+		args["Policy"] = policy
+	}
+	if len(o.PolicyARNs) > 0 {
+		arns := make([]any, 0, len(o.PolicyARNs))
+		for _, policyArn := range o.PolicyARNs {
+			arns = append(arns, map[string]any{"arn": aws.ToString(policyArn.Arn)})
+		}
+		args["PolicyArns"] = arns
+	}
+	if len(o.Tags) > 0 {
+		tags := make([]any, 0, len(o.Tags))
+		for _, tag := range o.Tags {
+			tags = append(tags, map[string]any{"Key": aws.ToString(tag.Key), "Value": aws.ToString(tag.Value)})
+		}
+		args["Tags"] = tags
+	}
+	if len(o.TransitiveTagKeys) > 0 {
+		keys := make([]any, 0, len(o.TransitiveTagKeys))
+		for _, key := range o.TransitiveTagKeys {
+			keys = append(keys, key)
+		}
+		args["TransitiveTagKeys"] = keys
+	}
+
+	var b bytes.Buffer
+	writePythonJSON(&b, args)
+	hash := sha1.Sum(b.Bytes())
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// writePythonJSON serializes v, as decoded by encoding/json, the way Python's
+// json.dumps(v, sort_keys=True) does
+func writePythonJSON(b *bytes.Buffer, v any) {
+	switch v := v.(type) {
+	case nil:
+		b.WriteString("null")
+	case bool:
+		b.WriteString(strconv.FormatBool(v))
+	case json.Number:
+		b.WriteString(v.String())
+	case string:
+		writePythonString(b, v)
+	case []any:
+		b.WriteByte('[')
+		for i, elem := range v {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			writePythonJSON(b, elem)
+		}
+		b.WriteByte(']')
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		b.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			writePythonString(b, key)
+			b.WriteString(": ")
+			writePythonJSON(b, v[key])
+		}
+		b.WriteByte('}')
+	}
+}
+
+// writePythonString writes s as a JSON string with Python's ASCII-only escaping
+func writePythonString(b *bytes.Buffer, s string) {
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"':
+			b.WriteString(`\"`)
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\b':
+			b.WriteString(`\b`)
+		case r == '\f':
+			b.WriteString(`\f`)
+		case r < 0x20 || r >= 0x7f:
+			if r > 0xffff {
+				r1, r2 := utf16.EncodeRune(r)
+				fmt.Fprintf(b, `\u%04x\u%04x`, r1, r2)
+			} else {
+				fmt.Fprintf(b, `\u%04x`, r)
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+}
+
+// cliCacheEntry is the part of the AWS CLI assumed-role cache file read and written
+type cliCacheEntry struct {
+	Credentials struct {
+		AccessKeyID     string `json:"AccessKeyId"`
+		SecretAccessKey string
+		SessionToken    string
+		Expiration      string
+	}
+}
+
+// NewFileCache returns a CredentialCache storing credentials in the AWS CLI
+// cache format in dir, or in DefaultCLICacheDir when empty, one file per key
+// named as the CLI does; see CLICacheKey
+func NewFileCache(dir string) CredentialCache {
+	return &fileCache{dir: dir}
+}
+
+// fileCache is the CredentialCache of a directory of AWS CLI cache files
+type fileCache struct {
+	dir         string
+	lockTimeout time.Duration
+}
+
+// filename returns the cache file of key, which must be a plain file name
+func (c *fileCache) filename(key string) (string, error) {
+	if key == "" || !filepath.IsLocal(key) || strings.ContainsAny(key, `/\`) {
+		return "", fmt.Errorf("%w: invalid key %q", ErrFileCache, key)
+	}
+	dir := c.dir
+	if dir == "" {
+		var err error
+		if dir, err = DefaultCLICacheDir(); err != nil {
+			return "", fmt.Errorf("%w: %w", ErrFileCache, err)
+		}
+	}
+	return filepath.Join(dir, key+".json"), nil
+}
+
+// Get implements the CredentialCache interface method; missing or corrupt files are a miss
+func (c *fileCache) Get(_ context.Context, key string) (aws.Credentials, bool) {
+	filename, err := c.filename(key)
+	if err != nil {
+		return aws.Credentials{}, false
+	}
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return aws.Credentials{}, false
+	}
+	var entry cliCacheEntry
+	if err := json.Unmarshal(b, &entry); err != nil {
+		return aws.Credentials{}, false
+	}
+	expires, ok := parseCLIExpiration(entry.Credentials.Expiration)
+	if !ok {
+		return aws.Credentials{}, false
+	}
+	return aws.Credentials{
+		AccessKeyID:     entry.Credentials.AccessKeyID,
+		SecretAccessKey: entry.Credentials.SecretAccessKey,
+		SessionToken:    entry.Credentials.SessionToken,
+		Source:          stscreds.ProviderName,
+		CanExpire:       true,
+		Expires:         expires,
+	}, true
+}
+
+// parseCLIExpiration parses the expiry formats written by the AWS CLI versions
+func parseCLIExpiration(s string) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05MST"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Put implements the CredentialCache interface method, writing the file
+// atomically and readable only by the current user
+func (c *fileCache) Put(_ context.Context, key string, creds aws.Credentials) error {
+	filename, err := c.filename(key)
+	if err != nil {
+		return err
+	}
+	if err := writeCLICacheFile(filename, creds); err != nil {
+		return fmt.Errorf("%w: %w", ErrFileCache, err)
+	}
+	return nil
+}
+
+// writeCLICacheFile writes creds to filename
+func writeCLICacheFile(filename string, creds aws.Credentials) error {
+	var entry cliCacheEntry
+	entry.Credentials.AccessKeyID = creds.AccessKeyID
+	entry.Credentials.SecretAccessKey = creds.SecretAccessKey
+	entry.Credentials.SessionToken = creds.SessionToken
+	entry.Credentials.Expiration = creds.Expires.UTC().Format(time.RFC3339)
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filename, b, 0o600)
+}
+
+// Invalidate implements the CredentialCache interface method
+func (c *fileCache) Invalidate(_ context.Context, key string) error {
+	filename, err := c.filename(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filename); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %w", ErrFileCache, err)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package awsconfig
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// stubSTSFromConfig replaces the package-internal STS clients with ones that
+// resolve the credentials of their config, as the SDK does when signing, and
+// then call client
+func stubSTSFromConfig(t *testing.T, client STSClient) {
+	t.Helper()
+	orig := newSTSFromConfig
+	t.Cleanup(func() { newSTSFromConfig = orig })
+	newSTSFromConfig = func(cfg aws.Config, _ ...func(*sts.Options)) STSClient {
+		return &signingSTS{creds: cfg.Credentials, client: client}
+	}
+}
+
+// signingSTS retrieves credentials before every call, failing as the SDK does
+// when they cannot be retrieved
+type signingSTS struct {
+	creds  aws.CredentialsProvider
+	client STSClient
+}
+
+// sign resolves the credentials a real client would sign the request with
+func (c *signingSTS) sign(ctx context.Context, operation string) error {
+	if c.creds == nil {
+		return nil
+	}
+	if _, err := c.creds.Retrieve(ctx); err != nil {
+		return fmt.Errorf("operation error STS: %s, failed to retrieve credentials: %w", operation, err)
+	}
+	return nil
+}
+
+// AssumeRole implements the STSClient interface method
+func (c *signingSTS) AssumeRole(
+	ctx context.Context,
+	params *sts.AssumeRoleInput,
+	optFns ...func(*sts.Options),
+) (*sts.AssumeRoleOutput, error) {
+	if err := c.sign(ctx, "AssumeRole"); err != nil {
+		return nil, err
+	}
+	return c.client.AssumeRole(ctx, params, optFns...)
+}
+
+// GetCallerIdentity implements the STSClient interface method
+func (c *signingSTS) GetCallerIdentity(
+	ctx context.Context,
+	params *sts.GetCallerIdentityInput,
+	optFns ...func(*sts.Options),
+) (*sts.GetCallerIdentityOutput, error) {
+	if err := c.sign(ctx, "GetCallerIdentity"); err != nil {
+		return nil, err
+	}
+	return c.client.GetCallerIdentity(ctx, params, optFns...)
+}
+
+// stsServer is an STS query API endpoint for the constructors that build their
+// own STS client. Every action returning credentials gets fresh ones valid for
+// an hour; GetCallerIdentity returns IdentityArn.
+type stsServer struct {
+	// IdentityArn is returned by GetCallerIdentity
+	IdentityArn string
+	// Errors maps actions to the error code they fail with
+	Errors map[string]string
+
+	mu       sync.Mutex
+	requests []url.Values
+}
+
+// newSTSServer starts an stsServer and returns it with a config using it
+func newSTSServer(t *testing.T) (*stsServer, aws.Config) {
+	t.Helper()
+	s := &stsServer{IdentityArn: "arn:aws:iam::123456789012:user/Alice"}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	cfg := aws.Config{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(srv.URL),
+		HTTPClient:   srv.Client(),
+		Credentials:  credentials.NewStaticCredentialsProvider("AKIAFAKEBASEACCESSKEY", "fake-base-secret-access-key", ""),
+		Retryer:      func() aws.Retryer { return aws.NopRetryer{} },
+	}
+	return s, cfg
+}
+
+// Requests returns the form of every request received, in order
+func (s *stsServer) Requests() []url.Values {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]url.Values(nil), s.requests...)
+}
+
+// ServeHTTP implements the http.Handler interface method
+func (s *stsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	action := r.PostForm.Get("Action")
+	s.mu.Lock()
+	s.requests = append(s.requests, r.PostForm)
+	code := s.Errors[action]
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/xml")
+	if code != "" {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprintf(w, `<ErrorResponse><Error><Type>Sender</Type><Code>%s</Code><Message>%s denied</Message></Error><RequestId>req</RequestId></ErrorResponse>`,
+			code, action)
+		return
+	}
+	var result string
+	if action == "GetCallerIdentity" {
+		result = fmt.Sprintf(`<Arn>%s</Arn><UserId>AIDAFAKEUSERID</UserId><Account>123456789012</Account>`, html.EscapeString(s.IdentityArn))
+	} else {
+		result = fmt.Sprintf(`<Credentials><AccessKeyId>ASIAFAKE%sACCESSKEY</AccessKeyId>`+
+			`<SecretAccessKey>fake-secret-access-key</SecretAccessKey><SessionToken>fake-session-token</SessionToken>`+
+			`<Expiration>%s</Expiration></Credentials>`+
+			`<AssumedRoleUser><Arn>arn:aws:sts::123456789012:assumed-role/Role/session</Arn><AssumedRoleId>AROAFAKE:session</AssumedRoleId></AssumedRoleUser>`,
+			fmt.Sprint(len(s.Requests())), time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	}
+	fmt.Fprintf(w, `<%[1]sResponse><%[1]sResult>%[2]s</%[1]sResult><ResponseMetadata><RequestId>req</RequestId></ResponseMetadata></%[1]sResponse>`,
+		action, result)
+}
+
+// stubClock fixes the time seen by the package, returning a func advancing it
+func stubClock(t *testing.T) func(time.Duration) {
+	t.Helper()
+	now := time.Now()
+	origNow := timeNow
+	t.Cleanup(func() { timeNow = origNow })
+	timeNow = func() time.Time { return now }
+	return func(d time.Duration) { now = now.Add(d) }
+}
@@ -0,0 +1,276 @@
+package awsconfig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+const (
+	// HTTPCredentialsProviderName is the Source of credentials returned by HTTPCredentialsProvider
+	HTTPCredentialsProviderName = SourcePrefix + "http"
+
+	// DefaultHTTPCredentialsTimeout bounds each request of an HTTPCredentialsProvider
+	DefaultHTTPCredentialsTimeout = 5 * time.Second
+	// DefaultHTTPCredentialsRetries is how many times a server error is retried
+	DefaultHTTPCredentialsRetries = 2
+
+	// httpCredentialsMaxResponse bounds the size of a credentials response read
+	httpCredentialsMaxResponse = 1 << 20
+)
+
+var (
+	// ErrInvalidHTTPCredentialsEndpoint is returned for endpoints that are not https, or http on a loopback address
+	ErrInvalidHTTPCredentialsEndpoint = errors.New("Credentials endpoint must be https, or http on a loopback address")
+	// ErrHTTPCredentials is returned when a credentials endpoint cannot be reached or returns an error
+	ErrHTTPCredentials = errors.New("Cannot get credentials from HTTP endpoint")
+	// ErrParseHTTPCredentials is returned when a credentials endpoint response is invalid
+	ErrParseHTTPCredentials = errors.New("Cannot parse HTTP endpoint credentials")
+)
+
+// containerHosts are the link-local addresses of the ECS and EKS Pod Identity
+// agents, allowed over http like loopback addresses
+var containerHosts = map[string]bool{
+	"169.254.170.2":         true,
+	"169.254.170.23":        true,
+	"fd00:ec2::23":          true,
+	"localhost":             true,
+	"localhost.localdomain": true,
+}
+
+// WithHTTPAuthToken sends token as the Authorization header of HTTPCredentialsProvider requests
+func WithHTTPAuthToken(token string) ConfOption {
+	return func(c *confOptions) {
+		c.httpAuthToken = token
+	}
+}
+
+// WithHTTPAuthTokenFile sends the content of filename as the Authorization
+// header of HTTPCredentialsProvider requests, reading it for every request so
+// rotated tokens are picked up, as AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE is
+func WithHTTPAuthTokenFile(filename string) ConfOption {
+	return func(c *confOptions) {
+		c.httpAuthTokenFile = filename
+	}
+}
+
+// HTTPCredentialsProvider implements the aws.CredentialsProvider interface by
+// getting credentials from an HTTP endpoint in the JSON shape of the ECS
+// container credentials endpoint.
+type HTTPCredentialsProvider struct {
+	endpoint  string
+	authToken string
+	tokenFile string
+	timeout   time.Duration
+	retries   int
+	backoff   func(attempt int) time.Duration
+	validate  bool
+	client    *http.Client
+}
+
+// NewHTTPCredentialsProvider initializes a new HTTPCredentialsProvider instance
+// getting credentials from endpoint. Each request is bounded by
+// DefaultHTTPCredentialsTimeout, or WithRetrieveTimeout, and server errors are
+// retried DefaultHTTPCredentialsRetries times, or as set by WithRetrieveRetries.
+// Redirects are refused.
+func NewHTTPCredentialsProvider(endpoint string, opts ...Option) aws.CredentialsProvider {
+	return newHTTPCredentialsProvider(endpoint, newConfOptions(opts))
+}
+
+// newHTTPCredentialsProvider returns the HTTPCredentialsProvider of conf
+func newHTTPCredentialsProvider(endpoint string, conf *confOptions) *HTTPCredentialsProvider {
+	p := &HTTPCredentialsProvider{
+		endpoint:  endpoint,
+		authToken: conf.httpAuthToken,
+		tokenFile: conf.httpAuthTokenFile,
+		timeout:   DefaultHTTPCredentialsTimeout,
+		retries:   DefaultHTTPCredentialsRetries,
+		backoff:   conf.retrieveBackoff,
+		validate:  conf.validateCredentials,
+		client: &http.Client{
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+	if conf.retrieveTimeout > 0 {
+		p.timeout = conf.retrieveTimeout
+	}
+	if conf.retrieveRetries > 0 {
+		p.retries = conf.retrieveRetries
+	}
+	return p
+}
+
+// validateHTTPCredentialsEndpoint checks endpoint is https, or http on a loopback or container agent address
+func validateHTTPCredentialsEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidHTTPCredentialsEndpoint, err)
+	}
+	switch {
+	case u.Scheme == "https" && u.Host != "":
+		return nil
+	case u.Scheme == "http":
+		host := u.Hostname()
+		if ip := net.ParseIP(host); (ip != nil && ip.IsLoopback()) || containerHosts[host] {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w, got %q", ErrInvalidHTTPCredentialsEndpoint, endpoint)
+}
+
+// Retrieve implements the aws.CredentialsProvider interface method
+func (p *HTTPCredentialsProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	if err := validateHTTPCredentialsEndpoint(p.endpoint); err != nil {
+		return aws.Credentials{}, err
+	}
+	for attempt := 0; ; attempt++ {
+		creds, retry, err := p.retrieve(ctx)
+		if err == nil || !retry || attempt >= p.retries {
+			return creds, err
+		}
+		var wait time.Duration
+		if p.backoff != nil {
+			wait = p.backoff(attempt + 1)
+		}
+		if err := sleepContext(ctx, wait); err != nil {
+			return aws.Credentials{}, err
+		}
+	}
+}
+
+// retrieve makes one request to the endpoint, reporting whether a failure may be retried
+func (p *HTTPCredentialsProvider) retrieve(ctx context.Context) (creds aws.Credentials, retry bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.endpoint, nil)
+	if err != nil {
+		return aws.Credentials{}, false, fmt.Errorf("%w: %w", ErrHTTPCredentials, err)
+	}
+	req.Header.Set("Accept", "application/json")
+	token := p.authToken
+	if p.tokenFile != "" {
+		b, err := os.ReadFile(p.tokenFile)
+		if err != nil {
+			return aws.Credentials{}, false, fmt.Errorf("%w: %w", ErrHTTPCredentials, err)
+		}
+		token = strings.TrimSpace(string(b))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		// Connection errors and timeouts of this attempt are retried, unless ctx of the caller is done
+		return aws.Credentials{}, true, fmt.Errorf("%w: %w", ErrHTTPCredentials, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, httpCredentialsMaxResponse))
+	if err != nil {
+		return aws.Credentials{}, true, fmt.Errorf("%w: %w", ErrHTTPCredentials, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		}
+		msg := resp.Status
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Message != "" {
+			msg = fmt.Sprintf("%s: %s: %s", resp.Status, apiErr.Code, apiErr.Message)
+		}
+		return aws.Credentials{}, resp.StatusCode >= 500, fmt.Errorf("%w: %s", ErrHTTPCredentials, msg)
+	}
+
+	var out containerCredentials
+	if err := json.Unmarshal(body, &out); err != nil {
+		return aws.Credentials{}, false, fmt.Errorf("%w: %w", ErrParseHTTPCredentials, err)
+	}
+	if out.AccessKeyID == "" || out.SecretAccessKey == "" {
+		return aws.Credentials{}, false, fmt.Errorf("%w: missing AccessKeyId or SecretAccessKey", ErrParseHTTPCredentials)
+	}
+	creds = aws.Credentials{
+		AccessKeyID:     out.AccessKeyID,
+		SecretAccessKey: out.SecretAccessKey,
+		SessionToken:    out.Token,
+		Source:          HTTPCredentialsProviderName,
+	}
+	if out.Expiration != "" {
+		expires, err := time.Parse(time.RFC3339, out.Expiration)
+		if err != nil {
+			return aws.Credentials{}, false, fmt.Errorf("%w: %w", ErrParseHTTPCredentials, err)
+		}
+		creds.CanExpire = true
+		creds.Expires = expires
+	}
+	if p.validate {
+		if err := ValidateCredentials(creds); err != nil {
+			return aws.Credentials{}, false, err
+		}
+	}
+	return creds, false, nil
+}
+
+// NewHTTPCredentialsConf returns an aws.Config getting credentials from the
+// HTTPCredentialsProvider of endpoint, caching them so the endpoint is only
+// requested on refresh.
+func NewHTTPCredentialsConf(
+	_ context.Context,
+	cfg aws.Config,
+	endpoint string,
+	opts ...Option,
+) (aws.Config, error) {
+	if err := validateHTTPCredentialsEndpoint(endpoint); err != nil {
+		return aws.Config{}, err
+	}
+	conf := newConfOptions(opts)
+
+	provider := newHTTPCredentialsProvider(endpoint, conf)
+	cacheOpts := append(
+		[]func(*aws.CredentialsCacheOptions){
+			func(options *aws.CredentialsCacheOptions) {
+				options.ExpiryWindow = 5 * time.Minute
+			},
+		},
+		conf.cacheOpts...,
+	)
+
+	config := cfg.Copy()
+	config.Credentials = aws.NewCredentialsCache(withStats(provider), cacheOpts...)
+	return config, nil
+}
+
+// String implements the fmt.Stringer interface method, printing only the
+// endpoint without user info or query, and whether an authorization token is
+// set
+func (p *HTTPCredentialsProvider) String() string {
+	return fmt.Sprintf("HTTPCredentialsProvider{Source: %q, Endpoint: %q, AuthToken: %t, TokenFile: %q}", HTTPCredentialsProviderName, redactedEndpoint(p.endpoint), p.authToken != "", p.tokenFile)
+}
+
+// GoString implements the fmt.GoStringer interface method, so %#v prints no more than String
+func (p *HTTPCredentialsProvider) GoString() string {
+	return "&awsconfig." + p.String()
+}
+
+// redactedEndpoint returns endpoint without user info, query, or fragment, any
+// of which may hold secrets
+func redactedEndpoint(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return ""
+	}
+	u.User, u.RawQuery, u.Fragment = nil, "", ""
+	return u.String()
+}
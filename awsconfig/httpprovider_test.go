@@ -0,0 +1,232 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestHTTPCredentialsProvider(t *testing.T) {
+	session := `{"AccessKeyId":"ASIAEXAMPLE","SecretAccessKey":"secret","Token":"token","Expiration":"2030-01-02T03:04:05Z"}`
+
+	tests := []struct {
+		name          string
+		responses     []httpResponse
+		opts          []Option
+		wantErr       error
+		wantRequests  int32
+		wantSession   bool
+		wantCanExpire bool
+	}{
+		{name: "Session", responses: []httpResponse{{200, session}}, wantRequests: 1, wantSession: true, wantCanExpire: true},
+		{
+			name:         "NonExpiring",
+			responses:    []httpResponse{{200, `{"AccessKeyId":"AKIDEXAMPLE","SecretAccessKey":"secret"}`}},
+			wantRequests: 1,
+		},
+		{
+			name:         "Unauthorized",
+			responses:    []httpResponse{{401, `{"code":"Unauthorized","message":"bad token"}`}},
+			wantErr:      ErrHTTPCredentials,
+			wantRequests: 1,
+		},
+		{name: "ServerErrorRetried", responses: []httpResponse{{500, "oops"}, {503, "busy"}, {200, session}}, wantRequests: 3, wantSession: true, wantCanExpire: true},
+		{name: "ServerErrorExhausted", responses: []httpResponse{{500, "oops"}}, wantErr: ErrHTTPCredentials, wantRequests: 3},
+		{
+			name: "RetriesOption", responses: []httpResponse{{500, "oops"}},
+			opts: []Option{WithRetrieveRetries(4, nil)}, wantErr: ErrHTTPCredentials, wantRequests: 5,
+		},
+		{name: "MalformedJSON", responses: []httpResponse{{200, `{"AccessKeyId":`}}, wantErr: ErrParseHTTPCredentials, wantRequests: 1},
+		{name: "MissingKeys", responses: []httpResponse{{200, `{"Token":"token"}`}}, wantErr: ErrParseHTTPCredentials, wantRequests: 1},
+		{
+			name:         "BadExpiration",
+			responses:    []httpResponse{{200, `{"AccessKeyId":"AKIDEXAMPLE","SecretAccessKey":"secret","Expiration":"soon"}`}},
+			wantErr:      ErrParseHTTPCredentials,
+			wantRequests: 1,
+		},
+		{name: "RedirectRefused", responses: []httpResponse{{302, ""}}, wantErr: ErrHTTPCredentials, wantRequests: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(requests.Add(1))
+				resp := tt.responses[min(n, len(tt.responses))-1]
+				if resp.status == http.StatusFound {
+					http.Redirect(w, r, "/elsewhere", resp.status)
+					return
+				}
+				w.WriteHeader(resp.status)
+				fmt.Fprint(w, resp.body)
+			}))
+			defer srv.Close()
+
+			creds, err := NewHTTPCredentialsProvider(srv.URL+"/creds", tt.opts...).Retrieve(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Retrieve error %v, want %v", err, tt.wantErr)
+			}
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("endpoint requested %d times, want %d", got, tt.wantRequests)
+			}
+			if err != nil {
+				return
+			}
+			if creds.Source != HTTPCredentialsProviderName {
+				t.Errorf("Source = %q, want %q", creds.Source, HTTPCredentialsProviderName)
+			}
+			if got := creds.SessionToken != ""; got != tt.wantSession {
+				t.Errorf("has session token %t, want %t", got, tt.wantSession)
+			}
+			if creds.CanExpire != tt.wantCanExpire {
+				t.Errorf("CanExpire = %t, want %t", creds.CanExpire, tt.wantCanExpire)
+			}
+			if tt.wantCanExpire && !creds.Expires.Equal(time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)) {
+				t.Errorf("Expires = %v", creds.Expires)
+			}
+		})
+	}
+}
+
+// httpResponse is a canned response of a test credentials endpoint
+type httpResponse struct {
+	status int
+	body   string
+}
+
+func TestHTTPCredentialsProviderAuthToken(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("Authorization"))
+		fmt.Fprint(w, `{"AccessKeyId":"AKIDEXAMPLE","SecretAccessKey":"secret"}`)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name      string
+		opts      []Option
+		fileToken []string
+		want      []string
+	}{
+		{name: "None", want: []string{"", ""}},
+		{name: "Static", opts: []Option{WithHTTPAuthToken("static")}, want: []string{"static", "static"}},
+		{
+			name:      "FileRereadPerRequest",
+			opts:      []Option{WithHTTPAuthToken("static"), WithHTTPAuthTokenFile(tokenFile)},
+			fileToken: []string{"first\n", "rotated\n"},
+			want:      []string{"first", "rotated"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			provider := NewHTTPCredentialsProvider(srv.URL, tt.opts...)
+			for i := range tt.want {
+				if tt.fileToken != nil {
+					if err := os.WriteFile(tokenFile, []byte(tt.fileToken[i]), 0o600); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if _, err := provider.Retrieve(context.Background()); err != nil {
+					t.Fatalf("Retrieve: %v", err)
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Authorization headers %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// A missing token file fails without requesting the endpoint
+	got = nil
+	_, err := NewHTTPCredentialsProvider(srv.URL, WithHTTPAuthTokenFile(filepath.Join(t.TempDir(), "missing"))).Retrieve(context.Background())
+	if !errors.Is(err, ErrHTTPCredentials) || len(got) != 0 {
+		t.Errorf("Retrieve error %v after %d requests, want %v without requests", err, len(got), ErrHTTPCredentials)
+	}
+}
+
+func TestHTTPCredentialsProviderTimeout(t *testing.T) {
+	var requests atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	provider := NewHTTPCredentialsProvider(srv.URL, WithRetrieveTimeout(50*time.Millisecond), WithRetrieveRetries(1, nil))
+	if _, err := provider.Retrieve(context.Background()); !errors.Is(err, ErrHTTPCredentials) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Retrieve error %v, want %v after a timeout", err, ErrHTTPCredentials)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("endpoint requested %d times, want 2", got)
+	}
+}
+
+func TestValidateHTTPCredentialsEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		wantErr  error
+	}{
+		{endpoint: "https://broker.example.com/creds"},
+		{endpoint: "http://127.0.0.1:8080/creds"},
+		{endpoint: "http://[::1]:8080/creds"},
+		{endpoint: "http://localhost/creds"},
+		{endpoint: "http://169.254.170.2/v2/credentials"},
+		{endpoint: "http://[fd00:ec2::23]/v1/credentials"},
+		{endpoint: "http://broker.example.com/creds", wantErr: ErrInvalidHTTPCredentialsEndpoint},
+		{endpoint: "http://10.0.0.1/creds", wantErr: ErrInvalidHTTPCredentialsEndpoint},
+		{endpoint: "ftp://broker.example.com/creds", wantErr: ErrInvalidHTTPCredentialsEndpoint},
+		{endpoint: "https:///creds", wantErr: ErrInvalidHTTPCredentialsEndpoint},
+		{endpoint: "://bad", wantErr: ErrInvalidHTTPCredentialsEndpoint},
+	}
+	for _, tt := range tests {
+		t.Run(tt.endpoint, func(t *testing.T) {
+			if err := validateHTTPCredentialsEndpoint(tt.endpoint); !errors.Is(err, tt.wantErr) {
+				t.Errorf("validateHTTPCredentialsEndpoint error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewHTTPCredentialsConf(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		fmt.Fprintf(w, `{"AccessKeyId":"ASIAEXAMPLE","SecretAccessKey":"secret","Token":"token","Expiration":%q}`,
+			time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	}))
+	defer srv.Close()
+
+	if _, err := NewHTTPCredentialsConf(context.Background(), aws.Config{}, "http://broker.example.com/"); !errors.Is(err, ErrInvalidHTTPCredentialsEndpoint) {
+		t.Errorf("NewHTTPCredentialsConf error %v, want %v", err, ErrInvalidHTTPCredentialsEndpoint)
+	}
+	cfg, err := NewHTTPCredentialsConf(context.Background(), aws.Config{Region: "eu-west-1"}, srv.URL)
+	if err != nil {
+		t.Fatalf("NewHTTPCredentialsConf: %v", err)
+	}
+	for range 3 {
+		if _, err := cfg.Credentials.Retrieve(context.Background()); err != nil {
+			t.Fatalf("Retrieve: %v", err)
+		}
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("endpoint requested %d times, want 1", got)
+	}
+	if cfg.Region != "eu-west-1" {
+		t.Errorf("Region = %q, want the base config's", cfg.Region)
+	}
+}
@@ -0,0 +1,173 @@
+package awsconfig
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+const (
+	// DefaultIdentityCacheTTL is how long an IdentityCache reuses a successful preflight
+	DefaultIdentityCacheTTL = 5 * time.Minute
+	// DefaultIdentityCacheNegativeTTL is how long an IdentityCache reuses a failed preflight
+	DefaultIdentityCacheNegativeTTL = 5 * time.Second
+)
+
+// IdentityCache memoizes the GetCallerIdentity preflight per base credentials
+// provider, or per injected STS client, and STS endpoint, so constructors
+// sharing a base config share one preflight. Expired results are dropped as
+// new preflights are made. It is safe for concurrent use.
+type IdentityCache struct {
+	ttl         time.Duration
+	negativeTTL time.Duration
+
+	mu      sync.Mutex
+	entries map[identityCacheKey]*identityCall
+}
+
+// identityCacheKey is the key of a preflight in an IdentityCache: the base
+// credentials provider, or the injected STS client, and the resolved options
+// of the STS endpoint it is sent to
+type identityCacheKey struct {
+	client    any
+	region    string
+	endpoint  string
+	fips      aws.FIPSEndpointState
+	dualStack aws.DualStackEndpointState
+}
+
+// identityCall is a preflight shared by the constructors using an IdentityCache
+type identityCall struct {
+	done    chan struct{}
+	out     *sts.GetCallerIdentityOutput
+	err     error
+	expires time.Time
+}
+
+// NewIdentityCache returns an IdentityCache reusing successful preflights for ttl
+// and failed ones for negativeTTL; zero selects the defaults
+func NewIdentityCache(ttl, negativeTTL time.Duration) *IdentityCache {
+	if ttl <= 0 {
+		ttl = DefaultIdentityCacheTTL
+	}
+	if negativeTTL <= 0 {
+		negativeTTL = DefaultIdentityCacheNegativeTTL
+	}
+	return &IdentityCache{
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		entries:     make(map[identityCacheKey]*identityCall),
+	}
+}
+
+// WithSharedIdentityCache reuses the GetCallerIdentity preflight results of cache
+func WithSharedIdentityCache(cache *IdentityCache) ConfOption {
+	return func(c *confOptions) {
+		c.identityCache = cache
+	}
+}
+
+// cachedCheckIdentity runs checkIdentity through the WithSharedIdentityCache
+// cache, if set and the base credentials can be used as its key
+func cachedCheckIdentity(
+	ctx context.Context,
+	cfg aws.Config,
+	client getCallerIdentityAPIClient,
+	conf *confOptions,
+) (*sts.GetCallerIdentityOutput, error) {
+	// Reuse a preflight made earlier in the same construction
+	if conf.callerIdentity != nil {
+		return conf.callerIdentity, nil
+	}
+
+	cache := conf.identityCache
+	var keyClient any = cfg.Credentials
+	if conf.stsClient != nil {
+		keyClient = conf.stsClient
+	}
+	if cache == nil || keyClient == nil || !reflect.TypeOf(keyClient).Comparable() {
+		return checkIdentity(ctx, client, conf)
+	}
+
+	// The same credentials may be checked against STS in other regions or partitions
+	o := sts.Options{Region: cfg.Region, BaseEndpoint: cfg.BaseEndpoint}
+	for _, fn := range conf.stsOpts {
+		fn(&o)
+	}
+	key := identityCacheKey{
+		client:    keyClient,
+		region:    o.Region,
+		endpoint:  aws.ToString(o.BaseEndpoint),
+		fips:      o.EndpointOptions.UseFIPSEndpoint,
+		dualStack: o.EndpointOptions.UseDualStackEndpoint,
+	}
+
+	cache.mu.Lock()
+	call, ok := cache.entries[key]
+	if ok && call.isExpired() {
+		ok = false
+	}
+	if !ok {
+		cache.prune()
+		call = &identityCall{done: make(chan struct{})}
+		cache.entries[key] = call
+		go cache.check(context.WithoutCancel(ctx), client, conf, call)
+	}
+	cache.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.out, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// check runs the preflight for call and sets how long its result is reused
+func (c *IdentityCache) check(
+	ctx context.Context,
+	client getCallerIdentityAPIClient,
+	conf *confOptions,
+	call *identityCall,
+) {
+	out, err := checkIdentity(ctx, client, conf)
+	ttl := c.ttl
+	if err != nil {
+		ttl = c.negativeTTL
+	}
+
+	c.mu.Lock()
+	call.out, call.err = out, err
+	call.expires = timeNow().Add(ttl)
+	c.mu.Unlock()
+	close(call.done)
+}
+
+// prune drops the expired preflights; the caller holds c.mu
+func (c *IdentityCache) prune() {
+	for key, call := range c.entries {
+		if call.isExpired() {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// isExpired reports whether the preflight is done and its result no longer
+// reused; the caller holds the mutex of its IdentityCache
+func (call *identityCall) isExpired() bool {
+	return isDone(call.done) && !timeNow().Before(call.expires)
+}
+
+// isDone reports whether done is closed
+func isDone(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,176 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigtest"
+)
+
+func TestIdentityCacheSharesPreflight(t *testing.T) {
+	fake := &awsconfigtest.FakeSTS{}
+	stubSTSFromConfig(t, fake)
+	base := aws.Config{Region: "us-east-1", Credentials: credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", "")}
+	cache := NewIdentityCache(0, 0)
+
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := NewAssumeRoleConf(context.Background(), base, testRoleArn, WithSharedIdentityCache(cache)); err != nil {
+				t.Errorf("NewAssumeRoleConf: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := fake.CallerIdentityCalls(); got != 1 {
+		t.Errorf("GetCallerIdentity called %d times for 50 configs, want 1", got)
+	}
+}
+
+func TestIdentityCacheKey(t *testing.T) {
+	creds := credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", "")
+	otherCreds := credentials.NewStaticCredentialsProvider("AKIDOTHER", "secret", "")
+	funcCreds := aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return aws.Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret"}, nil
+	})
+
+	tests := []struct {
+		name       string
+		first      aws.CredentialsProvider
+		second     aws.CredentialsProvider
+		secondOpts []Option
+		wantCalls  int
+	}{
+		{name: "SameCredentials", first: creds, second: creds, wantCalls: 1},
+		{name: "OtherCredentials", first: creds, second: otherCreds, wantCalls: 2},
+		{name: "UncomparableCredentials", first: funcCreds, second: funcCreds, wantCalls: 2},
+		{name: "OtherSTSRegion", first: creds, second: creds, secondOpts: []Option{WithSTSRegion("eu-west-1")}, wantCalls: 2},
+		{name: "SameSTSRegion", first: creds, second: creds, secondOpts: []Option{WithSTSRegion("us-east-1")}, wantCalls: 1},
+		{name: "OtherSTSEndpoint", first: creds, second: creds, secondOpts: []Option{WithSTSFIPSEndpoint()}, wantCalls: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &awsconfigtest.FakeSTS{}
+			stubSTSFromConfig(t, fake)
+			cache := NewIdentityCache(0, 0)
+			for i, provider := range []aws.CredentialsProvider{tt.first, tt.second} {
+				cfg := aws.Config{Region: "us-east-1", Credentials: provider}
+				opts := []Option{WithSharedIdentityCache(cache)}
+				if i > 0 {
+					opts = append(opts, tt.secondOpts...)
+				}
+				if _, err := NewAssumeRoleConf(context.Background(), cfg, testRoleArn, opts...); err != nil {
+					t.Fatalf("NewAssumeRoleConf: %v", err)
+				}
+			}
+			if got := fake.CallerIdentityCalls(); got != tt.wantCalls {
+				t.Errorf("GetCallerIdentity called %d times, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestIdentityCacheTTL(t *testing.T) {
+	denied := &smithy.GenericAPIError{Code: "AccessDenied", Message: "denied"}
+
+	tests := []struct {
+		name      string
+		err       error
+		advance   time.Duration
+		wantCalls int
+	}{
+		{name: "SuccessWithinTTL", advance: 4 * time.Minute, wantCalls: 1},
+		{name: "SuccessExpired", advance: 5 * time.Minute, wantCalls: 2},
+		{name: "FailureWithinNegativeTTL", err: denied, advance: 4 * time.Second, wantCalls: 1},
+		{name: "FailureExpired", err: denied, advance: 5 * time.Second, wantCalls: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			advance := stubClock(t)
+			fake := &awsconfigtest.FakeSTS{CallerIdentityErr: tt.err}
+			cache := NewIdentityCache(0, 0)
+			for i := range 2 {
+				if i > 0 {
+					advance(tt.advance)
+				}
+				_, err := NewAssumeRoleConf(context.Background(), aws.Config{}, testRoleArn,
+					WithSTSClient(fake), WithSharedIdentityCache(cache))
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("NewAssumeRoleConf error %v, want %v", err, tt.err)
+				}
+			}
+			if got := fake.CallerIdentityCalls(); got != tt.wantCalls {
+				t.Errorf("GetCallerIdentity called %d times, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestIdentityCachePrunesExpired(t *testing.T) {
+	advance := stubClock(t)
+	cache := NewIdentityCache(0, 0)
+	for i := range 3 {
+		if i > 0 {
+			advance(DefaultIdentityCacheTTL)
+		}
+		// Each preflight is made with another client, so none is reused
+		if _, err := NewAssumeRoleConf(context.Background(), aws.Config{}, testRoleArn,
+			WithSTSClient(&awsconfigtest.FakeSTS{}), WithSharedIdentityCache(cache)); err != nil {
+			t.Fatalf("NewAssumeRoleConf: %v", err)
+		}
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if got := len(cache.entries); got != 1 {
+		t.Errorf("cache holds %d preflights, want only the unexpired one", got)
+	}
+}
+
+func TestIdentityCacheWaiterCancelled(t *testing.T) {
+	fake := &awsconfigtest.FakeSTS{}
+	release := make(chan struct{})
+	client := &blockingIdentitySTS{FakeSTS: fake, release: release}
+	cache := NewIdentityCache(0, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewAssumeRoleConf(ctx, aws.Config{}, testRoleArn, WithSTSClient(client), WithSharedIdentityCache(cache)); !errors.Is(err, context.Canceled) {
+		t.Fatalf("NewAssumeRoleConf error %v, want %v", err, context.Canceled)
+	}
+
+	// The preflight outlives the cancelled caller and serves the next one
+	close(release)
+	if _, err := NewAssumeRoleConf(context.Background(), aws.Config{}, testRoleArn, WithSTSClient(client), WithSharedIdentityCache(cache)); err != nil {
+		t.Fatalf("NewAssumeRoleConf: %v", err)
+	}
+	if got := fake.CallerIdentityCalls(); got != 1 {
+		t.Errorf("GetCallerIdentity called %d times, want 1", got)
+	}
+}
+
+// blockingIdentitySTS holds GetCallerIdentity calls until release is closed
+type blockingIdentitySTS struct {
+	*awsconfigtest.FakeSTS
+	release chan struct{}
+}
+
+// GetCallerIdentity implements the STSClient interface method
+func (c *blockingIdentitySTS) GetCallerIdentity(
+	ctx context.Context,
+	params *sts.GetCallerIdentityInput,
+	optFns ...func(*sts.Options),
+) (*sts.GetCallerIdentityOutput, error) {
+	<-c.release
+	return c.FakeSTS.GetCallerIdentity(ctx, params, optFns...)
+}
@@ -0,0 +1,225 @@
+package awsconfig
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+const (
+	// DefaultIMDSRoleName is the role name served by NewIMDSServer unless WithIMDSRoleName is used
+	DefaultIMDSRoleName = "awsconfig"
+
+	// imdsCredentialsPath lists the role name and, under it, serves its credentials
+	imdsCredentialsPath = "/latest/meta-data/iam/security-credentials/"
+	// imdsTokenPath issues IMDSv2 session tokens
+	imdsTokenPath = "/latest/api/token"
+	// imdsTokenHeader carries an IMDSv2 session token
+	imdsTokenHeader = "X-aws-ec2-metadata-token"
+	// imdsTokenTTLHeader carries the requested lifetime of an IMDSv2 session token
+	imdsTokenTTLHeader = "X-aws-ec2-metadata-token-ttl-seconds"
+	// imdsMaxTokenTTL is the longest IMDSv2 session token lifetime
+	imdsMaxTokenTTL = 6 * time.Hour
+	// imdsNonExpiringValidity is the Expiration served for credentials that cannot expire
+	imdsNonExpiringValidity = time.Hour
+)
+
+// WithIMDSRoleName sets the role name NewIMDSServer lists and serves credentials under
+func WithIMDSRoleName(name string) ConfOption {
+	return func(c *confOptions) {
+		c.imdsRoleName = name
+	}
+}
+
+// WithIMDSv2Only makes NewIMDSServer reject requests without a valid IMDSv2
+// session token, as instances with HttpTokens set to required do
+func WithIMDSv2Only() ConfOption {
+	return func(c *confOptions) {
+		c.imdsV2Only = true
+	}
+}
+
+// NewIMDSServer returns an http.Handler serving the credentials of cfg as the
+// EC2 instance metadata service does, for SDKs and tools that can only get
+// credentials from it. It serves the role name listing and role credentials
+// document of the security-credentials path, retrieving the credentials of
+// cfg for each request so their Expiration is the real one, and issues
+// IMDSv2 session tokens. Requests with an invalid token are rejected, and so
+// are requests without one when WithIMDSv2Only is used.
+func NewIMDSServer(cfg aws.Config, opts ...Option) http.Handler {
+	conf := newConfOptions(opts)
+	roleName := conf.imdsRoleName
+	if roleName == "" {
+		roleName = DefaultIMDSRoleName
+	}
+	return &imdsServer{
+		credentials: cfg.Credentials,
+		roleName:    roleName,
+		v2Only:      conf.imdsV2Only,
+		tokens:      map[string]time.Time{},
+	}
+}
+
+// ListenAndServeIMDS serves NewIMDSServer on addr until ctx is done. Clients
+// find it through AWS_EC2_METADATA_SERVICE_ENDPOINT, or by routing
+// 169.254.169.254 to it.
+func ListenAndServeIMDS(ctx context.Context, addr string, cfg aws.Config, opts ...Option) error {
+	return listenAndServe(ctx, addr, NewIMDSServer(cfg, opts...))
+}
+
+// listenAndServe serves handler on addr until ctx is done
+func listenAndServe(ctx context.Context, addr string, handler http.Handler) error {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	stop := context.AfterFunc(ctx, func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	})
+	defer stop()
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return ctx.Err()
+}
+
+// imdsServer is the http.Handler of NewIMDSServer
+type imdsServer struct {
+	credentials aws.CredentialsProvider
+	roleName    string
+	v2Only      bool
+
+	mu     sync.Mutex
+	tokens map[string]time.Time
+}
+
+// imdsCredentials is the role credentials document of the instance metadata service
+type imdsCredentials struct {
+	Code            string
+	LastUpdated     string
+	Type            string
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string
+	Token           string
+	Expiration      string
+}
+
+// ServeHTTP implements the http.Handler interface method
+func (s *imdsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == imdsTokenPath {
+		s.serveToken(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authorized(r) {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	switch strings.TrimSuffix(r.URL.Path, "/") {
+	case strings.TrimSuffix(imdsCredentialsPath, "/"):
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte(s.roleName))
+	case imdsCredentialsPath + s.roleName:
+		s.serveCredentials(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveToken issues an IMDSv2 session token for the requested lifetime
+func (s *imdsServer) serveToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	// Like EC2, refuse tokens to requests relayed by a proxy
+	if r.Header.Get("X-Forwarded-For") != "" {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	seconds, err := strconv.Atoi(r.Header.Get(imdsTokenTTLHeader))
+	ttl := time.Duration(seconds) * time.Second
+	if err != nil || ttl < time.Second || ttl > imdsMaxTokenTTL {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	now := timeNow()
+	s.mu.Lock()
+	for t, expires := range s.tokens {
+		if !now.Before(expires) {
+			delete(s.tokens, t)
+		}
+	}
+	s.tokens[token] = now.Add(ttl)
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set(imdsTokenTTLHeader, strconv.Itoa(seconds))
+	_, _ = w.Write([]byte(token))
+}
+
+// authorized reports whether r has a valid session token, or none when IMDSv1 is allowed
+func (s *imdsServer) authorized(r *http.Request) bool {
+	token := r.Header.Get(imdsTokenHeader)
+	if token == "" {
+		return !s.v2Only
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expires, ok := s.tokens[token]
+	return ok && timeNow().Before(expires)
+}
+
+// serveCredentials serves the role credentials document with the current credentials
+func (s *imdsServer) serveCredentials(w http.ResponseWriter, r *http.Request) {
+	if s.credentials == nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	creds, err := s.credentials.Retrieve(r.Context())
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	now := timeNow()
+	expires := creds.Expires
+	if !creds.CanExpire {
+		// Clients require an expiry, and retrieve the credentials again after it
+		expires = now.Add(imdsNonExpiringValidity)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(imdsCredentials{
+		Code:            "Success",
+		LastUpdated:     now.UTC().Format(time.RFC3339),
+		Type:            "AWS-HMAC",
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		Token:           creds.SessionToken,
+		Expiration:      expires.UTC().Format(time.RFC3339),
+	})
+}
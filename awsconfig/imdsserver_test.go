@@ -0,0 +1,217 @@
+package awsconfig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+func TestIMDSServer(t *testing.T) {
+	const rolePath = imdsCredentialsPath + DefaultIMDSRoleName
+
+	tests := []struct {
+		name       string
+		opts       []Option
+		method     string
+		path       string
+		token      string
+		header     http.Header
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "V1ListRoles", path: imdsCredentialsPath, wantStatus: http.StatusOK, wantBody: DefaultIMDSRoleName},
+		{name: "V1ListRolesNoSlash", path: strings.TrimSuffix(imdsCredentialsPath, "/"), wantStatus: http.StatusOK, wantBody: DefaultIMDSRoleName},
+		{name: "V1Credentials", path: rolePath, wantStatus: http.StatusOK},
+		{name: "V1ValidToken", path: rolePath, token: "issue", wantStatus: http.StatusOK},
+		{name: "V1InvalidToken", path: rolePath, token: "forged", wantStatus: http.StatusUnauthorized},
+		{name: "V1UnknownRole", path: imdsCredentialsPath + "other", wantStatus: http.StatusNotFound},
+		{name: "V1Post", method: http.MethodPost, path: rolePath, wantStatus: http.StatusMethodNotAllowed},
+		{name: "RoleName", opts: []Option{WithIMDSRoleName("deploy")}, path: imdsCredentialsPath, wantStatus: http.StatusOK, wantBody: "deploy"},
+		{name: "V2OnlyNoToken", opts: []Option{WithIMDSv2Only()}, path: rolePath, wantStatus: http.StatusUnauthorized},
+		{name: "V2OnlyListNoToken", opts: []Option{WithIMDSv2Only()}, path: imdsCredentialsPath, wantStatus: http.StatusUnauthorized},
+		{name: "V2OnlyValidToken", opts: []Option{WithIMDSv2Only()}, path: rolePath, token: "issue", wantStatus: http.StatusOK},
+		{name: "V2OnlyInvalidToken", opts: []Option{WithIMDSv2Only()}, path: rolePath, token: "forged", wantStatus: http.StatusUnauthorized},
+		{name: "TokenGet", method: http.MethodGet, path: imdsTokenPath, wantStatus: http.StatusMethodNotAllowed},
+		{name: "TokenNoTTL", method: http.MethodPut, path: imdsTokenPath, wantStatus: http.StatusBadRequest},
+		{
+			name: "TokenTTLTooLong", method: http.MethodPut, path: imdsTokenPath, wantStatus: http.StatusBadRequest,
+			header: http.Header{imdsTokenTTLHeader: {"21601"}},
+		},
+		{
+			name: "TokenForwarded", method: http.MethodPut, path: imdsTokenPath, wantStatus: http.StatusForbidden,
+			header: http.Header{imdsTokenTTLHeader: {"60"}, "X-Forwarded-For": {"10.0.0.1"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := aws.Config{Credentials: credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", "")}
+			server := NewIMDSServer(cfg, tt.opts...)
+			token := tt.token
+			if token == "issue" {
+				token = imdsToken(t, server, 60)
+			}
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, tt.path, nil)
+			for key, values := range tt.header {
+				req.Header[key] = values
+			}
+			if token != "" {
+				req.Header.Set(imdsTokenHeader, token)
+			}
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("body %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestIMDSServerTokenExpiry(t *testing.T) {
+	advance := stubClock(t)
+	cfg := aws.Config{Credentials: credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", "")}
+	server := NewIMDSServer(cfg, WithIMDSv2Only())
+	token := imdsToken(t, server, 60)
+
+	for _, step := range []struct {
+		advance    time.Duration
+		wantStatus int
+	}{
+		{advance: 59 * time.Second, wantStatus: http.StatusOK},
+		{advance: time.Second, wantStatus: http.StatusUnauthorized},
+	} {
+		advance(step.advance)
+		req := httptest.NewRequest(http.MethodGet, imdsCredentialsPath, nil)
+		req.Header.Set(imdsTokenHeader, token)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		if rec.Code != step.wantStatus {
+			t.Errorf("status %d after %s, want %d", rec.Code, step.advance, step.wantStatus)
+		}
+	}
+}
+
+func TestIMDSServerCredentials(t *testing.T) {
+	stubClock(t)
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		creds          aws.Credentials
+		wantExpiration string
+	}{
+		{
+			name: "Session",
+			creds: aws.Credentials{
+				AccessKeyID: "ASIAEXAMPLE", SecretAccessKey: "secret", SessionToken: "token",
+				CanExpire: true, Expires: expires.In(time.FixedZone("CET", 3600)),
+			},
+			wantExpiration: "2030-01-02T03:04:05Z",
+		},
+		{
+			name:           "NonExpiring",
+			creds:          aws.Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret"},
+			wantExpiration: timeNow().Add(time.Hour).UTC().Format(time.RFC3339),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := aws.Config{Credentials: credentials.StaticCredentialsProvider{Value: tt.creds}}
+			rec := httptest.NewRecorder()
+			NewIMDSServer(cfg).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, imdsCredentialsPath+DefaultIMDSRoleName, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status %d", rec.Code)
+			}
+			var doc map[string]string
+			if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+				t.Fatalf("credentials document: %v", err)
+			}
+			want := map[string]string{
+				"Code":            "Success",
+				"LastUpdated":     timeNow().UTC().Format(time.RFC3339),
+				"Type":            "AWS-HMAC",
+				"AccessKeyId":     tt.creds.AccessKeyID,
+				"SecretAccessKey": tt.creds.SecretAccessKey,
+				"Token":           tt.creds.SessionToken,
+				"Expiration":      tt.wantExpiration,
+			}
+			for key, value := range want {
+				if doc[key] != value {
+					t.Errorf("%s = %q, want %q", key, doc[key], value)
+				}
+			}
+		})
+	}
+}
+
+func TestIMDSServerRetrieveFails(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider aws.CredentialsProvider
+	}{
+		{name: "NilCredentials"},
+		{name: "RetrieveFails", provider: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{}, errors.New("no credentials")
+		})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			NewIMDSServer(aws.Config{Credentials: tt.provider}).ServeHTTP(rec,
+				httptest.NewRequest(http.MethodGet, imdsCredentialsPath+DefaultIMDSRoleName, nil))
+			if rec.Code != http.StatusInternalServerError {
+				t.Errorf("status %d, want %d", rec.Code, http.StatusInternalServerError)
+			}
+		})
+	}
+}
+
+// imdsToken gets an IMDSv2 session token valid for seconds from server
+func imdsToken(t *testing.T, server http.Handler, seconds int) string {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPut, imdsTokenPath, nil)
+	req.Header.Set(imdsTokenTTLHeader, strconv.Itoa(seconds))
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.Len() == 0 {
+		t.Fatalf("token request status %d", rec.Code)
+	}
+	if got := rec.Header().Get(imdsTokenTTLHeader); got != strconv.Itoa(seconds) {
+		t.Errorf("token TTL header %q, want %d", got, seconds)
+	}
+	return rec.Body.String()
+}
+
+func TestListenAndServeIMDS(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- ListenAndServeIMDS(ctx, "127.0.0.1:0", aws.Config{})
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("ListenAndServeIMDS error %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ListenAndServeIMDS did not return when ctx was done")
+	}
+}
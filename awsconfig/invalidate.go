@@ -0,0 +1,26 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+var (
+	// ErrNotInvalidatable is returned when the credentials provider of an aws.Config cannot be invalidated
+	ErrNotInvalidatable = errors.New("Credentials provider of passed aws.Config cannot be invalidated")
+)
+
+// InvalidateCredentials drops the cached credentials of cfg, such as those of the
+// configs built by this package, so the next Retrieve fetches fresh ones. The
+// CredentialCache backends of the config are invalidated too.
+func InvalidateCredentials(cfg aws.Config) error {
+	invalidator, ok := cfg.Credentials.(interface{ Invalidate() })
+	if !ok {
+		return fmt.Errorf("%w: %T", ErrNotInvalidatable, cfg.Credentials)
+	}
+	invalidator.Invalidate()
+	return invalidateCacheBackends(context.Background(), cfg.Credentials)
+}
@@ -0,0 +1,62 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigtest"
+)
+
+func TestInvalidateCredentials(t *testing.T) {
+	fake := &awsconfigtest.FakeSTS{}
+	cfg, err := NewAssumeRoleConf(context.Background(), aws.Config{}, testRoleArn, WithSTSClient(fake), WithSkipIdentityCheck())
+	if err != nil {
+		t.Fatalf("NewAssumeRoleConf: %v", err)
+	}
+
+	steps := []struct {
+		name       string
+		invalidate bool
+		wantCalls  int
+	}{
+		{name: "First", wantCalls: 1},
+		{name: "Cached", wantCalls: 1},
+		{name: "Invalidated", invalidate: true, wantCalls: 2},
+		{name: "CachedAgain", wantCalls: 2},
+	}
+	for _, step := range steps {
+		if step.invalidate {
+			if err := InvalidateCredentials(cfg); err != nil {
+				t.Fatalf("%s: InvalidateCredentials: %v", step.name, err)
+			}
+		}
+		if _, err := cfg.Credentials.Retrieve(context.Background()); err != nil {
+			t.Fatalf("%s: Retrieve: %v", step.name, err)
+		}
+		if got := len(fake.AssumeRoleInputs()); got != step.wantCalls {
+			t.Errorf("%s: AssumeRole called %d times, want %d", step.name, got, step.wantCalls)
+		}
+	}
+}
+
+func TestInvalidateCredentialsNotInvalidatable(t *testing.T) {
+	tests := []struct {
+		name  string
+		creds aws.CredentialsProvider
+	}{
+		{name: "Nil"},
+		{name: "Static", creds: credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", "")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := InvalidateCredentials(aws.Config{Credentials: tt.creds})
+			if !errors.Is(err, ErrNotInvalidatable) {
+				t.Errorf("InvalidateCredentials error %v, want %v", err, ErrNotInvalidatable)
+			}
+		})
+	}
+}
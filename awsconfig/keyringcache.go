@@ -0,0 +1,177 @@
+package awsconfig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/zalando/go-keyring"
+)
+
+var (
+	// ErrKeyringUnavailable is passed to the warning handler when the keyring
+	// cannot be used; credentials are then kept in memory only
+	ErrKeyringUnavailable = errors.New("Keyring unavailable, not persisting credentials")
+)
+
+// Keyring stores secrets by service and user, such as the OS keychain, Secret
+// Service, or Windows Credential Manager. Get returns an empty secret, and
+// Delete no error, when none is stored.
+type Keyring interface {
+	Get(service, user string) (string, error)
+	Set(service, user, secret string) error
+	Delete(service, user string) error
+}
+
+// WithKeyringCache persists assumed credentials in the OS keyring under
+// serviceName, keyed by the AssumeRole request and session name, so later processes reuse
+// them until near expiry. Without a usable keyring credentials are only kept
+// in memory and ErrKeyringUnavailable is passed to the warning handler.
+func WithKeyringCache(serviceName string) ConfOption {
+	return func(c *confOptions) {
+		c.keyringService = serviceName
+	}
+}
+
+// WithKeyring makes WithKeyringCache use kr instead of the OS keyring
+func WithKeyring(kr Keyring) ConfOption {
+	return func(c *confOptions) {
+		c.keyring = kr
+	}
+}
+
+// osKeyring is the Keyring of the operating system
+type osKeyring struct{}
+
+// Get implements the Keyring interface method
+func (osKeyring) Get(service, user string) (string, error) {
+	secret, err := keyring.Get(service, user)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", nil
+	}
+	return secret, err
+}
+
+// Set implements the Keyring interface method
+func (osKeyring) Set(service, user, secret string) error {
+	return keyring.Set(service, user, secret)
+}
+
+// Delete implements the Keyring interface method
+func (osKeyring) Delete(service, user string) error {
+	err := keyring.Delete(service, user)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// keyringEntry is the credentials persisted in a keyring
+type keyringEntry struct {
+	AccessKeyID     string    `json:"AccessKeyId"`
+	SecretAccessKey string    `json:"SecretAccessKey"`
+	SessionToken    string    `json:"SessionToken"`
+	Expiration      time.Time `json:"Expiration"`
+}
+
+// NewKeyringCache returns a CredentialCache storing credentials in kr under
+// service, with the key as user. Once kr fails, for example when no keyring is available,
+// it is no longer used and the first Put reports ErrKeyringUnavailable.
+func NewKeyringCache(kr Keyring, service string) CredentialCache {
+	return &keyringCache{keyring: kr, service: service}
+}
+
+// keyringCache is the CredentialCache of the keyring secrets of a service
+type keyringCache struct {
+	keyring Keyring
+	service string
+
+	// failed is the first error of the keyring, after which it isn't used again
+	mu       sync.Mutex
+	failed   error
+	reported bool
+}
+
+// Get implements the CredentialCache interface method; missing or corrupt secrets are a miss
+func (c *keyringCache) Get(_ context.Context, key string) (aws.Credentials, bool) {
+	if c.isFailed() {
+		return aws.Credentials{}, false
+	}
+	secret, err := c.keyring.Get(c.service, key)
+	if err != nil {
+		c.fail(err)
+		return aws.Credentials{}, false
+	}
+	if secret == "" {
+		return aws.Credentials{}, false
+	}
+	var entry keyringEntry
+	if err := json.Unmarshal([]byte(secret), &entry); err != nil {
+		return aws.Credentials{}, false
+	}
+	return aws.Credentials{
+		AccessKeyID:     entry.AccessKeyID,
+		SecretAccessKey: entry.SecretAccessKey,
+		SessionToken:    entry.SessionToken,
+		Source:          stscreds.ProviderName,
+		CanExpire:       true,
+		Expires:         entry.Expiration,
+	}, true
+}
+
+// Put implements the CredentialCache interface method
+func (c *keyringCache) Put(_ context.Context, key string, creds aws.Credentials) error {
+	if !c.isFailed() {
+		b, err := json.Marshal(keyringEntry{
+			AccessKeyID:     creds.AccessKeyID,
+			SecretAccessKey: creds.SecretAccessKey,
+			SessionToken:    creds.SessionToken,
+			Expiration:      creds.Expires,
+		})
+		if err == nil {
+			err = c.keyring.Set(c.service, key, string(b))
+		}
+		if err == nil {
+			return nil
+		}
+		c.fail(err)
+	}
+
+	// Report the failure once rather than on every refresh
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.reported {
+		return nil
+	}
+	c.reported = true
+	return fmt.Errorf("%w: %w", ErrKeyringUnavailable, c.failed)
+}
+
+// Invalidate implements the CredentialCache interface method
+func (c *keyringCache) Invalidate(_ context.Context, key string) error {
+	if c.isFailed() {
+		return nil
+	}
+	return c.keyring.Delete(c.service, key)
+}
+
+// isFailed reports whether the keyring has failed before
+func (c *keyringCache) isFailed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.failed != nil
+}
+
+// fail records the first error of the keyring
+func (c *keyringCache) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.failed == nil {
+		c.failed = err
+	}
+}
@@ -0,0 +1,104 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigtest"
+)
+
+func TestKeyringCacheAcrossConfigs(t *testing.T) {
+	otherRole := "arn:aws:iam::123456789012:role/Other"
+
+	tests := []struct {
+		name        string
+		second      string
+		secondOpts  []Option
+		wantAssumes int
+		wantSecrets int
+	}{
+		{name: "SameRoleAndSession", second: testRoleArn, wantAssumes: 1, wantSecrets: 1},
+		{name: "OtherSession", second: testRoleArn, secondOpts: []Option{WithRoleSessionName("other")}, wantAssumes: 2, wantSecrets: 2},
+		{name: "OtherRole", second: otherRole, wantAssumes: 2, wantSecrets: 2},
+		{name: "OtherService", second: testRoleArn, secondOpts: []Option{WithKeyringCache("other-tool")}, wantAssumes: 2, wantSecrets: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kr := &awsconfigtest.FakeKeyring{}
+			fake := &awsconfigtest.FakeSTS{Duration: time.Hour}
+			opts := []Option{
+				WithSTSClient(fake), WithSkipIdentityCheck(), WithRoleSessionName("shared"),
+				WithKeyringCache("tool"), WithKeyring(kr),
+			}
+
+			// Each config stands in for a separate process sharing the keyring
+			for _, step := range []struct {
+				roleArn string
+				opts    []Option
+			}{{testRoleArn, nil}, {tt.second, tt.secondOpts}} {
+				cfg, err := NewAssumeRoleConf(context.Background(), aws.Config{}, step.roleArn, append(opts, step.opts...)...)
+				if err != nil {
+					t.Fatalf("NewAssumeRoleConf: %v", err)
+				}
+				if _, err := cfg.Credentials.Retrieve(context.Background()); err != nil {
+					t.Fatalf("Retrieve: %v", err)
+				}
+			}
+			if got := len(fake.AssumeRoleInputs()); got != tt.wantAssumes {
+				t.Errorf("AssumeRole called %d times, want %d", got, tt.wantAssumes)
+			}
+			if got := kr.Len(); got != tt.wantSecrets {
+				t.Errorf("keyring holds %d secrets, want %d", got, tt.wantSecrets)
+			}
+		})
+	}
+}
+
+func TestKeyringCacheUnavailable(t *testing.T) {
+	unavailable := errors.New("no Secret Service on the bus")
+	kr := &awsconfigtest.FakeKeyring{Err: unavailable}
+	fake := &awsconfigtest.FakeSTS{Duration: time.Hour}
+
+	var mu sync.Mutex
+	var warnings []error
+	cfg, err := NewAssumeRoleConf(context.Background(), aws.Config{}, testRoleArn,
+		WithSTSClient(fake), WithSkipIdentityCheck(), WithKeyringCache("tool"), WithKeyring(kr),
+		WithWarningHandler(func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			warnings = append(warnings, err)
+		}))
+	if err != nil {
+		t.Fatalf("NewAssumeRoleConf: %v", err)
+	}
+
+	// Refreshes keep working without persistence and warn only once
+	for range 3 {
+		if _, err := cfg.Credentials.Retrieve(context.Background()); err != nil {
+			t.Fatalf("Retrieve: %v", err)
+		}
+		cfg.Credentials.(*aws.CredentialsCache).Invalidate()
+	}
+	if got := len(fake.AssumeRoleInputs()); got != 3 {
+		t.Errorf("AssumeRole called %d times, want 3", got)
+	}
+	if len(warnings) != 1 || !errors.Is(warnings[0], ErrKeyringUnavailable) || !errors.Is(warnings[0], unavailable) {
+		t.Errorf("warnings %v, want one %v", warnings, ErrKeyringUnavailable)
+	}
+}
+
+func TestKeyringCacheCorruptSecret(t *testing.T) {
+	kr := &awsconfigtest.FakeKeyring{}
+	if err := kr.Set("tool", "user", "{not json"); err != nil {
+		t.Fatal(err)
+	}
+	cache := NewKeyringCache(kr, "tool")
+	if _, ok := cache.Get(context.Background(), "user"); ok {
+		t.Error("Get reported a hit for a corrupt secret")
+	}
+}
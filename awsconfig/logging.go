@@ -0,0 +1,87 @@
+package awsconfig
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+)
+
+// Messages and attribute keys of the events logged by WithLogger; they are stable
+const (
+	LogMsgRefreshStarted   = "awsconfig credentials refresh started"
+	LogMsgRefreshSucceeded = "awsconfig credentials refresh succeeded"
+	LogMsgRefreshFailed    = "awsconfig credentials refresh failed"
+
+	LogKeyProvider = "provider"
+	LogKeyRoleArn  = "role_arn"
+	LogKeyDuration = "duration"
+	LogKeyExpires  = "expires"
+	LogKeyError    = "error"
+)
+
+// WithLogger logs credential refreshes to logger: when they start, succeed with
+// the new expiry, or fail, and how long they took
+func WithLogger(logger *slog.Logger) ConfOption {
+	return func(c *confOptions) {
+		c.logger = logger
+	}
+}
+
+// WithRedactedLogs masks the account ID of role ARNs logged by WithLogger
+func WithRedactedLogs() ConfOption {
+	return func(c *confOptions) {
+		c.redactLogs = true
+	}
+}
+
+// withLogging wraps provider to log its refreshes, if WithLogger is set
+func withLogging(conf *confOptions, name, roleArn string, provider aws.CredentialsProvider) aws.CredentialsProvider {
+	if conf.logger == nil {
+		return provider
+	}
+	attrs := []any{slog.String(LogKeyProvider, name)}
+	if roleArn != "" {
+		if conf.redactLogs {
+			roleArn = redactArn(roleArn)
+		}
+		attrs = append(attrs, slog.String(LogKeyRoleArn, roleArn))
+	}
+	return &loggingProvider{logger: conf.logger.With(attrs...), provider: provider}
+}
+
+// redactArn masks the account ID of an ARN
+func redactArn(s string) string {
+	parsed, err := arn.Parse(s)
+	if err != nil || parsed.AccountID == "" {
+		return s
+	}
+	parsed.AccountID = strings.Repeat("*", len(parsed.AccountID))
+	return parsed.String()
+}
+
+// loggingProvider logs the refreshes of its provider
+type loggingProvider struct {
+	logger   *slog.Logger
+	provider aws.CredentialsProvider
+}
+
+// Retrieve implements the aws.CredentialsProvider interface method
+func (p *loggingProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	p.logger.DebugContext(ctx, LogMsgRefreshStarted)
+	start := timeNow()
+	creds, err := p.provider.Retrieve(ctx)
+	took := slog.Duration(LogKeyDuration, timeNow().Sub(start))
+	if err != nil {
+		p.logger.ErrorContext(ctx, LogMsgRefreshFailed, took, slog.Any(LogKeyError, err))
+		return creds, err
+	}
+	if creds.CanExpire {
+		p.logger.InfoContext(ctx, LogMsgRefreshSucceeded, took, slog.Time(LogKeyExpires, creds.Expires))
+	} else {
+		p.logger.InfoContext(ctx, LogMsgRefreshSucceeded, took)
+	}
+	return creds, nil
+}
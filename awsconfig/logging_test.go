@@ -0,0 +1,99 @@
+package awsconfig
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/smithy-go"
+
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigtest"
+)
+
+// captureLogs returns a logger writing JSON records and a func decoding those logged so far
+func captureLogs(t *testing.T) (*slog.Logger, func() []map[string]any) {
+	t.Helper()
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	return logger, func() []map[string]any {
+		var records []map[string]any
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			var record map[string]any
+			if err := json.Unmarshal([]byte(line), &record); err != nil {
+				t.Fatalf("log line %q: %v", line, err)
+			}
+			records = append(records, record)
+		}
+		return records
+	}
+}
+
+func TestWithLogger(t *testing.T) {
+	denied := &smithy.GenericAPIError{Code: "AccessDenied", Message: "denied"}
+	tests := []struct {
+		name        string
+		custom      bool
+		opts        []Option
+		assumeErr   error
+		wantMsg     string
+		wantRoleArn string
+	}{
+		{name: "AssumeRoleSucceeded", wantMsg: LogMsgRefreshSucceeded, wantRoleArn: testRoleArn},
+		{name: "AssumeRoleFailed", assumeErr: denied, wantMsg: LogMsgRefreshFailed, wantRoleArn: testRoleArn},
+		{
+			name:        "Redacted",
+			opts:        []Option{WithRedactedLogs()},
+			wantMsg:     LogMsgRefreshSucceeded,
+			wantRoleArn: "arn:aws:iam::************:role/Target",
+		},
+		{name: "CustomFunction", custom: true, wantMsg: LogMsgRefreshSucceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, records := captureLogs(t)
+			opts := append([]Option{WithLogger(logger)}, tt.opts...)
+			var cfg aws.Config
+			var err error
+			if tt.custom {
+				retrieve, _ := countingRetrieve(time.Hour)
+				cfg, err = NewCustomFunctionConf(context.Background(), aws.Config{}, retrieve, opts...)
+			} else {
+				fake := &awsconfigtest.FakeSTS{AssumeRoleErr: tt.assumeErr}
+				opts = append(opts, WithSTSClient(fake), WithSkipIdentityCheck())
+				cfg, err = NewAssumeRoleConf(context.Background(), aws.Config{}, testRoleArn, opts...)
+			}
+			if err != nil {
+				t.Fatalf("constructor: %v", err)
+			}
+			if _, err := cfg.Credentials.Retrieve(context.Background()); !errors.Is(err, tt.assumeErr) {
+				t.Fatalf("Retrieve error %v, want %v", err, tt.assumeErr)
+			}
+
+			got := records()
+			if len(got) != 2 {
+				t.Fatalf("logged %d records, want 2: %v", len(got), got)
+			}
+			if got[0]["msg"] != LogMsgRefreshStarted || got[1]["msg"] != tt.wantMsg {
+				t.Errorf("logged %q then %q, want %q then %q", got[0]["msg"], got[1]["msg"], LogMsgRefreshStarted, tt.wantMsg)
+			}
+			done := got[1]
+			if _, ok := done[LogKeyDuration]; !ok {
+				t.Errorf("record %v has no %s", done, LogKeyDuration)
+			}
+			if roleArn, _ := done[LogKeyRoleArn].(string); roleArn != tt.wantRoleArn {
+				t.Errorf("record %s %q, want %q", LogKeyRoleArn, roleArn, tt.wantRoleArn)
+			}
+			_, hasExpires := done[LogKeyExpires]
+			_, hasError := done[LogKeyError]
+			if failed := tt.assumeErr != nil; hasExpires == failed || hasError != failed {
+				t.Errorf("record %v: has %s %t, has %s %t", done, LogKeyExpires, hasExpires, LogKeyError, hasError)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+)
+
+var (
+	// ErrDiscoverMaxDuration is passed to the warning handler when the MaxSessionDuration
+	// of the target role cannot be read and the default session duration is used instead
+	ErrDiscoverMaxDuration = errors.New("Cannot discover MaxSessionDuration of IAM Role, using default duration")
+)
+
+// GetRoleAPIClient is a client capable of the IAM GetRole operation
+type GetRoleAPIClient interface {
+	GetRole(ctx context.Context, params *iam.GetRoleInput, optFns ...func(*iam.Options)) (*iam.GetRoleOutput, error)
+}
+
+// WithMaxAvailableDuration requests the longest session the target role allows,
+// read from its MaxSessionDuration with iam:GetRole at construction time
+func WithMaxAvailableDuration() ConfOption {
+	return func(c *confOptions) {
+		c.maxAvailableDuration = true
+	}
+}
+
+// WithIAMClient makes WithMaxAvailableDuration use client instead of building one
+// from the base config. It is needed for roles in accounts other than that of
+// the base credentials, as GetRole only reads roles of the caller's account.
+func WithIAMClient(client GetRoleAPIClient) ConfOption {
+	return func(c *confOptions) {
+		c.iamClient = client
+	}
+}
+
+// discoverMaxSessionDuration reads the MaxSessionDuration of the role; failures
+// are passed to the warning handler and reported as ok == false. Without an
+// injected IAM client, roles of accounts other than that of caller, when known,
+// aren't looked up: GetRole would read a role of the same name in the caller's.
+func discoverMaxSessionDuration(
+	ctx context.Context,
+	cfg aws.Config,
+	conf *confOptions,
+	parsed arn.ARN,
+	caller *CallerIdentity,
+) (duration time.Duration, ok bool) {
+	client := conf.iamClient
+	if client == nil {
+		if caller != nil && caller.AccountID != "" && caller.AccountID != parsed.AccountID {
+			if conf.warn != nil {
+				conf.warn(fmt.Errorf("%w: %s: role is not in account %s of the base credentials, use WithIAMClient",
+					ErrDiscoverMaxDuration, parsed, caller.AccountID))
+			}
+			return 0, false
+		}
+		client = iam.NewFromConfig(cfg)
+	}
+
+	// GetRole takes the bare role name, without any path
+	roleName := parsed.Resource[strings.LastIndex(parsed.Resource, "/")+1:]
+	out, err := client.GetRole(ctx, &iam.GetRoleInput{
+		RoleName: aws.String(roleName),
+	})
+	if err == nil && (out.Role == nil || out.Role.MaxSessionDuration == nil) {
+		err = errors.New("GetRole returned no MaxSessionDuration")
+	}
+	if err != nil {
+		if conf.warn != nil {
+			conf.warn(fmt.Errorf("%w: %s: %w", ErrDiscoverMaxDuration, parsed, err))
+		}
+		return 0, false
+	}
+	return time.Duration(*out.Role.MaxSessionDuration) * time.Second, true
+}
@@ -0,0 +1,103 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigtest"
+)
+
+func TestWithMaxAvailableDuration(t *testing.T) {
+	tests := []struct {
+		name         string
+		roleArn      string
+		callerArn    string
+		account      string
+		iam          *awsconfigtest.FakeIAM
+		wantDuration int32
+		wantWarning  error
+		wantRole     string
+	}{
+		{name: "OneHour", iam: &awsconfigtest.FakeIAM{MaxSessionDuration: time.Hour}, wantDuration: 3600},
+		{name: "TwelveHours", iam: &awsconfigtest.FakeIAM{MaxSessionDuration: 12 * time.Hour}, wantDuration: 43200},
+		{
+			name:         "RolePath",
+			roleArn:      "arn:aws:iam::123456789012:role/service-role/Deploy",
+			iam:          &awsconfigtest.FakeIAM{MaxSessionDuration: 4 * time.Hour},
+			wantDuration: 14400,
+			wantRole:     "Deploy",
+		},
+		{
+			name:         "ChainedClamped",
+			callerArn:    "arn:aws:sts::123456789012:assumed-role/Base/session",
+			iam:          &awsconfigtest.FakeIAM{MaxSessionDuration: 12 * time.Hour},
+			wantDuration: 3600,
+		},
+		{
+			name:         "AccessDenied",
+			iam:          &awsconfigtest.FakeIAM{GetRoleErr: errors.New("AccessDenied: iam:GetRole")},
+			wantDuration: 900, // stscreds default
+			wantWarning:  ErrDiscoverMaxDuration,
+		},
+		{
+			// Built from the base config, the IAM client would read a role of the caller's account
+			name:         "CrossAccount",
+			account:      "210987654321",
+			wantDuration: 900,
+			wantWarning:  ErrDiscoverMaxDuration,
+		},
+		{
+			name:         "CrossAccountIAMClient",
+			account:      "210987654321",
+			iam:          &awsconfigtest.FakeIAM{MaxSessionDuration: 4 * time.Hour},
+			wantDuration: 14400,
+			wantRole:     "Target",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roleArn := tt.roleArn
+			if roleArn == "" {
+				roleArn = testRoleArn
+			}
+			fake := &awsconfigtest.FakeSTS{}
+			if tt.callerArn != "" {
+				fake.Identity.Arn = aws.String(tt.callerArn)
+			}
+			if tt.account != "" {
+				fake.Identity.Account = aws.String(tt.account)
+			}
+			var warnings []error
+			opts := []Option{
+				WithSTSClient(fake),
+				WithMaxAvailableDuration(),
+				WithWarningHandler(func(err error) { warnings = append(warnings, err) }),
+			}
+			if tt.iam != nil {
+				opts = append(opts, WithIAMClient(tt.iam))
+			}
+			cfg, err := NewAssumeRoleConf(context.Background(), aws.Config{}, roleArn, opts...)
+			if err != nil {
+				t.Fatalf("NewAssumeRoleConf: %v", err)
+			}
+			if _, err := cfg.Credentials.Retrieve(context.Background()); err != nil {
+				t.Fatalf("Retrieve: %v", err)
+			}
+			if got := aws.ToInt32(fake.AssumeRoleInputs()[0].DurationSeconds); got != tt.wantDuration {
+				t.Errorf("AssumeRole DurationSeconds %d, want %d", got, tt.wantDuration)
+			}
+			if tt.wantWarning != nil && (len(warnings) != 1 || !errors.Is(warnings[0], tt.wantWarning)) {
+				t.Errorf("warnings %v, want %v", warnings, tt.wantWarning)
+			}
+			if wantRole := tt.wantRole; wantRole != "" {
+				if names := tt.iam.RoleNames(); len(names) != 1 || names[0] != wantRole {
+					t.Errorf("GetRole called for %q, want %q", names, wantRole)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package awsconfig
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// MetricsCollector observes credential refreshes. ObserveRefresh is called once
+// per refresh with the role ARN, empty for custom functions, how long the
+// refresh took, and its error, if any. It must be safe for concurrent use.
+type MetricsCollector interface {
+	ObserveRefresh(role string, d time.Duration, err error)
+}
+
+// WithMetrics reports every credential refresh to collector; see the
+// awsconfigprom package for a Prometheus collector
+func WithMetrics(collector MetricsCollector) ConfOption {
+	return func(c *confOptions) {
+		c.metrics = collector
+	}
+}
+
+// withMetrics wraps provider to report its refreshes, if WithMetrics is set
+func withMetrics(conf *confOptions, roleArn string, provider aws.CredentialsProvider) aws.CredentialsProvider {
+	if conf.metrics == nil {
+		return provider
+	}
+	return &metricsProvider{collector: conf.metrics, roleArn: roleArn, provider: provider}
+}
+
+// metricsProvider reports the refreshes of its provider to a MetricsCollector
+type metricsProvider struct {
+	collector MetricsCollector
+	roleArn   string
+	provider  aws.CredentialsProvider
+}
+
+// Retrieve implements the aws.CredentialsProvider interface method
+func (p *metricsProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	start := timeNow()
+	creds, err := p.provider.Retrieve(ctx)
+	p.collector.ObserveRefresh(p.roleArn, timeNow().Sub(start), err)
+	return creds, err
+}
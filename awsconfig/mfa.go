@@ -0,0 +1,92 @@
+package awsconfig
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidMFAToken is returned when an MFA token code is not 6 digits
+	ErrInvalidMFAToken = errors.New("MFA token code must be exactly 6 digits")
+	// ErrInvalidTOTPSecret is returned when a TOTP seed is not valid base32
+	ErrInvalidTOTPSecret = errors.New("Cannot decode base32 TOTP secret")
+)
+
+const totpPeriod = 30
+
+// timeNow is the package clock, replaceable in tests
+var timeNow = time.Now
+
+// StdinTokenProvider returns an MFA token provider for WithMFA that writes prompt
+// to w and reads a 6-digit token code line from r.
+func StdinTokenProvider(r io.Reader, w io.Writer, prompt string) func() (string, error) {
+	reader := bufio.NewReader(r)
+	return func() (string, error) {
+		if _, err := fmt.Fprint(w, prompt); err != nil {
+			return "", err
+		}
+		line, err := reader.ReadString('\n')
+		if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+			return "", err
+		}
+		token := strings.TrimSpace(line)
+		if !isMFAToken(token) {
+			return "", ErrInvalidMFAToken
+		}
+		return token, nil
+	}
+}
+
+// isMFAToken reports whether token is exactly 6 ASCII digits
+func isMFAToken(token string) bool {
+	if len(token) != 6 {
+		return false
+	}
+	for _, c := range token {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// TOTPTokenProvider returns an MFA token provider for WithMFA that computes
+// RFC 6238 codes (SHA-1, 30-second period, 6 digits) from a base32 seed.
+func TOTPTokenProvider(secretBase32 string) (func() (string, error), error) {
+	// Tolerate whitespace, lower case, and missing or present padding
+	normalized := strings.ToUpper(strings.Join(strings.Fields(secretBase32), ""))
+	normalized = strings.TrimRight(normalized, "=")
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(normalized)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidTOTPSecret, err)
+	}
+	if len(secret) == 0 {
+		return nil, ErrInvalidTOTPSecret
+	}
+	return func() (string, error) {
+		return totpCode(secret, timeNow()), nil
+	}, nil
+}
+
+// totpCode computes the 6-digit RFC 6238 code for secret at t
+func totpCode(secret []byte, t time.Time) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(t.Unix()/totpPeriod))
+
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	// RFC 4226 dynamic truncation
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", code%1000000)
+}
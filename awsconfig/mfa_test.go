@@ -0,0 +1,116 @@
+package awsconfig
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStdinTokenProvider(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr error
+	}{
+		{name: "Valid", input: "123456\n", want: "123456"},
+		{name: "Whitespace", input: "  654321 \r\n", want: "654321"},
+		{name: "NoNewline", input: "000111", want: "000111"},
+		{name: "TooShort", input: "12345\n", wantErr: ErrInvalidMFAToken},
+		{name: "TooLong", input: "1234567\n", wantErr: ErrInvalidMFAToken},
+		{name: "NotDigits", input: "12a456\n", wantErr: ErrInvalidMFAToken},
+		{name: "Empty", input: "\n", wantErr: ErrInvalidMFAToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			got, err := StdinTokenProvider(strings.NewReader(tt.input), &out, "MFA code: ")()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("token provider error %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("token provider returned %q, want %q", got, tt.want)
+			}
+			if out.String() != "MFA code: " {
+				t.Errorf("prompt written as %q", out.String())
+			}
+		})
+	}
+}
+
+func TestStdinTokenProviderScripted(t *testing.T) {
+	// A pipe feeding several codes serves one per call
+	provider := StdinTokenProvider(strings.NewReader("111111\n222222\n"), &bytes.Buffer{}, "")
+	for _, want := range []string{"111111", "222222"} {
+		if got, err := provider(); err != nil || got != want {
+			t.Errorf("token provider returned %q, %v, want %q", got, err, want)
+		}
+	}
+	if _, err := provider(); err == nil {
+		t.Error("token provider succeeded on exhausted input")
+	}
+}
+
+// rfc6238Secret is the RFC 6238 SHA-1 test seed "12345678901234567890"
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPTokenProvider(t *testing.T) {
+	// RFC 6238 Appendix B SHA-1 vectors, truncated to 6 digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1111111111, want: "050471"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+		{unix: 20000000000, want: "353130"},
+	}
+	provider, err := TOTPTokenProvider(rfc6238Secret)
+	if err != nil {
+		t.Fatalf("TOTPTokenProvider: %v", err)
+	}
+	origNow := timeNow
+	t.Cleanup(func() { timeNow = origNow })
+	for _, tt := range tests {
+		timeNow = func() time.Time { return time.Unix(tt.unix, 0) }
+		got, err := provider()
+		if err != nil || got != tt.want {
+			t.Errorf("code at %d = %q, %v, want %q", tt.unix, got, err, tt.want)
+		}
+	}
+}
+
+func TestTOTPTokenProviderSecret(t *testing.T) {
+	tests := []struct {
+		name    string
+		secret  string
+		wantErr error
+	}{
+		{name: "Unpadded", secret: rfc6238Secret},
+		{name: "LowerCase", secret: strings.ToLower(rfc6238Secret)},
+		{name: "Grouped", secret: "GEZD GNBV GY3T QOJQ GEZD GNBV GY3T QOJQ"},
+		{name: "Padded", secret: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGE======"},
+		{name: "PaddingStripped", secret: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGE"},
+		{name: "Invalid", secret: "not!base32", wantErr: ErrInvalidTOTPSecret},
+		{name: "Empty", secret: "", wantErr: ErrInvalidTOTPSecret},
+		{name: "OnlyPadding", secret: "====", wantErr: ErrInvalidTOTPSecret},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := TOTPTokenProvider(tt.secret)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("TOTPTokenProvider error %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if code, err := provider(); err != nil || !isMFAToken(code) {
+				t.Errorf("provider returned %q, %v", code, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package awsconfig
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// Option configures the aws.Config constructors in this package. It is
+// implemented by AssumeRoleOption, WebIdentityOption, SessionTokenOption,
+// SAMLRoleOption and FederationTokenOption, which set fields on the STS request
+// of their constructor, and by ConfOption, which controls how the aws.Config
+// itself is built.
+type Option interface {
+	apply(*confOptions)
+}
+
+// AssumeRoleOption sets fields on the stscreds.AssumeRoleOptions of the AssumeRole request
+type AssumeRoleOption func(*stscreds.AssumeRoleOptions)
+
+func (o AssumeRoleOption) apply(c *confOptions) {
+	c.assumeRoleOpts = append(c.assumeRoleOpts, o)
+}
+
+// WithAssumeRoleOptions adapts plain stscreds option funcs, such as a
+// []func(*stscreds.AssumeRoleOptions) passed to NewAssumeRoleConf before it
+// took Options, into a single Option
+func WithAssumeRoleOptions(fns ...func(*stscreds.AssumeRoleOptions)) ConfOption {
+	return func(c *confOptions) {
+		c.assumeRoleOpts = append(c.assumeRoleOpts, fns...)
+	}
+}
+
+// WebIdentityOption sets fields on the stscreds.WebIdentityRoleOptions of NewWebIdentityConf
+type WebIdentityOption func(*stscreds.WebIdentityRoleOptions)
+
+func (o WebIdentityOption) apply(c *confOptions) {
+	c.webIdentityOpts = append(c.webIdentityOpts, o)
+}
+
+// WithWebIdentityRoleOptions adapts plain stscreds option funcs, such as a
+// []func(*stscreds.WebIdentityRoleOptions) passed to NewWebIdentityConf before
+// it took Options, into a single Option
+func WithWebIdentityRoleOptions(fns ...func(*stscreds.WebIdentityRoleOptions)) ConfOption {
+	return func(c *confOptions) {
+		c.webIdentityOpts = append(c.webIdentityOpts, fns...)
+	}
+}
+
+// SessionTokenOption sets fields on the SessionTokenOptions of NewSessionTokenConf
+type SessionTokenOption func(*SessionTokenOptions)
+
+func (o SessionTokenOption) apply(c *confOptions) {
+	c.sessionTokenOpts = append(c.sessionTokenOpts, o)
+}
+
+// WithSessionTokenOptions adapts plain SessionTokenOptions funcs, such as a
+// []func(*SessionTokenOptions) passed to NewSessionTokenConf before it took
+// Options, into a single Option
+func WithSessionTokenOptions(fns ...func(*SessionTokenOptions)) ConfOption {
+	return func(c *confOptions) {
+		c.sessionTokenOpts = append(c.sessionTokenOpts, fns...)
+	}
+}
+
+// SAMLRoleOption sets fields on the SAMLRoleOptions of NewSAMLConf
+type SAMLRoleOption func(*SAMLRoleOptions)
+
+func (o SAMLRoleOption) apply(c *confOptions) {
+	c.samlOpts = append(c.samlOpts, o)
+}
+
+// WithSAMLRoleOptions adapts plain SAMLRoleOptions funcs, such as a
+// []func(*SAMLRoleOptions) passed to NewSAMLConf before it took Options, into
+// a single Option
+func WithSAMLRoleOptions(fns ...func(*SAMLRoleOptions)) ConfOption {
+	return func(c *confOptions) {
+		c.samlOpts = append(c.samlOpts, fns...)
+	}
+}
+
+// FederationTokenOption sets fields on the FederationTokenOptions of NewFederationTokenConf
+type FederationTokenOption func(*FederationTokenOptions)
+
+func (o FederationTokenOption) apply(c *confOptions) {
+	c.federationTokenOpts = append(c.federationTokenOpts, o)
+}
+
+// WithFederationTokenOptions adapts plain FederationTokenOptions funcs, such as
+// a []func(*FederationTokenOptions) passed to NewFederationTokenConf before it
+// took Options, into a single Option
+func WithFederationTokenOptions(fns ...func(*FederationTokenOptions)) ConfOption {
+	return func(c *confOptions) {
+		c.federationTokenOpts = append(c.federationTokenOpts, fns...)
+	}
+}
+
+// ConfOption controls how the constructors in this package build an aws.Config
+type ConfOption func(*confOptions)
+
+func (o ConfOption) apply(c *confOptions) {
+	o(c)
+}
+
+// confOptions is the resolved set of Options passed to a constructor
+type confOptions struct {
+	assumeRoleOpts      []func(*stscreds.AssumeRoleOptions)
+	webIdentityOpts     []func(*stscreds.WebIdentityRoleOptions)
+	sessionTokenOpts    []func(*SessionTokenOptions)
+	samlOpts            []func(*SAMLRoleOptions)
+	federationTokenOpts []func(*FederationTokenOptions)
+	cacheOpts           []func(*aws.CredentialsCacheOptions)
+	skipIdentityCheck   bool
+	processTimeout      *time.Duration
+	ssoCacheDir         string
+	forcedTTL           time.Duration
+	warn                func(error)
+	propagatePanic      bool
+	retrieveTimeout     time.Duration
+	retrieveRetries     int
+	retrieveBackoff     func(attempt int) time.Duration
+
+	identityCheckAttempts int
+	callerIdentity        *sts.GetCallerIdentityOutput
+	stsClient             STSClient
+	stsOpts               []func(*sts.Options)
+	stsEndpoint           string
+	skipPartitionCheck    bool
+	rolePath              string
+	sessionNameFromCaller bool
+	denyStatements        []policyStatement
+	decodeAuthErrors      bool
+	postAssumeVerify      bool
+	expectedAccounts      []string
+	preWarm               bool
+	refreshWindow         time.Duration
+	maxStaleness          time.Duration
+	stsLimiter            *STSLimiter
+	circuitBreaker        *CircuitBreaker
+	stsFallbackRegions    []string
+	logger                *slog.Logger
+	redactLogs            bool
+	tracerProvider        any // trace.TracerProvider, only used by tracing.go
+	metrics               MetricsCollector
+	refreshCallback       func(RefreshEvent)
+	concurrency           int
+	organizationsClient   OrganizationsAPIClient
+	skipManagementAccount bool
+	accountFilter         func(orgtypes.Account) bool
+	identityCache         *IdentityCache
+	fileCache             bool
+	fileCacheDir          string
+	fileCacheLockTimeout  time.Duration
+	fileCacheShared       bool
+	cacheBackends         []CredentialCache
+	minValidity           time.Duration
+	scrubAWSEnv           bool
+	expirationComment     bool
+	consoleIssuer         string
+	federationEndpoint    string
+	unsignedPayload       bool
+	imdsRoleName          string
+	imdsV2Only            bool
+	httpAuthToken         string
+	httpAuthTokenFile     string
+	podIdentityEndpoint   string
+	podIdentityTokenFile  string
+	cognitoClient         CognitoIdentityAPIClient
+	cognitoRoleArn        string
+	secretsManagerClient  SecretsManagerAPIClient
+	secretVersionStage    string
+	ssmClient             SSMAPIClient
+	ssmParameterPath      bool
+	stickyChain           bool
+	fallbackThreshold     int
+	recoveryProbeInterval time.Duration
+	fallbackCallback      func(FallbackEvent)
+	validateTimeout       time.Duration
+	validateCredentials   bool
+	redactSource          bool
+	customFunctionName    string
+	keyringService        string
+	keyring               Keyring
+	chainedDurationPolicy ChainedDurationPolicy
+	maxAvailableDuration  bool
+	iamClient             GetRoleAPIClient
+}
+
+// newConfOptions applies opts in order over the package defaults
+func newConfOptions(opts []Option) *confOptions {
+	c := &confOptions{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt.apply(c)
+		}
+	}
+	return c
+}
+
+// WithSkipIdentityCheck skips the GetCallerIdentity preflight made against the
+// base config, e.g. where sts:GetCallerIdentity is denied by an SCP.
+func WithSkipIdentityCheck() ConfOption {
+	return func(c *confOptions) {
+		c.skipIdentityCheck = true
+	}
+}
+
+// WithCacheOptions passes optFns through to the aws.NewCredentialsCache wrapping the provider
+func WithCacheOptions(optFns ...func(*aws.CredentialsCacheOptions)) ConfOption {
+	return func(c *confOptions) {
+		c.cacheOpts = append(c.cacheOpts, optFns...)
+	}
+}
+
+// WithExpiryWindow sets how long before expiry the cached credentials are refreshed
+func WithExpiryWindow(window time.Duration) ConfOption {
+	return WithCacheOptions(func(o *aws.CredentialsCacheOptions) {
+		o.ExpiryWindow = window
+	})
+}
+
+// WithExpiryWindowJitterFrac randomizes the expiry window by up to frac (0.0-1.0)
+func WithExpiryWindowJitterFrac(frac float64) ConfOption {
+	return WithCacheOptions(func(o *aws.CredentialsCacheOptions) {
+		o.ExpiryWindowJitterFrac = frac
+	})
+}
+
+// WithProcessTimeout bounds how long NewProcessProvider and NewProcessConf let the
+// command run; zero disables the limit
+func WithProcessTimeout(timeout time.Duration) ConfOption {
+	return func(c *confOptions) {
+		c.processTimeout = &timeout
+	}
+}
+
+// WithForcedTTL stamps an expiry of ttl from retrieval on custom function,
+// Secrets Manager, and SSM parameter credentials that don't set one, so the
+// cache refreshes them periodically.
+func WithForcedTTL(ttl time.Duration) ConfOption {
+	return func(c *confOptions) {
+		c.forcedTTL = ttl
+	}
+}
+
+// WithWarningHandler receives non-fatal problems noticed while building or
+// refreshing credentials, which are otherwise ignored.
+func WithWarningHandler(handler func(error)) ConfOption {
+	return func(c *confOptions) {
+		c.warn = handler
+	}
+}
+
+// WithoutPanicRecovery lets panics in a custom retrieve function crash the
+// process instead of being converted into an ErrRetrievePanic error.
+func WithoutPanicRecovery() ConfOption {
+	return func(c *confOptions) {
+		c.propagatePanic = true
+	}
+}
+
+// WithRetrieveTimeout bounds each custom function retrieval; zero means no timeout
+func WithRetrieveTimeout(timeout time.Duration) ConfOption {
+	return func(c *confOptions) {
+		c.retrieveTimeout = timeout
+	}
+}
+
+// WithRetrieveRetries retries a failing custom retrieve function up to max more
+// times, waiting backoff(attempt) between attempts; a nil backoff retries
+// immediately. Errors wrapping ErrPermanent are not retried.
+func WithRetrieveRetries(max int, backoff func(attempt int) time.Duration) ConfOption {
+	return func(c *confOptions) {
+		c.retrieveRetries = max
+		c.retrieveBackoff = backoff
+	}
+}
+
+// WithIdentityCheckAttempts sets how many times the GetCallerIdentity preflight is
+// tried when it fails with a timeout, connection error, or throttle
+func WithIdentityCheckAttempts(attempts int) ConfOption {
+	return func(c *confOptions) {
+		c.identityCheckAttempts = attempts
+	}
+}
+
+// WithPreWarm retrieves credentials before the constructor returns, bounded by
+// its context, so the first call made with the config doesn't wait on them
+func WithPreWarm() ConfOption {
+	return func(c *confOptions) {
+		c.preWarm = true
+	}
+}
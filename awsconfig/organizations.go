@@ -0,0 +1,93 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	"github.com/aws/aws-sdk-go-v2/service/organizations/types"
+)
+
+var (
+	// ErrListAccounts is returned when the accounts of an organization cannot be listed
+	ErrListAccounts = errors.New("Cannot list accounts of AWS Organization")
+)
+
+// OrganizationsAPIClient is a client capable of the Organizations ListAccounts
+// and DescribeOrganization operations
+type OrganizationsAPIClient interface {
+	organizations.ListAccountsAPIClient
+	DescribeOrganization(ctx context.Context, params *organizations.DescribeOrganizationInput, optFns ...func(*organizations.Options)) (*organizations.DescribeOrganizationOutput, error)
+}
+
+// WithOrganizationsClient makes AssumeRoleInOrganization use client instead of building one from the base config
+func WithOrganizationsClient(client OrganizationsAPIClient) ConfOption {
+	return func(c *confOptions) {
+		c.organizationsClient = client
+	}
+}
+
+// WithoutManagementAccount makes AssumeRoleInOrganization skip the organization's management account
+func WithoutManagementAccount() ConfOption {
+	return func(c *confOptions) {
+		c.skipManagementAccount = true
+	}
+}
+
+// WithAccountFilter makes AssumeRoleInOrganization skip the active accounts for which keep returns false
+func WithAccountFilter(keep func(types.Account) bool) ConfOption {
+	return func(c *confOptions) {
+		c.accountFilter = keep
+	}
+}
+
+// AssumeRoleInOrganization assumes roleName in every ACTIVE member account of the
+// organization of mgmtCfg, as AssumeRoleInAccounts does. The error is only set
+// when the accounts cannot be listed; per-account failures are in errs.
+func AssumeRoleInOrganization(
+	ctx context.Context,
+	mgmtCfg aws.Config,
+	roleName string,
+	opts ...Option,
+) (configs map[string]aws.Config, errs map[string]error, err error) {
+	conf := newConfOptions(opts)
+	client := conf.organizationsClient
+	if client == nil {
+		client = organizations.NewFromConfig(mgmtCfg)
+	}
+
+	managementAccountID := ""
+	if conf.skipManagementAccount {
+		out, err := client.DescribeOrganization(ctx, &organizations.DescribeOrganizationInput{})
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %w", ErrListAccounts, err)
+		}
+		if out.Organization != nil {
+			managementAccountID = aws.ToString(out.Organization.MasterAccountId)
+		}
+	}
+
+	var accountIDs []string
+	paginator := organizations.NewListAccountsPaginator(client, &organizations.ListAccountsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %w", ErrListAccounts, err)
+		}
+		for _, account := range page.Accounts {
+			accountID := aws.ToString(account.Id)
+			if account.Status != types.AccountStatusActive || accountID == managementAccountID {
+				continue
+			}
+			if conf.accountFilter != nil && !conf.accountFilter(account) {
+				continue
+			}
+			accountIDs = append(accountIDs, accountID)
+		}
+	}
+
+	configs, errs = AssumeRoleInAccounts(ctx, mgmtCfg, accountIDs, roleName, opts...)
+	return configs, errs, nil
+}
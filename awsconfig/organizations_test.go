@@ -0,0 +1,118 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/aws/smithy-go"
+
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigtest"
+)
+
+// testOrgAccounts returns n accounts numbered from 1, suspended where suspended says so
+func testOrgAccounts(n int, suspended map[int]bool) []types.Account {
+	var accounts []types.Account
+	for i := 1; i <= n; i++ {
+		status := types.AccountStatusActive
+		if suspended[i] {
+			status = types.AccountStatusSuspended
+		}
+		accounts = append(accounts, types.Account{
+			Id:     aws.String(fmt.Sprintf("%012d", i)),
+			Name:   aws.String(fmt.Sprintf("account-%d", i)),
+			Status: status,
+		})
+	}
+	return accounts
+}
+
+func TestAssumeRoleInOrganization(t *testing.T) {
+	denied := &smithy.GenericAPIError{Code: "AccessDenied", Message: "denied"}
+	tests := []struct {
+		name          string
+		accounts      int
+		suspended     map[int]bool
+		deny          map[string]error
+		opts          []Option
+		wantPageCalls int
+		wantConfigs   string
+		wantErrs      string
+	}{
+		{
+			name:          "Paginated",
+			accounts:      5,
+			wantPageCalls: 3,
+			wantConfigs:   "000000000001 000000000002 000000000003 000000000004 000000000005",
+		},
+		{
+			name:          "ActiveOnly",
+			accounts:      4,
+			suspended:     map[int]bool{2: true, 4: true},
+			wantPageCalls: 2,
+			wantConfigs:   "000000000001 000000000003",
+		},
+		{
+			name:          "WithoutManagementAccount",
+			accounts:      3,
+			opts:          []Option{WithoutManagementAccount()},
+			wantPageCalls: 2,
+			wantConfigs:   "000000000002 000000000003",
+		},
+		{
+			name:     "AccountFilter",
+			accounts: 4,
+			opts: []Option{WithAccountFilter(func(account types.Account) bool {
+				return !strings.HasSuffix(aws.ToString(account.Name), "-3")
+			})},
+			wantPageCalls: 2,
+			wantConfigs:   "000000000001 000000000002 000000000004",
+		},
+		{
+			name:          "PartialFailure",
+			accounts:      3,
+			deny:          map[string]error{"000000000002": denied},
+			wantPageCalls: 2,
+			wantConfigs:   "000000000001 000000000003",
+			wantErrs:      "000000000002",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			org := &awsconfigtest.FakeOrganizations{
+				Accounts:            testOrgAccounts(tt.accounts, tt.suspended),
+				PageSize:            2,
+				ManagementAccountID: "000000000001",
+			}
+			client := &accountSTS{FakeSTS: &awsconfigtest.FakeSTS{}, deny: tt.deny}
+			opts := append([]Option{WithOrganizationsClient(org), WithSTSClient(client), WithPreWarm()}, tt.opts...)
+			configs, errs, err := AssumeRoleInOrganization(context.Background(), aws.Config{Region: "us-east-1"}, "OrganizationAccountAccessRole", opts...)
+			if err != nil {
+				t.Fatalf("AssumeRoleInOrganization: %v", err)
+			}
+			if got := org.PageCalls(); got != tt.wantPageCalls {
+				t.Errorf("ListAccounts called %d times, want %d", got, tt.wantPageCalls)
+			}
+			if got := sortedKeys(configs); got != tt.wantConfigs {
+				t.Errorf("configs for %q, want %q", got, tt.wantConfigs)
+			}
+			if got := sortedKeys(errs); got != tt.wantErrs {
+				t.Errorf("errors for %q, want %q: %v", got, tt.wantErrs, errs)
+			}
+		})
+	}
+}
+
+func TestAssumeRoleInOrganizationListFails(t *testing.T) {
+	denied := &smithy.GenericAPIError{Code: "AWSOrganizationsNotInUseException"}
+	org := &awsconfigtest.FakeOrganizations{ListAccountsErr: denied}
+	_, _, err := AssumeRoleInOrganization(context.Background(), aws.Config{Region: "us-east-1"}, "OrganizationAccountAccessRole",
+		WithOrganizationsClient(org), WithSTSClient(&awsconfigtest.FakeSTS{}))
+	if !errors.Is(err, ErrListAccounts) || !errors.Is(err, denied) {
+		t.Errorf("AssumeRoleInOrganization error %v, want %v wrapping %v", err, ErrListAccounts, denied)
+	}
+}
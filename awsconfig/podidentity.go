@@ -0,0 +1,55 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// EnvContainerAuthorizationTokenFile names the file holding the token of the EKS Pod Identity agent
+const EnvContainerAuthorizationTokenFile = "AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"
+
+var (
+	// ErrPodIdentityEndpointNotSet is returned when AWS_CONTAINER_CREDENTIALS_FULL_URI is
+	// not set, usually because the pod has no EKS Pod Identity association
+	ErrPodIdentityEndpointNotSet = errors.New(
+		"AWS_CONTAINER_CREDENTIALS_FULL_URI is not set, the pod may not be associated with an EKS Pod Identity",
+	)
+	// ErrPodIdentityTokenFileNotSet is returned when AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE is not set
+	ErrPodIdentityTokenFileNotSet = errors.New(
+		"AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE is not set, the pod may not be associated with an EKS Pod Identity",
+	)
+)
+
+// WithPodIdentityEndpoint makes NewPodIdentityConf use endpoint and tokenFile
+// instead of reading them from the environment
+func WithPodIdentityEndpoint(endpoint, tokenFile string) ConfOption {
+	return func(c *confOptions) {
+		c.podIdentityEndpoint = endpoint
+		c.podIdentityTokenFile = tokenFile
+	}
+}
+
+// NewPodIdentityConf returns an aws.Config getting credentials from the EKS Pod
+// Identity agent at AWS_CONTAINER_CREDENTIALS_FULL_URI, authenticating with the
+// token in AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE, read for every request as
+// the agent rotates it. The options of NewHTTPCredentialsConf apply.
+func NewPodIdentityConf(ctx context.Context, cfg aws.Config, opts ...Option) (aws.Config, error) {
+	conf := newConfOptions(opts)
+	endpoint, tokenFile := conf.podIdentityEndpoint, conf.podIdentityTokenFile
+	if endpoint == "" {
+		endpoint = os.Getenv(EnvContainerCredentialsFullURI)
+	}
+	if tokenFile == "" {
+		tokenFile = os.Getenv(EnvContainerAuthorizationTokenFile)
+	}
+	if endpoint == "" {
+		return aws.Config{}, ErrPodIdentityEndpointNotSet
+	}
+	if tokenFile == "" {
+		return aws.Config{}, ErrPodIdentityTokenFileNotSet
+	}
+	return NewHTTPCredentialsConf(ctx, cfg, endpoint, append(opts, WithHTTPAuthTokenFile(tokenFile))...)
+}
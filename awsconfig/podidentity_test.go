@@ -0,0 +1,138 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestNewPodIdentityConf(t *testing.T) {
+	agent := newPodIdentityAgent(t)
+	tokenFile := filepath.Join(t.TempDir(), "eks-pod-identity-token")
+	if err := os.WriteFile(tokenFile, []byte("first-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		opts []Option
+	}{
+		{
+			name: "Environment",
+			env:  map[string]string{EnvContainerCredentialsFullURI: agent.URL, EnvContainerAuthorizationTokenFile: tokenFile},
+		},
+		{
+			name: "Override",
+			env:  map[string]string{EnvContainerCredentialsFullURI: "", EnvContainerAuthorizationTokenFile: ""},
+			opts: []Option{WithPodIdentityEndpoint(agent.URL, tokenFile)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			if err := os.WriteFile(tokenFile, []byte("first-token\n"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			agent.reset()
+
+			cfg, err := NewPodIdentityConf(context.Background(), aws.Config{Region: "eu-west-1"}, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewPodIdentityConf: %v", err)
+			}
+			creds, err := cfg.Credentials.Retrieve(context.Background())
+			if err != nil {
+				t.Fatalf("Retrieve: %v", err)
+			}
+			if creds.AccessKeyID != "ASIAPODIDENTITY" || creds.Source != HTTPCredentialsProviderName {
+				t.Errorf("Retrieve returned %+v", creds)
+			}
+
+			// The agent rotates the token; the next refresh sends the new one
+			if err := os.WriteFile(tokenFile, []byte("rotated-token\n"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			cfg.Credentials.(*aws.CredentialsCache).Invalidate()
+			if _, err := cfg.Credentials.Retrieve(context.Background()); err != nil {
+				t.Fatalf("Retrieve after rotation: %v", err)
+			}
+			if got, want := fmt.Sprint(agent.tokens()), "[first-token rotated-token]"; got != want {
+				t.Errorf("agent received tokens %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestNewPodIdentityConfErrors(t *testing.T) {
+	agent := newPodIdentityAgent(t)
+	tokenFile := filepath.Join(t.TempDir(), "token")
+
+	tests := []struct {
+		name    string
+		uri     string
+		file    string
+		wantErr error
+	}{
+		{name: "NotAssociated", wantErr: ErrPodIdentityEndpointNotSet},
+		{name: "NoTokenFile", uri: agent.URL, wantErr: ErrPodIdentityTokenFileNotSet},
+		{name: "NoEndpoint", file: tokenFile, wantErr: ErrPodIdentityEndpointNotSet},
+		{name: "RemoteHTTP", uri: "http://agent.example.com/v1/credentials", file: tokenFile, wantErr: ErrInvalidHTTPCredentialsEndpoint},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(EnvContainerCredentialsFullURI, tt.uri)
+			t.Setenv(EnvContainerAuthorizationTokenFile, tt.file)
+			if _, err := NewPodIdentityConf(context.Background(), aws.Config{}); !errors.Is(err, tt.wantErr) {
+				t.Errorf("NewPodIdentityConf error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// podIdentityAgent emulates the EKS Pod Identity agent, recording the
+// Authorization tokens it receives
+type podIdentityAgent struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	received []string
+}
+
+// newPodIdentityAgent starts a podIdentityAgent closed when the test ends
+func newPodIdentityAgent(t *testing.T) *podIdentityAgent {
+	a := &podIdentityAgent{}
+	a.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.mu.Lock()
+		a.received = append(a.received, r.Header.Get("Authorization"))
+		a.mu.Unlock()
+		fmt.Fprintf(w, `{"AccessKeyId":"ASIAPODIDENTITY","SecretAccessKey":"secret","Token":"token","AccountId":"123456789012","Expiration":%q}`,
+			time.Now().Add(6*time.Hour).UTC().Format(time.RFC3339))
+	}))
+	t.Cleanup(a.Close)
+	return a
+}
+
+// tokens returns the Authorization tokens received
+func (a *podIdentityAgent) tokens() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]string(nil), a.received...)
+}
+
+// reset forgets the tokens received
+func (a *podIdentityAgent) reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.received = nil
+}
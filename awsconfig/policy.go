@@ -0,0 +1,186 @@
+package awsconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+var (
+	// ErrInvalidSessionPolicy is returned when an inline session policy is not a valid policy document
+	ErrInvalidSessionPolicy = errors.New("Invalid inline session policy")
+	// ErrPolicyTooLarge is returned alongside the minified policy by MinifyPolicy when
+	// it is still likely over the STS limit; callers may ignore it and let STS decide
+	ErrPolicyTooLarge = errors.New("Session policy likely exceeds the STS size limit even when minified")
+	// ErrInvalidPolicyArn is returned when a managed session policy ARN is malformed or there are too many
+	ErrInvalidPolicyArn = errors.New("Invalid managed session policy ARN")
+)
+
+const (
+	// MaxSessionPolicySize is the most plaintext characters STS accepts in inline session policies
+	MaxSessionPolicySize = 2048
+
+	// maxPolicyArns is the most managed session policy ARNs STS accepts
+	maxPolicyArns = 10
+)
+
+// validatePolicy checks an inline session policy is a JSON object with the
+// top-level Version and Statement fields; an empty policy is not checked
+func validatePolicy(policy string) error {
+	if policy == "" {
+		return nil
+	}
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(policy), &doc); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return fmt.Errorf("%w: at offset %d: %w", ErrInvalidSessionPolicy, syntaxErr.Offset, err)
+		}
+		return fmt.Errorf("%w: not a JSON object", ErrInvalidSessionPolicy)
+	}
+	for _, field := range []string{"Version", "Statement"} {
+		if _, ok := doc[field]; !ok {
+			return fmt.Errorf("%w: missing %s", ErrInvalidSessionPolicy, field)
+		}
+	}
+	return nil
+}
+
+// validatePolicyArns checks the managed session policy ARNs of a request
+func validatePolicyArns(policyArns []types.PolicyDescriptorType) error {
+	if len(policyArns) > maxPolicyArns {
+		return fmt.Errorf("%w: %d ARNs, limit %d", ErrInvalidPolicyArn, len(policyArns), maxPolicyArns)
+	}
+	for _, policyArn := range policyArns {
+		s := aws.ToString(policyArn.Arn)
+		parsed, err := arn.Parse(s)
+		if err != nil {
+			return fmt.Errorf("%w: %q: %w", ErrInvalidPolicyArn, s, err)
+		}
+		if parsed.Service != "iam" || !strings.HasPrefix(parsed.Resource, "policy/") {
+			return fmt.Errorf("%w: %q is not an IAM policy ARN", ErrInvalidPolicyArn, s)
+		}
+	}
+	return nil
+}
+
+// MinifyPolicy strips insignificant whitespace from a session policy, keeping
+// field order. If the result is still over MaxSessionPolicySize it is returned
+// along with an ErrPolicyTooLarge error.
+func MinifyPolicy(policy string) (string, error) {
+	if err := validatePolicy(policy); err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	if err := json.Compact(&b, []byte(policy)); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidSessionPolicy, err)
+	}
+	minified := b.String()
+	if size := len(minified); size > MaxSessionPolicySize {
+		return minified, fmt.Errorf("%w: %d characters, limit %d", ErrPolicyTooLarge, size, MaxSessionPolicySize)
+	}
+	return minified, nil
+}
+
+// EstimatePackedPolicySize estimates the size STS counts against MaxSessionPolicySize,
+// the length of the minified policy. It is best-effort: STS packs policies with
+// an undocumented compression, so treat it as an upper bound for headroom checks.
+func EstimatePackedPolicySize(policy string) int {
+	var b bytes.Buffer
+	if err := json.Compact(&b, []byte(policy)); err != nil {
+		return len(policy)
+	}
+	return b.Len()
+}
+
+// WithMinifiedPolicy sets an inline session policy after passing it through
+// MinifyPolicy; a policy that can't be minified is set as given
+func WithMinifiedPolicy(policy string) AssumeRoleOption {
+	// Invalid policies are left for the construction-time check to report
+	if minified, _ := MinifyPolicy(policy); minified != "" {
+		policy = minified
+	}
+	return WithPolicy(policy)
+}
+
+// uniqueSid returns sid, or sid with the lowest number from 2 up appended that
+// is not yet in seen, and adds the result to seen
+func uniqueSid(sid string, seen map[string]bool) string {
+	unique := sid
+	for n := 2; seen[unique]; n++ {
+		unique = fmt.Sprintf("%s%d", sid, n)
+	}
+	seen[unique] = true
+	return unique
+}
+
+// policyVersion is the only policy language version MergePolicies emits
+const policyVersion = "2012-10-17"
+
+// MergePolicies combines session policy documents into one minified document by
+// concatenating their statements. Duplicate Sids are made unique by appending a
+// number. Every document must use Version 2012-10-17.
+func MergePolicies(policies ...string) (string, error) {
+	var statements []json.RawMessage
+	sids := map[string]bool{}
+	for i, policy := range policies {
+		if err := validatePolicy(policy); err != nil {
+			return "", fmt.Errorf("policy %d: %w", i+1, err)
+		}
+		var doc struct {
+			Version   string
+			Statement json.RawMessage
+		}
+		if err := json.Unmarshal([]byte(policy), &doc); err != nil {
+			return "", fmt.Errorf("policy %d: %w: %w", i+1, ErrInvalidSessionPolicy, err)
+		}
+		if doc.Version != policyVersion {
+			return "", fmt.Errorf(
+				"policy %d: %w: Version %q, not %q", i+1, ErrInvalidSessionPolicy, doc.Version, policyVersion,
+			)
+		}
+
+		// Statement may be a single object or an array of them
+		var docStatements []map[string]json.RawMessage
+		if err := json.Unmarshal(doc.Statement, &docStatements); err != nil {
+			var statement map[string]json.RawMessage
+			if err := json.Unmarshal(doc.Statement, &statement); err != nil {
+				return "", fmt.Errorf("policy %d: %w: Statement is not an object or array", i+1, ErrInvalidSessionPolicy)
+			}
+			docStatements = append(docStatements, statement)
+		}
+
+		for _, statement := range docStatements {
+			if raw, ok := statement["Sid"]; ok {
+				var sid string
+				if err := json.Unmarshal(raw, &sid); err != nil {
+					return "", fmt.Errorf("policy %d: %w: Sid is not a string", i+1, ErrInvalidSessionPolicy)
+				}
+				statement["Sid"], _ = json.Marshal(uniqueSid(sid, sids))
+			}
+			b, err := json.Marshal(statement)
+			if err != nil {
+				return "", err
+			}
+			statements = append(statements, b)
+		}
+	}
+	if len(statements) == 0 {
+		return "", fmt.Errorf("%w: no statements to merge", ErrInvalidSessionPolicy)
+	}
+
+	b, err := json.Marshal(struct {
+		Version   string
+		Statement []json.RawMessage
+	}{policyVersion, statements})
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
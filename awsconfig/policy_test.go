@@ -0,0 +1,244 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigtest"
+)
+
+const testPolicy = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"*"}]}`
+
+func TestNewAssumeRoleConfPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    string
+		wantErr   error
+		wantInMsg string
+	}{
+		{name: "Empty"},
+		{name: "Valid", policy: testPolicy},
+		{name: "Truncated", policy: testPolicy[:40], wantErr: ErrInvalidSessionPolicy},
+		{name: "SyntaxError", policy: `{"Version": "2012-10-17",, "Statement": []}`, wantErr: ErrInvalidSessionPolicy, wantInMsg: "offset 26"},
+		{name: "MissingStatement", policy: `{"Version":"2012-10-17"}`, wantErr: ErrInvalidSessionPolicy, wantInMsg: "missing Statement"},
+		{name: "MissingVersion", policy: `{"Statement":[]}`, wantErr: ErrInvalidSessionPolicy, wantInMsg: "missing Version"},
+		{name: "NotAnObject", policy: `["Version","Statement"]`, wantErr: ErrInvalidSessionPolicy, wantInMsg: "not a JSON object"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewAssumeRoleConf(context.Background(), aws.Config{}, testRoleArn,
+				WithSTSClient(&awsconfigtest.FakeSTS{}), WithSkipIdentityCheck(), WithPolicy(tt.policy))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewAssumeRoleConf error %v, want %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), tt.wantInMsg) {
+				t.Errorf("NewAssumeRoleConf error %q does not contain %q", err, tt.wantInMsg)
+			}
+		})
+	}
+}
+
+// indentedPolicy returns a policy of n statements, indented as a generator
+// would write it
+func indentedPolicy(n int) string {
+	var b strings.Builder
+	b.WriteString("{\n    \"Version\": \"2012-10-17\",\n    \"Statement\": [\n")
+	for i := range n {
+		if i > 0 {
+			b.WriteString(",\n")
+		}
+		fmt.Fprintf(&b, "        {\n            \"Sid\": \"Read%d\",\n            \"Effect\": \"Allow\",\n"+
+			"            \"Action\": [\n                \"s3:GetObject\"\n            ],\n"+
+			"            \"Resource\": \"arn:aws:s3:::bucket-%d/*\"\n        }", i, i)
+	}
+	b.WriteString("\n    ]\n}\n")
+	return b.String()
+}
+
+func TestMinifyPolicy(t *testing.T) {
+	// Over the limit as written, within it once minified
+	policy := indentedPolicy(20)
+	if len(policy) <= MaxSessionPolicySize {
+		t.Fatalf("test policy is %d characters, want it over %d", len(policy), MaxSessionPolicySize)
+	}
+	minified, err := MinifyPolicy(policy)
+	if err != nil {
+		t.Fatalf("MinifyPolicy: %v", err)
+	}
+	if strings.ContainsAny(minified, " \n") {
+		t.Errorf("MinifyPolicy left whitespace: %s", minified)
+	}
+	if size := EstimatePackedPolicySize(policy); size != len(minified) || size > MaxSessionPolicySize {
+		t.Errorf("EstimatePackedPolicySize = %d, want %d within %d", size, len(minified), MaxSessionPolicySize)
+	}
+	if !strings.HasPrefix(minified, `{"Version":"2012-10-17","Statement":[{"Sid":"Read0",`) {
+		t.Errorf("MinifyPolicy reordered fields: %s", minified[:60])
+	}
+
+	got := aws.ToString(assumeRoleInput(t, WithMinifiedPolicy(policy)).Policy)
+	if got != minified {
+		t.Errorf("WithMinifiedPolicy sent %d characters, want the %d of the minified policy", len(got), len(minified))
+	}
+}
+
+func TestMinifyPolicyErrors(t *testing.T) {
+	tooLarge, err := MinifyPolicy(indentedPolicy(40))
+	if !errors.Is(err, ErrPolicyTooLarge) || tooLarge == "" {
+		t.Errorf("MinifyPolicy returned %d characters, %v, want the policy and %v", len(tooLarge), err, ErrPolicyTooLarge)
+	}
+	if _, err := MinifyPolicy(`{"Version":`); !errors.Is(err, ErrInvalidSessionPolicy) {
+		t.Errorf("MinifyPolicy error %v, want %v", err, ErrInvalidSessionPolicy)
+	}
+}
+
+func TestMergePolicies(t *testing.T) {
+	guardrail := `{"Version": "2012-10-17", "Statement": {"Sid": "DenyIAM", "Effect": "Deny", "Action": "iam:*", "Resource": "*"}}`
+	tenant := `{"Version": "2012-10-17", "Statement": [{"Sid": "Read", "Effect": "Allow", "Action": "s3:GetObject", "Resource": "*"}]}`
+	extra := `{"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Action": "sqs:*", "Resource": "*"}]}`
+
+	tests := []struct {
+		name     string
+		policies []string
+		want     string
+		wantErr  error
+	}{
+		{
+			name:     "ThreeWay",
+			policies: []string{guardrail, tenant, extra},
+			want: `{"Version":"2012-10-17","Statement":[` +
+				`{"Action":"iam:*","Effect":"Deny","Resource":"*","Sid":"DenyIAM"},` +
+				`{"Action":"s3:GetObject","Effect":"Allow","Resource":"*","Sid":"Read"},` +
+				`{"Action":"sqs:*","Effect":"Allow","Resource":"*"}]}`,
+		},
+		{
+			name:     "DuplicateSid",
+			policies: []string{tenant, tenant, tenant},
+			want: `{"Version":"2012-10-17","Statement":[` +
+				`{"Action":"s3:GetObject","Effect":"Allow","Resource":"*","Sid":"Read"},` +
+				`{"Action":"s3:GetObject","Effect":"Allow","Resource":"*","Sid":"Read2"},` +
+				`{"Action":"s3:GetObject","Effect":"Allow","Resource":"*","Sid":"Read3"}]}`,
+		},
+		{name: "OldVersion", policies: []string{tenant, `{"Version": "2008-10-17", "Statement": []}`}, wantErr: ErrInvalidSessionPolicy},
+		{name: "StatementString", policies: []string{`{"Version": "2012-10-17", "Statement": "Allow"}`}, wantErr: ErrInvalidSessionPolicy},
+		{name: "Invalid", policies: []string{tenant, `{`}, wantErr: ErrInvalidSessionPolicy},
+		{name: "NoStatements", policies: []string{`{"Version": "2012-10-17", "Statement": []}`}, wantErr: ErrInvalidSessionPolicy},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergePolicies(tt.policies...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("MergePolicies error %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("MergePolicies =\n%s\nwant\n%s", got, tt.want)
+			}
+			if err == nil {
+				if err := validatePolicy(got); err != nil {
+					t.Errorf("merged policy not usable with WithPolicy: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestNewAssumeRoleConfPolicyArns(t *testing.T) {
+	const readOnly = "arn:aws:iam::aws:policy/ReadOnlyAccess"
+	tooMany := make([]string, maxPolicyArns+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("arn:aws:iam::123456789012:policy/p%d", i)
+	}
+	tests := []struct {
+		name      string
+		arns      []string
+		wantErr   error
+		wantInMsg string
+	}{
+		{name: "Valid", arns: []string{readOnly, "arn:aws-cn:iam::123456789012:policy/path/custom"}},
+		{name: "EmptySkipped", arns: []string{"", readOnly, ""}},
+		{name: "AtLimit", arns: tooMany[:maxPolicyArns]},
+		{name: "TooMany", arns: tooMany, wantErr: ErrInvalidPolicyArn, wantInMsg: "11 ARNs, limit 10"},
+		{
+			name:      "MissingRegionField",
+			arns:      []string{readOnly, "arn:aws:iam:aws:policy/ReadOnlyAccess"},
+			wantErr:   ErrInvalidPolicyArn,
+			wantInMsg: `"arn:aws:iam:aws:policy/ReadOnlyAccess"`,
+		},
+		{
+			name:      "NotIAM",
+			arns:      []string{"arn:aws:s3:::bucket/policy/x"},
+			wantErr:   ErrInvalidPolicyArn,
+			wantInMsg: `"arn:aws:s3:::bucket/policy/x" is not an IAM policy ARN`,
+		},
+		{
+			name:      "NotPolicy",
+			arns:      []string{"arn:aws:iam::123456789012:role/Admin"},
+			wantErr:   ErrInvalidPolicyArn,
+			wantInMsg: `"arn:aws:iam::123456789012:role/Admin" is not an IAM policy ARN`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewAssumeRoleConf(context.Background(), aws.Config{}, testRoleArn,
+				WithSTSClient(&awsconfigtest.FakeSTS{}), WithSkipIdentityCheck(), WithPolicyArns(tt.arns))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewAssumeRoleConf error %v, want %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), tt.wantInMsg) {
+				t.Errorf("NewAssumeRoleConf error %q does not contain %q", err, tt.wantInMsg)
+			}
+		})
+	}
+}
+
+func TestWithPolicyArnsSkipsEmpty(t *testing.T) {
+	const readOnly = "arn:aws:iam::aws:policy/ReadOnlyAccess"
+	in := assumeRoleInput(t, WithPolicyArns([]string{"", readOnly, ""}))
+	if len(in.PolicyArns) != 1 || aws.ToString(in.PolicyArns[0].Arn) != readOnly {
+		t.Errorf("AssumeRole PolicyArns %v, want only %s", in.PolicyArns, readOnly)
+	}
+}
+
+func TestWithPolicyArn(t *testing.T) {
+	const (
+		readOnly = "arn:aws:iam::aws:policy/ReadOnlyAccess"
+		billing  = "arn:aws:iam::aws:policy/job-function/Billing"
+		custom   = "arn:aws:iam::123456789012:policy/custom"
+	)
+	tests := []struct {
+		name string
+		opts []Option
+		want []string
+	}{
+		{
+			name: "AppendsAfterWithPolicyArns",
+			opts: []Option{WithPolicyArns([]string{readOnly}), WithPolicyArn(billing), WithPolicyArn(custom)},
+			want: []string{readOnly, billing, custom},
+		},
+		{
+			name: "DuplicatesCollapsed",
+			opts: []Option{WithPolicyArns([]string{readOnly}), WithPolicyArn(readOnly), WithPolicyArn(billing), WithPolicyArn(billing)},
+			want: []string{readOnly, billing},
+		},
+		{
+			name: "WithPolicyArnsReplaces",
+			opts: []Option{WithPolicyArn(billing), WithPolicyArns([]string{readOnly})},
+			want: []string{readOnly},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, p := range assumeRoleInput(t, tt.opts...).PolicyArns {
+				got = append(got, aws.ToString(p.Arn))
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("AssumeRole PolicyArns %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+)
+
+const (
+	// DefaultIdentityCheckAttempts is how many times the GetCallerIdentity preflight is tried
+	DefaultIdentityCheckAttempts = 3
+
+	identityCheckBaseDelay = 100 * time.Millisecond
+	identityCheckMaxDelay  = 2 * time.Second
+)
+
+// getCallerIdentityAPIClient is a client capable of the STS GetCallerIdentity operation
+type getCallerIdentityAPIClient interface {
+	GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error)
+}
+
+// checkIdentity runs the GetCallerIdentity preflight, retrying transient failures
+// with exponential backoff up to the configured number of attempts.
+func checkIdentity(
+	ctx context.Context,
+	client getCallerIdentityAPIClient,
+	conf *confOptions,
+) (*sts.GetCallerIdentityOutput, error) {
+	maxAttempts := conf.identityCheckAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultIdentityCheckAttempts
+	}
+
+	delay := identityCheckBaseDelay
+	for attempt := 1; ; attempt++ {
+		out, err := client.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+		if err == nil {
+			return out, nil
+		}
+		if attempt >= maxAttempts || !isTransientError(err) {
+			return nil, fmt.Errorf("%w after %d attempt(s): %w", ErrIdentityCheckFailed, attempt, err)
+		}
+		if ctxErr := sleepContext(ctx, delay); ctxErr != nil {
+			return nil, fmt.Errorf("%w after %d attempt(s): %w: %w", ErrIdentityCheckFailed, attempt, ctxErr, err)
+		}
+		delay = min(delay*2, identityCheckMaxDelay)
+	}
+}
+
+// isTransientError reports whether err is a timeout, connection failure, or throttle
+func isTransientError(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return throttleErrorCodes[apiErr.ErrorCode()]
+	}
+	// Covers timeouts, DNS failures, and refused or reset connections
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
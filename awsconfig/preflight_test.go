@@ -0,0 +1,105 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigtest"
+)
+
+// scriptedSTS fails GetCallerIdentity with each of errs in turn, then defers to FakeSTS
+type scriptedSTS struct {
+	*awsconfigtest.FakeSTS
+
+	mu    sync.Mutex
+	errs  []error
+	calls int
+}
+
+// GetCallerIdentity implements the STSClient interface method
+func (s *scriptedSTS) GetCallerIdentity(
+	ctx context.Context,
+	params *sts.GetCallerIdentityInput,
+	optFns ...func(*sts.Options),
+) (*sts.GetCallerIdentityOutput, error) {
+	s.mu.Lock()
+	call := s.calls
+	s.calls++
+	s.mu.Unlock()
+	if call < len(s.errs) {
+		return nil, s.errs[call]
+	}
+	return s.FakeSTS.GetCallerIdentity(ctx, params, optFns...)
+}
+
+func TestCheckIdentityRetries(t *testing.T) {
+	throttled := &smithy.GenericAPIError{Code: "Throttling", Message: "Rate exceeded"}
+	timeout := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("i/o timeout")}
+	denied := &smithy.GenericAPIError{Code: "AccessDenied", Message: "denied"}
+	badToken := &smithy.GenericAPIError{Code: "InvalidClientTokenId", Message: "bad token"}
+
+	tests := []struct {
+		name      string
+		errs      []error
+		attempts  int
+		wantErr   error
+		wantCalls int
+	}{
+		{name: "TransientTwiceThenSuccess", errs: []error{throttled, timeout}, wantCalls: 3},
+		{name: "RequestLimitExceeded", errs: []error{&smithy.GenericAPIError{Code: "RequestLimitExceeded"}}, wantCalls: 2},
+		{name: "Exhausted", errs: []error{throttled, throttled, timeout}, wantErr: timeout, wantCalls: 3},
+		{name: "AccessDenied", errs: []error{denied}, wantErr: denied, wantCalls: 1},
+		{name: "InvalidClientTokenId", errs: []error{badToken}, wantErr: badToken, wantCalls: 1},
+		{name: "SingleAttempt", errs: []error{throttled}, attempts: 1, wantErr: throttled, wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &scriptedSTS{FakeSTS: &awsconfigtest.FakeSTS{}, errs: tt.errs}
+			opts := []Option{WithSTSClient(client)}
+			if tt.attempts != 0 {
+				opts = append(opts, WithIdentityCheckAttempts(tt.attempts))
+			}
+			_, err := NewAssumeRoleConf(context.Background(), aws.Config{}, testRoleArn, opts...)
+			if client.calls != tt.wantCalls {
+				t.Errorf("GetCallerIdentity called %d times, want %d", client.calls, tt.wantCalls)
+			}
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("NewAssumeRoleConf: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrIdentityCheckFailed) || !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewAssumeRoleConf error %v, want %v wrapping %v", err, ErrIdentityCheckFailed, tt.wantErr)
+			}
+			if want := fmt.Sprintf("after %d attempt(s)", tt.wantCalls); !strings.Contains(err.Error(), want) {
+				t.Errorf("NewAssumeRoleConf error %q does not mention %q", err, want)
+			}
+		})
+	}
+}
+
+func TestCheckIdentityCancelled(t *testing.T) {
+	client := &scriptedSTS{
+		FakeSTS: &awsconfigtest.FakeSTS{},
+		errs:    []error{&smithy.GenericAPIError{Code: "Throttling"}},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := checkIdentity(ctx, client, newConfOptions(nil))
+	if !errors.Is(err, context.Canceled) || !errors.Is(err, ErrIdentityCheckFailed) {
+		t.Errorf("checkIdentity error %v, want %v wrapping %v", err, ErrIdentityCheckFailed, context.Canceled)
+	}
+	if client.calls != 1 {
+		t.Errorf("GetCallerIdentity called %d times after cancellation, want 1", client.calls)
+	}
+}
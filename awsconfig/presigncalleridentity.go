@@ -0,0 +1,80 @@
+package awsconfig
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+const (
+	// VaultServerIDHeader is the header Vault's AWS auth method checks against
+	// its configured iam_server_id_header_value
+	VaultServerIDHeader = "X-Vault-AWS-IAM-Server-ID"
+
+	// getCallerIdentityBody is the form body of a GetCallerIdentity request
+	getCallerIdentityBody = "Action=GetCallerIdentity&Version=2011-06-15"
+)
+
+// SignedRequest is a signed sts:GetCallerIdentity request in the form of the
+// login parameters of Vault's AWS IAM auth method: the URL, headers as JSON,
+// and body are base64 encoded.
+type SignedRequest struct {
+	Method  string `json:"iam_http_request_method"`
+	URL     string `json:"iam_request_url"`
+	Headers string `json:"iam_request_headers"`
+	Body    string `json:"iam_request_body"`
+}
+
+// PresignCallerIdentity signs a GetCallerIdentity request with the credentials
+// of cfg without sending it, so a service such as Vault can send it to prove
+// the caller's identity. headers are added and signed, such as the
+// VaultServerIDHeader. The request goes to the regional STS endpoint of cfg,
+// or the global endpoint in us-east-1 when it has no region, Vault's default.
+func PresignCallerIdentity(ctx context.Context, cfg aws.Config, headers map[string]string) (SignedRequest, error) {
+	if cfg.Credentials == nil {
+		return SignedRequest{}, ErrNilCredentials
+	}
+	creds, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return SignedRequest{}, fmt.Errorf("%w: %w", ErrRetrieveCredentials, err)
+	}
+
+	region, endpoint := "us-east-1", "https://sts.amazonaws.com/"
+	if cfg.Region != "" && cfg.Region != "us-east-1" {
+		region, endpoint = cfg.Region, "https://sts."+cfg.Region+".amazonaws.com/"
+		if regionPartition(cfg.Region) == "aws-cn" {
+			endpoint = "https://sts." + cfg.Region + ".amazonaws.com.cn/"
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(getCallerIdentityBody))
+	if err != nil {
+		return SignedRequest{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	hash := sha256.Sum256([]byte(getCallerIdentityBody))
+	if err := v4.NewSigner().SignHTTP(ctx, creds, req, hex.EncodeToString(hash[:]), "sts", region, timeNow()); err != nil {
+		return SignedRequest{}, err
+	}
+	headersJSON, err := json.Marshal(req.Header)
+	if err != nil {
+		return SignedRequest{}, err
+	}
+	return SignedRequest{
+		Method:  req.Method,
+		URL:     base64.StdEncoding.EncodeToString([]byte(req.URL.String())),
+		Headers: base64.StdEncoding.EncodeToString(headersJSON),
+		Body:    base64.StdEncoding.EncodeToString([]byte(getCallerIdentityBody)),
+	}, nil
+}
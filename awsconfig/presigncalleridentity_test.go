@@ -0,0 +1,133 @@
+package awsconfig
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+func TestPresignCallerIdentity(t *testing.T) {
+	stubClock(t)
+	creds := aws.Credentials{AccessKeyID: "ASIAEXAMPLE", SecretAccessKey: "secret", SessionToken: "token"}
+
+	tests := []struct {
+		name      string
+		region    string
+		headers   map[string]string
+		wantURL   string
+		wantScope string
+	}{
+		{name: "NoRegion", wantURL: "https://sts.amazonaws.com/", wantScope: "/us-east-1/sts/aws4_request"},
+		{name: "USEast1", region: "us-east-1", wantURL: "https://sts.amazonaws.com/", wantScope: "/us-east-1/sts/aws4_request"},
+		{name: "Regional", region: "eu-west-1", wantURL: "https://sts.eu-west-1.amazonaws.com/", wantScope: "/eu-west-1/sts/aws4_request"},
+		{name: "China", region: "cn-north-1", wantURL: "https://sts.cn-north-1.amazonaws.com.cn/", wantScope: "/cn-north-1/sts/aws4_request"},
+		{
+			name:      "VaultServerID",
+			headers:   map[string]string{VaultServerIDHeader: "vault.example.com"},
+			wantURL:   "https://sts.amazonaws.com/",
+			wantScope: "/us-east-1/sts/aws4_request",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := aws.Config{Region: tt.region, Credentials: credentials.StaticCredentialsProvider{Value: creds}}
+			signed, err := PresignCallerIdentity(context.Background(), cfg, tt.headers)
+			if err != nil {
+				t.Fatalf("PresignCallerIdentity: %v", err)
+			}
+			if signed.Method != http.MethodPost {
+				t.Errorf("Method = %s, want POST", signed.Method)
+			}
+			if got := decodeBase64(t, signed.URL); got != tt.wantURL {
+				t.Errorf("URL = %s, want %s", got, tt.wantURL)
+			}
+			body := decodeBase64(t, signed.Body)
+			if body != "Action=GetCallerIdentity&Version=2011-06-15" {
+				t.Errorf("Body = %s", body)
+			}
+			var headers http.Header
+			if err := json.Unmarshal([]byte(decodeBase64(t, signed.Headers)), &headers); err != nil {
+				t.Fatalf("Headers: %v", err)
+			}
+
+			auth := headers.Get("Authorization")
+			if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=ASIAEXAMPLE/") || !strings.Contains(auth, tt.wantScope+",") {
+				t.Errorf("Authorization = %q", auth)
+			}
+			if headers.Get("X-Amz-Security-Token") != "token" {
+				t.Errorf("X-Amz-Security-Token = %q, want the session token", headers.Get("X-Amz-Security-Token"))
+			}
+			for key, value := range tt.headers {
+				if headers.Get(key) != value {
+					t.Errorf("%s = %q, want %q", key, headers.Get(key), value)
+				}
+				if !strings.Contains(auth, strings.ToLower(key)) {
+					t.Errorf("%s not in the SignedHeaders of %q", key, auth)
+				}
+			}
+
+			// Signing the same request again yields the same signature
+			req, err := http.NewRequest(http.MethodPost, tt.wantURL, strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for key, values := range headers {
+				if key != "Authorization" && key != "X-Amz-Date" && key != "X-Amz-Security-Token" {
+					req.Header[key] = values
+				}
+			}
+			hash := sha256.Sum256([]byte(body))
+			region := strings.Split(tt.wantScope, "/")[1]
+			if err := v4.NewSigner().SignHTTP(context.Background(), creds, req, hex.EncodeToString(hash[:]), "sts", region, timeNow()); err != nil {
+				t.Fatal(err)
+			}
+			if want := req.Header.Get("Authorization"); auth != want {
+				t.Errorf("Authorization = %q, want %q", auth, want)
+			}
+		})
+	}
+}
+
+func TestPresignCallerIdentityErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider aws.CredentialsProvider
+		wantErr  error
+	}{
+		{name: "NilCredentials", wantErr: ErrNilCredentials},
+		{
+			name: "RetrieveFails",
+			provider: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+				return aws.Credentials{}, errors.New("no credentials")
+			}),
+			wantErr: ErrRetrieveCredentials,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := PresignCallerIdentity(context.Background(), aws.Config{Credentials: tt.provider}, nil); !errors.Is(err, tt.wantErr) {
+				t.Errorf("PresignCallerIdentity error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// decodeBase64 decodes the standard base64 s
+func decodeBase64(t *testing.T, s string) string {
+	t.Helper()
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatalf("not base64: %v", err)
+	}
+	return string(b)
+}
@@ -0,0 +1,146 @@
+package awsconfig
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+const (
+	// ProcessProviderName is the Source of credentials returned by ProcessProvider
+	ProcessProviderName = SourcePrefix + "process"
+
+	// DefaultProcessTimeout bounds how long a credential process may run
+	DefaultProcessTimeout = time.Minute
+)
+
+var (
+	// ErrRunCredentialProcess is returned when a credential process fails to run
+	ErrRunCredentialProcess = errors.New("Cannot run credential process")
+	// ErrParseCredentialProcess is returned when credential process output is invalid
+	ErrParseCredentialProcess = errors.New("Cannot parse credential process output")
+)
+
+// ProcessProvider implements the aws.CredentialsProvider interface by running an
+// external command that prints credential_process JSON on stdout.
+type ProcessProvider struct {
+	command string
+	args    []string
+	timeout time.Duration
+}
+
+// NewProcessProvider initializes a new ProcessProvider instance and returns aws.CredentialsProvider interface.
+// The command runs for at most DefaultProcessTimeout unless WithProcessTimeout is passed.
+func NewProcessProvider(command string, args []string, opts ...Option) aws.CredentialsProvider {
+	return newProcessProvider(command, args, newConfOptions(opts))
+}
+
+// newProcessProvider builds a ProcessProvider from resolved options
+func newProcessProvider(command string, args []string, conf *confOptions) *ProcessProvider {
+	provider := &ProcessProvider{
+		command: command,
+		args:    args,
+		timeout: DefaultProcessTimeout,
+	}
+	if conf.processTimeout != nil {
+		provider.timeout = *conf.processTimeout
+	}
+	return provider
+}
+
+// Retrieve implements the aws.CredentialsProvider interface method
+func (p *ProcessProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.command, p.args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			err = fmt.Errorf("%w: %w", ctx.Err(), err)
+		}
+		return aws.Credentials{}, fmt.Errorf(
+			"%w %q: %w: %s", ErrRunCredentialProcess, p.command, err, strings.TrimSpace(stderr.String()),
+		)
+	}
+
+	var out credentialProcessOutput
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		return aws.Credentials{}, fmt.Errorf("%w %q: %w", ErrParseCredentialProcess, p.command, err)
+	}
+	if out.Version != credentialProcessVersion {
+		return aws.Credentials{}, fmt.Errorf(
+			"%w %q: unsupported Version %d", ErrParseCredentialProcess, p.command, out.Version,
+		)
+	}
+	if out.AccessKeyID == "" || out.SecretAccessKey == "" {
+		return aws.Credentials{}, fmt.Errorf(
+			"%w %q: missing AccessKeyId or SecretAccessKey", ErrParseCredentialProcess, p.command,
+		)
+	}
+
+	creds := aws.Credentials{
+		AccessKeyID:     out.AccessKeyID,
+		SecretAccessKey: out.SecretAccessKey,
+		SessionToken:    out.SessionToken,
+		Source:          ProcessProviderName,
+	}
+	if out.Expiration != "" {
+		expires, err := time.Parse(time.RFC3339, out.Expiration)
+		if err != nil {
+			return aws.Credentials{}, fmt.Errorf("%w %q: %w", ErrParseCredentialProcess, p.command, err)
+		}
+		creds.CanExpire = true
+		creds.Expires = expires
+	}
+	return creds, nil
+}
+
+// NewProcessConf initializes a new ProcessProvider-backed aws.Config, caching
+// the credentials so the command only runs on refresh.
+func NewProcessConf(
+	_ context.Context,
+	cfg aws.Config,
+	command string,
+	args []string,
+	opts ...Option,
+) (aws.Config, error) {
+	conf := newConfOptions(opts)
+
+	provider := newProcessProvider(command, args, conf)
+	cacheOpts := append(
+		[]func(*aws.CredentialsCacheOptions){
+			func(options *aws.CredentialsCacheOptions) {
+				options.ExpiryWindow = 5 * time.Minute
+			},
+		},
+		conf.cacheOpts...,
+	)
+
+	config := cfg.Copy()
+	config.Credentials = aws.NewCredentialsCache(withStats(provider), cacheOpts...)
+	return config, nil
+}
+
+// String implements the fmt.Stringer interface method, printing only the
+// command and how many arguments it has, as arguments may hold secrets
+func (p *ProcessProvider) String() string {
+	return fmt.Sprintf("ProcessProvider{Source: %q, Command: %q, Args: %d}", ProcessProviderName, p.command, len(p.args))
+}
+
+// GoString implements the fmt.GoStringer interface method, so %#v prints no more than String
+func (p *ProcessProvider) GoString() string {
+	return "&awsconfig." + p.String()
+}
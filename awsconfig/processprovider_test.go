@@ -0,0 +1,118 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// envProcessHelper makes the test binary act as a credential process
+const envProcessHelper = "AWSCONFIG_TEST_CREDENTIAL_PROCESS"
+
+// TestProcessHelper is the credential process run by the ProcessProvider
+// tests, behaving as named by its last argument
+func TestProcessHelper(t *testing.T) {
+	if os.Getenv(envProcessHelper) != "1" {
+		t.Skip("only run as a credential process")
+	}
+	switch os.Args[len(os.Args)-1] {
+	case "expiring":
+		fmt.Println(`{"Version": 1, "AccessKeyId": "ASIAFAKE", "SecretAccessKey": "secret", "SessionToken": "token", "Expiration": "2030-01-02T03:04:05Z"}`)
+	case "static":
+		fmt.Println(`{"Version": 1, "AccessKeyId": "AKIAFAKE", "SecretAccessKey": "secret"}`)
+	case "version2":
+		fmt.Println(`{"Version": 2, "AccessKeyId": "AKIAFAKE", "SecretAccessKey": "secret"}`)
+	case "missingkeys":
+		fmt.Println(`{"Version": 1}`)
+	case "garbage":
+		fmt.Println("not json")
+	case "fail":
+		fmt.Fprintln(os.Stderr, "token expired, log in again")
+		os.Exit(1)
+	case "hang":
+		time.Sleep(time.Minute)
+	}
+	os.Exit(0)
+}
+
+// processHelperArgs returns the arguments running the test binary as a credential process in mode
+func processHelperArgs(t *testing.T, mode string) []string {
+	t.Helper()
+	t.Setenv(envProcessHelper, "1")
+	return []string{"-test.run=^TestProcessHelper$", "--", mode}
+}
+
+func TestProcessProvider(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      string
+		opts      []Option
+		want      aws.Credentials
+		wantErr   error
+		wantInErr string
+	}{
+		{
+			name: "Expiring",
+			mode: "expiring",
+			want: aws.Credentials{
+				AccessKeyID: "ASIAFAKE", SecretAccessKey: "secret", SessionToken: "token", Source: ProcessProviderName,
+				CanExpire: true, Expires: time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC),
+			},
+		},
+		{
+			name: "NonExpiring",
+			mode: "static",
+			want: aws.Credentials{AccessKeyID: "AKIAFAKE", SecretAccessKey: "secret", Source: ProcessProviderName},
+		},
+		{name: "UnsupportedVersion", mode: "version2", wantErr: ErrParseCredentialProcess, wantInErr: "Version 2"},
+		{name: "MissingKeys", mode: "missingkeys", wantErr: ErrParseCredentialProcess},
+		{name: "InvalidJSON", mode: "garbage", wantErr: ErrParseCredentialProcess},
+		{name: "Failure", mode: "fail", wantErr: ErrRunCredentialProcess, wantInErr: "token expired, log in again"},
+		{
+			name:    "Timeout",
+			mode:    "hang",
+			opts:    []Option{WithProcessTimeout(100 * time.Millisecond)},
+			wantErr: context.DeadlineExceeded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := NewProcessProvider(os.Args[0], processHelperArgs(t, tt.mode), tt.opts...)
+			creds, err := provider.Retrieve(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Retrieve error %v, want %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), tt.wantInErr) {
+				t.Errorf("Retrieve error %q does not contain %q", err, tt.wantInErr)
+			}
+			if !creds.Expires.Equal(tt.want.Expires) {
+				t.Errorf("Retrieve returned expiry %v, want %v", creds.Expires, tt.want.Expires)
+			}
+			creds.Expires, tt.want.Expires = time.Time{}, time.Time{}
+			if creds != tt.want {
+				t.Errorf("Retrieve returned %+v, want %+v", creds, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewProcessConf(t *testing.T) {
+	cfg, err := NewProcessConf(context.Background(), aws.Config{}, os.Args[0], processHelperArgs(t, "expiring"))
+	if err != nil {
+		t.Fatalf("NewProcessConf: %v", err)
+	}
+	for range 3 {
+		if _, err := cfg.Credentials.Retrieve(context.Background()); err != nil {
+			t.Fatalf("Retrieve: %v", err)
+		}
+	}
+	if stats, _ := ProviderStats(cfg); stats.RefreshCount != 1 {
+		t.Errorf("the command ran %d times for 3 Retrieves, want 1", stats.RefreshCount)
+	}
+}
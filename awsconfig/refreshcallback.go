@@ -0,0 +1,102 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+var (
+	// ErrRefreshCallbackPanic is passed to the warning handler when a refresh callback panics
+	ErrRefreshCallbackPanic = errors.New("Refresh callback panicked")
+)
+
+// RefreshEvent describes one credential refresh
+type RefreshEvent struct {
+	// Provider is the kind of provider refreshed, e.g. "AssumeRole" or "CustomFunction"
+	Provider string
+	// RoleArn is the role assumed, empty for custom functions
+	RoleArn string
+	// OldExpires is the expiry of the credentials being replaced, zero on the first refresh
+	OldExpires time.Time
+	// NewExpires is the expiry of the new credentials, zero on failure or if they don't expire
+	NewExpires time.Time
+	// Duration is how long the refresh took
+	Duration time.Duration
+	// Err is the error the refresh failed with, if any
+	Err error
+}
+
+// WithRefreshCallback calls fn after every credential refresh. fn runs on its
+// own goroutine so it can never block Retrieve; events may therefore arrive
+// out of order, and a panic in fn is recovered and passed to the warning handler.
+func WithRefreshCallback(fn func(RefreshEvent)) ConfOption {
+	return func(c *confOptions) {
+		c.refreshCallback = fn
+	}
+}
+
+// withRefreshCallback wraps provider to report its refreshes, if WithRefreshCallback is set
+func withRefreshCallback(conf *confOptions, name, roleArn string, provider aws.CredentialsProvider) aws.CredentialsProvider {
+	if conf.refreshCallback == nil {
+		return provider
+	}
+	return &callbackProvider{
+		callback: conf.refreshCallback,
+		warn:     conf.warn,
+		name:     name,
+		roleArn:  roleArn,
+		provider: provider,
+	}
+}
+
+// callbackProvider reports the refreshes of its provider to a callback
+type callbackProvider struct {
+	callback func(RefreshEvent)
+	warn     func(error)
+	name     string
+	roleArn  string
+	provider aws.CredentialsProvider
+
+	mu          sync.Mutex
+	lastExpires time.Time
+}
+
+// Retrieve implements the aws.CredentialsProvider interface method
+func (p *callbackProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	start := timeNow()
+	creds, err := p.provider.Retrieve(ctx)
+	event := RefreshEvent{
+		Provider: p.name,
+		RoleArn:  p.roleArn,
+		Duration: timeNow().Sub(start),
+		Err:      err,
+	}
+	if err == nil && creds.CanExpire {
+		event.NewExpires = creds.Expires
+	}
+
+	p.mu.Lock()
+	event.OldExpires = p.lastExpires
+	if err == nil {
+		p.lastExpires = event.NewExpires
+	}
+	p.mu.Unlock()
+
+	go p.notify(event)
+	return creds, err
+}
+
+// notify calls the callback, recovering any panic
+func (p *callbackProvider) notify(event RefreshEvent) {
+	defer func() {
+		if r := recover(); r != nil && p.warn != nil {
+			p.warn(fmt.Errorf("%w: %v", ErrRefreshCallbackPanic, r))
+		}
+	}()
+	p.callback(event)
+}
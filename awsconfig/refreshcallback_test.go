@@ -0,0 +1,127 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/smithy-go"
+
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigtest"
+)
+
+// nextEvent waits for the next event sent to events
+func nextEvent(t *testing.T, events <-chan RefreshEvent) RefreshEvent {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no refresh event")
+		return RefreshEvent{}
+	}
+}
+
+func TestWithRefreshCallback(t *testing.T) {
+	denied := &smithy.GenericAPIError{Code: "AccessDenied", Message: "denied"}
+	events := make(chan RefreshEvent, 10)
+	fake := &awsconfigtest.FakeSTS{}
+	cfg, err := NewAssumeRoleConf(context.Background(), aws.Config{}, testRoleArn,
+		WithSTSClient(fake), WithSkipIdentityCheck(), WithRefreshCallback(func(event RefreshEvent) { events <- event }))
+	if err != nil {
+		t.Fatalf("NewAssumeRoleConf: %v", err)
+	}
+
+	steps := []struct {
+		name      string
+		assumeErr error
+	}{
+		{name: "FirstRefresh"},
+		{name: "SecondRefresh"},
+		{name: "FailedRefresh", assumeErr: denied},
+	}
+	var lastExpires time.Time
+	for _, step := range steps {
+		fake.AssumeRoleErr = step.assumeErr
+		if err := InvalidateCredentials(cfg); err != nil {
+			t.Fatalf("%s: InvalidateCredentials: %v", step.name, err)
+		}
+		creds, err := cfg.Credentials.Retrieve(context.Background())
+		if !errors.Is(err, step.assumeErr) {
+			t.Fatalf("%s: Retrieve error %v, want %v", step.name, err, step.assumeErr)
+		}
+
+		event := nextEvent(t, events)
+		if event.Provider != "AssumeRole" || event.RoleArn != testRoleArn {
+			t.Errorf("%s: event for %s %s, want AssumeRole %s", step.name, event.Provider, event.RoleArn, testRoleArn)
+		}
+		if !errors.Is(event.Err, step.assumeErr) {
+			t.Errorf("%s: event error %v, want %v", step.name, event.Err, step.assumeErr)
+		}
+		if !event.OldExpires.Equal(lastExpires) {
+			t.Errorf("%s: event OldExpires %v, want %v", step.name, event.OldExpires, lastExpires)
+		}
+		wantNew := creds.Expires
+		if step.assumeErr != nil {
+			wantNew = time.Time{}
+		}
+		if !event.NewExpires.Equal(wantNew) {
+			t.Errorf("%s: event NewExpires %v, want %v", step.name, event.NewExpires, wantNew)
+		}
+		if event.Duration < 0 {
+			t.Errorf("%s: event Duration %v", step.name, event.Duration)
+		}
+		if step.assumeErr == nil {
+			lastExpires = creds.Expires
+		}
+	}
+}
+
+func TestWithRefreshCallbackPanic(t *testing.T) {
+	warnings := make(chan error, 1)
+	retrieve, _ := countingRetrieve(time.Hour)
+	cfg, err := NewCustomFunctionConf(context.Background(), aws.Config{}, retrieve,
+		WithRefreshCallback(func(RefreshEvent) { panic("alerting is down") }),
+		WithWarningHandler(func(err error) { warnings <- err }))
+	if err != nil {
+		t.Fatalf("NewCustomFunctionConf: %v", err)
+	}
+	if _, err := cfg.Credentials.Retrieve(context.Background()); err != nil {
+		t.Fatalf("Retrieve: %v", err)
+	}
+	select {
+	case err := <-warnings:
+		if !errors.Is(err, ErrRefreshCallbackPanic) {
+			t.Errorf("warning %v, want %v", err, ErrRefreshCallbackPanic)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("callback panic not reported")
+	}
+}
+
+func TestWithRefreshCallbackDoesNotBlock(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	retrieve, _ := countingRetrieve(time.Hour)
+	cfg, err := NewCustomFunctionConf(context.Background(), aws.Config{}, retrieve,
+		WithRefreshCallback(func(RefreshEvent) { <-release }))
+	if err != nil {
+		t.Fatalf("NewCustomFunctionConf: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := cfg.Credentials.Retrieve(context.Background())
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Retrieve: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Retrieve blocked on the refresh callback")
+	}
+}
@@ -0,0 +1,123 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+var (
+	// ErrUnknownConfig is returned by Registry.Get for a name that was never registered
+	ErrUnknownConfig = errors.New("No config registered under passed name")
+)
+
+// Registry lazily builds and memoizes named aws.Configs, such as one per logical
+// assume-role target. It is safe for concurrent use; the zero value is ready to use.
+type Registry struct {
+	mu      sync.Mutex
+	entries map[string]*registryEntry
+}
+
+// registryEntry is the builder registered under a name and its memoized result
+type registryEntry struct {
+	builder  func(ctx context.Context) (aws.Config, error)
+	cfg      *aws.Config
+	inflight *buildCall
+}
+
+// buildCall is a build shared by concurrent callers of Registry.Get
+type buildCall struct {
+	done chan struct{}
+	cfg  aws.Config
+	err  error
+}
+
+// Register sets the builder of name, replacing any builder and config registered earlier
+func (r *Registry) Register(name string, builder func(ctx context.Context) (aws.Config, error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.entries == nil {
+		r.entries = make(map[string]*registryEntry)
+	}
+	r.entries[name] = &registryEntry{builder: builder}
+}
+
+// Get returns the config of name, building it on first use. Concurrent callers
+// share a single build; a caller whose ctx is done stops waiting without
+// cancelling it. Failed builds are not memoized, so the next Get retries.
+func (r *Registry) Get(ctx context.Context, name string) (aws.Config, error) {
+	r.mu.Lock()
+	entry, ok := r.entries[name]
+	if !ok {
+		r.mu.Unlock()
+		return aws.Config{}, fmt.Errorf("%w: %s", ErrUnknownConfig, name)
+	}
+	if entry.cfg != nil {
+		cfg := *entry.cfg
+		r.mu.Unlock()
+		return cfg, nil
+	}
+	call := entry.inflight
+	if call == nil {
+		call = &buildCall{done: make(chan struct{})}
+		entry.inflight = call
+		go r.build(context.WithoutCancel(ctx), entry, call)
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.cfg, call.err
+	case <-ctx.Done():
+		return aws.Config{}, ctx.Err()
+	}
+}
+
+// build runs the builder of entry for call, memoizing the result unless it
+// failed or the entry was invalidated or replaced meanwhile
+func (r *Registry) build(ctx context.Context, entry *registryEntry, call *buildCall) {
+	call.cfg, call.err = entry.builder(ctx)
+
+	r.mu.Lock()
+	if entry.inflight == call {
+		entry.inflight = nil
+		if call.err == nil {
+			cfg := call.cfg
+			entry.cfg = &cfg
+		}
+	}
+	r.mu.Unlock()
+	close(call.done)
+}
+
+// Invalidate drops the memoized config of name, so the next Get rebuilds it.
+// A build already running is not memoized when it completes.
+func (r *Registry) Invalidate(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if entry, ok := r.entries[name]; ok {
+		entry.cfg = nil
+		entry.inflight = nil
+	}
+}
+
+// RangeNames calls fn for each registered name in sorted order until fn returns false
+func (r *Registry) RangeNames(fn func(name string) bool) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.entries))
+	for name := range r.entries {
+		names = append(names, name)
+	}
+	r.mu.Unlock()
+
+	sort.Strings(names)
+	for _, name := range names {
+		if !fn(name) {
+			return
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/smithy-go"
+
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigtest"
+)
+
+// assumeRoleBuilder returns a Registry builder assuming testRoleArn through fake,
+// and the number of builds run; each build waits for gate to be closed, if set
+func assumeRoleBuilder(fake *awsconfigtest.FakeSTS, gate <-chan struct{}) (func(context.Context) (aws.Config, error), *atomic.Int32) {
+	var builds atomic.Int32
+	return func(ctx context.Context) (aws.Config, error) {
+		builds.Add(1)
+		if gate != nil {
+			<-gate
+		}
+		return NewAssumeRoleConf(ctx, aws.Config{}, testRoleArn, WithSTSClient(fake))
+	}, &builds
+}
+
+func TestRegistryConcurrentGet(t *testing.T) {
+	fake := &awsconfigtest.FakeSTS{}
+	gate := make(chan struct{})
+	builder, builds := assumeRoleBuilder(fake, gate)
+	var r Registry
+	r.Register("billing", builder)
+
+	var wg sync.WaitGroup
+	configs := make([]aws.Config, 50)
+	for i := range configs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cfg, err := r.Get(context.Background(), "billing")
+			if err != nil {
+				t.Errorf("Get: %v", err)
+			}
+			configs[i] = cfg
+		}()
+	}
+	for builds.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(gate)
+	wg.Wait()
+
+	if got := builds.Load(); got != 1 {
+		t.Errorf("builder ran %d times for 50 concurrent Gets, want 1", got)
+	}
+	if got := fake.CallerIdentityCalls(); got != 1 {
+		t.Errorf("GetCallerIdentity called %d times, want 1", got)
+	}
+	for i, cfg := range configs {
+		if cfg.Credentials != configs[0].Credentials {
+			t.Fatalf("Get %d returned a different config", i)
+		}
+	}
+}
+
+func TestRegistryInvalidate(t *testing.T) {
+	steps := []struct {
+		name       string
+		invalidate bool
+		wantBuilds int32
+	}{
+		{name: "Built", wantBuilds: 1},
+		{name: "Memoized", wantBuilds: 1},
+		{name: "Invalidated", invalidate: true, wantBuilds: 2},
+		{name: "MemoizedAgain", wantBuilds: 2},
+	}
+	builder, builds := assumeRoleBuilder(&awsconfigtest.FakeSTS{}, nil)
+	var r Registry
+	r.Register("audit", builder)
+	var prev aws.Config
+	for _, step := range steps {
+		if step.invalidate {
+			r.Invalidate("audit")
+		}
+		cfg, err := r.Get(context.Background(), "audit")
+		if err != nil {
+			t.Fatalf("%s: Get: %v", step.name, err)
+		}
+		if got := builds.Load(); got != step.wantBuilds {
+			t.Errorf("%s: builder ran %d times, want %d", step.name, got, step.wantBuilds)
+		}
+		if rebuilt := cfg.Credentials != prev.Credentials; rebuilt != (step.invalidate || prev.Credentials == nil) {
+			t.Errorf("%s: config rebuilt %t", step.name, rebuilt)
+		}
+		prev = cfg
+	}
+}
+
+func TestRegistryInvalidateDuringBuild(t *testing.T) {
+	gate := make(chan struct{})
+	builder, builds := assumeRoleBuilder(&awsconfigtest.FakeSTS{}, gate)
+	var r Registry
+	r.Register("tenant-1", builder)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := r.Get(context.Background(), "tenant-1")
+		done <- err
+	}()
+	for builds.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	r.Invalidate("tenant-1")
+	close(gate)
+	if err := <-done; err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	// The build that was running when invalidated is not memoized
+	if _, err := r.Get(context.Background(), "tenant-1"); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got := builds.Load(); got != 2 {
+		t.Errorf("builder ran %d times, want 2", got)
+	}
+}
+
+func TestRegistryErrors(t *testing.T) {
+	denied := &smithy.GenericAPIError{Code: "AccessDenied", Message: "denied"}
+	fake := &awsconfigtest.FakeSTS{CallerIdentityErr: denied}
+	builder, builds := assumeRoleBuilder(fake, nil)
+	var r Registry
+	r.Register("billing", builder)
+
+	if _, err := r.Get(context.Background(), "billing"); !errors.Is(err, denied) {
+		t.Fatalf("Get error %v, want %v", err, denied)
+	}
+	// Failed builds are retried rather than memoized
+	fake.CallerIdentityErr = nil
+	if _, err := r.Get(context.Background(), "billing"); err != nil {
+		t.Fatalf("Get after the failure: %v", err)
+	}
+	if got := builds.Load(); got != 2 {
+		t.Errorf("builder ran %d times, want 2", got)
+	}
+
+	if _, err := r.Get(context.Background(), "audit"); !errors.Is(err, ErrUnknownConfig) {
+		t.Errorf("Get of an unregistered name: %v, want %v", err, ErrUnknownConfig)
+	}
+}
+
+func TestRegistryGetCancelled(t *testing.T) {
+	gate := make(chan struct{})
+	builder, builds := assumeRoleBuilder(&awsconfigtest.FakeSTS{}, gate)
+	var r Registry
+	r.Register("billing", builder)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := r.Get(ctx, "billing")
+		done <- err
+	}()
+	for builds.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Get error %v, want %v", err, context.Canceled)
+	}
+
+	// The build carries on and is memoized for the next caller
+	close(gate)
+	if _, err := r.Get(context.Background(), "billing"); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got := builds.Load(); got != 1 {
+		t.Errorf("builder ran %d times, want 1", got)
+	}
+}
+
+func TestRegistryRangeNames(t *testing.T) {
+	builder, _ := assumeRoleBuilder(&awsconfigtest.FakeSTS{}, nil)
+	var r Registry
+	for _, name := range []string{"tenant-2", "audit", "billing", "tenant-1"} {
+		r.Register(name, builder)
+	}
+	tests := []struct {
+		name  string
+		limit int
+		want  string
+	}{
+		{name: "All", limit: 10, want: "audit billing tenant-1 tenant-2"},
+		{name: "StopEarly", limit: 2, want: "audit billing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			r.RangeNames(func(name string) bool {
+				got = append(got, name)
+				return len(got) < tt.limit
+			})
+			if strings.Join(got, " ") != tt.want {
+				t.Errorf("RangeNames %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package awsconfig
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+var (
+	// ErrNotARoleArn is returned when a well-formed ARN does not name an IAM Role
+	ErrNotARoleArn = errors.New("Passed ARN is not an IAM Role ARN")
+	// ErrPartitionMismatch is returned when a role ARN is in a different partition
+	// than the region its STS calls are sent to
+	ErrPartitionMismatch = errors.New("IAM Role ARN partition does not match STS region partition")
+	// ErrInvalidRoleName is returned when a role name or path has characters IAM disallows
+	ErrInvalidRoleName = errors.New("Invalid IAM Role name or path")
+)
+
+// roleNameRegexp matches the role names IAM allows
+var roleNameRegexp = regexp.MustCompile(`^[\w+=,.@-]{1,64}$`)
+
+// knownPartitions are the AWS partitions IAM Roles can live in
+var knownPartitions = map[string]bool{
+	"aws":        true,
+	"aws-cn":     true,
+	"aws-us-gov": true,
+	"aws-iso":    true,
+	"aws-iso-b":  true,
+	"aws-iso-e":  true,
+	"aws-iso-f":  true,
+	"aws-eusc":   true,
+}
+
+// regionPartitionPrefixes maps region name prefixes to their non-commercial partition
+var regionPartitionPrefixes = []struct {
+	prefix    string
+	partition string
+}{
+	{"cn-", "aws-cn"},
+	{"us-gov-", "aws-us-gov"},
+	{"us-iso-", "aws-iso"},
+	{"us-isob-", "aws-iso-b"},
+	{"eu-isoe-", "aws-iso-e"},
+	{"us-isof-", "aws-iso-f"},
+	{"eusc-", "aws-eusc"},
+}
+
+// regionPartition returns the partition region belongs to, "aws" unless it is
+// a known region prefix of another partition
+func regionPartition(region string) string {
+	for _, p := range regionPartitionPrefixes {
+		if strings.HasPrefix(region, p.prefix) {
+			return p.partition
+		}
+	}
+	return "aws"
+}
+
+// checkPartition verifies the role ARN is in the partition of the region the
+// package-internal STS client targets, and of each WithSTSFallbackRegions
+// region; an unset region is not checked
+func checkPartition(cfg aws.Config, conf *confOptions, parsed arn.ARN) error {
+	if conf.skipPartitionCheck {
+		return nil
+	}
+	if region := stsRegion(cfg, conf); region != "" {
+		if partition := regionPartition(region); partition != parsed.Partition {
+			return fmt.Errorf(
+				"%w: %s is in %q, region %q is in %q",
+				ErrPartitionMismatch, parsed, parsed.Partition, region, partition,
+			)
+		}
+	}
+	for _, region := range conf.stsFallbackRegions {
+		if partition := regionPartition(region); partition != parsed.Partition {
+			return fmt.Errorf(
+				"%w: %s is in %q, fallback region %q is in %q",
+				ErrPartitionMismatch, parsed, parsed.Partition, region, partition,
+			)
+		}
+	}
+	return nil
+}
+
+// stsRegion returns the region the package-internal STS client targets
+func stsRegion(cfg aws.Config, conf *confOptions) string {
+	o := sts.Options{Region: cfg.Region}
+	for _, fn := range conf.stsOpts {
+		fn(&o)
+	}
+	return o.Region
+}
+
+// WithoutPartitionCheck skips verifying that role ARNs are in the partition of the
+// STS region, for setups where region names don't follow the usual conventions
+func WithoutPartitionCheck() ConfOption {
+	return func(c *confOptions) {
+		c.skipPartitionCheck = true
+	}
+}
+
+// BuildRoleArn assembles a canonical role ARN from its parts. An empty partition
+// defaults to "aws" and path may be given with or without surrounding slashes.
+func BuildRoleArn(partition, accountID, path, roleName string) (string, error) {
+	if partition == "" {
+		partition = "aws"
+	}
+	if !roleNameRegexp.MatchString(roleName) {
+		return "", fmt.Errorf("%w: role name %q", ErrInvalidRoleName, roleName)
+	}
+
+	// Normalize path to "/" or "/segment[/segment...]/"
+	path = strings.Trim(path, "/")
+	if path != "" {
+		for _, c := range path {
+			if c < '!' || c > '~' {
+				return "", fmt.Errorf("%w: path %q", ErrInvalidRoleName, path)
+			}
+		}
+		path = "/" + path + "/"
+	} else {
+		path = "/"
+	}
+
+	roleArn := arn.ARN{
+		Partition: partition,
+		Service:   "iam",
+		AccountID: accountID,
+		Resource:  "role" + path + roleName,
+	}.String()
+	if _, err := parseRoleArn(roleArn); err != nil {
+		return "", err
+	}
+	return roleArn, nil
+}
+
+// parseRoleArn parses roleArn and verifies it names an IAM Role, paths allowed
+func parseRoleArn(roleArn string) (arn.ARN, error) {
+	parsed, err := arn.Parse(roleArn)
+	if err != nil {
+		return arn.ARN{}, fmt.Errorf("%w: %w", ErrInvalidRoleArn, err)
+	}
+
+	var reason string
+	switch {
+	case !knownPartitions[parsed.Partition]:
+		reason = fmt.Sprintf("unknown partition %q", parsed.Partition)
+	case parsed.Service != "iam":
+		reason = fmt.Sprintf("service %q, not \"iam\"", parsed.Service)
+	case parsed.Region != "":
+		reason = fmt.Sprintf("region %q, IAM ARNs have none", parsed.Region)
+	case !isAccountID(parsed.AccountID):
+		reason = fmt.Sprintf("account ID %q, not 12 digits", parsed.AccountID)
+	case !strings.HasPrefix(parsed.Resource, "role/") || strings.HasSuffix(parsed.Resource, "/"):
+		reason = fmt.Sprintf("resource %q, not role/[path/]name", parsed.Resource)
+	default:
+		return parsed, nil
+	}
+	return arn.ARN{}, fmt.Errorf("%w: %q has %s", ErrNotARoleArn, roleArn, reason)
+}
+
+// isAccountID reports whether id is a 12-digit AWS account ID
+func isAccountID(id string) bool {
+	if len(id) != 12 {
+		return false
+	}
+	for _, c := range id {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
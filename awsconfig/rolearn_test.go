@@ -0,0 +1,124 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigtest"
+)
+
+func TestNewAssumeRoleConfRoleArn(t *testing.T) {
+	tests := []struct {
+		name    string
+		roleArn string
+		wantErr error
+	}{
+		{"Role", testRoleArn, nil},
+		{"ServiceRolePath", "arn:aws:iam::123456789012:role/service-role/Foo", nil},
+		{"NestedPath", "arn:aws:iam::123456789012:role/org/team/Foo", nil},
+		{"GovCloud", "arn:aws-us-gov:iam::123456789012:role/Foo", nil},
+		{"NotAnArn", "Foo", ErrInvalidRoleArn},
+		{"User", "arn:aws:iam::123456789012:user/Bob", ErrNotARoleArn},
+		{"AssumedRole", "arn:aws:sts::123456789012:assumed-role/Foo/session", ErrNotARoleArn},
+		{"Bucket", "arn:aws:s3:::my-bucket", ErrNotARoleArn},
+		{"UnknownPartition", "arn:aws-moon:iam::123456789012:role/Foo", ErrNotARoleArn},
+		{"Region", "arn:aws:iam:us-east-1:123456789012:role/Foo", ErrNotARoleArn},
+		{"ShortAccount", "arn:aws:iam::12345:role/Foo", ErrNotARoleArn},
+		{"NoRoleName", "arn:aws:iam::123456789012:role/", ErrNotARoleArn},
+		{"PathOnly", "arn:aws:iam::123456789012:role/org/", ErrNotARoleArn},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &awsconfigtest.FakeSTS{}
+			_, err := NewAssumeRoleConf(context.Background(), aws.Config{}, tt.roleArn,
+				WithSTSClient(fake), WithSkipIdentityCheck())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewAssumeRoleConf error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewAssumeRoleConfPartition(t *testing.T) {
+	tests := []struct {
+		name    string
+		roleArn string
+		region  string
+		opts    []Option
+		wantErr error
+	}{
+		{name: "Matching", roleArn: testRoleArn, region: "us-east-1"},
+		{name: "MatchingChina", roleArn: "arn:aws-cn:iam::123456789012:role/Foo", region: "cn-north-1"},
+		{name: "MatchingGovCloud", roleArn: "arn:aws-us-gov:iam::123456789012:role/Foo", region: "us-gov-west-1"},
+		{name: "NoRegion", roleArn: "arn:aws-cn:iam::123456789012:role/Foo"},
+		{name: "AwsToChina", roleArn: "arn:aws-cn:iam::123456789012:role/Foo", region: "us-east-1", wantErr: ErrPartitionMismatch},
+		{name: "GovCloudToAws", roleArn: testRoleArn, region: "us-gov-east-1", wantErr: ErrPartitionMismatch},
+		{name: "STSRegionChecked", roleArn: testRoleArn, region: "us-east-1",
+			opts: []Option{WithSTSRegion("cn-northwest-1")}, wantErr: ErrPartitionMismatch},
+		{name: "Suppressed", roleArn: "arn:aws-cn:iam::123456789012:role/Foo", region: "us-east-1",
+			opts: []Option{WithoutPartitionCheck()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]Option{WithSTSClient(&awsconfigtest.FakeSTS{})}, tt.opts...)
+			_, err := NewAssumeRoleConf(context.Background(), aws.Config{Region: tt.region}, tt.roleArn, opts...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewAssumeRoleConf error %v, want %v", err, tt.wantErr)
+			}
+			if err != nil && strings.Count(err.Error(), " is in ") != 2 {
+				t.Errorf("NewAssumeRoleConf error %q does not name both partitions", err)
+			}
+		})
+	}
+}
+
+func TestBuildRoleArn(t *testing.T) {
+	tests := []struct {
+		name      string
+		partition string
+		accountID string
+		path      string
+		roleName  string
+		want      string
+		wantErr   error
+	}{
+		{name: "NoPath", accountID: "123456789012", roleName: "Deploy", want: "arn:aws:iam::123456789012:role/Deploy"},
+		{name: "SlashPath", accountID: "123456789012", path: "/", roleName: "Deploy", want: "arn:aws:iam::123456789012:role/Deploy"},
+		{name: "ServiceRole", accountID: "123456789012", path: "/service-role/", roleName: "Deploy",
+			want: "arn:aws:iam::123456789012:role/service-role/Deploy"},
+		{name: "BarePath", accountID: "123456789012", path: "org/team", roleName: "Deploy",
+			want: "arn:aws:iam::123456789012:role/org/team/Deploy"},
+		{name: "GovCloud", partition: "aws-us-gov", accountID: "123456789012", roleName: "Deploy",
+			want: "arn:aws-us-gov:iam::123456789012:role/Deploy"},
+		{name: "China", partition: "aws-cn", accountID: "123456789012", path: "/service-role", roleName: "Deploy",
+			want: "arn:aws-cn:iam::123456789012:role/service-role/Deploy"},
+		{name: "AllowedCharacters", accountID: "123456789012", roleName: "a+b=c,d.e@f-g_h", want: "arn:aws:iam::123456789012:role/a+b=c,d.e@f-g_h"},
+		{name: "ShortAccount", accountID: "12345", roleName: "Deploy", wantErr: ErrNotARoleArn},
+		{name: "UnknownPartition", partition: "aws-moon", accountID: "123456789012", roleName: "Deploy", wantErr: ErrNotARoleArn},
+		{name: "EmptyName", accountID: "123456789012", wantErr: ErrInvalidRoleName},
+		{name: "NameWithSlash", accountID: "123456789012", roleName: "team/Deploy", wantErr: ErrInvalidRoleName},
+		{name: "NameWithSpace", accountID: "123456789012", roleName: "De ploy", wantErr: ErrInvalidRoleName},
+		{name: "NameTooLong", accountID: "123456789012", roleName: strings.Repeat("r", 65), wantErr: ErrInvalidRoleName},
+		{name: "PathWithSpace", accountID: "123456789012", path: "/my team/", roleName: "Deploy", wantErr: ErrInvalidRoleName},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := BuildRoleArn(tt.partition, tt.accountID, tt.path, tt.roleName)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("BuildRoleArn error %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("BuildRoleArn = %q, want %q", got, tt.want)
+			}
+			if err == nil {
+				if _, err := parseRoleArn(got); err != nil {
+					t.Errorf("BuildRoleArn result rejected by role ARN validation: %v", err)
+				}
+			}
+		})
+	}
+}
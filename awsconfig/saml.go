@@ -0,0 +1,192 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+// SAMLProviderName prefixes the Source of credentials returned by
+// SAMLRoleProvider, followed by the role ARN
+const SAMLProviderName = SourcePrefix + "saml"
+
+var (
+	// ErrInvalidSAMLPrincipalArn is returned when a SAML provider ARN cannot be parsed
+	ErrInvalidSAMLPrincipalArn = errors.New("Cannot parse passed SAML provider ARN")
+	// ErrSAMLAssertion is returned when the assertion provider fails
+	ErrSAMLAssertion = errors.New("Cannot obtain SAML assertion")
+	// ErrSAMLAssumeRole is returned when STS rejects AssumeRoleWithSAML
+	ErrSAMLAssumeRole = errors.New("Cannot assume IAM Role with SAML assertion")
+)
+
+// AssumeRoleWithSAMLAPIClient is a client capable of the STS AssumeRoleWithSAML operation.
+type AssumeRoleWithSAMLAPIClient interface {
+	AssumeRoleWithSAML(ctx context.Context, params *sts.AssumeRoleWithSAMLInput, optFns ...func(*sts.Options)) (*sts.AssumeRoleWithSAMLOutput, error)
+}
+
+// SAMLRoleOptions is the configurable options for SAMLRoleProvider
+type SAMLRoleOptions struct {
+	// Client implementation of the AssumeRoleWithSAML operation
+	Client AssumeRoleWithSAMLAPIClient
+
+	// IAM Role ARN to be assumed
+	RoleARN string
+
+	// ARN of the SAML provider in IAM that describes the IdP
+	PrincipalARN string
+
+	// Expiry duration of the STS credentials; STS assigns a default if unset
+	Duration time.Duration
+
+	// Optional inline session policy
+	Policy *string
+
+	// Optional managed session policy ARNs
+	PolicyARNs []types.PolicyDescriptorType
+}
+
+// SAMLRoleProvider implements the aws.CredentialsProvider interface
+type SAMLRoleProvider struct {
+	options           SAMLRoleOptions
+	assertionProvider func(ctx context.Context) (string, error)
+}
+
+// NewSAMLRoleProvider initializes a new SAMLRoleProvider which fetches a fresh
+// assertion from assertionProvider on every Retrieve.
+func NewSAMLRoleProvider(
+	client AssumeRoleWithSAMLAPIClient,
+	principalArn string,
+	roleArn string,
+	assertionProvider func(ctx context.Context) (string, error),
+	opts ...func(*SAMLRoleOptions),
+) *SAMLRoleProvider {
+	o := SAMLRoleOptions{
+		Client:       client,
+		RoleARN:      roleArn,
+		PrincipalARN: principalArn,
+	}
+	for _, fn := range opts {
+		fn(&o)
+	}
+	return &SAMLRoleProvider{
+		options:           o,
+		assertionProvider: assertionProvider,
+	}
+}
+
+// Retrieve implements the aws.CredentialsProvider interface method
+func (p *SAMLRoleProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	// Assertions are short-lived, so always fetch a new one
+	assertion, err := p.assertionProvider(ctx)
+	if err != nil {
+		return aws.Credentials{}, fmt.Errorf("%w: %w", ErrSAMLAssertion, err)
+	}
+
+	input := &sts.AssumeRoleWithSAMLInput{
+		PrincipalArn:  aws.String(p.options.PrincipalARN),
+		RoleArn:       aws.String(p.options.RoleARN),
+		SAMLAssertion: aws.String(assertion),
+		Policy:        p.options.Policy,
+		PolicyArns:    p.options.PolicyARNs,
+	}
+	if p.options.Duration != 0 {
+		input.DurationSeconds = aws.Int32(int32(p.options.Duration / time.Second))
+	}
+
+	resp, err := p.options.Client.AssumeRoleWithSAML(ctx, input)
+	if err != nil {
+		return aws.Credentials{}, fmt.Errorf("%w: %w", ErrSAMLAssumeRole, err)
+	}
+	if resp == nil || resp.Credentials == nil {
+		return aws.Credentials{}, fmt.Errorf("%w: response has no credentials", ErrSAMLAssumeRole)
+	}
+
+	var accountID string
+	if resp.AssumedRoleUser != nil {
+		if parsed, err := arn.Parse(aws.ToString(resp.AssumedRoleUser.Arn)); err == nil {
+			accountID = parsed.AccountID
+		}
+	}
+
+	return aws.Credentials{
+		AccessKeyID:     aws.ToString(resp.Credentials.AccessKeyId),
+		SecretAccessKey: aws.ToString(resp.Credentials.SecretAccessKey),
+		SessionToken:    aws.ToString(resp.Credentials.SessionToken),
+		Source:          sourceLabel(SAMLProviderName, p.options.RoleARN),
+		CanExpire:       true,
+		Expires:         aws.ToTime(resp.Credentials.Expiration),
+		AccountID:       accountID,
+	}, nil
+}
+
+// NewSAMLConf returns an aws.Config configured to assume the given roleArn with
+// AssumeRoleWithSAML using auto-refreshing credentials, with optional
+// SAMLRoleOptions and ConfOptions, such as WithSTSFIPSEndpoint.
+func NewSAMLConf(
+	_ context.Context,
+	cfg aws.Config,
+	principalArn string,
+	roleArn string,
+	assertionProvider func(ctx context.Context) (string, error),
+	opts ...Option,
+) (aws.Config, error) {
+	conf := newConfOptions(opts)
+
+	// Validate principal and role ARNs
+	if _, err := arn.Parse(principalArn); err != nil {
+		return aws.Config{}, fmt.Errorf("%w: %w", ErrInvalidSAMLPrincipalArn, err)
+	}
+	if _, err := parseRoleArn(roleArn); err != nil {
+		return aws.Config{}, err
+	}
+	if assertionProvider == nil {
+		return aws.Config{}, fmt.Errorf("%w: nil assertion provider", ErrSAMLAssertion)
+	}
+	if err := validateSTSEndpoint(conf); err != nil {
+		return aws.Config{}, err
+	}
+
+	stsClient, ok := newSTSClient(cfg, conf).(AssumeRoleWithSAMLAPIClient)
+	if !ok {
+		return aws.Config{}, fmt.Errorf("%w: STS client does not support AssumeRoleWithSAML", ErrSAMLAssumeRole)
+	}
+	provider := NewSAMLRoleProvider(stsClient, principalArn, roleArn, assertionProvider, conf.samlOpts...)
+
+	newCfg := cfg.Copy()
+	newCfg.Credentials = aws.NewCredentialsCache(
+		withSource(sourceLabel(SAMLProviderName, roleArn), withStats(provider)),
+		conf.cacheOpts...,
+	)
+	return newCfg, nil
+}
+
+// WithSAMLDuration sets the SAML session duration
+func WithSAMLDuration(duration time.Duration) SAMLRoleOption {
+	return func(o *SAMLRoleOptions) {
+		o.Duration = duration
+	}
+}
+
+// WithSAMLPolicy sets an inline session policy on the SAML session
+func WithSAMLPolicy(policy string) SAMLRoleOption {
+	return func(o *SAMLRoleOptions) {
+		o.Policy = aws.String(policy)
+	}
+}
+
+// String implements the fmt.Stringer interface method, printing only the role
+// and principal ARNs, never the STS client
+func (p *SAMLRoleProvider) String() string {
+	return fmt.Sprintf("SAMLRoleProvider{Source: %q, PrincipalARN: %q}", sourceLabel(SAMLProviderName, p.options.RoleARN), p.options.PrincipalARN)
+}
+
+// GoString implements the fmt.GoStringer interface method, so %#v prints no more than String
+func (p *SAMLRoleProvider) GoString() string {
+	return "&awsconfig." + p.String()
+}
@@ -0,0 +1,146 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigtest"
+)
+
+const (
+	testSAMLPrincipalArn = "arn:aws:iam::123456789012:saml-provider/IdP"
+	testSAMLRoleArn      = "arn:aws:iam::123456789012:role/SAML"
+)
+
+// fakeSAMLClient answers AssumeRoleWithSAML with resp or err, recording the assertions sent
+type fakeSAMLClient struct {
+	resp       *sts.AssumeRoleWithSAMLOutput
+	err        error
+	assertions []string
+}
+
+// AssumeRoleWithSAML implements the AssumeRoleWithSAMLAPIClient interface method
+func (c *fakeSAMLClient) AssumeRoleWithSAML(
+	_ context.Context,
+	params *sts.AssumeRoleWithSAMLInput,
+	_ ...func(*sts.Options),
+) (*sts.AssumeRoleWithSAMLOutput, error) {
+	c.assertions = append(c.assertions, aws.ToString(params.SAMLAssertion))
+	return c.resp, c.err
+}
+
+func TestSAMLRoleProvider(t *testing.T) {
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	validResp := &sts.AssumeRoleWithSAMLOutput{
+		AssumedRoleUser: &types.AssumedRoleUser{Arn: aws.String("arn:aws:sts::123456789012:assumed-role/SAML/alice")},
+		Credentials: &types.Credentials{
+			AccessKeyId:     aws.String("ASIAFAKESAMLACCESSKEY"),
+			SecretAccessKey: aws.String("fake-secret-access-key"),
+			SessionToken:    aws.String("fake-session-token"),
+			Expiration:      aws.Time(expires),
+		},
+	}
+	idpErr := errors.New("IdP unavailable")
+	stsErr := errors.New("InvalidIdentityToken")
+
+	tests := []struct {
+		name         string
+		assertionErr error
+		client       *fakeSAMLClient
+		wantErr      error
+	}{
+		{"Success", nil, &fakeSAMLClient{resp: validResp}, nil},
+		{"AssertionError", idpErr, &fakeSAMLClient{resp: validResp}, ErrSAMLAssertion},
+		{"STSError", nil, &fakeSAMLClient{err: stsErr}, ErrSAMLAssumeRole},
+		{"NilCredentials", nil, &fakeSAMLClient{resp: &sts.AssumeRoleWithSAMLOutput{}}, ErrSAMLAssumeRole},
+		{"NilResponse", nil, &fakeSAMLClient{}, ErrSAMLAssumeRole},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			assertionProvider := func(context.Context) (string, error) {
+				calls++
+				return fmt.Sprintf("assertion-%d", calls), tt.assertionErr
+			}
+			provider := NewSAMLRoleProvider(tt.client, testSAMLPrincipalArn, testSAMLRoleArn, assertionProvider)
+
+			creds, err := provider.Retrieve(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Retrieve error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == ErrSAMLAssertion && errors.Is(err, ErrSAMLAssumeRole) ||
+				tt.wantErr == ErrSAMLAssumeRole && errors.Is(err, ErrSAMLAssertion) {
+				t.Errorf("Retrieve error %v matches both the IdP and STS errors", err)
+			}
+			if err != nil {
+				return
+			}
+			if creds.AccessKeyID != "ASIAFAKESAMLACCESSKEY" || creds.AccountID != "123456789012" ||
+				!creds.CanExpire || !creds.Expires.Equal(expires) {
+				t.Errorf("Retrieve returned %+v", creds)
+			}
+
+			// Assertions are fetched again for every refresh
+			if _, err := provider.Retrieve(context.Background()); err != nil {
+				t.Fatalf("second Retrieve: %v", err)
+			}
+			if got := tt.client.assertions; len(got) != 2 || got[0] != "assertion-1" || got[1] != "assertion-2" {
+				t.Errorf("AssumeRoleWithSAML received assertions %q, want a fresh one per Retrieve", got)
+			}
+		})
+	}
+}
+
+func TestNewSAMLConf(t *testing.T) {
+	assertionProvider := func(context.Context) (string, error) { return "assertion", nil }
+	tests := []struct {
+		name              string
+		principalArn      string
+		roleArn           string
+		assertionProvider func(context.Context) (string, error)
+		opts              []Option
+		wantErr           error
+	}{
+		{"Valid", testSAMLPrincipalArn, testSAMLRoleArn, assertionProvider, nil, nil},
+		{"InvalidPrincipal", "IdP", testSAMLRoleArn, assertionProvider, nil, ErrInvalidSAMLPrincipalArn},
+		{"InvalidRole", testSAMLPrincipalArn, "SAML", assertionProvider, nil, ErrInvalidRoleArn},
+		{"NilAssertionProvider", testSAMLPrincipalArn, testSAMLRoleArn, nil, nil, ErrSAMLAssertion},
+		{"InvalidSTSEndpoint", testSAMLPrincipalArn, testSAMLRoleArn, assertionProvider,
+			[]Option{WithSTSEndpoint("localhost:4566")}, ErrInvalidSTSEndpoint},
+		{"STSClientWithoutSAML", testSAMLPrincipalArn, testSAMLRoleArn, assertionProvider,
+			[]Option{WithSTSClient(&awsconfigtest.FakeSTS{})}, ErrSAMLAssumeRole},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, cfg := newSTSServer(t)
+			newCfg, err := NewSAMLConf(context.Background(), cfg, tt.principalArn, tt.roleArn, tt.assertionProvider,
+				append([]Option{WithSAMLDuration(time.Hour)}, tt.opts...)...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewSAMLConf error %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if _, err := newCfg.Credentials.Retrieve(context.Background()); err != nil {
+				t.Fatalf("Retrieve: %v", err)
+			}
+			requests := srv.Requests()
+			if len(requests) != 1 {
+				t.Fatalf("STS received %d requests, want 1", len(requests))
+			}
+			form := requests[0]
+			if form.Get("Action") != "AssumeRoleWithSAML" || form.Get("SAMLAssertion") != "assertion" ||
+				form.Get("RoleArn") != tt.roleArn || form.Get("PrincipalArn") != tt.principalArn ||
+				form.Get("DurationSeconds") != "3600" {
+				t.Errorf("STS received %v", form)
+			}
+		})
+	}
+}
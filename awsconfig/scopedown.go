@@ -0,0 +1,97 @@
+package awsconfig
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// readOnlyActions are the action name patterns WithReadOnlyPolicy leaves allowed
+var readOnlyActions = []string{"*:BatchGet*", "*:Describe*", "*:Get*", "*:List*"}
+
+// policyStatement is a statement of the scope-down policies generated by this package
+type policyStatement struct {
+	Sid       string `json:",omitempty"`
+	Effect    string
+	Action    []string `json:",omitempty"`
+	NotAction []string `json:",omitempty"`
+	Resource  string
+}
+
+// WithReadOnlyPolicy scopes the session down to read-only access by denying every
+// action whose name does not start with Get, List, Describe, or BatchGet. It is
+// merged into any WithPolicy session policy rather than replacing it.
+func WithReadOnlyPolicy() ConfOption {
+	return func(c *confOptions) {
+		c.denyStatements = append(c.denyStatements, policyStatement{
+			Sid:       "ReadOnly",
+			Effect:    "Deny",
+			NotAction: readOnlyActions,
+			Resource:  "*",
+		})
+	}
+}
+
+// WithDenyActions scopes the session down by denying the given actions, which
+// may use wildcards. It is merged into any WithPolicy session policy rather than
+// replacing it. Construction fails if no actions are given.
+func WithDenyActions(actions ...string) ConfOption {
+	return func(c *confOptions) {
+		c.denyStatements = append(c.denyStatements, policyStatement{
+			Sid:      "DenyActions",
+			Effect:   "Deny",
+			Action:   actions,
+			Resource: "*",
+		})
+	}
+}
+
+// applyScopeDownPolicy merges the deny statements of conf into the session policy.
+// Session policies only grant what they allow, so without a WithPolicy policy an
+// allow-all statement is added for the denies to carve from.
+func applyScopeDownPolicy(roleArn string, conf *confOptions) error {
+	if len(conf.denyStatements) == 0 {
+		return nil
+	}
+
+	var statements []policyStatement
+	policy := aws.ToString(effectiveAssumeRoleOptions(roleArn, conf).Policy)
+	if policy == "" {
+		statements = append(statements, policyStatement{
+			Sid:      "AllowAll",
+			Effect:   "Allow",
+			Action:   []string{"*"},
+			Resource: "*",
+		})
+	}
+
+	// Repeated options would otherwise repeat Sids, which IAM rejects
+	sids := map[string]bool{}
+	for _, statement := range conf.denyStatements {
+		if len(statement.Action) == 0 && len(statement.NotAction) == 0 {
+			return fmt.Errorf("%w: WithDenyActions needs at least one action", ErrInvalidSessionPolicy)
+		}
+		statement.Sid = uniqueSid(statement.Sid, sids)
+		statements = append(statements, statement)
+	}
+	b, err := json.Marshal(struct {
+		Version   string
+		Statement []policyStatement
+	}{policyVersion, statements})
+	if err != nil {
+		return err
+	}
+
+	scopeDown := string(b)
+	if policy != "" {
+		if scopeDown, err = MergePolicies(policy, scopeDown); err != nil {
+			return err
+		}
+	}
+	if size := len(scopeDown); size > MaxSessionPolicySize {
+		return fmt.Errorf("%w: %d characters, limit %d", ErrPolicyTooLarge, size, MaxSessionPolicySize)
+	}
+	conf.assumeRoleOpts = append(conf.assumeRoleOpts, WithPolicy(scopeDown))
+	return nil
+}
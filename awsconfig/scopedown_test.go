@@ -0,0 +1,83 @@
+package awsconfig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigtest"
+)
+
+// scopeDownDoc is a generated session policy as parsed back from the request
+type scopeDownDoc struct {
+	Version   string
+	Statement []policyStatement
+}
+
+func TestScopeDownPolicy(t *testing.T) {
+	readOnly := policyStatement{Sid: "ReadOnly", Effect: "Deny", NotAction: readOnlyActions, Resource: "*"}
+	allowAll := policyStatement{Sid: "AllowAll", Effect: "Allow", Action: []string{"*"}, Resource: "*"}
+	tests := []struct {
+		name string
+		opts []Option
+		want []policyStatement
+	}{
+		{
+			name: "ReadOnly",
+			opts: []Option{WithReadOnlyPolicy()},
+			want: []policyStatement{allowAll, readOnly},
+		},
+		{
+			name: "DenyActions",
+			opts: []Option{WithDenyActions("s3:Delete*", "iam:*")},
+			want: []policyStatement{allowAll, {Sid: "DenyActions", Effect: "Deny", Action: []string{"s3:Delete*", "iam:*"}, Resource: "*"}},
+		},
+		{
+			name: "RepeatedOptionsGetUniqueSids",
+			opts: []Option{WithDenyActions("s3:*"), WithDenyActions("iam:*"), WithReadOnlyPolicy(), WithReadOnlyPolicy()},
+			want: []policyStatement{
+				allowAll,
+				{Sid: "DenyActions", Effect: "Deny", Action: []string{"s3:*"}, Resource: "*"},
+				{Sid: "DenyActions2", Effect: "Deny", Action: []string{"iam:*"}, Resource: "*"},
+				readOnly,
+				{Sid: "ReadOnly2", Effect: "Deny", NotAction: readOnlyActions, Resource: "*"},
+			},
+		},
+		{
+			name: "MergedWithPolicy",
+			opts: []Option{WithPolicy(`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:GetObject"],"Resource":"*"}]}`), WithReadOnlyPolicy()},
+			want: []policyStatement{{Effect: "Allow", Action: []string{"s3:GetObject"}, Resource: "*"}, readOnly},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := aws.ToString(assumeRoleInput(t, tt.opts...).Policy)
+			if size := EstimatePackedPolicySize(policy); size > MaxSessionPolicySize {
+				t.Errorf("policy is %d characters, over the %d limit", size, MaxSessionPolicySize)
+			}
+			var doc scopeDownDoc
+			if err := json.Unmarshal([]byte(policy), &doc); err != nil {
+				t.Fatalf("policy is not JSON: %v\n%s", err, policy)
+			}
+			if doc.Version != policyVersion {
+				t.Errorf("policy Version %q, want %q", doc.Version, policyVersion)
+			}
+			got, _ := json.Marshal(doc.Statement)
+			want, _ := json.Marshal(tt.want)
+			if string(got) != string(want) {
+				t.Errorf("policy statements\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestWithDenyActionsEmpty(t *testing.T) {
+	_, err := NewAssumeRoleConf(context.Background(), aws.Config{}, testRoleArn,
+		WithSTSClient(&awsconfigtest.FakeSTS{}), WithSkipIdentityCheck(), WithDenyActions())
+	if !errors.Is(err, ErrInvalidSessionPolicy) {
+		t.Errorf("NewAssumeRoleConf error %v, want %v", err, ErrInvalidSessionPolicy)
+	}
+}
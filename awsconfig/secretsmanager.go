@@ -0,0 +1,193 @@
+package awsconfig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	smtypes "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+)
+
+// SecretsManagerProviderName prefixes the Source of credentials returned by
+// SecretsManagerProvider, followed by the secret ID
+const SecretsManagerProviderName = SourcePrefix + "secretsmanager"
+
+var (
+	// ErrSecretNotFound is returned when the credentials secret does not exist
+	ErrSecretNotFound = errors.New("Credentials secret not found")
+	// ErrGetSecret is returned when the credentials secret cannot be read
+	ErrGetSecret = errors.New("Cannot get credentials secret")
+	// ErrParseCredentialsJSON is returned when a secret or parameter doesn't hold valid credentials JSON
+	ErrParseCredentialsJSON = errors.New("Cannot parse credentials JSON")
+)
+
+// credentialsJSONAliases are the accepted field names of credentials JSON,
+// including the snake_case names of the shared credentials file
+var credentialsJSONAliases = struct {
+	accessKeyID, secretAccessKey, sessionToken, expiration []string
+}{
+	accessKeyID:     []string{"AccessKeyID", "AccessKeyId", "aws_access_key_id", "access_key_id"},
+	secretAccessKey: []string{"SecretAccessKey", "aws_secret_access_key", "secret_access_key"},
+	sessionToken:    []string{"SessionToken", "aws_session_token", "session_token"},
+	expiration:      []string{"Expiration", "aws_expiration", "expiration"},
+}
+
+// SecretsManagerAPIClient is a client capable of the Secrets Manager GetSecretValue operation
+type SecretsManagerAPIClient interface {
+	GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+}
+
+// WithSecretVersionStage reads the version of a credentials secret with stage,
+// such as AWSPREVIOUS, instead of AWSCURRENT
+func WithSecretVersionStage(stage string) ConfOption {
+	return func(c *confOptions) {
+		c.secretVersionStage = stage
+	}
+}
+
+// WithSecretsManagerClient makes NewSecretsManagerConf use client instead of building one from the base config
+func WithSecretsManagerClient(client SecretsManagerAPIClient) ConfOption {
+	return func(c *confOptions) {
+		c.secretsManagerClient = client
+	}
+}
+
+// SecretsManagerProvider implements the aws.CredentialsProvider interface by
+// reading credentials JSON from a Secrets Manager secret:
+//
+//	{"AccessKeyID": "...", "SecretAccessKey": "...", "SessionToken": "...", "Expiration": "2006-01-02T15:04:05Z"}
+//
+// SessionToken and the RFC 3339 Expiration are optional, and the snake_case
+// names of the shared credentials file, such as aws_access_key_id, are accepted.
+type SecretsManagerProvider struct {
+	client       SecretsManagerAPIClient
+	secretID     string
+	versionStage string
+	forcedTTL    time.Duration
+}
+
+// NewSecretsManagerProvider initializes a new SecretsManagerProvider reading
+// secretID, a secret name or ARN. Credentials without an Expiration are given
+// one WithForcedTTL after retrieval, so rotated secrets are picked up.
+func NewSecretsManagerProvider(client SecretsManagerAPIClient, secretID string, opts ...Option) *SecretsManagerProvider {
+	conf := newConfOptions(opts)
+	return &SecretsManagerProvider{
+		client:       client,
+		secretID:     secretID,
+		versionStage: conf.secretVersionStage,
+		forcedTTL:    conf.forcedTTL,
+	}
+}
+
+// Retrieve implements the aws.CredentialsProvider interface method
+func (p *SecretsManagerProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	input := &secretsmanager.GetSecretValueInput{SecretId: aws.String(p.secretID)}
+	if p.versionStage != "" {
+		input.VersionStage = aws.String(p.versionStage)
+	}
+	resp, err := p.client.GetSecretValue(ctx, input)
+	if err != nil {
+		var notFound *smtypes.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			return aws.Credentials{}, fmt.Errorf("%w %q: %w", ErrSecretNotFound, p.secretID, err)
+		}
+		return aws.Credentials{}, fmt.Errorf("%w %q: %w", ErrGetSecret, p.secretID, err)
+	}
+
+	secret := resp.SecretBinary
+	if resp.SecretString != nil {
+		secret = []byte(*resp.SecretString)
+	}
+	creds, err := parseCredentialsJSON(secret)
+	if err != nil {
+		return aws.Credentials{}, fmt.Errorf("%w in secret %q: %w", ErrParseCredentialsJSON, p.secretID, err)
+	}
+	creds.Source = sourceLabel(SecretsManagerProviderName, p.secretID)
+	return withForcedTTL(creds, p.forcedTTL), nil
+}
+
+// parseCredentialsJSON parses the credentials JSON documented on SecretsManagerProvider
+func parseCredentialsJSON(b []byte) (aws.Credentials, error) {
+	var fields map[string]any
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return aws.Credentials{}, err
+	}
+	lookup := func(names []string) (string, error) {
+		for _, name := range names {
+			if v, ok := fields[name]; ok {
+				s, ok := v.(string)
+				if !ok {
+					return "", fmt.Errorf("%s is not a string", name)
+				}
+				return s, nil
+			}
+		}
+		return "", nil
+	}
+
+	var creds aws.Credentials
+	var expiration string
+	for _, f := range []struct {
+		names []string
+		value *string
+	}{
+		{credentialsJSONAliases.accessKeyID, &creds.AccessKeyID},
+		{credentialsJSONAliases.secretAccessKey, &creds.SecretAccessKey},
+		{credentialsJSONAliases.sessionToken, &creds.SessionToken},
+		{credentialsJSONAliases.expiration, &expiration},
+	} {
+		v, err := lookup(f.names)
+		if err != nil {
+			return aws.Credentials{}, err
+		}
+		*f.value = v
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return aws.Credentials{}, errors.New("missing AccessKeyID or SecretAccessKey")
+	}
+	if expiration != "" {
+		expires, err := time.Parse(time.RFC3339, expiration)
+		if err != nil {
+			return aws.Credentials{}, err
+		}
+		creds.CanExpire = true
+		creds.Expires = expires
+	}
+	return creds, nil
+}
+
+// NewSecretsManagerConf returns an aws.Config using the credentials stored in
+// secretID, cached until they expire; see SecretsManagerProvider
+func NewSecretsManagerConf(
+	_ context.Context,
+	cfg aws.Config,
+	secretID string,
+	opts ...Option,
+) (aws.Config, error) {
+	conf := newConfOptions(opts)
+
+	client := conf.secretsManagerClient
+	if client == nil {
+		client = secretsmanager.NewFromConfig(cfg)
+	}
+	provider := NewSecretsManagerProvider(client, secretID, opts...)
+
+	newCfg := cfg.Copy()
+	newCfg.Credentials = aws.NewCredentialsCache(withStats(provider), conf.cacheOpts...)
+	return newCfg, nil
+}
+
+// String implements the fmt.Stringer interface method, printing only the secret
+// ID and version stage
+func (p *SecretsManagerProvider) String() string {
+	return fmt.Sprintf("SecretsManagerProvider{Source: %q, VersionStage: %q}", sourceLabel(SecretsManagerProviderName, p.secretID), p.versionStage)
+}
+
+// GoString implements the fmt.GoStringer interface method, so %#v prints no more than String
+func (p *SecretsManagerProvider) GoString() string {
+	return "&awsconfig." + p.String()
+}
@@ -0,0 +1,187 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	smtypes "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+)
+
+const testSecretID = "break-glass/admin"
+
+func TestSecretsManagerProvider(t *testing.T) {
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name   string
+		secret string
+		binary bool
+		opts   []Option
+		ttl    time.Duration
+		want   aws.Credentials
+	}{
+		{
+			name:   "Documented",
+			secret: `{"AccessKeyID": "AKIAEXAMPLE", "SecretAccessKey": "secret"}`,
+			want:   aws.Credentials{AccessKeyID: "AKIAEXAMPLE", SecretAccessKey: "secret"},
+		},
+		{
+			name:   "SessionAndExpiration",
+			secret: `{"AccessKeyId": "ASIAEXAMPLE", "SecretAccessKey": "secret", "SessionToken": "token", "Expiration": "2030-01-02T03:04:05Z"}`,
+			want:   aws.Credentials{AccessKeyID: "ASIAEXAMPLE", SecretAccessKey: "secret", SessionToken: "token", CanExpire: true, Expires: expires},
+		},
+		{
+			name:   "SharedCredentialsAliases",
+			secret: `{"aws_access_key_id": "ASIAEXAMPLE", "aws_secret_access_key": "secret", "aws_session_token": "token", "aws_expiration": "2030-01-02T03:04:05Z"}`,
+			want:   aws.Credentials{AccessKeyID: "ASIAEXAMPLE", SecretAccessKey: "secret", SessionToken: "token", CanExpire: true, Expires: expires},
+		},
+		{
+			name:   "SnakeCaseAliases",
+			secret: `{"access_key_id": "AKIAEXAMPLE", "secret_access_key": "secret", "session_token": "token"}`,
+			want:   aws.Credentials{AccessKeyID: "AKIAEXAMPLE", SecretAccessKey: "secret", SessionToken: "token"},
+		},
+		{
+			name:   "SecretBinary",
+			secret: `{"AccessKeyID": "AKIAEXAMPLE", "SecretAccessKey": "secret"}`,
+			binary: true,
+			want:   aws.Credentials{AccessKeyID: "AKIAEXAMPLE", SecretAccessKey: "secret"},
+		},
+		{
+			name:   "ForcedTTL",
+			secret: `{"AccessKeyID": "AKIAEXAMPLE", "SecretAccessKey": "secret"}`,
+			opts:   []Option{WithForcedTTL(time.Hour)},
+			ttl:    time.Hour,
+			want:   aws.Credentials{AccessKeyID: "AKIAEXAMPLE", SecretAccessKey: "secret", CanExpire: true},
+		},
+		{
+			name:   "ForcedTTLKeepsExpiration",
+			secret: `{"AccessKeyID": "ASIAEXAMPLE", "SecretAccessKey": "secret", "Expiration": "2030-01-02T03:04:05Z"}`,
+			opts:   []Option{WithForcedTTL(time.Hour)},
+			want:   aws.Credentials{AccessKeyID: "ASIAEXAMPLE", SecretAccessKey: "secret", CanExpire: true, Expires: expires},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubClock(t)
+			client := &stubSecretsManager{secret: tt.secret, binary: tt.binary}
+			creds, err := NewSecretsManagerProvider(client, testSecretID, tt.opts...).Retrieve(context.Background())
+			if err != nil {
+				t.Fatalf("Retrieve: %v", err)
+			}
+			tt.want.Source = sourceLabel(SecretsManagerProviderName, testSecretID)
+			if tt.ttl > 0 {
+				tt.want.Expires = timeNow().Add(tt.ttl)
+			}
+			if !creds.Expires.Equal(tt.want.Expires) {
+				t.Errorf("Expires %v, want %v", creds.Expires, tt.want.Expires)
+			}
+			creds.Expires, tt.want.Expires = time.Time{}, time.Time{}
+			if creds != tt.want {
+				t.Errorf("Retrieve returned %+v, want %+v", creds, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecretsManagerProviderErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		client  *stubSecretsManager
+		wantErr error
+	}{
+		{
+			name:    "NotFound",
+			client:  &stubSecretsManager{err: &smtypes.ResourceNotFoundException{Message: aws.String("no such secret")}},
+			wantErr: ErrSecretNotFound,
+		},
+		{
+			name:    "AccessDenied",
+			client:  &stubSecretsManager{err: errors.New("AccessDeniedException")},
+			wantErr: ErrGetSecret,
+		},
+		{
+			name:    "MalformedJSON",
+			client:  &stubSecretsManager{secret: `{"AccessKeyID": "AKIAEXAMPLE",`},
+			wantErr: ErrParseCredentialsJSON,
+		},
+		{
+			name:    "NotAnObject",
+			client:  &stubSecretsManager{secret: `"AKIAEXAMPLE:secret"`},
+			wantErr: ErrParseCredentialsJSON,
+		},
+		{
+			name:    "MissingSecretAccessKey",
+			client:  &stubSecretsManager{secret: `{"AccessKeyID": "AKIAEXAMPLE"}`},
+			wantErr: ErrParseCredentialsJSON,
+		},
+		{
+			name:    "NonStringField",
+			client:  &stubSecretsManager{secret: `{"AccessKeyID": "AKIAEXAMPLE", "SecretAccessKey": 42}`},
+			wantErr: ErrParseCredentialsJSON,
+		},
+		{
+			name:    "BadExpiration",
+			client:  &stubSecretsManager{secret: `{"AccessKeyID": "AKIAEXAMPLE", "SecretAccessKey": "secret", "Expiration": "tomorrow"}`},
+			wantErr: ErrParseCredentialsJSON,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewSecretsManagerProvider(tt.client, testSecretID).Retrieve(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Retrieve error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewSecretsManagerConf(t *testing.T) {
+	client := &stubSecretsManager{secret: `{"AccessKeyID": "ASIAEXAMPLE", "SecretAccessKey": "secret", "Expiration": "2030-01-02T03:04:05Z"}`}
+	cfg, err := NewSecretsManagerConf(context.Background(), aws.Config{}, testSecretID,
+		WithSecretsManagerClient(client), WithSecretVersionStage("AWSPREVIOUS"))
+	if err != nil {
+		t.Fatalf("NewSecretsManagerConf: %v", err)
+	}
+	for range 3 {
+		if _, err := cfg.Credentials.Retrieve(context.Background()); err != nil {
+			t.Fatalf("Retrieve: %v", err)
+		}
+	}
+	if len(client.inputs) != 1 {
+		t.Fatalf("GetSecretValue called %d times, want 1 for cached credentials", len(client.inputs))
+	}
+	if got := aws.ToString(client.inputs[0].VersionStage); got != "AWSPREVIOUS" {
+		t.Errorf("VersionStage %q, want AWSPREVIOUS", got)
+	}
+	if got := aws.ToString(client.inputs[0].SecretId); got != testSecretID {
+		t.Errorf("SecretId %q, want %q", got, testSecretID)
+	}
+}
+
+// stubSecretsManager is a SecretsManagerAPIClient returning secret, or err,
+// and recording its inputs
+type stubSecretsManager struct {
+	secret string
+	binary bool
+	err    error
+
+	inputs []secretsmanager.GetSecretValueInput
+}
+
+func (s *stubSecretsManager) GetSecretValue(
+	_ context.Context,
+	params *secretsmanager.GetSecretValueInput,
+	_ ...func(*secretsmanager.Options),
+) (*secretsmanager.GetSecretValueOutput, error) {
+	s.inputs = append(s.inputs, *params)
+	if s.err != nil {
+		return nil, s.err
+	}
+	if s.binary {
+		return &secretsmanager.GetSecretValueOutput{SecretBinary: []byte(s.secret)}, nil
+	}
+	return &secretsmanager.GetSecretValueOutput{SecretString: aws.String(s.secret)}, nil
+}
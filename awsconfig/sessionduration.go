@@ -0,0 +1,44 @@
+package awsconfig
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+)
+
+var (
+	// ErrInvalidDuration is returned when a requested session duration is outside what STS accepts
+	ErrInvalidDuration = errors.New("Session duration must be whole seconds between 15m0s and 12h0m0s")
+)
+
+const (
+	// MinSessionDuration is the shortest session STS issues for AssumeRole
+	MinSessionDuration = 15 * time.Minute
+	// MaxSessionDuration is the longest session STS issues for AssumeRole
+	MaxSessionDuration = 12 * time.Hour
+)
+
+// effectiveAssumeRoleOptions applies the AssumeRoleOptions of conf to a scratch
+// copy, the same way stscreds does, so they can be inspected before use.
+func effectiveAssumeRoleOptions(roleArn string, conf *confOptions) stscreds.AssumeRoleOptions {
+	o := stscreds.AssumeRoleOptions{
+		RoleARN: roleArn,
+	}
+	for _, fn := range conf.assumeRoleOpts {
+		fn(&o)
+	}
+	return o
+}
+
+// validateDuration checks a requested session duration; zero defers to the role's default
+func validateDuration(duration time.Duration) error {
+	if duration == 0 {
+		return nil
+	}
+	if duration < MinSessionDuration || duration > MaxSessionDuration || duration%time.Second != 0 {
+		return fmt.Errorf("%w, got %s", ErrInvalidDuration, duration)
+	}
+	return nil
+}
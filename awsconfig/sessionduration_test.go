@@ -0,0 +1,100 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigtest"
+)
+
+func TestNewAssumeRoleConfDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantErr error
+	}{
+		{"Unset", nil, nil},
+		{"Minimum", []Option{WithDuration(15 * time.Minute)}, nil},
+		{"Maximum", []Option{WithDuration(12 * time.Hour)}, nil},
+		{"BelowMinimum", []Option{WithDuration(15*time.Minute - time.Second)}, ErrInvalidDuration},
+		{"AboveMaximum", []Option{WithDuration(12*time.Hour + time.Second)}, ErrInvalidDuration},
+		{"SubSecond", []Option{WithDuration(time.Hour + time.Millisecond)}, ErrInvalidDuration},
+		{"Negative", []Option{WithDuration(-time.Hour)}, ErrInvalidDuration},
+		{"LastOptionWins", []Option{WithDuration(time.Minute), WithDuration(time.Hour)}, nil},
+		{"RawStscredsOption", []Option{WithAssumeRoleOptions(func(o *stscreds.AssumeRoleOptions) {
+			o.Duration = 13 * time.Hour
+		})}, ErrInvalidDuration},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &awsconfigtest.FakeSTS{}
+			opts := append([]Option{WithSTSClient(fake), WithSkipIdentityCheck()}, tt.opts...)
+			_, err := NewAssumeRoleConf(context.Background(), aws.Config{}, testRoleArn, opts...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewAssumeRoleConf error %v, want %v", err, tt.wantErr)
+			}
+			if err != nil && len(fake.AssumeRoleInputs()) != 0 {
+				t.Error("AssumeRole called despite an invalid duration")
+			}
+		})
+	}
+}
+
+func TestNewAssumeRoleConfChainedDuration(t *testing.T) {
+	const (
+		userArn        = "arn:aws:iam::123456789012:user/Alice"
+		assumedRoleArn = "arn:aws:sts::123456789012:assumed-role/Base/session"
+	)
+	tests := []struct {
+		name         string
+		callerArn    string
+		opts         []Option
+		wantErr      error
+		wantDuration int32
+		wantWarning  bool
+	}{
+		{name: "UserLongSession", callerArn: userArn, opts: []Option{WithDuration(2 * time.Hour)}, wantDuration: 7200},
+		{name: "ChainedNoDuration", callerArn: assumedRoleArn, wantDuration: 900}, // stscreds default
+		{name: "ChainedWithinLimit", callerArn: assumedRoleArn, opts: []Option{WithDuration(time.Hour)}, wantDuration: 3600},
+		{name: "ChainedClampedByDefault", callerArn: assumedRoleArn, opts: []Option{WithDuration(2 * time.Hour)},
+			wantDuration: 3600, wantWarning: true},
+		{name: "ChainedClamp", callerArn: assumedRoleArn,
+			opts:         []Option{WithDuration(2 * time.Hour), WithChainedDurationPolicy(ChainedDurationClamp)},
+			wantDuration: 3600, wantWarning: true},
+		{name: "ChainedError", callerArn: assumedRoleArn,
+			opts:    []Option{WithDuration(2 * time.Hour), WithChainedDurationPolicy(ChainedDurationError)},
+			wantErr: ErrChainedDurationExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &awsconfigtest.FakeSTS{}
+			fake.Identity.Arn = aws.String(tt.callerArn)
+			var warnings []error
+			opts := append([]Option{
+				WithSTSClient(fake),
+				WithWarningHandler(func(err error) { warnings = append(warnings, err) }),
+			}, tt.opts...)
+			cfg, err := NewAssumeRoleConf(context.Background(), aws.Config{}, testRoleArn, opts...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewAssumeRoleConf error %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if gotWarning := len(warnings) == 1 && errors.Is(warnings[0], ErrChainedDurationExceeded); gotWarning != tt.wantWarning {
+				t.Errorf("warnings %v, want chained duration warning %t", warnings, tt.wantWarning)
+			}
+			if _, err := cfg.Credentials.Retrieve(context.Background()); err != nil {
+				t.Fatalf("Retrieve: %v", err)
+			}
+			if got := aws.ToInt32(fake.AssumeRoleInputs()[0].DurationSeconds); got != tt.wantDuration {
+				t.Errorf("AssumeRole DurationSeconds %d, want %d", got, tt.wantDuration)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package awsconfig
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"slices"
+	"strings"
+)
+
+var (
+	// ErrInvalidSessionName is returned when a role session name would be rejected by STS
+	ErrInvalidSessionName = errors.New(`Role session name must be 2-64 characters of [\w+=,.@-]`)
+)
+
+const (
+	// minSessionNameLength is the shortest RoleSessionName STS accepts
+	minSessionNameLength = 2
+	// maxSessionNameLength is the longest RoleSessionName STS accepts
+	maxSessionNameLength = 64
+	// sessionNameHashLength is how many hex digits of hash mark a truncated session name
+	sessionNameHashLength = 8
+	// sessionNamePadding lengthens derived session names shorter than minSessionNameLength
+	sessionNamePadding = "-session"
+)
+
+// WithSessionNameFromCaller names the role session after the caller: the IAM or
+// federated user name, or the current role session name. When the identity
+// preflight is skipped the OS user and hostname are used instead. An explicit
+// WithRoleSessionName takes precedence.
+func WithSessionNameFromCaller() ConfOption {
+	return func(c *confOptions) {
+		c.sessionNameFromCaller = true
+	}
+}
+
+// callerSessionName derives a role session name from a caller identity, or from
+// the local OS user and hostname when identity is nil. Names too short for STS,
+// such as a one-letter user name, are padded; empty means none could be derived.
+func callerSessionName(identity *CallerIdentity) string {
+	var name string
+	switch {
+	case identity != nil && (identity.Type == IdentityTypeUser || identity.Type == IdentityTypeFederatedUser):
+		name = SanitizeSessionName(identity.UserName)
+	case identity != nil && identity.Type == IdentityTypeAssumedRole:
+		name = SanitizeSessionName(identity.SessionName)
+	case identity != nil && identity.Type == IdentityTypeRoot:
+		name = "root"
+	default:
+		var parts []string
+		if u, err := user.Current(); err == nil && u.Username != "" {
+			parts = append(parts, u.Username)
+		}
+		if host, err := os.Hostname(); err == nil && host != "" {
+			parts = append(parts, host)
+		}
+		name = SanitizeSessionName(strings.Join(parts, "-"))
+	}
+	if name != "" && len(name) < minSessionNameLength {
+		name += sessionNamePadding
+	}
+	return name
+}
+
+// SanitizeSessionName makes name acceptable to STS as a RoleSessionName: runs of
+// disallowed characters become a single "-", and names over 64 characters are
+// truncated with a short hash of the original appended so they stay distinct.
+// Valid names are returned unchanged.
+func SanitizeSessionName(name string) string {
+	var b strings.Builder
+	replaced := false
+	for _, r := range name {
+		if isSessionNameRune(r) {
+			b.WriteRune(r)
+			replaced = false
+		} else if !replaced {
+			b.WriteByte('-')
+			replaced = true
+		}
+	}
+	sanitized := b.String()
+	if len(sanitized) <= maxSessionNameLength {
+		return sanitized
+	}
+
+	// Only ASCII runes survive sanitization, so byte truncation can't split one
+	sum := sha256.Sum256([]byte(name))
+	suffix := "-" + hex.EncodeToString(sum[:])[:sessionNameHashLength]
+	return sanitized[:maxSessionNameLength-len(suffix)] + suffix
+}
+
+// WithSanitizedRoleSessionName sets the session name after passing it through SanitizeSessionName
+func WithSanitizedRoleSessionName(name string) AssumeRoleOption {
+	return WithRoleSessionName(SanitizeSessionName(name))
+}
+
+// validateSessionName checks a role session name; empty lets STS generate one
+func validateSessionName(name string) error {
+	if name == "" {
+		return nil
+	}
+	var invalid []string
+	for _, r := range name {
+		if !isSessionNameRune(r) && !slices.Contains(invalid, string(r)) {
+			invalid = append(invalid, string(r))
+		}
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("%w: %q has invalid characters %q", ErrInvalidSessionName, name, invalid)
+	}
+	if n := len(name); n < minSessionNameLength || n > maxSessionNameLength {
+		return fmt.Errorf("%w: %q is %d characters", ErrInvalidSessionName, name, n)
+	}
+	return nil
+}
+
+// isSessionNameRune reports whether r matches the STS session name class [\w+=,.@-]
+func isSessionNameRune(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return true
+	default:
+		return strings.ContainsRune("_+=,.@-", r)
+	}
+}
@@ -0,0 +1,179 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/user"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigtest"
+)
+
+func TestWithSessionNameFromCaller(t *testing.T) {
+	tests := []struct {
+		name      string
+		callerArn string
+		opts      []Option
+		want      string
+	}{
+		{name: "IAMUser", callerArn: "arn:aws:iam::123456789012:user/alice", want: "alice"},
+		{name: "IAMUserWithPath", callerArn: "arn:aws:iam::123456789012:user/eng/alice", want: "alice"},
+		{name: "AssumedRole", callerArn: "arn:aws:sts::123456789012:assumed-role/Base/alice@laptop", want: "alice@laptop"},
+		{name: "FederatedUser", callerArn: "arn:aws:sts::123456789012:federated-user/plugin", want: "plugin"},
+		{name: "Root", callerArn: "arn:aws:iam::123456789012:root", want: "root"},
+		{name: "OneLetterUser", callerArn: "arn:aws:iam::123456789012:user/a", want: "a-session"},
+		{name: "ExplicitWins", callerArn: "arn:aws:iam::123456789012:user/alice",
+			opts: []Option{WithRoleSessionName("explicit")}, want: "explicit"},
+		{name: "LongSessionTruncated", callerArn: "arn:aws:sts::123456789012:assumed-role/Base/" + strings.Repeat("s", 70),
+			want: strings.Repeat("s", 55) + "-"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &awsconfigtest.FakeSTS{}
+			fake.Identity.Arn = aws.String(tt.callerArn)
+			opts := append([]Option{WithSessionNameFromCaller()}, tt.opts...)
+			got := aws.ToString(fakeAssumeRoleInput(t, fake, opts...).RoleSessionName)
+			if !strings.HasPrefix(got, tt.want) {
+				t.Errorf("AssumeRole session name %q, want %q", got, tt.want)
+			}
+			if err := validateSessionName(got); err != nil {
+				t.Errorf("derived session name rejected: %v", err)
+			}
+		})
+	}
+}
+
+func TestWithSessionNameFromCallerSkippedPreflight(t *testing.T) {
+	var parts []string
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		parts = append(parts, u.Username)
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		parts = append(parts, host)
+	}
+	want := SanitizeSessionName(strings.Join(parts, "-"))
+	if want == "" {
+		t.Skip("no OS user or hostname to derive a session name from")
+	}
+	if len(want) < minSessionNameLength {
+		want += sessionNamePadding
+	}
+
+	fake := &awsconfigtest.FakeSTS{}
+	got := aws.ToString(fakeAssumeRoleInput(t, fake, WithSkipIdentityCheck(), WithSessionNameFromCaller()).RoleSessionName)
+	if got != want {
+		t.Errorf("AssumeRole session name %q, want %q", got, want)
+	}
+	if fake.CallerIdentityCalls() != 0 {
+		t.Error("preflight ran despite WithSkipIdentityCheck")
+	}
+}
+
+func TestCallerSessionName(t *testing.T) {
+	tests := []struct {
+		name     string
+		identity CallerIdentity
+		want     string
+	}{
+		{"User", CallerIdentity{Type: IdentityTypeUser, UserName: "alice"}, "alice"},
+		{"OneCharacter", CallerIdentity{Type: IdentityTypeUser, UserName: "a"}, "a-session"},
+		{"OnlyDisallowed", CallerIdentity{Type: IdentityTypeAssumedRole, SessionName: "日本"}, "--session"},
+		{"Empty", CallerIdentity{Type: IdentityTypeFederatedUser}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := callerSessionName(&tt.identity)
+			if got != tt.want {
+				t.Errorf("callerSessionName = %q, want %q", got, tt.want)
+			}
+			if got != "" {
+				if err := validateSessionName(got); err != nil {
+					t.Errorf("callerSessionName result rejected: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestSanitizeSessionName(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"Valid", "alice@example.com", "alice@example.com"},
+		{"ValidAllClasses", "a_b+c=d,e.f@g-h", "a_b+c=d,e.f@g-h"},
+		{"Exactly64", strings.Repeat("a", 64), strings.Repeat("a", 64)},
+		{"EmailWithPlusAndSpace", "Alice Smith <alice+ci@example.com>", "Alice-Smith-alice+ci@example.com-"},
+		{"RunsCollapsed", "a//\\b  c", "a-b-c"},
+		{"MultiByte", "ユーザー", "-"},
+		{"PodName", "web-7d4b9c8f6d-x2k9q_3f2504e0-4f89-11d3-9a0c-0305e82c3301", "web-7d4b9c8f6d-x2k9q_3f2504e0-4f89-11d3-9a0c-0305e82c3301"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeSessionName(tt.input); got != tt.want {
+				t.Errorf("SanitizeSessionName(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeSessionNameTruncates(t *testing.T) {
+	pod := "batch-worker-" + strings.Repeat("é", 20) + "-" + strings.Repeat("x", 40)
+	long1 := pod + "-3f2504e0-4f89-11d3-9a0c-0305e82c3301"
+	long2 := pod + "-6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+
+	got1, got2 := SanitizeSessionName(long1), SanitizeSessionName(long2)
+	for _, got := range []string{got1, got2} {
+		if len(got) != maxSessionNameLength {
+			t.Errorf("SanitizeSessionName returned %d characters, want %d", len(got), maxSessionNameLength)
+		}
+		if err := validateSessionName(got); err != nil {
+			t.Errorf("SanitizeSessionName result rejected: %v", err)
+		}
+	}
+	if got1 == got2 {
+		t.Errorf("names differing past the limit both sanitized to %q", got1)
+	}
+	if SanitizeSessionName(long1) != got1 {
+		t.Error("SanitizeSessionName is not deterministic")
+	}
+	if got := aws.ToString(assumeRoleInput(t, WithSanitizedRoleSessionName(long1)).RoleSessionName); got != got1 {
+		t.Errorf("WithSanitizedRoleSessionName sent %q, want %q", got, got1)
+	}
+}
+
+func TestNewAssumeRoleConfSessionName(t *testing.T) {
+	tests := []struct {
+		name        string
+		sessionName string
+		wantErr     error
+		wantInMsg   string
+	}{
+		{name: "Empty", sessionName: ""},
+		{name: "Exactly64", sessionName: strings.Repeat("s", 64)},
+		{name: "TwoCharacters", sessionName: "ab"},
+		{name: "TooLong", sessionName: strings.Repeat("s", 65), wantErr: ErrInvalidSessionName, wantInMsg: "65 characters"},
+		{name: "OneCharacter", sessionName: "s", wantErr: ErrInvalidSessionName, wantInMsg: "1 characters"},
+		{name: "Space", sessionName: "alice smith", wantErr: ErrInvalidSessionName, wantInMsg: `[" "]`},
+		{name: "SeveralInvalid", sessionName: "a/b c/d", wantErr: ErrInvalidSessionName, wantInMsg: `["/" " "]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &awsconfigtest.FakeSTS{}
+			_, err := NewAssumeRoleConf(context.Background(), aws.Config{}, testRoleArn,
+				WithSTSClient(fake), WithSkipIdentityCheck(),
+				WithRoleSessionName("placeholder"), WithRoleSessionName(tt.sessionName))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewAssumeRoleConf error %v, want %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), tt.wantInMsg) {
+				t.Errorf("NewAssumeRoleConf error %q does not contain %q", err, tt.wantInMsg)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// SessionTokenProviderName is the Source of credentials returned by SessionTokenProvider
+const SessionTokenProviderName = SourcePrefix + "sessiontoken"
+
+var (
+	// ErrGetSessionToken is returned when GetSessionToken fails
+	ErrGetSessionToken = errors.New("Cannot get session token for passed aws.Config")
+	// ErrMFATokenProvider is returned when the MFA token provider fails; GetSessionToken
+	// with MFA cannot be renewed without a fresh token code.
+	ErrMFATokenProvider = errors.New("MFA token provider failed, session token cannot be renewed without MFA")
+)
+
+// GetSessionTokenAPIClient is a client capable of the STS GetSessionToken operation.
+type GetSessionTokenAPIClient interface {
+	GetSessionToken(ctx context.Context, params *sts.GetSessionTokenInput, optFns ...func(*sts.Options)) (*sts.GetSessionTokenOutput, error)
+}
+
+// SessionTokenOptions is the configurable options for SessionTokenProvider
+type SessionTokenOptions struct {
+	// Client implementation of the GetSessionToken operation
+	Client GetSessionTokenAPIClient
+
+	// Expiry duration of the STS credentials; STS assigns a default if unset
+	Duration time.Duration
+
+	// Serial number or ARN of the MFA device, if MFA is required
+	SerialNumber *string
+
+	// Provides the MFA token code whenever the session is refreshed
+	TokenProvider func() (string, error)
+}
+
+// SessionTokenProvider implements the aws.CredentialsProvider interface
+type SessionTokenProvider struct {
+	options SessionTokenOptions
+}
+
+// NewSessionTokenProvider initializes a new SessionTokenProvider instance
+func NewSessionTokenProvider(
+	client GetSessionTokenAPIClient,
+	opts ...func(*SessionTokenOptions),
+) *SessionTokenProvider {
+	o := SessionTokenOptions{
+		Client: client,
+	}
+	for _, fn := range opts {
+		fn(&o)
+	}
+	return &SessionTokenProvider{options: o}
+}
+
+// Retrieve implements the aws.CredentialsProvider interface method
+func (p *SessionTokenProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	input := &sts.GetSessionTokenInput{}
+	if p.options.Duration != 0 {
+		input.DurationSeconds = aws.Int32(int32(p.options.Duration / time.Second))
+	}
+	if p.options.SerialNumber != nil {
+		if p.options.TokenProvider == nil {
+			return aws.Credentials{}, fmt.Errorf("%w: no token provider set", ErrMFATokenProvider)
+		}
+		code, err := p.options.TokenProvider()
+		if err != nil {
+			return aws.Credentials{}, fmt.Errorf("%w: %w", ErrMFATokenProvider, err)
+		}
+		input.SerialNumber = p.options.SerialNumber
+		input.TokenCode = aws.String(code)
+	}
+
+	resp, err := p.options.Client.GetSessionToken(ctx, input)
+	if err != nil {
+		return aws.Credentials{}, fmt.Errorf("%w: %w", ErrGetSessionToken, err)
+	}
+	if resp == nil || resp.Credentials == nil {
+		return aws.Credentials{}, fmt.Errorf("%w: response has no credentials", ErrGetSessionToken)
+	}
+
+	return aws.Credentials{
+		AccessKeyID:     aws.ToString(resp.Credentials.AccessKeyId),
+		SecretAccessKey: aws.ToString(resp.Credentials.SecretAccessKey),
+		SessionToken:    aws.ToString(resp.Credentials.SessionToken),
+		Source:          SessionTokenProviderName,
+		CanExpire:       true,
+		Expires:         aws.ToTime(resp.Credentials.Expiration),
+	}, nil
+}
+
+// NewSessionTokenConf returns an aws.Config using temporary session credentials
+// obtained from the long-term credentials of cfg via GetSessionToken, with
+// optional SessionTokenOptions and ConfOptions, such as WithSTSFIPSEndpoint.
+func NewSessionTokenConf(
+	ctx context.Context,
+	cfg aws.Config,
+	opts ...Option,
+) (aws.Config, error) {
+	conf := newConfOptions(opts)
+	if err := validateSTSEndpoint(conf); err != nil {
+		return aws.Config{}, err
+	}
+
+	var client GetSessionTokenAPIClient
+	if sessionTokenClient, ok := conf.stsClient.(GetSessionTokenAPIClient); ok {
+		client = sessionTokenClient
+	} else {
+		client = sts.NewFromConfig(cfg, conf.stsOpts...)
+	}
+	provider := NewSessionTokenProvider(client, conf.sessionTokenOpts...)
+	cached := aws.NewCredentialsCache(withStats(provider), conf.cacheOpts...)
+
+	// Obtain the first session now so bad keys or MFA codes fail construction
+	if _, err := cached.Retrieve(ctx); err != nil {
+		return aws.Config{}, err
+	}
+
+	newCfg := cfg.Copy()
+	newCfg.Credentials = cached
+	return newCfg, nil
+}
+
+// WithSessionTokenDuration sets the session token duration
+func WithSessionTokenDuration(duration time.Duration) SessionTokenOption {
+	return func(o *SessionTokenOptions) {
+		o.Duration = duration
+	}
+}
+
+// WithSessionTokenMFA sets the MFA serial number and token provider
+func WithSessionTokenMFA(serial string, tokenProvider func() (string, error)) SessionTokenOption {
+	return func(o *SessionTokenOptions) {
+		o.SerialNumber = aws.String(serial)
+		o.TokenProvider = tokenProvider
+	}
+}
+
+// String implements the fmt.Stringer interface method, printing only whether
+// MFA is used, never the STS client
+func (p *SessionTokenProvider) String() string {
+	return fmt.Sprintf("SessionTokenProvider{Source: %q, MFA: %t}", SessionTokenProviderName, p.options.SerialNumber != nil)
+}
+
+// GoString implements the fmt.GoStringer interface method, so %#v prints no more than String
+func (p *SessionTokenProvider) GoString() string {
+	return "&awsconfig." + p.String()
+}
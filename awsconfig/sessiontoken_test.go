@@ -0,0 +1,151 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+// fakeSessionTokenClient answers GetSessionToken with out or err, recording inputs
+type fakeSessionTokenClient struct {
+	out    *sts.GetSessionTokenOutput
+	err    error
+	inputs []*sts.GetSessionTokenInput
+}
+
+// GetSessionToken implements the GetSessionTokenAPIClient interface method
+func (f *fakeSessionTokenClient) GetSessionToken(
+	_ context.Context,
+	params *sts.GetSessionTokenInput,
+	_ ...func(*sts.Options),
+) (*sts.GetSessionTokenOutput, error) {
+	f.inputs = append(f.inputs, params)
+	return f.out, f.err
+}
+
+func TestSessionTokenProvider(t *testing.T) {
+	expires := time.Now().Add(time.Hour).UTC()
+	valid := &sts.GetSessionTokenOutput{Credentials: &types.Credentials{
+		AccessKeyId:     aws.String("ASIAFAKESESSIONKEY"),
+		SecretAccessKey: aws.String("secret"),
+		SessionToken:    aws.String("token"),
+		Expiration:      aws.Time(expires),
+	}}
+	tokenErr := errors.New("no tty")
+
+	tests := []struct {
+		name      string
+		out       *sts.GetSessionTokenOutput
+		err       error
+		opts      []func(*SessionTokenOptions)
+		wantErr   error
+		wantCalls int
+		wantMFA   bool
+	}{
+		{name: "NoMFA", out: valid, wantCalls: 1},
+		{
+			name:      "MFA",
+			out:       valid,
+			opts:      []func(*SessionTokenOptions){WithSessionTokenMFA("arn:aws:iam::123456789012:mfa/alice", fixedToken("123456", nil))},
+			wantCalls: 1,
+			wantMFA:   true,
+		},
+		{
+			name:    "MFATokenProviderFails",
+			out:     valid,
+			opts:    []func(*SessionTokenOptions){WithSessionTokenMFA("arn:aws:iam::123456789012:mfa/alice", fixedToken("", tokenErr))},
+			wantErr: ErrMFATokenProvider,
+		},
+		{
+			name:    "MFANoTokenProvider",
+			out:     valid,
+			opts:    []func(*SessionTokenOptions){WithSessionTokenMFA("arn:aws:iam::123456789012:mfa/alice", nil)},
+			wantErr: ErrMFATokenProvider,
+		},
+		{name: "STSError", err: errors.New("InvalidClientTokenId"), wantErr: ErrGetSessionToken, wantCalls: 1},
+		{name: "NoCredentials", out: &sts.GetSessionTokenOutput{}, wantErr: ErrGetSessionToken, wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeSessionTokenClient{out: tt.out, err: tt.err}
+			opts := append([]func(*SessionTokenOptions){WithSessionTokenDuration(2 * time.Hour)}, tt.opts...)
+			creds, err := NewSessionTokenProvider(client, opts...).Retrieve(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Retrieve error %v, want %v", err, tt.wantErr)
+			}
+			if len(client.inputs) != tt.wantCalls {
+				t.Fatalf("GetSessionToken called %d times, want %d", len(client.inputs), tt.wantCalls)
+			}
+			if tt.wantCalls > 0 {
+				input := client.inputs[0]
+				if got := aws.ToInt32(input.DurationSeconds); got != 7200 {
+					t.Errorf("GetSessionToken DurationSeconds %d, want 7200", got)
+				}
+				if gotMFA := input.SerialNumber != nil && aws.ToString(input.TokenCode) == "123456"; gotMFA != tt.wantMFA {
+					t.Errorf("GetSessionToken serial %v code %v, want MFA %t", input.SerialNumber, input.TokenCode, tt.wantMFA)
+				}
+			}
+			if err != nil {
+				return
+			}
+			if creds.AccessKeyID != "ASIAFAKESESSIONKEY" || !creds.CanExpire || !creds.Expires.Equal(expires) ||
+				creds.Source != SessionTokenProviderName {
+				t.Errorf("Retrieve returned %+v", creds)
+			}
+		})
+	}
+}
+
+func TestNewSessionTokenConfRefreshPromptsMFA(t *testing.T) {
+	srv, cfg := newSTSServer(t)
+	var prompts int
+	tokens := func() (string, error) {
+		prompts++
+		if prompts > 2 {
+			return "", errors.New("prompt closed")
+		}
+		return "123456", nil
+	}
+
+	newCfg, err := NewSessionTokenConf(context.Background(), cfg,
+		WithSessionTokenMFA("arn:aws:iam::123456789012:mfa/alice", tokens))
+	if err != nil {
+		t.Fatalf("NewSessionTokenConf: %v", err)
+	}
+	req := srv.Requests()[0]
+	if req.Get("Action") != "GetSessionToken" || req.Get("TokenCode") != "123456" ||
+		req.Get("SerialNumber") != "arn:aws:iam::123456789012:mfa/alice" {
+		t.Errorf("GetSessionToken request %v", req)
+	}
+
+	cache := newCfg.Credentials.(*aws.CredentialsCache)
+	cache.Invalidate()
+	if _, err := newCfg.Credentials.Retrieve(context.Background()); err != nil {
+		t.Fatalf("Retrieve after refresh: %v", err)
+	}
+	cache.Invalidate()
+	if _, err := newCfg.Credentials.Retrieve(context.Background()); !errors.Is(err, ErrMFATokenProvider) {
+		t.Errorf("Retrieve error %v once token provider fails, want %v", err, ErrMFATokenProvider)
+	}
+	if prompts != 3 {
+		t.Errorf("token provider called %d times for 3 sessions, want 3", prompts)
+	}
+}
+
+func TestNewSessionTokenConfFails(t *testing.T) {
+	srv, cfg := newSTSServer(t)
+	srv.Errors = map[string]string{"GetSessionToken": "InvalidClientTokenId"}
+	if _, err := NewSessionTokenConf(context.Background(), cfg); !errors.Is(err, ErrGetSessionToken) {
+		t.Errorf("NewSessionTokenConf error %v, want %v", err, ErrGetSessionToken)
+	}
+}
+
+// fixedToken returns an MFA token provider always returning code and err
+func fixedToken(code string, err error) func() (string, error) {
+	return func() (string, error) { return code, err }
+}
@@ -0,0 +1,99 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	// ErrCacheLockTimeout is passed to the warning handler when the lock of a shared
+	// file cache isn't acquired within the lock timeout and is stolen
+	ErrCacheLockTimeout = errors.New("Timed out waiting for shared credential cache lock, refreshing without it")
+)
+
+const (
+	// DefaultCacheLockTimeout is how long WithSharedFileCache waits for another
+	// process to finish refreshing before refreshing anyway
+	DefaultCacheLockTimeout = 30 * time.Second
+
+	cacheLockPollInterval = 50 * time.Millisecond
+)
+
+// WithSharedFileCache is WithFileCache for hosts running many processes assuming
+// the same role. A process refreshing holds an advisory lock on the cache file,
+// flock on Unix and LockFileEx on Windows, and the others wait for it and read
+// the credentials it wrote rather than calling STS themselves. A lock held
+// longer than lockTimeout, or DefaultCacheLockTimeout when zero, is assumed to
+// belong to a hung process and ignored. Locks of crashed processes are released
+// by the operating system.
+func WithSharedFileCache(dir string, lockTimeout time.Duration) ConfOption {
+	return func(c *confOptions) {
+		c.fileCache = true
+		c.fileCacheDir = dir
+		c.fileCacheLockTimeout = lockTimeout
+		if lockTimeout <= 0 {
+			c.fileCacheLockTimeout = DefaultCacheLockTimeout
+		}
+		c.fileCacheShared = true
+	}
+}
+
+// NewSharedFileCache is NewFileCache with the advisory locking of
+// WithSharedFileCache; lockTimeout of zero selects DefaultCacheLockTimeout
+func NewSharedFileCache(dir string, lockTimeout time.Duration) CredentialCache {
+	if lockTimeout <= 0 {
+		lockTimeout = DefaultCacheLockTimeout
+	}
+	return &fileCache{dir: dir, lockTimeout: lockTimeout}
+}
+
+// Lock takes the lock of the file of key in a shared file cache; other file
+// caches aren't locked
+func (c *fileCache) Lock(ctx context.Context, key string) (unlock func(), err error) {
+	if c.lockTimeout <= 0 {
+		return func() {}, nil
+	}
+	filename, err := c.filename(key)
+	if err != nil {
+		return nil, err
+	}
+	return lockCacheFile(ctx, filename, c.lockTimeout)
+}
+
+// lockCacheFile takes the exclusive lock of filename, polling until timeout
+func lockCacheFile(ctx context.Context, filename string, timeout time.Duration) (unlock func(), err error) {
+	if err := os.MkdirAll(filepath.Dir(filename), 0o700); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFileCache, err)
+	}
+	f, err := os.OpenFile(filename+".lock", os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFileCache, err)
+	}
+
+	deadline := timeNow().Add(timeout)
+	for {
+		locked, err := tryLockFile(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%w: %w", ErrFileCache, err)
+		}
+		if locked {
+			return func() {
+				unlockFile(f)
+				f.Close()
+			}, nil
+		}
+		if !timeNow().Before(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("%w after %s", ErrCacheLockTimeout, timeout)
+		}
+		if err := sleepContext(ctx, cacheLockPollInterval); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !unix && !windows
+
+package awsconfig
+
+import "os"
+
+// tryLockFile always succeeds; this platform has no advisory file locks
+func tryLockFile(*os.File) (bool, error) {
+	return true, nil
+}
+
+// unlockFile does nothing; this platform has no advisory file locks
+func unlockFile(*os.File) error {
+	return nil
+}
@@ -0,0 +1,138 @@
+//go:build unix || windows
+
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigtest"
+)
+
+func TestSharedFileCacheConcurrentProcesses(t *testing.T) {
+	dir := t.TempDir()
+	fake := &awsconfigtest.FakeSTS{Duration: time.Hour}
+	client := &slowAssumeRoleSTS{FakeSTS: fake, delay: 100 * time.Millisecond}
+
+	// Each goroutine stands in for a worker process with its own cache handle
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cfg, err := NewAssumeRoleConf(context.Background(), aws.Config{}, testRoleArn,
+				WithSTSClient(client), WithSkipIdentityCheck(), WithSharedFileCache(dir, 0))
+			if err != nil {
+				t.Errorf("NewAssumeRoleConf: %v", err)
+				return
+			}
+			if _, err := cfg.Credentials.Retrieve(context.Background()); err != nil {
+				t.Errorf("Retrieve: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := len(fake.AssumeRoleInputs()); got != 1 {
+		t.Errorf("AssumeRole called %d times by 10 processes, want 1", got)
+	}
+}
+
+func TestSharedFileCacheLockHeld(t *testing.T) {
+	tests := []struct {
+		name        string
+		cancel      bool
+		wantErr     error
+		wantWarning error
+		wantAssumes int
+	}{
+		{name: "StolenAfterTimeout", wantWarning: ErrCacheLockTimeout, wantAssumes: 1},
+		{name: "CancelledWhileWaiting", cancel: true, wantErr: context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			fake := &awsconfigtest.FakeSTS{Duration: time.Hour}
+			var warnings []error
+			opts := []Option{
+				WithSTSClient(fake), WithSkipIdentityCheck(), WithSharedFileCache(dir, 200*time.Millisecond),
+				WithWarningHandler(func(err error) { warnings = append(warnings, err) }),
+			}
+
+			// A hung process holds the lock for the whole test
+			key, err := CLICacheKey(effectiveAssumeRoleOptions(testRoleArn, newConfOptions(opts)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			filename := filepath.Join(dir, key+".json")
+			unlock, err := lockCacheFile(context.Background(), filename, time.Second)
+			if err != nil {
+				t.Fatalf("lockCacheFile: %v", err)
+			}
+			defer unlock()
+
+			ctx := context.Background()
+			if tt.cancel {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, 50*time.Millisecond)
+				defer cancel()
+			}
+			cfg, err := NewAssumeRoleConf(context.Background(), aws.Config{}, testRoleArn, opts...)
+			if err != nil {
+				t.Fatalf("NewAssumeRoleConf: %v", err)
+			}
+			if _, err := cfg.Credentials.Retrieve(ctx); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Retrieve error %v, want %v", err, tt.wantErr)
+			}
+			if got := len(fake.AssumeRoleInputs()); got != tt.wantAssumes {
+				t.Errorf("AssumeRole called %d times, want %d", got, tt.wantAssumes)
+			}
+			if tt.wantWarning == nil && len(warnings) > 0 {
+				t.Errorf("unexpected warnings %v", warnings)
+			}
+			if tt.wantWarning != nil && (len(warnings) != 1 || !errors.Is(warnings[0], tt.wantWarning)) {
+				t.Errorf("warnings %v, want one %v", warnings, tt.wantWarning)
+			}
+		})
+	}
+}
+
+func TestSharedFileCacheLockFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "nested", "key.json")
+	unlock, err := lockCacheFile(context.Background(), filename, time.Second)
+	if err != nil {
+		t.Fatalf("lockCacheFile: %v", err)
+	}
+	if _, err := lockCacheFile(context.Background(), filename, 100*time.Millisecond); !errors.Is(err, ErrCacheLockTimeout) {
+		t.Errorf("second lock error %v, want %v", err, ErrCacheLockTimeout)
+	}
+	unlock()
+	unlock, err = lockCacheFile(context.Background(), filename, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("lock after unlock: %v", err)
+	}
+	unlock()
+}
+
+// slowAssumeRoleSTS takes delay to answer AssumeRole, as STS does under load
+type slowAssumeRoleSTS struct {
+	*awsconfigtest.FakeSTS
+	delay time.Duration
+}
+
+// AssumeRole implements the STSClient interface method
+func (c *slowAssumeRoleSTS) AssumeRole(
+	ctx context.Context,
+	params *sts.AssumeRoleInput,
+	optFns ...func(*sts.Options),
+) (*sts.AssumeRoleOutput, error) {
+	time.Sleep(c.delay)
+	return c.FakeSTS.AssumeRole(ctx, params, optFns...)
+}
@@ -0,0 +1,161 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+var (
+	// ErrSharedCredentialsFile is returned when a shared credentials file cannot be updated
+	ErrSharedCredentialsFile = errors.New("Cannot update shared credentials file")
+	// ErrInvalidProfileName is returned when a profile name cannot be written as an INI section
+	ErrInvalidProfileName = errors.New("Invalid shared credentials profile name")
+)
+
+const (
+	// sharedCredentialsExpiryComment starts the expiry comment of WithExpirationComment
+	sharedCredentialsExpiryComment = "# Expires "
+)
+
+// sharedCredentialsKeys are the keys of a profile replaced by WriteSharedCredentials
+var sharedCredentialsKeys = map[string]bool{
+	"aws_access_key_id":     true,
+	"aws_secret_access_key": true,
+	"aws_session_token":     true,
+	"aws_security_token":    true,
+}
+
+// WithExpirationComment makes WriteSharedCredentials precede the keys of
+// temporary credentials with a comment giving their expiry
+func WithExpirationComment() ConfOption {
+	return func(c *confOptions) {
+		c.expirationComment = true
+	}
+}
+
+// WriteSharedCredentials retrieves the credentials of cfg and writes them to
+// profile of the shared credentials file at path, for tools that can only read
+// that file. The keys of profile are replaced in place, keeping its other keys,
+// the other profiles, and comments; the file is created readable only by the
+// current user when missing. Writers hold a lock file next to path and replace
+// the file atomically.
+func WriteSharedCredentials(ctx context.Context, cfg aws.Config, path, profile string, opts ...Option) error {
+	conf := newConfOptions(opts)
+	if profile == "" || strings.ContainsAny(profile, "[]\r\n") {
+		return fmt.Errorf("%w, got %q", ErrInvalidProfileName, profile)
+	}
+	if cfg.Credentials == nil {
+		return ErrNilCredentials
+	}
+	creds, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrRetrieveCredentials, err)
+	}
+
+	keys := make([]string, 0, 4)
+	if creds.CanExpire && conf.expirationComment {
+		keys = append(keys, sharedCredentialsExpiryComment+creds.Expires.UTC().Format(time.RFC3339))
+	}
+	keys = append(keys,
+		"aws_access_key_id = "+creds.AccessKeyID,
+		"aws_secret_access_key = "+creds.SecretAccessKey,
+	)
+	if creds.SessionToken != "" {
+		keys = append(keys, "aws_session_token = "+creds.SessionToken)
+	}
+
+	unlock, err := lockCacheFile(ctx, path, DefaultCacheLockTimeout)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrSharedCredentialsFile, err)
+	}
+	defer unlock()
+
+	mode := fs.FileMode(0o600)
+	var lines []string
+	b, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if info, err := os.Stat(path); err == nil {
+			mode = info.Mode().Perm()
+		}
+		lines = strings.Split(strings.TrimRight(strings.ReplaceAll(string(b), "\r\n", "\n"), "\n"), "\n")
+	case !errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("%w: %w", ErrSharedCredentialsFile, err)
+	}
+
+	content := strings.Join(replaceProfileKeys(lines, profile, keys), "\n") + "\n"
+	if err := writeFileAtomic(path, []byte(content), mode); err != nil {
+		return fmt.Errorf("%w: %w", ErrSharedCredentialsFile, err)
+	}
+	return nil
+}
+
+// replaceProfileKeys returns the INI lines with the credential keys of profile
+// replaced by keys, appending the profile when missing
+func replaceProfileKeys(lines []string, profile string, keys []string) []string {
+	out := make([]string, 0, len(lines)+len(keys)+2)
+	found, inProfile := false, false
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			inProfile = strings.TrimSpace(trimmed[1:len(trimmed)-1]) == profile
+			out = append(out, line)
+			if inProfile && !found {
+				found = true
+				out = append(out, keys...)
+			}
+			continue
+		}
+		if inProfile {
+			if strings.HasPrefix(trimmed, sharedCredentialsExpiryComment) {
+				continue
+			}
+			if key, _, ok := strings.Cut(trimmed, "="); ok && sharedCredentialsKeys[strings.ToLower(strings.TrimSpace(key))] {
+				continue
+			}
+		}
+		out = append(out, line)
+	}
+	if !found {
+		if len(out) > 0 && strings.TrimSpace(out[len(out)-1]) != "" {
+			out = append(out, "")
+		}
+		out = append(out, "["+profile+"]")
+		out = append(out, keys...)
+	}
+	return out
+}
+
+// writeFileAtomic replaces filename with b through a temporary file in the same
+// directory, so readers never see a partial file
+func writeFileAtomic(filename string, b []byte, mode fs.FileMode) error {
+	dir := filepath.Dir(filename)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(filename)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
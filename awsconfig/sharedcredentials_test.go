@@ -0,0 +1,192 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+func TestWriteSharedCredentials(t *testing.T) {
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	session := aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return aws.Credentials{
+			AccessKeyID: "ASIAEXAMPLE", SecretAccessKey: "secret", SessionToken: "token",
+			CanExpire: true, Expires: expires,
+		}, nil
+	})
+	static := credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "static-secret", "")
+	existing := strings.Join([]string{
+		"# Managed by hand",
+		"[default]",
+		"aws_access_key_id = AKIDDEFAULT",
+		"aws_secret_access_key = default-secret",
+		"",
+		"[assumed]",
+		"# Expires 2020-01-01T00:00:00Z",
+		"region = eu-west-1",
+		"aws_access_key_id = ASIAOLD",
+		"AWS_Secret_Access_Key=old-secret",
+		"aws_security_token = old-token",
+		"",
+		"[other]",
+		"aws_access_key_id = AKIDOTHER",
+		"",
+	}, "\r\n")
+
+	tests := []struct {
+		name     string
+		existing string
+		provider aws.CredentialsProvider
+		profile  string
+		opts     []Option
+		want     string
+	}{
+		{
+			name:     "NewFile",
+			provider: session,
+			profile:  "assumed",
+			want:     "[assumed]\naws_access_key_id = ASIAEXAMPLE\naws_secret_access_key = secret\naws_session_token = token\n",
+		},
+		{
+			name:     "UpdateInPlace",
+			existing: existing,
+			provider: session,
+			profile:  "assumed",
+			opts:     []Option{WithExpirationComment()},
+			want: strings.Join([]string{
+				"# Managed by hand",
+				"[default]",
+				"aws_access_key_id = AKIDDEFAULT",
+				"aws_secret_access_key = default-secret",
+				"",
+				"[assumed]",
+				"# Expires 2030-01-02T03:04:05Z",
+				"aws_access_key_id = ASIAEXAMPLE",
+				"aws_secret_access_key = secret",
+				"aws_session_token = token",
+				"region = eu-west-1",
+				"",
+				"[other]",
+				"aws_access_key_id = AKIDOTHER",
+				"",
+			}, "\n"),
+		},
+		{
+			name:     "AppendProfile",
+			existing: "[default]\naws_access_key_id = AKIDDEFAULT\n",
+			provider: static,
+			profile:  "static",
+			opts:     []Option{WithExpirationComment()},
+			want:     "[default]\naws_access_key_id = AKIDDEFAULT\n\n[static]\naws_access_key_id = AKIDEXAMPLE\naws_secret_access_key = static-secret\n",
+		},
+		{
+			name:     "SpacedSection",
+			existing: "[ assumed ]\naws_access_key_id = ASIAOLD\n",
+			provider: static,
+			profile:  "assumed",
+			want:     "[ assumed ]\naws_access_key_id = AKIDEXAMPLE\naws_secret_access_key = static-secret\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "credentials")
+			if tt.existing != "" {
+				if err := os.WriteFile(path, []byte(tt.existing), 0o640); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := WriteSharedCredentials(context.Background(), aws.Config{Credentials: tt.provider}, path, tt.profile, tt.opts...); err != nil {
+				t.Fatalf("WriteSharedCredentials: %v", err)
+			}
+			b, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(b); got != tt.want {
+				t.Errorf("file is\n%s\nwant\n%s", got, tt.want)
+			}
+
+			// Existing files keep their mode; new ones are readable only by the user
+			wantMode := os.FileMode(0o600)
+			if tt.existing != "" {
+				wantMode = 0o640
+			}
+			if info, err := os.Stat(path); err != nil || info.Mode().Perm() != wantMode {
+				t.Errorf("file mode %v (%v), want %v", info.Mode().Perm(), err, wantMode)
+			}
+		})
+	}
+}
+
+func TestWriteSharedCredentialsErrors(t *testing.T) {
+	retrieveErr := errors.New("no credentials")
+	static := credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", "")
+
+	tests := []struct {
+		name     string
+		provider aws.CredentialsProvider
+		profile  string
+		wantErr  error
+	}{
+		{name: "EmptyProfile", provider: static, wantErr: ErrInvalidProfileName},
+		{name: "BracketProfile", provider: static, profile: "a]b", wantErr: ErrInvalidProfileName},
+		{name: "NewlineProfile", provider: static, profile: "a\nb", wantErr: ErrInvalidProfileName},
+		{name: "NilCredentials", profile: "assumed", wantErr: ErrNilCredentials},
+		{
+			name:    "RetrieveFails",
+			profile: "assumed",
+			provider: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+				return aws.Credentials{}, retrieveErr
+			}),
+			wantErr: retrieveErr,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "credentials")
+			err := WriteSharedCredentials(context.Background(), aws.Config{Credentials: tt.provider}, path, tt.profile)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("WriteSharedCredentials error %v, want %v", err, tt.wantErr)
+			}
+			if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("file written after failing: %v", err)
+			}
+		})
+	}
+}
+
+func TestWriteSharedCredentialsConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	cfg := aws.Config{Credentials: credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", "")}
+
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := WriteSharedCredentials(context.Background(), cfg, path, fmt.Sprintf("profile-%d", i)); err != nil {
+				t.Errorf("WriteSharedCredentials: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 10 {
+		if !strings.Contains(string(b), fmt.Sprintf("[profile-%d]\n", i)) {
+			t.Errorf("profile-%d lost by a concurrent writer", i)
+		}
+	}
+}
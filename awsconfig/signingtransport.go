@@ -0,0 +1,127 @@
+package awsconfig
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+const (
+	// emptyPayloadHash is the SHA-256 of an empty request body
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	// unsignedPayload is the payload hash of requests signed without their body
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// WithUnsignedPayload makes NewSigningRoundTripper sign requests without
+// hashing their body, for large or streamed bodies the service accepts unsigned
+func WithUnsignedPayload() ConfOption {
+	return func(c *confOptions) {
+		c.unsignedPayload = true
+	}
+}
+
+// NewSigningRoundTripper returns an http.RoundTripper signing requests with
+// SigV4 for service in region, or the region of cfg when empty, before passing
+// them to next, or http.DefaultTransport when nil. Credentials are retrieved
+// from cfg for every request, so cached credentials are reused and refreshed
+// as usual. The body is read to hash it, through GetBody when set, so a
+// request can be sent, and signed, again.
+func NewSigningRoundTripper(cfg aws.Config, service, region string, next http.RoundTripper, opts ...Option) http.RoundTripper {
+	conf := newConfOptions(opts)
+	if region == "" {
+		region = cfg.Region
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &signingRoundTripper{
+		credentials:     cfg.Credentials,
+		service:         service,
+		region:          region,
+		unsignedPayload: conf.unsignedPayload,
+		signer:          v4.NewSigner(),
+		next:            next,
+	}
+}
+
+// signingRoundTripper is the http.RoundTripper of NewSigningRoundTripper
+type signingRoundTripper struct {
+	credentials     aws.CredentialsProvider
+	service         string
+	region          string
+	unsignedPayload bool
+	signer          *v4.Signer
+	next            http.RoundTripper
+}
+
+// RoundTrip implements the http.RoundTripper interface method
+func (t *signingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	signed, payloadHash, err := t.prepare(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.sign(signed, payloadHash); err != nil {
+		// The RoundTripper contract is to close the body even on errors
+		if signed.Body != nil {
+			signed.Body.Close()
+		}
+		return nil, err
+	}
+	return t.next.RoundTrip(signed)
+}
+
+// sign signs req with the current credentials
+func (t *signingRoundTripper) sign(req *http.Request, payloadHash string) error {
+	if t.credentials == nil {
+		return ErrNilCredentials
+	}
+	creds, err := t.credentials.Retrieve(req.Context())
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrRetrieveCredentials, err)
+	}
+	// S3 requires the payload hash header, and accepts unsigned payloads through it
+	if t.unsignedPayload || t.service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+	return t.signer.SignHTTP(req.Context(), creds, req, payloadHash, t.service, t.region, timeNow())
+}
+
+// prepare returns a copy of req to sign, with a body that can be read again,
+// and the hash of its payload; the body of req is closed when it is replaced
+func (t *signingRoundTripper) prepare(req *http.Request) (*http.Request, string, error) {
+	signed := req.Clone(req.Context())
+	if req.Body == nil || req.Body == http.NoBody {
+		return signed, emptyPayloadHash, nil
+	}
+	if t.unsignedPayload {
+		return signed, unsignedPayload, nil
+	}
+
+	body := req.Body
+	if req.GetBody != nil {
+		req.Body.Close()
+		var err error
+		if body, err = req.GetBody(); err != nil {
+			return nil, "", err
+		}
+	}
+	b, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return nil, "", err
+	}
+	hash := sha256.Sum256(b)
+	signed.Body = io.NopCloser(bytes.NewReader(b))
+	signed.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(b)), nil
+	}
+	signed.ContentLength = int64(len(b))
+	return signed, hex.EncodeToString(hash[:]), nil
+}
@@ -0,0 +1,209 @@
+package awsconfig
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+func TestSigningRoundTripper(t *testing.T) {
+	stubClock(t)
+	retrieve, calls := countingRetrieve(time.Hour)
+	creds, _ := retrieve(context.Background())
+	calls.Store(0)
+
+	tests := []struct {
+		name        string
+		method      string
+		body        string
+		noGetBody   bool
+		service     string
+		region      string
+		opts        []Option
+		wantRegion  string
+		wantPayload string
+	}{
+		{name: "NoBody", method: http.MethodGet, service: "execute-api", wantRegion: "eu-west-1"},
+		{name: "Body", method: http.MethodPost, body: `{"query":1}`, service: "es", wantRegion: "eu-west-1"},
+		{name: "BodyWithoutGetBody", method: http.MethodPut, body: `{"query":1}`, noGetBody: true, service: "es", region: "us-east-2", wantRegion: "us-east-2"},
+		{
+			name: "UnsignedPayload", method: http.MethodPut, body: "large object", service: "s3", opts: []Option{WithUnsignedPayload()},
+			wantRegion: "eu-west-1", wantPayload: unsignedPayload,
+		},
+		{name: "S3PayloadHeader", method: http.MethodPut, body: "object", service: "s3", wantRegion: "eu-west-1", wantPayload: sha256Hex("object")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var bodies []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				mu.Lock()
+				bodies = append(bodies, string(body))
+				mu.Unlock()
+				if err := verifySigV4(r, body, creds, tt.service, tt.wantRegion); err != nil {
+					t.Error(err)
+				}
+				if got := r.Header.Get("X-Amz-Content-Sha256"); got != tt.wantPayload {
+					t.Errorf("X-Amz-Content-Sha256 = %q, want %q", got, tt.wantPayload)
+				}
+			}))
+			defer srv.Close()
+
+			cfg := aws.Config{Region: "eu-west-1", Credentials: aws.CredentialsProviderFunc(retrieve)}
+			client := &http.Client{Transport: NewSigningRoundTripper(cfg, tt.service, tt.region, nil, tt.opts...)}
+			var body io.Reader
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+				if tt.noGetBody {
+					// Hides the body type http.NewRequest sets GetBody for
+					body = io.MultiReader(body)
+				}
+			}
+			req, err := http.NewRequest(tt.method, srv.URL+"/path?b=2&a=1", body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Sending the request twice re-signs it with its whole body
+			for range 2 {
+				resp, err := client.Do(req)
+				if err != nil {
+					t.Fatalf("Do: %v", err)
+				}
+				resp.Body.Close()
+				if req.GetBody == nil {
+					break
+				}
+				if req.Body, err = req.GetBody(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, got := range bodies {
+				if got != tt.body {
+					t.Errorf("server received body %q, want %q", got, tt.body)
+				}
+			}
+			if got := calls.Swap(0); int(got) != len(bodies) {
+				t.Errorf("credentials retrieved %d times for %d requests", got, len(bodies))
+			}
+		})
+	}
+}
+
+func TestSigningRoundTripperErrors(t *testing.T) {
+	retrieveErr := errors.New("no credentials")
+
+	tests := []struct {
+		name     string
+		provider aws.CredentialsProvider
+		wantErr  error
+	}{
+		{name: "NilCredentials", wantErr: ErrNilCredentials},
+		{
+			name: "RetrieveFails",
+			provider: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+				return aws.Credentials{}, retrieveErr
+			}),
+			wantErr: retrieveErr,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			next := roundTripFunc(func(*http.Request) (*http.Response, error) {
+				called = true
+				return nil, errors.New("unexpected request")
+			})
+			rt := NewSigningRoundTripper(aws.Config{Credentials: tt.provider}, "es", "eu-west-1", next)
+			body := &closeRecorder{Reader: strings.NewReader("body")}
+			req, err := http.NewRequest(http.MethodPost, "https://search.example.com/", body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := rt.RoundTrip(req); !errors.Is(err, tt.wantErr) {
+				t.Errorf("RoundTrip error %v, want %v", err, tt.wantErr)
+			}
+			if called {
+				t.Error("unsigned request passed on")
+			}
+			if !body.closed {
+				t.Error("request body left open")
+			}
+		})
+	}
+}
+
+// verifySigV4 signs a copy of the received request r, limited to the headers
+// it signed, and compares the signatures
+func verifySigV4(r *http.Request, body []byte, creds aws.Credentials, service, region string) error {
+	auth := r.Header.Get("Authorization")
+	_, signedHeaders, ok := strings.Cut(auth, "SignedHeaders=")
+	if !ok {
+		return errors.New("request has no SigV4 Authorization header: " + auth)
+	}
+	signedHeaders, _, _ = strings.Cut(signedHeaders, ",")
+	signedAt, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		return errors.New("X-Amz-Date is not a SigV4 timestamp: " + r.Header.Get("X-Amz-Date"))
+	}
+
+	req, err := http.NewRequest(r.Method, "http://"+r.Host+r.RequestURI, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for _, key := range strings.Split(signedHeaders, ";") {
+		if key != "host" && key != "x-amz-date" && key != "x-amz-security-token" {
+			req.Header[http.CanonicalHeaderKey(key)] = r.Header.Values(key)
+		}
+	}
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		payloadHash = sha256Hex(string(body))
+	}
+	if err := v4.NewSigner().SignHTTP(context.Background(), creds, req, payloadHash, service, region, signedAt); err != nil {
+		return err
+	}
+	if want := req.Header.Get("Authorization"); auth != want {
+		return errors.New("Authorization " + auth + " does not verify, want " + want)
+	}
+	return nil
+}
+
+// sha256Hex returns the hex SHA-256 of s
+func sha256Hex(s string) string {
+	hash := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(hash[:])
+}
+
+// roundTripFunc is an http.RoundTripper calling itself
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip implements the http.RoundTripper interface method
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// closeRecorder records whether it was closed
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+// Close implements the io.Closer interface method
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
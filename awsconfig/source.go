@@ -0,0 +1,74 @@
+package awsconfig
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// SourcePrefix starts the Source of credentials returned by the providers of
+// this package. It is followed by the kind of provider and, where known, what
+// the credentials were retrieved from in parentheses, such as
+// "mostly-harmless/assumerole(arn:aws:iam::123456789012:role/Foo)".
+// ChainProvider and FallbackProvider pass on the Source of the provider
+// the credentials came from. With WithSTSFallbackRegions the Source ends with
+// the STS region that served the credentials, such as "...role/Foo)@us-west-2".
+const SourcePrefix = "mostly-harmless/"
+
+// WithRedactedSource leaves role ARNs and account IDs out of the Source of
+// assumed credentials, so it only names the kind of provider
+func WithRedactedSource() ConfOption {
+	return func(c *confOptions) {
+		c.redactSource = true
+	}
+}
+
+// sourceLabel returns the Source of credentials of the provider called name,
+// retrieved from detail when set
+func sourceLabel(name, detail string) string {
+	if detail == "" {
+		return name
+	}
+	return name + "(" + detail + ")"
+}
+
+// roleSourceLabel returns the Source of credentials of the provider called name
+// for role, unless WithRedactedSource is set
+func roleSourceLabel(conf *confOptions, name, role string) string {
+	if conf.redactSource {
+		return name
+	}
+	return sourceLabel(name, role)
+}
+
+// withSource stamps source on the credentials returned by provider. Cache
+// backends such as NewFileCache cannot persist the Source, so it is stamped
+// outside of them.
+func withSource(source string, provider aws.CredentialsProvider) aws.CredentialsProvider {
+	return &sourceProvider{source: source, provider: provider}
+}
+
+// sourceProvider sets the Source of the credentials of the wrapped provider
+type sourceProvider struct {
+	source   string
+	provider aws.CredentialsProvider
+}
+
+// Retrieve implements the aws.CredentialsProvider interface method
+func (p *sourceProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	var region string
+	creds, err := p.provider.Retrieve(withServingRegion(ctx, &region))
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+	creds.Source = p.source
+	if region != "" {
+		creds.Source += "@" + region
+	}
+	return creds, nil
+}
+
+// IsCredentialsProvider reports whether the wrapped provider is of the type of target
+func (p *sourceProvider) IsCredentialsProvider(target aws.CredentialsProvider) bool {
+	return aws.IsCredentialsProvider(p.provider, target)
+}
@@ -0,0 +1,190 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// SSMParameterProviderName prefixes the Source of credentials returned by
+// SSMParameterProvider, followed by the parameter name or path
+const SSMParameterProviderName = SourcePrefix + "ssmparameter"
+
+var (
+	// ErrParameterNotFound is returned when a credentials parameter does not exist
+	ErrParameterNotFound = errors.New("Credentials parameter not found")
+	// ErrParameterAccessDenied is returned when a credentials parameter, or its KMS key, cannot be read
+	ErrParameterAccessDenied = errors.New("Access denied to credentials parameter")
+	// ErrGetParameter is returned when a credentials parameter cannot be read
+	ErrGetParameter = errors.New("Cannot get credentials parameter")
+)
+
+// Names of the parameters under the path of WithSSMParameterPath
+const (
+	SSMAccessKeyIDParameter     = "access_key_id"
+	SSMSecretAccessKeyParameter = "secret_access_key"
+	SSMSessionTokenParameter    = "session_token"
+)
+
+// SSMAPIClient is a client capable of the SSM GetParameter and GetParameters operations
+type SSMAPIClient interface {
+	GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error)
+	GetParameters(ctx context.Context, params *ssm.GetParametersInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersOutput, error)
+}
+
+// WithSSMClient makes NewSSMParameterConf use client instead of building one from the base config
+func WithSSMClient(client SSMAPIClient) ConfOption {
+	return func(c *confOptions) {
+		c.ssmClient = client
+	}
+}
+
+// WithSSMParameterPath reads credentials from the access_key_id,
+// secret_access_key, and optional session_token parameters under the
+// parameter name, in one GetParameters call, instead of a JSON parameter
+func WithSSMParameterPath() ConfOption {
+	return func(c *confOptions) {
+		c.ssmParameterPath = true
+	}
+}
+
+// SSMParameterProvider implements the aws.CredentialsProvider interface by
+// reading credentials from SSM Parameter Store SecureString parameters: one
+// holding the credentials JSON documented on SecretsManagerProvider, or, with
+// WithSSMParameterPath, one parameter per key. Parameters don't expire, so
+// use WithForcedTTL to refresh credentials without an Expiration.
+type SSMParameterProvider struct {
+	client    SSMAPIClient
+	name      string
+	path      bool
+	forcedTTL time.Duration
+}
+
+// NewSSMParameterProvider initializes a new SSMParameterProvider reading the parameter name
+func NewSSMParameterProvider(client SSMAPIClient, name string, opts ...Option) *SSMParameterProvider {
+	conf := newConfOptions(opts)
+	return &SSMParameterProvider{
+		client:    client,
+		name:      name,
+		path:      conf.ssmParameterPath,
+		forcedTTL: conf.forcedTTL,
+	}
+}
+
+// Retrieve implements the aws.CredentialsProvider interface method
+func (p *SSMParameterProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	var creds aws.Credentials
+	var err error
+	if p.path {
+		creds, err = p.retrievePath(ctx)
+	} else {
+		creds, err = p.retrieveJSON(ctx)
+	}
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+	creds.Source = sourceLabel(SSMParameterProviderName, p.name)
+	return withForcedTTL(creds, p.forcedTTL), nil
+}
+
+// retrieveJSON reads the credentials JSON parameter
+func (p *SSMParameterProvider) retrieveJSON(ctx context.Context) (aws.Credentials, error) {
+	resp, err := p.client.GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(p.name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return aws.Credentials{}, parameterError(p.name, err)
+	}
+	if resp.Parameter == nil {
+		return aws.Credentials{}, fmt.Errorf("%w %q", ErrParameterNotFound, p.name)
+	}
+	creds, err := parseCredentialsJSON([]byte(aws.ToString(resp.Parameter.Value)))
+	if err != nil {
+		return aws.Credentials{}, fmt.Errorf("%w in parameter %q: %w", ErrParseCredentialsJSON, p.name, err)
+	}
+	return creds, nil
+}
+
+// retrievePath reads the parameters under the path in one call
+func (p *SSMParameterProvider) retrievePath(ctx context.Context) (aws.Credentials, error) {
+	prefix := strings.TrimSuffix(p.name, "/") + "/"
+	accessKeyID := prefix + SSMAccessKeyIDParameter
+	secretAccessKey := prefix + SSMSecretAccessKeyParameter
+	sessionToken := prefix + SSMSessionTokenParameter
+	resp, err := p.client.GetParameters(ctx, &ssm.GetParametersInput{
+		Names:          []string{accessKeyID, secretAccessKey, sessionToken},
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return aws.Credentials{}, parameterError(p.name, err)
+	}
+
+	values := make(map[string]string, len(resp.Parameters))
+	for _, param := range resp.Parameters {
+		values[aws.ToString(param.Name)] = aws.ToString(param.Value)
+	}
+	// The session token is optional, so only the keys have to exist
+	for _, name := range []string{accessKeyID, secretAccessKey} {
+		if _, ok := values[name]; !ok {
+			return aws.Credentials{}, fmt.Errorf("%w %q", ErrParameterNotFound, name)
+		}
+	}
+	return aws.Credentials{
+		AccessKeyID:     values[accessKeyID],
+		SecretAccessKey: values[secretAccessKey],
+		SessionToken:    values[sessionToken],
+	}, nil
+}
+
+// parameterError wraps an SSM error with ErrParameterNotFound, ErrParameterAccessDenied, or ErrGetParameter
+func parameterError(name string, err error) error {
+	var notFound *ssmtypes.ParameterNotFound
+	var invalidKey *ssmtypes.InvalidKeyId
+	switch {
+	case errors.As(err, &notFound):
+		return fmt.Errorf("%w %q: %w", ErrParameterNotFound, name, err)
+	case IsAccessDenied(err), errors.As(err, &invalidKey):
+		return fmt.Errorf("%w %q: %w", ErrParameterAccessDenied, name, err)
+	default:
+		return fmt.Errorf("%w %q: %w", ErrGetParameter, name, err)
+	}
+}
+
+// NewSSMParameterConf returns an aws.Config using the credentials stored in the
+// SSM parameter name, cached until they expire; see SSMParameterProvider
+func NewSSMParameterConf(
+	_ context.Context,
+	cfg aws.Config,
+	name string,
+	opts ...Option,
+) (aws.Config, error) {
+	conf := newConfOptions(opts)
+
+	client := conf.ssmClient
+	if client == nil {
+		client = ssm.NewFromConfig(cfg)
+	}
+	provider := NewSSMParameterProvider(client, name, opts...)
+
+	newCfg := cfg.Copy()
+	newCfg.Credentials = aws.NewCredentialsCache(withStats(provider), conf.cacheOpts...)
+	return newCfg, nil
+}
+
+// String implements the fmt.Stringer interface method, printing only the
+// parameter name and whether it is a path
+func (p *SSMParameterProvider) String() string {
+	return fmt.Sprintf("SSMParameterProvider{Source: %q, Path: %t}", sourceLabel(SSMParameterProviderName, p.name), p.path)
+}
+
+// GoString implements the fmt.GoStringer interface method, so %#v prints no more than String
+func (p *SSMParameterProvider) GoString() string {
+	return "&awsconfig." + p.String()
+}
@@ -0,0 +1,229 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/aws/smithy-go"
+)
+
+func TestSSMParameterProvider(t *testing.T) {
+	tests := []struct {
+		name      string
+		parameter string
+		params    map[string]string
+		opts      []Option
+		ttl       time.Duration
+		want      aws.Credentials
+	}{
+		{
+			name:      "JSON",
+			parameter: "/creds/json",
+			params:    map[string]string{"/creds/json": `{"aws_access_key_id": "AKIAEXAMPLE", "aws_secret_access_key": "secret"}`},
+			want:      aws.Credentials{AccessKeyID: "AKIAEXAMPLE", SecretAccessKey: "secret"},
+		},
+		{
+			name:      "JSONExpiration",
+			parameter: "/creds/json",
+			params:    map[string]string{"/creds/json": `{"AccessKeyId": "ASIAEXAMPLE", "SecretAccessKey": "secret", "SessionToken": "token", "Expiration": "2030-01-02T03:04:05Z"}`},
+			opts:      []Option{WithForcedTTL(time.Hour)},
+			want: aws.Credentials{AccessKeyID: "ASIAEXAMPLE", SecretAccessKey: "secret", SessionToken: "token",
+				CanExpire: true, Expires: time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)},
+		},
+		{
+			name:      "JSONForcedTTL",
+			parameter: "/creds/json",
+			params:    map[string]string{"/creds/json": `{"AccessKeyID": "AKIAEXAMPLE", "SecretAccessKey": "secret"}`},
+			opts:      []Option{WithForcedTTL(15 * time.Minute)},
+			ttl:       15 * time.Minute,
+			want:      aws.Credentials{AccessKeyID: "AKIAEXAMPLE", SecretAccessKey: "secret", CanExpire: true},
+		},
+		{
+			name:      "Path",
+			parameter: "/creds/path/",
+			params: map[string]string{
+				"/creds/path/access_key_id":     "ASIAEXAMPLE",
+				"/creds/path/secret_access_key": "secret",
+				"/creds/path/session_token":     "token",
+			},
+			opts: []Option{WithSSMParameterPath()},
+			want: aws.Credentials{AccessKeyID: "ASIAEXAMPLE", SecretAccessKey: "secret", SessionToken: "token"},
+		},
+		{
+			name:      "PathWithoutSessionToken",
+			parameter: "/creds/path",
+			params: map[string]string{
+				"/creds/path/access_key_id":     "AKIAEXAMPLE",
+				"/creds/path/secret_access_key": "secret",
+			},
+			opts: []Option{WithSSMParameterPath(), WithForcedTTL(time.Hour)},
+			ttl:  time.Hour,
+			want: aws.Credentials{AccessKeyID: "AKIAEXAMPLE", SecretAccessKey: "secret", CanExpire: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubClock(t)
+			client := &stubSSM{params: tt.params}
+			creds, err := NewSSMParameterProvider(client, tt.parameter, tt.opts...).Retrieve(context.Background())
+			if err != nil {
+				t.Fatalf("Retrieve: %v", err)
+			}
+			tt.want.Source = sourceLabel(SSMParameterProviderName, tt.parameter)
+			if tt.ttl > 0 {
+				tt.want.Expires = timeNow().Add(tt.ttl)
+			}
+			if !creds.Expires.Equal(tt.want.Expires) {
+				t.Errorf("Expires %v, want %v", creds.Expires, tt.want.Expires)
+			}
+			creds.Expires, tt.want.Expires = time.Time{}, time.Time{}
+			if creds != tt.want {
+				t.Errorf("Retrieve returned %+v, want %+v", creds, tt.want)
+			}
+			if len(client.calls) != 1 {
+				t.Errorf("SSM calls %v, want one", client.calls)
+			}
+			if !client.decrypted {
+				t.Error("parameters were read without decryption")
+			}
+		})
+	}
+}
+
+func TestSSMParameterProviderErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		client  *stubSSM
+		path    bool
+		wantErr error
+	}{
+		{
+			name:    "NotFound",
+			client:  &stubSSM{},
+			wantErr: ErrParameterNotFound,
+		},
+		{
+			name:    "AccessDenied",
+			client:  &stubSSM{err: &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "not authorized"}},
+			wantErr: ErrParameterAccessDenied,
+		},
+		{
+			name:    "InvalidKeyId",
+			client:  &stubSSM{err: &ssmtypes.InvalidKeyId{Message: aws.String("kms key disabled")}},
+			wantErr: ErrParameterAccessDenied,
+		},
+		{
+			name:    "Throttled",
+			client:  &stubSSM{err: &smithy.GenericAPIError{Code: "ThrottlingException"}},
+			wantErr: ErrGetParameter,
+		},
+		{
+			name:    "MalformedJSON",
+			client:  &stubSSM{params: map[string]string{"/creds": "AKIAEXAMPLE:secret"}},
+			wantErr: ErrParseCredentialsJSON,
+		},
+		{
+			name:    "PathMissingSecretAccessKey",
+			client:  &stubSSM{params: map[string]string{"/creds/access_key_id": "AKIAEXAMPLE"}},
+			path:    true,
+			wantErr: ErrParameterNotFound,
+		},
+		{
+			name:    "PathAccessDenied",
+			client:  &stubSSM{err: &smithy.GenericAPIError{Code: "AccessDeniedException"}},
+			path:    true,
+			wantErr: ErrParameterAccessDenied,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			if tt.path {
+				opts = append(opts, WithSSMParameterPath())
+			}
+			_, err := NewSSMParameterProvider(tt.client, "/creds", opts...).Retrieve(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Retrieve error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewSSMParameterConf(t *testing.T) {
+	client := &stubSSM{params: map[string]string{"/creds": `{"AccessKeyID": "AKIAEXAMPLE", "SecretAccessKey": "secret"}`}}
+	cfg, err := NewSSMParameterConf(context.Background(), aws.Config{}, "/creds",
+		WithSSMClient(client), WithForcedTTL(time.Hour))
+	if err != nil {
+		t.Fatalf("NewSSMParameterConf: %v", err)
+	}
+	for range 3 {
+		creds, err := cfg.Credentials.Retrieve(context.Background())
+		if err != nil {
+			t.Fatalf("Retrieve: %v", err)
+		}
+		if ttl := time.Until(creds.Expires); ttl < 59*time.Minute || ttl > time.Hour {
+			t.Errorf("credentials refresh in %v, want the forced TTL of 1h", ttl)
+		}
+	}
+	if len(client.calls) != 1 {
+		t.Errorf("GetParameter called %d times, want 1 for cached credentials", len(client.calls))
+	}
+}
+
+// stubSSM is an SSMAPIClient serving params, or failing with err, and
+// recording the operations called
+type stubSSM struct {
+	params map[string]string
+	err    error
+
+	calls     []string
+	decrypted bool
+}
+
+func (s *stubSSM) GetParameter(
+	_ context.Context,
+	params *ssm.GetParameterInput,
+	_ ...func(*ssm.Options),
+) (*ssm.GetParameterOutput, error) {
+	s.calls = append(s.calls, "GetParameter")
+	s.decrypted = aws.ToBool(params.WithDecryption)
+	if s.err != nil {
+		return nil, s.err
+	}
+	value, ok := s.params[aws.ToString(params.Name)]
+	if !ok {
+		return nil, &ssmtypes.ParameterNotFound{}
+	}
+	return &ssm.GetParameterOutput{Parameter: &ssmtypes.Parameter{
+		Name:  params.Name,
+		Type:  ssmtypes.ParameterTypeSecureString,
+		Value: aws.String(value),
+	}}, nil
+}
+
+func (s *stubSSM) GetParameters(
+	_ context.Context,
+	params *ssm.GetParametersInput,
+	_ ...func(*ssm.Options),
+) (*ssm.GetParametersOutput, error) {
+	s.calls = append(s.calls, "GetParameters")
+	s.decrypted = aws.ToBool(params.WithDecryption)
+	if s.err != nil {
+		return nil, s.err
+	}
+	// Like SSM, report unknown names as invalid rather than failing
+	out := &ssm.GetParametersOutput{}
+	for _, name := range params.Names {
+		if value, ok := s.params[name]; ok {
+			out.Parameters = append(out.Parameters, ssmtypes.Parameter{Name: aws.String(name), Value: aws.String(value)})
+		} else {
+			out.InvalidParameters = append(out.InvalidParameters, name)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,136 @@
+package awsconfig
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/ssocreds"
+	"github.com/aws/aws-sdk-go-v2/service/sso"
+)
+
+// SSOProviderName prefixes the Source of credentials of NewSSOConf configs,
+// followed by the account ID and role name unless WithRedactedSource is set
+const SSOProviderName = SourcePrefix + "sso"
+
+// ErrSSOLoginRequired is returned when the cached SSO access token is missing or expired
+var ErrSSOLoginRequired = errors.New("SSO session is missing or expired, run `aws sso login`")
+
+// SSOToken is an SSO access token in the layout the AWS CLI keeps under ~/.aws/sso/cache
+type SSOToken struct {
+	StartURL              string     `json:"startUrl,omitempty"`
+	Region                string     `json:"region,omitempty"`
+	AccessToken           string     `json:"accessToken"`
+	ExpiresAt             time.Time  `json:"expiresAt"`
+	RefreshToken          string     `json:"refreshToken,omitempty"`
+	ClientID              string     `json:"clientId,omitempty"`
+	ClientSecret          string     `json:"clientSecret,omitempty"`
+	RegistrationExpiresAt *time.Time `json:"registrationExpiresAt,omitempty"`
+}
+
+// Expired returns if the token has expired
+func (t SSOToken) Expired() bool {
+	return t.AccessToken == "" || !t.ExpiresAt.After(time.Now())
+}
+
+// DefaultSSOCacheDir returns the directory the AWS CLI caches SSO tokens in
+func DefaultSSOCacheDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".aws", "sso", "cache"), nil
+}
+
+// SSOCachedTokenFilepath returns the path of the cached token for startURL within cacheDir
+func SSOCachedTokenFilepath(cacheDir, startURL string) string {
+	hash := sha1.Sum([]byte(startURL))
+	return filepath.Join(cacheDir, hex.EncodeToString(hash[:])+".json")
+}
+
+// loadSSOToken reads a cached SSO token, mapping missing or expired tokens to ErrSSOLoginRequired
+func loadSSOToken(filename string) (SSOToken, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return SSOToken{}, fmt.Errorf("%w: %w", ErrSSOLoginRequired, err)
+	}
+	var token SSOToken
+	if err := json.Unmarshal(b, &token); err != nil {
+		return SSOToken{}, fmt.Errorf("%w: cannot parse %s: %w", ErrSSOLoginRequired, filename, err)
+	}
+	if token.Expired() {
+		return SSOToken{}, fmt.Errorf("%w: token expired at %s", ErrSSOLoginRequired, token.ExpiresAt)
+	}
+	return token, nil
+}
+
+// NewSSOConf returns an aws.Config for roleName in accountID using the SSO access
+// token cached by `aws sso login` for startURL, with auto-refreshing credentials.
+func NewSSOConf(
+	_ context.Context,
+	cfg aws.Config,
+	startURL string,
+	region string,
+	accountID string,
+	roleName string,
+	opts ...Option,
+) (aws.Config, error) {
+	conf := newConfOptions(opts)
+
+	cacheDir := conf.ssoCacheDir
+	if cacheDir == "" {
+		var err error
+		if cacheDir, err = DefaultSSOCacheDir(); err != nil {
+			return aws.Config{}, fmt.Errorf("%w: %w", ErrSSOLoginRequired, err)
+		}
+	}
+	tokenFile := SSOCachedTokenFilepath(cacheDir, startURL)
+
+	// Fail early if the user needs to log in
+	if _, err := loadSSOToken(tokenFile); err != nil {
+		return aws.Config{}, err
+	}
+
+	ssoClient := sso.NewFromConfig(cfg, func(o *sso.Options) {
+		o.Region = region
+	})
+	provider := &ssoProvider{
+		provider: ssocreds.New(ssoClient, accountID, roleName, startURL, func(o *ssocreds.Options) {
+			o.CachedTokenFilepath = tokenFile
+		}),
+	}
+
+	newCfg := cfg.Copy()
+	source := roleSourceLabel(conf, SSOProviderName, accountID+"/"+roleName)
+	newCfg.Credentials = aws.NewCredentialsCache(withSource(source, withStats(provider)), conf.cacheOpts...)
+	return newCfg, nil
+}
+
+// ssoProvider maps ssocreds token errors to ErrSSOLoginRequired
+type ssoProvider struct {
+	provider aws.CredentialsProvider
+}
+
+// Retrieve implements the aws.CredentialsProvider interface method
+func (p *ssoProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	creds, err := p.provider.Retrieve(ctx)
+	var tokenErr *ssocreds.InvalidTokenError
+	if errors.As(err, &tokenErr) {
+		return aws.Credentials{}, fmt.Errorf("%w: %w", ErrSSOLoginRequired, err)
+	}
+	return creds, err
+}
+
+// WithSSOCacheDir overrides the directory SSO access tokens are read from
+func WithSSOCacheDir(dir string) ConfOption {
+	return func(c *confOptions) {
+		c.ssoCacheDir = dir
+	}
+}
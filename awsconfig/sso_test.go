@@ -0,0 +1,111 @@
+package awsconfig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+const testSSOStartURL = "https://example.awsapps.com/start"
+
+// writeSSOToken caches token for startURL in dir as `aws sso login` does
+func writeSSOToken(t *testing.T, dir, startURL string, token SSOToken) {
+	t.Helper()
+	b, err := json.Marshal(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(SSOCachedTokenFilepath(dir, startURL), b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newSSOServer serves GetRoleCredentials to holders of accessToken
+func newSSOServer(t *testing.T, accessToken string) aws.Config {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/federation/credentials" || r.Header.Get("x-amz-sso_bearer_token") != accessToken {
+			w.Header().Set("X-Amzn-Errortype", "UnauthorizedException")
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"message": "Session token not found or invalid"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"roleCredentials": {"accessKeyId": "ASIAFAKESSO%s", "secretAccessKey": "secret", "sessionToken": "token", "expiration": %d}}`,
+			r.URL.Query().Get("account_id"), time.Now().Add(time.Hour).UnixMilli())
+	}))
+	t.Cleanup(srv.Close)
+	return aws.Config{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(srv.URL),
+		HTTPClient:   srv.Client(),
+		Retryer:      func() aws.Retryer { return aws.NopRetryer{} },
+	}
+}
+
+func TestSSOCachedTokenFilepath(t *testing.T) {
+	// The AWS CLI names the file after the hex SHA-1 of the start URL
+	got := SSOCachedTokenFilepath("cache", testSSOStartURL)
+	want := filepath.Join("cache", "e8be5486177c5b5392bd9aa76563515b29358e6e.json")
+	if got != want {
+		t.Errorf("SSOCachedTokenFilepath returned %q, want %q", got, want)
+	}
+}
+
+func TestNewSSOConf(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   *SSOToken
+		wantErr error
+	}{
+		{"Valid", &SSOToken{StartURL: testSSOStartURL, AccessToken: "access-token", ExpiresAt: time.Now().Add(time.Hour)}, nil},
+		{"Missing", nil, ErrSSOLoginRequired},
+		{"Expired", &SSOToken{StartURL: testSSOStartURL, AccessToken: "access-token", ExpiresAt: time.Now().Add(-time.Minute)}, ErrSSOLoginRequired},
+		{"NoAccessToken", &SSOToken{StartURL: testSSOStartURL, ExpiresAt: time.Now().Add(time.Hour)}, ErrSSOLoginRequired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.token != nil {
+				writeSSOToken(t, dir, testSSOStartURL, *tt.token)
+			}
+			cfg, err := NewSSOConf(context.Background(), newSSOServer(t, "access-token"), testSSOStartURL, "us-east-1",
+				"123456789012", "Developer", WithSSOCacheDir(dir))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewSSOConf error %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			creds, err := cfg.Credentials.Retrieve(context.Background())
+			if err != nil {
+				t.Fatalf("Retrieve: %v", err)
+			}
+			if creds.AccessKeyID != "ASIAFAKESSO123456789012" || !creds.CanExpire {
+				t.Errorf("Retrieve returned %+v", creds)
+			}
+		})
+	}
+}
+
+func TestNewSSOConfRevokedToken(t *testing.T) {
+	dir := t.TempDir()
+	writeSSOToken(t, dir, testSSOStartURL,
+		SSOToken{StartURL: testSSOStartURL, AccessToken: "revoked-token", ExpiresAt: time.Now().Add(time.Hour)})
+	cfg, err := NewSSOConf(context.Background(), newSSOServer(t, "access-token"), testSSOStartURL, "us-east-1",
+		"123456789012", "Developer", WithSSOCacheDir(dir))
+	if err != nil {
+		t.Fatalf("NewSSOConf: %v", err)
+	}
+	if _, err := cfg.Credentials.Retrieve(context.Background()); err == nil {
+		t.Error("Retrieve with a token the service rejects succeeded")
+	}
+}
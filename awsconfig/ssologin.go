@@ -0,0 +1,155 @@
+package awsconfig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc/types"
+)
+
+var (
+	// ErrSSORegisterClient is returned when the SSO OIDC client cannot be registered
+	ErrSSORegisterClient = errors.New("Cannot register SSO OIDC client")
+	// ErrSSODeviceAuthStart is returned when SSO device authorization cannot be started
+	ErrSSODeviceAuthStart = errors.New("Cannot start SSO device authorization")
+	// ErrSSOCreateToken is returned when the SSO access token cannot be created
+	ErrSSOCreateToken = errors.New("Cannot create SSO access token")
+	// ErrSSODeviceAuthExpired is returned when the user does not approve SSO device authorization in time
+	ErrSSODeviceAuthExpired = errors.New("SSO device authorization expired before it was approved")
+)
+
+const (
+	ssoClientName      = "mostly-harmless-awsconfig"
+	ssoDeviceGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+	// RFC 8628 defaults for the device authorization grant
+	ssoDefaultPollInterval = 5 * time.Second
+	ssoSlowDownIncrement   = 5 * time.Second
+)
+
+// ssoPollWait waits between CreateToken polls; replaced in tests
+var ssoPollWait = sleepContext
+
+// LoginSSO performs the SSO OIDC device authorization grant for startURL,
+// calling prompt with the verification URI and user code for the user to approve,
+// then polling until an access token is issued or ctx is cancelled.
+func LoginSSO(
+	ctx context.Context,
+	cfg aws.Config,
+	startURL string,
+	region string,
+	prompt func(verificationURI, userCode string),
+) (SSOToken, error) {
+	client := ssooidc.NewFromConfig(cfg, func(o *ssooidc.Options) {
+		o.Region = region
+	})
+
+	// Register a public client for this login
+	reg, err := client.RegisterClient(ctx, &ssooidc.RegisterClientInput{
+		ClientName: aws.String(ssoClientName),
+		ClientType: aws.String("public"),
+	})
+	if err != nil {
+		return SSOToken{}, fmt.Errorf("%w: %w", ErrSSORegisterClient, err)
+	}
+
+	auth, err := client.StartDeviceAuthorization(ctx, &ssooidc.StartDeviceAuthorizationInput{
+		ClientId:     reg.ClientId,
+		ClientSecret: reg.ClientSecret,
+		StartUrl:     aws.String(startURL),
+	})
+	if err != nil {
+		return SSOToken{}, fmt.Errorf("%w: %w", ErrSSODeviceAuthStart, err)
+	}
+
+	verificationURI := aws.ToString(auth.VerificationUriComplete)
+	if verificationURI == "" {
+		verificationURI = aws.ToString(auth.VerificationUri)
+	}
+	if prompt != nil {
+		prompt(verificationURI, aws.ToString(auth.UserCode))
+	}
+
+	interval := time.Duration(auth.Interval) * time.Second
+	if interval <= 0 {
+		interval = ssoDefaultPollInterval
+	}
+	deadline := timeNow().Add(time.Duration(auth.ExpiresIn) * time.Second)
+
+	for {
+		if err := ssoPollWait(ctx, interval); err != nil {
+			return SSOToken{}, err
+		}
+
+		out, err := client.CreateToken(ctx, &ssooidc.CreateTokenInput{
+			ClientId:     reg.ClientId,
+			ClientSecret: reg.ClientSecret,
+			DeviceCode:   auth.DeviceCode,
+			GrantType:    aws.String(ssoDeviceGrantType),
+		})
+
+		var pending *types.AuthorizationPendingException
+		var slowDown *types.SlowDownException
+		switch {
+		case err == nil:
+			token := SSOToken{
+				StartURL:     startURL,
+				Region:       region,
+				AccessToken:  aws.ToString(out.AccessToken),
+				ExpiresAt:    timeNow().Add(time.Duration(out.ExpiresIn) * time.Second).UTC(),
+				RefreshToken: aws.ToString(out.RefreshToken),
+				ClientID:     aws.ToString(reg.ClientId),
+				ClientSecret: aws.ToString(reg.ClientSecret),
+			}
+			if reg.ClientSecretExpiresAt > 0 {
+				registrationExpiresAt := time.Unix(reg.ClientSecretExpiresAt, 0).UTC()
+				token.RegistrationExpiresAt = &registrationExpiresAt
+			}
+			return token, nil
+		case errors.As(err, &slowDown):
+			interval += ssoSlowDownIncrement
+		case errors.As(err, &pending):
+		default:
+			return SSOToken{}, fmt.Errorf("%w: %w", ErrSSOCreateToken, err)
+		}
+
+		if auth.ExpiresIn > 0 && timeNow().After(deadline) {
+			return SSOToken{}, ErrSSODeviceAuthExpired
+		}
+	}
+}
+
+// WriteSSOToken persists token into cacheDir using the AWS CLI file name and
+// layout, so NewSSOConf and the CLI can both consume it.
+func WriteSSOToken(cacheDir string, token SSOToken) error {
+	if err := os.MkdirAll(cacheDir, 0o700); err != nil {
+		return err
+	}
+	b, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see a partial token
+	filename := SSOCachedTokenFilepath(cacheDir, token.StartURL)
+	tmp, err := os.CreateTemp(filepath.Dir(filename), ".sso-token-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
@@ -0,0 +1,175 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// oidcServer is an SSO OIDC endpoint answering CreateToken polls with the
+// error codes of tokenErrors in turn, then with a token
+type oidcServer struct {
+	tokenErrors []string
+
+	mu    sync.Mutex
+	polls int
+}
+
+// ServeHTTP implements the http.Handler interface method
+func (s *oidcServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	switch r.URL.Path {
+	case "/client/register":
+		fmt.Fprint(w, `{"clientId": "client-id", "clientSecret": "client-secret", "clientSecretExpiresAt": 1893456000}`)
+	case "/device_authorization":
+		fmt.Fprint(w, `{"deviceCode": "device-code", "userCode": "ABCD-EFGH", "verificationUri": "https://device.sso.example/",`+
+			` "verificationUriComplete": "https://device.sso.example/?user_code=ABCD-EFGH", "expiresIn": 600, "interval": 1}`)
+	case "/token":
+		s.mu.Lock()
+		poll := s.polls
+		s.polls++
+		s.mu.Unlock()
+		if poll < len(s.tokenErrors) {
+			w.Header().Set("X-Amzn-ErrorType", s.tokenErrors[poll])
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error": "pending"}`)
+			return
+		}
+		fmt.Fprint(w, `{"accessToken": "access-token", "expiresIn": 28800, "refreshToken": "refresh-token", "tokenType": "Bearer"}`)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// stubSSOPollWait records the waits between polls without sleeping, advancing
+// the clock instead, and cancels the context after cancelAfter waits if set
+func stubSSOPollWait(t *testing.T, cancel context.CancelFunc, cancelAfter int) *[]time.Duration {
+	t.Helper()
+	now := time.Now()
+	origWait, origNow := ssoPollWait, timeNow
+	t.Cleanup(func() { ssoPollWait, timeNow = origWait, origNow })
+	timeNow = func() time.Time { return now }
+
+	var waits []time.Duration
+	ssoPollWait = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		now = now.Add(d)
+		if cancel != nil && len(waits) > cancelAfter {
+			cancel()
+		}
+		return ctx.Err()
+	}
+	return &waits
+}
+
+func TestLoginSSO(t *testing.T) {
+	tests := []struct {
+		name        string
+		tokenErrors []string
+		cancelAfter int
+		wantWaits   []time.Duration
+		wantErr     error
+	}{
+		{
+			name:      "Approved",
+			wantWaits: []time.Duration{time.Second},
+		},
+		{
+			name:        "PendingAndSlowDown",
+			tokenErrors: []string{"AuthorizationPendingException", "SlowDownException", "AuthorizationPendingException"},
+			wantWaits:   []time.Duration{time.Second, time.Second, 6 * time.Second, 6 * time.Second},
+		},
+		{
+			name:        "Denied",
+			tokenErrors: []string{"AccessDeniedException"},
+			wantWaits:   []time.Duration{time.Second},
+			wantErr:     ErrSSOCreateToken,
+		},
+		{
+			name:        "Expired",
+			tokenErrors: make([]string, 601),
+			wantErr:     ErrSSODeviceAuthExpired,
+		},
+		{
+			name:        "Cancelled",
+			tokenErrors: []string{"AuthorizationPendingException", "AuthorizationPendingException"},
+			cancelAfter: 1,
+			wantWaits:   []time.Duration{time.Second, time.Second},
+			wantErr:     context.Canceled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := range tt.tokenErrors {
+				if tt.tokenErrors[i] == "" {
+					tt.tokenErrors[i] = "AuthorizationPendingException"
+				}
+			}
+			srv := httptest.NewServer(&oidcServer{tokenErrors: tt.tokenErrors})
+			defer srv.Close()
+			cfg := aws.Config{
+				BaseEndpoint: aws.String(srv.URL),
+				HTTPClient:   srv.Client(),
+				Retryer:      func() aws.Retryer { return aws.NopRetryer{} },
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancelAfter == 0 {
+				cancel = nil
+			}
+			waits := stubSSOPollWait(t, cancel, tt.cancelAfter)
+
+			var prompted []string
+			token, err := LoginSSO(ctx, cfg, testSSOStartURL, "us-east-1", func(uri, code string) {
+				prompted = append(prompted, uri, code)
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("LoginSSO error %v, want %v", err, tt.wantErr)
+			}
+			if len(prompted) != 2 || prompted[0] != "https://device.sso.example/?user_code=ABCD-EFGH" || prompted[1] != "ABCD-EFGH" {
+				t.Errorf("prompt called with %q, want the complete verification URI and user code", prompted)
+			}
+			if tt.wantWaits != nil && fmt.Sprint(*waits) != fmt.Sprint(tt.wantWaits) {
+				t.Errorf("LoginSSO waited %v between polls, want %v", *waits, tt.wantWaits)
+			}
+			if err != nil {
+				return
+			}
+			if token.AccessToken != "access-token" || token.RefreshToken != "refresh-token" ||
+				token.ClientID != "client-id" || token.StartURL != testSSOStartURL || token.Region != "us-east-1" ||
+				token.RegistrationExpiresAt == nil || token.Expired() {
+				t.Errorf("LoginSSO returned %+v", token)
+			}
+		})
+	}
+}
+
+func TestWriteSSOToken(t *testing.T) {
+	dir := t.TempDir()
+	token := SSOToken{
+		StartURL:    testSSOStartURL,
+		Region:      "us-east-1",
+		AccessToken: "access-token",
+		ExpiresAt:   time.Now().Add(time.Hour).UTC().Truncate(time.Second),
+	}
+	if err := WriteSSOToken(dir, token); err != nil {
+		t.Fatalf("WriteSSOToken: %v", err)
+	}
+
+	// NewSSOConf reads the file the CLI would
+	got, err := loadSSOToken(SSOCachedTokenFilepath(dir, testSSOStartURL))
+	if err != nil {
+		t.Fatalf("loadSSOToken: %v", err)
+	}
+	if got.AccessToken != token.AccessToken || !got.ExpiresAt.Equal(token.ExpiresAt) || got.StartURL != token.StartURL {
+		t.Errorf("read back %+v, want %+v", got, token)
+	}
+}
@@ -0,0 +1,76 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+var (
+	// ErrServingStaleCredentials is passed to the warning handler when a refresh
+	// fails and the previous, still valid, credentials are returned instead
+	ErrServingStaleCredentials = errors.New("Credentials refresh failed, serving previous credentials")
+)
+
+// WithStaleIfError keeps returning the last credentials retrieved when a refresh
+// fails, for up to maxStaleness after the first failure and never past their
+// expiry. Each failure is passed to the WithWarningHandler handler.
+func WithStaleIfError(maxStaleness time.Duration) ConfOption {
+	return func(c *confOptions) {
+		c.maxStaleness = maxStaleness
+	}
+}
+
+// staleIfErrorProvider serves the last good credentials of provider while it fails
+type staleIfErrorProvider struct {
+	provider     aws.CredentialsProvider
+	maxStaleness time.Duration
+	warn         func(error)
+
+	mu           sync.Mutex
+	last         aws.Credentials
+	failingSince time.Time
+}
+
+// withStaleIfError wraps provider according to WithStaleIfError, if set
+func withStaleIfError(conf *confOptions, provider aws.CredentialsProvider) aws.CredentialsProvider {
+	if conf.maxStaleness <= 0 {
+		return provider
+	}
+	return &staleIfErrorProvider{
+		provider:     provider,
+		maxStaleness: conf.maxStaleness,
+		warn:         conf.warn,
+	}
+}
+
+// Retrieve implements the aws.CredentialsProvider interface method
+func (p *staleIfErrorProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	creds, err := p.provider.Retrieve(ctx)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := timeNow()
+	if err == nil {
+		p.last = creds
+		p.failingSince = time.Time{}
+		return creds, nil
+	}
+
+	if p.failingSince.IsZero() {
+		p.failingSince = now
+	}
+	if !p.last.HasKeys() || now.Sub(p.failingSince) > p.maxStaleness ||
+		(p.last.CanExpire && !now.Before(p.last.Expires)) {
+		return aws.Credentials{}, err
+	}
+	if p.warn != nil {
+		p.warn(fmt.Errorf("%w: %w", ErrServingStaleCredentials, err))
+	}
+	traceServedStale(ctx, err)
+	return p.last, nil
+}
@@ -0,0 +1,163 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// switchProvider returns whatever result it was last set to
+type switchProvider struct {
+	mu    sync.Mutex
+	creds aws.Credentials
+	err   error
+}
+
+// set makes the provider return creds, or err when it is not nil
+func (p *switchProvider) set(creds aws.Credentials, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.creds, p.err = creds, err
+}
+
+// Retrieve implements the aws.CredentialsProvider interface method
+func (p *switchProvider) Retrieve(context.Context) (aws.Credentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return aws.Credentials{}, p.err
+	}
+	return p.creds, nil
+}
+
+func TestStaleIfError(t *testing.T) {
+	brownout := errors.New("sts brownout")
+	type step struct {
+		at        time.Duration
+		fail      bool
+		wantKey   string
+		wantErr   bool
+		wantWarns int
+	}
+	tests := []struct {
+		name         string
+		maxStaleness time.Duration
+		steps        []step
+	}{
+		{
+			name:         "StalenessBound",
+			maxStaleness: 10 * time.Minute,
+			steps: []step{
+				{at: 0, wantKey: "key-0s"},
+				{at: 20 * time.Minute, fail: true, wantKey: "key-0s", wantWarns: 1},
+				{at: 25 * time.Minute, fail: true, wantKey: "key-0s", wantWarns: 2},
+				// A success resets the staleness bound
+				{at: 26 * time.Minute, wantKey: "key-26m0s", wantWarns: 2},
+				{at: 30 * time.Minute, fail: true, wantKey: "key-26m0s", wantWarns: 3},
+				{at: 40 * time.Minute, fail: true, wantKey: "key-26m0s", wantWarns: 4},
+				{at: 41 * time.Minute, fail: true, wantErr: true, wantWarns: 4},
+			},
+		},
+		{
+			name:         "ExpiryBound",
+			maxStaleness: time.Hour,
+			steps: []step{
+				{at: 0, wantKey: "key-0s"},
+				{at: 35 * time.Minute, fail: true, wantKey: "key-0s", wantWarns: 1},
+				{at: 40 * time.Minute, fail: true, wantErr: true, wantWarns: 1},
+			},
+		},
+		{
+			name:         "NothingToServe",
+			maxStaleness: time.Hour,
+			steps: []step{
+				{at: 0, fail: true, wantErr: true},
+				{at: time.Minute, wantKey: "key-1m0s"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			now := start
+			origNow := timeNow
+			t.Cleanup(func() { timeNow = origNow })
+			timeNow = func() time.Time { return now }
+
+			inner := &switchProvider{}
+			var warnings []error
+			conf := newConfOptions([]Option{WithStaleIfError(tt.maxStaleness), WithWarningHandler(func(err error) {
+				warnings = append(warnings, err)
+			})})
+			provider := withStaleIfError(conf, inner)
+
+			for _, s := range tt.steps {
+				now = start.Add(s.at)
+				creds := testCredentials(0)
+				creds.AccessKeyID = fmt.Sprintf("key-%v", s.at)
+				creds.CanExpire, creds.Expires = true, now.Add(40*time.Minute)
+				if s.fail {
+					inner.set(aws.Credentials{}, brownout)
+				} else {
+					inner.set(creds, nil)
+				}
+
+				got, err := provider.Retrieve(context.Background())
+				if s.wantErr {
+					if !errors.Is(err, brownout) {
+						t.Errorf("at %v: Retrieve error %v, want %v", s.at, err, brownout)
+					}
+				} else if err != nil || got.AccessKeyID != s.wantKey {
+					t.Errorf("at %v: Retrieve %q, %v, want %q", s.at, got.AccessKeyID, err, s.wantKey)
+				}
+				if len(warnings) != s.wantWarns {
+					t.Errorf("at %v: %d warnings, want %d", s.at, len(warnings), s.wantWarns)
+				}
+			}
+			for _, w := range warnings {
+				if !errors.Is(w, ErrServingStaleCredentials) || !errors.Is(w, brownout) {
+					t.Errorf("warning %v, want %v wrapping %v", w, ErrServingStaleCredentials, brownout)
+				}
+			}
+		})
+	}
+}
+
+func TestStaleIfErrorConcurrent(t *testing.T) {
+	brownout := errors.New("sts brownout")
+	var calls atomic.Int32
+	inner := aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		// Every other retrieval fails
+		if calls.Add(1)%2 == 0 {
+			return aws.Credentials{}, brownout
+		}
+		return testCredentials(time.Hour), nil
+	})
+	var warnings atomic.Int32
+	conf := newConfOptions([]Option{WithStaleIfError(time.Hour), WithWarningHandler(func(error) { warnings.Add(1) })})
+	provider := withStaleIfError(conf, inner)
+	if _, err := provider.Retrieve(context.Background()); err != nil {
+		t.Fatalf("Retrieve: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := provider.Retrieve(context.Background()); err != nil {
+				t.Errorf("Retrieve: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := warnings.Load(); got != 25 {
+		t.Errorf("%d warnings, want 25", got)
+	}
+}
@@ -0,0 +1,44 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+// StaticProviderName is the Source of credentials returned by NewStaticConf configs
+const StaticProviderName = SourcePrefix + "static"
+
+var (
+	// ErrEmptyAccessKeyID is returned when static credentials lack an access key ID
+	ErrEmptyAccessKeyID = errors.New("Static credentials require an access key ID")
+	// ErrEmptySecretAccessKey is returned when static credentials lack a secret access key
+	ErrEmptySecretAccessKey = errors.New("Static credentials require a secret access key")
+)
+
+// NewStaticConf returns an aws.Config using fixed, never-expiring credentials.
+// The sessionToken may be empty for long-term IAM user keys.
+func NewStaticConf(
+	_ context.Context,
+	cfg aws.Config,
+	accessKeyID string,
+	secretAccessKey string,
+	sessionToken string,
+) (aws.Config, error) {
+	if accessKeyID == "" {
+		return aws.Config{}, ErrEmptyAccessKeyID
+	}
+	if secretAccessKey == "" {
+		return aws.Config{}, ErrEmptySecretAccessKey
+	}
+
+	provider := credentials.NewStaticCredentialsProvider(accessKeyID, secretAccessKey, sessionToken)
+	provider.Value.Source = StaticProviderName
+
+	// Static credentials never expire, so there is nothing to cache
+	newCfg := cfg.Copy()
+	newCfg.Credentials = provider
+	return newCfg, nil
+}
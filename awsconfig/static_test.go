@@ -0,0 +1,55 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestNewStaticConf(t *testing.T) {
+	tests := []struct {
+		name            string
+		accessKeyID     string
+		secretAccessKey string
+		sessionToken    string
+		wantErr         error
+	}{
+		{"LongTerm", "AKIAFAKESTATICKEY", "fake-secret-access-key", "", nil},
+		{"WithSessionToken", "ASIAFAKESTATICKEY", "fake-secret-access-key", "fake-session-token", nil},
+		{"EmptyAccessKeyID", "", "fake-secret-access-key", "", ErrEmptyAccessKeyID},
+		{"EmptySecretAccessKey", "AKIAFAKESTATICKEY", "", "", ErrEmptySecretAccessKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := aws.Config{Region: "eu-west-1"}
+			cfg, err := NewStaticConf(context.Background(), base, tt.accessKeyID, tt.secretAccessKey, tt.sessionToken)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewStaticConf error %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if base.Credentials != nil {
+				t.Error("NewStaticConf modified the passed config")
+			}
+			if cfg.Region != base.Region {
+				t.Errorf("NewStaticConf returned region %q, want %q", cfg.Region, base.Region)
+			}
+			creds, err := cfg.Credentials.Retrieve(context.Background())
+			if err != nil {
+				t.Fatalf("Retrieve: %v", err)
+			}
+			want := aws.Credentials{
+				AccessKeyID:     tt.accessKeyID,
+				SecretAccessKey: tt.secretAccessKey,
+				SessionToken:    tt.sessionToken,
+				Source:          StaticProviderName,
+			}
+			if creds != want {
+				t.Errorf("Retrieve returned %+v, want %+v", creds, want)
+			}
+		})
+	}
+}
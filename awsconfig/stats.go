@@ -0,0 +1,97 @@
+package awsconfig
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// Stats describes the refreshes of a credentials provider built by this package
+type Stats struct {
+	// RefreshCount is the number of refreshes attempted, successful or not
+	RefreshCount int64
+	// LastRefreshTime is when the most recent refresh started
+	LastRefreshTime time.Time
+	// LastRefreshDuration is how long the most recent refresh took
+	LastRefreshDuration time.Duration
+	// LastError is the error of the most recent refresh, nil if it succeeded
+	LastError error
+}
+
+// ProviderStats reports the refresh statistics of the credentials of cfg. ok is
+// false when they weren't built by this package. Reading never blocks a
+// concurrent Retrieve.
+func ProviderStats(cfg aws.Config) (stats Stats, ok bool) {
+	if cfg.Credentials == nil {
+		return Stats{}, false
+	}
+	probe := &statsProbe{}
+	if !aws.IsCredentialsProvider(cfg.Credentials, probe) || probe.provider == nil {
+		return Stats{}, false
+	}
+	return probe.provider.stats(), true
+}
+
+// statsProbe is passed to aws.IsCredentialsProvider to find a statsProvider
+// behind an aws.CredentialsCache, which otherwise hides its provider
+type statsProbe struct {
+	provider *statsProvider
+}
+
+// Retrieve implements the aws.CredentialsProvider interface method
+func (*statsProbe) Retrieve(context.Context) (aws.Credentials, error) {
+	return aws.Credentials{}, nil
+}
+
+// refreshRecord is the outcome of a single refresh
+type refreshRecord struct {
+	start    time.Time
+	duration time.Duration
+	err      error
+}
+
+// statsProvider records the refreshes of its provider
+type statsProvider struct {
+	provider aws.CredentialsProvider
+	count    atomic.Int64
+	last     atomic.Pointer[refreshRecord]
+}
+
+// withStats wraps provider to record its refreshes; it must be the provider
+// passed to aws.NewCredentialsCache, or to withCacheBackends, so ProviderStats
+// can find it
+func withStats(provider aws.CredentialsProvider) aws.CredentialsProvider {
+	return &statsProvider{provider: provider}
+}
+
+// Retrieve implements the aws.CredentialsProvider interface method
+func (p *statsProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	start := timeNow()
+	creds, err := p.provider.Retrieve(ctx)
+	p.last.Store(&refreshRecord{start: start, duration: timeNow().Sub(start), err: err})
+	p.count.Add(1)
+	return creds, err
+}
+
+// IsCredentialsProvider reports whether the wrapped provider is of the type of
+// target, or fills in target when it is a statsProbe
+func (p *statsProvider) IsCredentialsProvider(target aws.CredentialsProvider) bool {
+	if probe, ok := target.(*statsProbe); ok {
+		probe.provider = p
+		return true
+	}
+	return aws.IsCredentialsProvider(p.provider, target)
+}
+
+// stats returns a snapshot of the recorded refreshes
+func (p *statsProvider) stats() Stats {
+	stats := Stats{RefreshCount: p.count.Load()}
+	if last := p.last.Load(); last != nil {
+		stats.LastRefreshTime = last.start
+		stats.LastRefreshDuration = last.duration
+		stats.LastError = last.err
+	}
+	return stats
+}
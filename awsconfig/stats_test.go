@@ -0,0 +1,119 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigtest"
+)
+
+func TestProviderStats(t *testing.T) {
+	advance := stubClock(t)
+	brokerDown := errors.New("broker down")
+	var fail bool
+	cfg, err := NewCustomFunctionConf(context.Background(), aws.Config{}, func(context.Context) (aws.Credentials, error) {
+		advance(2 * time.Second)
+		if fail {
+			return aws.Credentials{}, brokerDown
+		}
+		return testCredentials(time.Hour), nil
+	})
+	if err != nil {
+		t.Fatalf("NewCustomFunctionConf: %v", err)
+	}
+	if stats, ok := ProviderStats(cfg); !ok || stats != (Stats{}) {
+		t.Fatalf("ProviderStats before any refresh %+v, %t", stats, ok)
+	}
+
+	steps := []struct {
+		name      string
+		fail      bool
+		wantCount int64
+	}{
+		{name: "First", wantCount: 1},
+		{name: "Second", wantCount: 2},
+		{name: "Failed", fail: true, wantCount: 3},
+		{name: "Recovered", wantCount: 4},
+	}
+	for _, step := range steps {
+		fail = step.fail
+		advance(time.Minute)
+		start := timeNow()
+		if err := InvalidateCredentials(cfg); err != nil {
+			t.Fatalf("%s: InvalidateCredentials: %v", step.name, err)
+		}
+		_, _ = cfg.Credentials.Retrieve(context.Background())
+
+		stats, ok := ProviderStats(cfg)
+		if !ok {
+			t.Fatalf("%s: ProviderStats not found", step.name)
+		}
+		want := Stats{RefreshCount: step.wantCount, LastRefreshTime: start, LastRefreshDuration: 2 * time.Second}
+		if step.fail {
+			want.LastError = brokerDown
+		}
+		if stats.RefreshCount != want.RefreshCount || !stats.LastRefreshTime.Equal(want.LastRefreshTime) ||
+			stats.LastRefreshDuration != want.LastRefreshDuration || !errors.Is(stats.LastError, want.LastError) {
+			t.Errorf("%s: ProviderStats %+v, want %+v", step.name, stats, want)
+		}
+	}
+}
+
+func TestProviderStatsConstructors(t *testing.T) {
+	assumed, err := NewAssumeRoleConf(context.Background(), aws.Config{}, testRoleArn,
+		WithSTSClient(&awsconfigtest.FakeSTS{}), WithSkipIdentityCheck())
+	if err != nil {
+		t.Fatalf("NewAssumeRoleConf: %v", err)
+	}
+	tests := []struct {
+		name   string
+		cfg    aws.Config
+		wantOK bool
+	}{
+		{name: "AssumeRole", cfg: assumed, wantOK: true},
+		{name: "Static", cfg: aws.Config{Credentials: credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", "")}},
+		{name: "ForeignCache", cfg: aws.Config{Credentials: aws.NewCredentialsCache(credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", ""))}},
+		{name: "NilCredentials"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := ProviderStats(tt.cfg); ok != tt.wantOK {
+				t.Errorf("ProviderStats ok %t, want %t", ok, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestProviderStatsDoesNotBlock(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	cfg, err := NewCustomFunctionConf(context.Background(), aws.Config{}, func(context.Context) (aws.Credentials, error) {
+		close(started)
+		<-release
+		return testCredentials(time.Hour), nil
+	})
+	if err != nil {
+		t.Fatalf("NewCustomFunctionConf: %v", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = cfg.Credentials.Retrieve(context.Background())
+	}()
+	<-started
+
+	// Read while the refresh is in flight
+	if stats, ok := ProviderStats(cfg); !ok || stats.RefreshCount != 0 {
+		t.Errorf("ProviderStats during the first refresh %+v, %t", stats, ok)
+	}
+	close(release)
+	<-done
+	if stats, _ := ProviderStats(cfg); stats.RefreshCount != 1 {
+		t.Errorf("ProviderStats RefreshCount %d, want 1", stats.RefreshCount)
+	}
+}
@@ -0,0 +1,105 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+var (
+	// ErrInvalidSTSEndpoint is returned when the URL passed to WithSTSEndpoint is not an absolute http(s) URL
+	ErrInvalidSTSEndpoint = errors.New("STS endpoint must be an absolute http or https URL")
+)
+
+// STSClient is the STS surface the assume-role constructors use: the GetCallerIdentity
+// preflight and the stscreds AssumeRole provider.
+type STSClient interface {
+	stscreds.AssumeRoleAPIClient
+	GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error)
+}
+
+// newSTSFromConfig builds the package-internal STS clients; replaced in tests to
+// inspect the options they are constructed with
+var newSTSFromConfig = func(cfg aws.Config, optFns ...func(*sts.Options)) STSClient {
+	return sts.NewFromConfig(cfg, optFns...)
+}
+
+// newSTSClient returns the injected STS client, or one built from cfg
+func newSTSClient(cfg aws.Config, conf *confOptions) STSClient {
+	client := conf.stsClient
+	if client == nil {
+		client = newSTSFromConfig(cfg, conf.stsOpts...)
+	}
+	return limitSTSClient(conf, withSTSFallback(cfg, conf, client))
+}
+
+// WithSTSClient makes the assume-role constructors use client instead of building
+// one from the base config, e.g. to customize middleware or substitute a fake in tests.
+func WithSTSClient(client STSClient) ConfOption {
+	return func(c *confOptions) {
+		c.stsClient = client
+	}
+}
+
+// WithSTSRegion sends the package-internal STS calls to the given region, leaving
+// the Region of the returned aws.Config untouched. It has no effect on a client
+// passed to WithSTSClient.
+func WithSTSRegion(region string) ConfOption {
+	return func(c *confOptions) {
+		c.stsOpts = append(c.stsOpts, func(o *sts.Options) {
+			o.Region = region
+		})
+	}
+}
+
+// WithSTSFIPSEndpoint sends the package-internal STS calls to FIPS endpoints without
+// enabling FIPS on the base config. It has no effect on a client passed to WithSTSClient.
+func WithSTSFIPSEndpoint() ConfOption {
+	return func(c *confOptions) {
+		c.stsOpts = append(c.stsOpts, func(o *sts.Options) {
+			o.EndpointOptions.UseFIPSEndpoint = aws.FIPSEndpointStateEnabled
+		})
+	}
+}
+
+// WithSTSDualStackEndpoint sends the package-internal STS calls to dual-stack
+// (IPv6) endpoints. It has no effect on a client passed to WithSTSClient.
+func WithSTSDualStackEndpoint() ConfOption {
+	return func(c *confOptions) {
+		c.stsOpts = append(c.stsOpts, func(o *sts.Options) {
+			o.EndpointOptions.UseDualStackEndpoint = aws.DualStackEndpointStateEnabled
+		})
+	}
+}
+
+// WithSTSEndpoint sends the package-internal STS calls to endpoint, e.g. LocalStack
+// or a private STS VPC endpoint, without a resolver on the base config. It has no
+// effect on a client passed to WithSTSClient.
+func WithSTSEndpoint(endpoint string) ConfOption {
+	return func(c *confOptions) {
+		c.stsEndpoint = endpoint
+		c.stsOpts = append(c.stsOpts, func(o *sts.Options) {
+			o.BaseEndpoint = aws.String(endpoint)
+		})
+	}
+}
+
+// validateSTSEndpoint checks the URL passed to WithSTSEndpoint, if any
+func validateSTSEndpoint(conf *confOptions) error {
+	if conf.stsEndpoint == "" {
+		return nil
+	}
+	u, err := url.Parse(conf.stsEndpoint)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSTSEndpoint, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w, got %q", ErrInvalidSTSEndpoint, conf.stsEndpoint)
+	}
+	return nil
+}
@@ -0,0 +1,297 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigtest"
+)
+
+// captureSTSOptions replaces the package-internal STS clients with fake, recording
+// the sts.Options each would have been built with
+func captureSTSOptions(t *testing.T, fake STSClient) *[]sts.Options {
+	t.Helper()
+	orig := newSTSFromConfig
+	t.Cleanup(func() { newSTSFromConfig = orig })
+	var built []sts.Options
+	newSTSFromConfig = func(cfg aws.Config, optFns ...func(*sts.Options)) STSClient {
+		o := sts.Options{Region: cfg.Region}
+		for _, fn := range optFns {
+			fn(&o)
+		}
+		built = append(built, o)
+		return fake
+	}
+	return &built
+}
+
+func TestWithSTSRegion(t *testing.T) {
+	tests := []struct {
+		name       string
+		baseRegion string
+		opts       []Option
+		wantRegion string
+	}{
+		{"BaseRegion", "us-east-1", nil, "us-east-1"},
+		{"Override", "us-east-1", []Option{WithSTSRegion("us-west-2")}, "us-west-2"},
+		{"OverrideEmpty", "", []Option{WithSTSRegion("eu-central-1")}, "eu-central-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &awsconfigtest.FakeSTS{}
+			built := captureSTSOptions(t, fake)
+			cfg, err := NewAssumeRoleConf(context.Background(), aws.Config{Region: tt.baseRegion}, testRoleArn, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewAssumeRoleConf: %v", err)
+			}
+			if cfg.Region != tt.baseRegion {
+				t.Errorf("config Region %q, want base region %q", cfg.Region, tt.baseRegion)
+			}
+			// One client serves both the preflight and AssumeRole
+			if len(*built) != 1 || (*built)[0].Region != tt.wantRegion {
+				t.Fatalf("STS clients built with %+v, want one in %q", *built, tt.wantRegion)
+			}
+			if fake.CallerIdentityCalls() != 1 {
+				t.Errorf("preflight made %d calls through the client, want 1", fake.CallerIdentityCalls())
+			}
+		})
+	}
+}
+
+// hostRecorder sends every request to target, recording the host it was meant for
+type hostRecorder struct {
+	target *url.URL
+	next   http.RoundTripper
+
+	mu    sync.Mutex
+	hosts []string
+}
+
+// RoundTrip implements the http.RoundTripper interface method
+func (r *hostRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.mu.Lock()
+	r.hosts = append(r.hosts, req.URL.Host)
+	r.mu.Unlock()
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = r.target.Scheme, r.target.Host
+	return r.next.RoundTrip(req)
+}
+
+// newResolvingSTSServer returns a config whose STS endpoint is resolved by the
+// SDK as usual, with requests sent to an stsServer, and the hosts they were for
+func newResolvingSTSServer(t *testing.T) (aws.Config, *hostRecorder) {
+	t.Helper()
+	_, cfg := newSTSServer(t)
+	target, err := url.Parse(aws.ToString(cfg.BaseEndpoint))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := &hostRecorder{target: target, next: cfg.HTTPClient.(*http.Client).Transport}
+	cfg.BaseEndpoint = nil
+	cfg.HTTPClient = &http.Client{Transport: recorder}
+	return cfg, recorder
+}
+
+func TestSTSEndpointOptions(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("token"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	constructors := []struct {
+		name string
+		new  func(cfg aws.Config, opts ...Option) (aws.Config, error)
+	}{
+		{"AssumeRole", func(cfg aws.Config, opts ...Option) (aws.Config, error) {
+			return NewAssumeRoleConf(context.Background(), cfg, testRoleArn, append(opts, WithSkipIdentityCheck())...)
+		}},
+		{"WebIdentity", func(cfg aws.Config, opts ...Option) (aws.Config, error) {
+			return NewWebIdentityConf(context.Background(), cfg, testWebIdentityRoleArn, tokenFile, opts...)
+		}},
+		{"SessionToken", func(cfg aws.Config, opts ...Option) (aws.Config, error) {
+			return NewSessionTokenConf(context.Background(), cfg, opts...)
+		}},
+		{"SAML", func(cfg aws.Config, opts ...Option) (aws.Config, error) {
+			return NewSAMLConf(context.Background(), cfg, testSAMLPrincipalArn, testSAMLRoleArn,
+				func(context.Context) (string, error) { return "assertion", nil }, opts...)
+		}},
+		{"FederationToken", func(cfg aws.Config, opts ...Option) (aws.Config, error) {
+			return NewFederationTokenConf(context.Background(), cfg, "plugin", opts...)
+		}},
+	}
+	tests := []struct {
+		name     string
+		opts     []Option
+		wantHost string
+	}{
+		{"Default", nil, "sts.us-east-1.amazonaws.com"},
+		{"Region", []Option{WithSTSRegion("eu-west-1")}, "sts.eu-west-1.amazonaws.com"},
+		{"FIPS", []Option{WithSTSFIPSEndpoint()}, "sts-fips.us-east-1.amazonaws.com"},
+		{"DualStack", []Option{WithSTSDualStackEndpoint()}, "sts.us-east-1.api.aws"},
+	}
+	for _, c := range constructors {
+		for _, tt := range tests {
+			t.Run(c.name+"/"+tt.name, func(t *testing.T) {
+				cfg, recorder := newResolvingSTSServer(t)
+				newCfg, err := c.new(cfg, tt.opts...)
+				if err != nil {
+					t.Fatalf("constructor: %v", err)
+				}
+				if _, err := newCfg.Credentials.Retrieve(context.Background()); err != nil {
+					t.Fatalf("Retrieve: %v", err)
+				}
+				if len(recorder.hosts) == 0 || recorder.hosts[len(recorder.hosts)-1] != tt.wantHost {
+					t.Errorf("STS requests sent to %q, want %q", recorder.hosts, tt.wantHost)
+				}
+				if newCfg.Region != "us-east-1" {
+					t.Errorf("config Region %q, want it untouched", newCfg.Region)
+				}
+			})
+		}
+	}
+}
+
+func TestWithSTSFIPSEndpointClientOptions(t *testing.T) {
+	fake := &awsconfigtest.FakeSTS{}
+	built := captureSTSOptions(t, fake)
+	if _, err := NewAssumeRoleConf(context.Background(), aws.Config{Region: "us-gov-west-1"},
+		"arn:aws-us-gov:iam::123456789012:role/Target", WithSTSFIPSEndpoint()); err != nil {
+		t.Fatalf("NewAssumeRoleConf: %v", err)
+	}
+	if len(*built) != 1 || (*built)[0].EndpointOptions.UseFIPSEndpoint != aws.FIPSEndpointStateEnabled {
+		t.Errorf("STS clients built with %+v, want FIPS enabled", *built)
+	}
+}
+
+func TestLegacyOptionAdapters(t *testing.T) {
+	srv, cfg := newSTSServer(t)
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("token"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Option funcs built for the signatures the constructors had before Options
+	webIdentityOpts := []func(*stscreds.WebIdentityRoleOptions){WithWebIdentitySessionName("legacy-web")}
+	sessionTokenOpts := []func(*SessionTokenOptions){WithSessionTokenDuration(time.Hour)}
+	samlOpts := []func(*SAMLRoleOptions){WithSAMLDuration(2 * time.Hour)}
+	federationOpts := []func(*FederationTokenOptions){WithFederationDuration(3 * time.Hour)}
+
+	webCfg, err := NewWebIdentityConf(context.Background(), cfg, testWebIdentityRoleArn, tokenFile,
+		WithWebIdentityRoleOptions(webIdentityOpts...))
+	if err != nil {
+		t.Fatalf("NewWebIdentityConf: %v", err)
+	}
+	if _, err := webCfg.Credentials.Retrieve(context.Background()); err != nil {
+		t.Fatalf("Retrieve: %v", err)
+	}
+	if _, err := NewSessionTokenConf(context.Background(), cfg, WithSessionTokenOptions(sessionTokenOpts...)); err != nil {
+		t.Fatalf("NewSessionTokenConf: %v", err)
+	}
+	samlCfg, err := NewSAMLConf(context.Background(), cfg, testSAMLPrincipalArn, testSAMLRoleArn,
+		func(context.Context) (string, error) { return "assertion", nil }, WithSAMLRoleOptions(samlOpts...))
+	if err != nil {
+		t.Fatalf("NewSAMLConf: %v", err)
+	}
+	if _, err := samlCfg.Credentials.Retrieve(context.Background()); err != nil {
+		t.Fatalf("Retrieve: %v", err)
+	}
+	federationCfg, err := NewFederationTokenConf(context.Background(), cfg, "plugin",
+		WithFederationTokenOptions(federationOpts...))
+	if err != nil {
+		t.Fatalf("NewFederationTokenConf: %v", err)
+	}
+	if _, err := federationCfg.Credentials.Retrieve(context.Background()); err != nil {
+		t.Fatalf("Retrieve: %v", err)
+	}
+
+	requests := srv.Requests()
+	if len(requests) != 4 {
+		t.Fatalf("STS received %d requests, want 4", len(requests))
+	}
+	if got := requests[0].Get("RoleSessionName"); got != "legacy-web" {
+		t.Errorf("AssumeRoleWithWebIdentity session name %q, want legacy-web", got)
+	}
+	if got := requests[1].Get("DurationSeconds"); got != "3600" {
+		t.Errorf("GetSessionToken duration %q, want 3600", got)
+	}
+	if got := requests[2].Get("DurationSeconds"); got != "7200" {
+		t.Errorf("AssumeRoleWithSAML duration %q, want 7200", got)
+	}
+	if got := requests[3].Get("DurationSeconds"); got != "10800" {
+		t.Errorf("GetFederationToken duration %q, want 10800", got)
+	}
+}
+
+func TestWithSTSDualStackEndpointClientOptions(t *testing.T) {
+	fake := &awsconfigtest.FakeSTS{}
+	built := captureSTSOptions(t, fake)
+	base := aws.Config{Region: "us-east-1"}
+	cfg, err := NewAssumeRoleConf(context.Background(), base, testRoleArn, WithSTSDualStackEndpoint())
+	if err != nil {
+		t.Fatalf("NewAssumeRoleConf: %v", err)
+	}
+	if len(*built) != 1 || (*built)[0].EndpointOptions.UseDualStackEndpoint != aws.DualStackEndpointStateEnabled {
+		t.Errorf("STS clients built with %+v, want dual-stack enabled", *built)
+	}
+	// The preflight and AssumeRole both go through the dual-stack client
+	if _, err := cfg.Credentials.Retrieve(context.Background()); err != nil {
+		t.Fatalf("Retrieve: %v", err)
+	}
+	if fake.CallerIdentityCalls() != 1 || len(fake.AssumeRoleInputs()) != 1 {
+		t.Errorf("dual-stack client saw %d preflights and %d AssumeRoles, want 1 each",
+			fake.CallerIdentityCalls(), len(fake.AssumeRoleInputs()))
+	}
+	if len(cfg.ConfigSources) != len(base.ConfigSources) || cfg.BaseEndpoint != nil {
+		t.Errorf("returned config was given endpoint settings: %+v", cfg)
+	}
+}
+
+func TestWithSTSEndpoint(t *testing.T) {
+	srv, cfg := newSTSServer(t)
+	endpoint := aws.ToString(cfg.BaseEndpoint)
+	cfg.BaseEndpoint = nil
+
+	newCfg, err := NewAssumeRoleConf(context.Background(), cfg, testRoleArn, WithSTSEndpoint(endpoint))
+	if err != nil {
+		t.Fatalf("NewAssumeRoleConf: %v", err)
+	}
+	creds, err := newCfg.Credentials.Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("Retrieve: %v", err)
+	}
+	if creds.AccessKeyID != "ASIAFAKE2ACCESSKEY" || creds.SecretAccessKey != "fake-secret-access-key" || !creds.CanExpire {
+		t.Errorf("Retrieve returned %+v", creds)
+	}
+	var actions []string
+	for _, req := range srv.Requests() {
+		actions = append(actions, req.Get("Action"))
+	}
+	if len(actions) != 2 || actions[0] != "GetCallerIdentity" || actions[1] != "AssumeRole" {
+		t.Errorf("endpoint received %q, want the preflight and AssumeRole", actions)
+	}
+	if newCfg.BaseEndpoint != nil {
+		t.Errorf("returned config BaseEndpoint %q, want it unset", *newCfg.BaseEndpoint)
+	}
+}
+
+func TestWithSTSEndpointInvalid(t *testing.T) {
+	for _, endpoint := range []string{"localhost:4566", "ftp://localhost:4566", "http://", "://bad"} {
+		t.Run(endpoint, func(t *testing.T) {
+			_, err := NewAssumeRoleConf(context.Background(), aws.Config{}, testRoleArn,
+				WithSTSClient(&awsconfigtest.FakeSTS{}), WithSTSEndpoint(endpoint))
+			if !errors.Is(err, ErrInvalidSTSEndpoint) {
+				t.Errorf("NewAssumeRoleConf error %v, want %v", err, ErrInvalidSTSEndpoint)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+var (
+	// ErrSTSFailover is passed to the warning handler when an STS call fails over to another region
+	ErrSTSFailover = errors.New("STS call failed over to fallback region")
+)
+
+// WithSTSFallbackRegions retries package-internal STS calls, including the identity
+// preflight, against each of regions in order when the primary region fails with
+// a throttle, network, or server error. The region that served the credentials
+// ends their Source, as in "mostly-harmless/assumerole(...)@us-west-2", and the
+// region that served a failed-over call is passed to the WithWarningHandler
+// handler wrapped in ErrSTSFailover. Each region must be in the role's partition.
+func WithSTSFallbackRegions(regions ...string) ConfOption {
+	return func(c *confOptions) {
+		c.stsFallbackRegions = append(c.stsFallbackRegions, regions...)
+	}
+}
+
+// withSTSFallback wraps client to fail over to the WithSTSFallbackRegions regions, if set
+func withSTSFallback(cfg aws.Config, conf *confOptions, client STSClient) STSClient {
+	if len(conf.stsFallbackRegions) == 0 {
+		return client
+	}
+	c := &failoverSTSClient{
+		primary:       client,
+		primaryRegion: stsRegion(cfg, conf),
+		regions:       conf.stsFallbackRegions,
+		warn:          conf.warn,
+	}
+	for _, region := range conf.stsFallbackRegions {
+		optFns := append(conf.stsOpts[:len(conf.stsOpts):len(conf.stsOpts)], func(o *sts.Options) {
+			o.Region = region
+		})
+		c.fallbacks = append(c.fallbacks, newSTSFromConfig(cfg, optFns...))
+	}
+	return c
+}
+
+// failoverSTSClient is an STSClient that retries failed calls in fallback regions
+type failoverSTSClient struct {
+	primary       STSClient
+	primaryRegion string
+	fallbacks     []STSClient
+	regions       []string
+	warn          func(error)
+}
+
+// servingRegionKey is the context key of the *string a provider's Retrieve passes
+// down to learn the region of the failover STS client that served it
+type servingRegionKey struct{}
+
+// withServingRegion returns ctx recording the region that serves STS calls made with it into region
+func withServingRegion(ctx context.Context, region *string) context.Context {
+	return context.WithValue(ctx, servingRegionKey{}, region)
+}
+
+// failover calls call with the primary client, then each fallback client in turn
+// while the error is one another region might not have
+func failover[T any](ctx context.Context, c *failoverSTSClient, call func(STSClient) (T, error)) (T, error) {
+	out, err := call(c.primary)
+	region := c.primaryRegion
+	for i := 0; err != nil && isFailoverError(err) && i < len(c.fallbacks); i++ {
+		prevErr := err
+		region = c.regions[i]
+		if out, err = call(c.fallbacks[i]); err == nil && c.warn != nil {
+			c.warn(fmt.Errorf("%w %s: %w", ErrSTSFailover, region, prevErr))
+		}
+	}
+	if served, ok := ctx.Value(servingRegionKey{}).(*string); ok && err == nil {
+		*served = region
+	}
+	return out, err
+}
+
+// isFailoverError reports whether err is a throttle, network, or server error
+func isFailoverError(err error) bool {
+	var statusErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &statusErr) && statusErr.HTTPStatusCode() >= 500 {
+		return true
+	}
+	return isTransientError(err)
+}
+
+// AssumeRole implements the STSClient interface method
+func (c *failoverSTSClient) AssumeRole(
+	ctx context.Context,
+	params *sts.AssumeRoleInput,
+	optFns ...func(*sts.Options),
+) (*sts.AssumeRoleOutput, error) {
+	return failover(ctx, c, func(client STSClient) (*sts.AssumeRoleOutput, error) {
+		return client.AssumeRole(ctx, params, optFns...)
+	})
+}
+
+// GetCallerIdentity implements the STSClient interface method
+func (c *failoverSTSClient) GetCallerIdentity(
+	ctx context.Context,
+	params *sts.GetCallerIdentityInput,
+	optFns ...func(*sts.Options),
+) (*sts.GetCallerIdentityOutput, error) {
+	return failover(ctx, c, func(client STSClient) (*sts.GetCallerIdentityOutput, error) {
+		return client.GetCallerIdentity(ctx, params, optFns...)
+	})
+}
+
+// AssumeRoleWithSAML fails over as AssumeRole does, if the clients support the operation
+func (c *failoverSTSClient) AssumeRoleWithSAML(
+	ctx context.Context,
+	params *sts.AssumeRoleWithSAMLInput,
+	optFns ...func(*sts.Options),
+) (*sts.AssumeRoleWithSAMLOutput, error) {
+	return failover(ctx, c, func(client STSClient) (*sts.AssumeRoleWithSAMLOutput, error) {
+		samlClient, ok := client.(AssumeRoleWithSAMLAPIClient)
+		if !ok {
+			return nil, errors.New("STS client does not support AssumeRoleWithSAML")
+		}
+		return samlClient.AssumeRoleWithSAML(ctx, params, optFns...)
+	})
+}
+
+// GetFederationToken fails over as AssumeRole does, if the clients support the operation
+func (c *failoverSTSClient) GetFederationToken(
+	ctx context.Context,
+	params *sts.GetFederationTokenInput,
+	optFns ...func(*sts.Options),
+) (*sts.GetFederationTokenOutput, error) {
+	return failover(ctx, c, func(client STSClient) (*sts.GetFederationTokenOutput, error) {
+		federationClient, ok := client.(GetFederationTokenAPIClient)
+		if !ok {
+			return nil, errors.New("STS client does not support GetFederationToken")
+		}
+		return federationClient.GetFederationToken(ctx, params, optFns...)
+	})
+}
+
+// DecodeAuthorizationMessage passes through to the primary client, if it supports the operation
+func (c *failoverSTSClient) DecodeAuthorizationMessage(
+	ctx context.Context,
+	params *sts.DecodeAuthorizationMessageInput,
+	optFns ...func(*sts.Options),
+) (*sts.DecodeAuthorizationMessageOutput, error) {
+	decoder, ok := c.primary.(decodeAuthorizationMessageAPIClient)
+	if !ok {
+		return nil, errors.New("STS client does not support DecodeAuthorizationMessage")
+	}
+	return decoder.DecodeAuthorizationMessage(ctx, params, optFns...)
+}
@@ -0,0 +1,160 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigtest"
+)
+
+// stubRegionalSTS makes the package-internal STS clients call the fake of their region
+func stubRegionalSTS(t *testing.T, fakes map[string]*awsconfigtest.FakeSTS) {
+	t.Helper()
+	orig := newSTSFromConfig
+	t.Cleanup(func() { newSTSFromConfig = orig })
+	newSTSFromConfig = func(cfg aws.Config, optFns ...func(*sts.Options)) STSClient {
+		o := sts.Options{Region: cfg.Region}
+		for _, fn := range optFns {
+			fn(&o)
+		}
+		fake, ok := fakes[o.Region]
+		if !ok {
+			t.Fatalf("no STS fake for region %q", o.Region)
+		}
+		return fake
+	}
+}
+
+func TestWithSTSFallbackRegions(t *testing.T) {
+	throttled := &smithy.GenericAPIError{Code: "Throttling", Message: "Rate exceeded"}
+	unreachable := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	denied := &smithy.GenericAPIError{Code: "AccessDenied", Message: "denied"}
+
+	tests := []struct {
+		name       string
+		preflight  bool
+		errs       map[string]error
+		wantRegion string
+		wantCalls  map[string]int
+		wantErr    error
+	}{
+		{
+			name:       "PrimaryUp",
+			wantRegion: "us-east-1",
+			wantCalls:  map[string]int{"us-east-1": 1},
+		},
+		{
+			name:       "PrimaryDown",
+			errs:       map[string]error{"us-east-1": throttled},
+			wantRegion: "us-west-2",
+			wantCalls:  map[string]int{"us-east-1": 1, "us-west-2": 1},
+		},
+		{
+			name:       "PrimaryAndFirstFallbackDown",
+			errs:       map[string]error{"us-east-1": unreachable, "us-west-2": throttled},
+			wantRegion: "us-east-2",
+			wantCalls:  map[string]int{"us-east-1": 1, "us-west-2": 1, "us-east-2": 1},
+		},
+		{
+			name:      "AllDown",
+			errs:      map[string]error{"us-east-1": throttled, "us-west-2": throttled, "us-east-2": unreachable},
+			wantCalls: map[string]int{"us-east-1": 1, "us-west-2": 1, "us-east-2": 1},
+			wantErr:   unreachable,
+		},
+		{
+			name:      "AccessDeniedDoesNotFailOver",
+			errs:      map[string]error{"us-east-1": denied},
+			wantCalls: map[string]int{"us-east-1": 1},
+			wantErr:   denied,
+		},
+		{
+			name:      "PreflightPrimaryDown",
+			preflight: true,
+			errs:      map[string]error{"us-east-1": throttled},
+			wantCalls: map[string]int{"us-east-1": 1, "us-west-2": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakes := map[string]*awsconfigtest.FakeSTS{}
+			for _, region := range []string{"us-east-1", "us-west-2", "us-east-2"} {
+				fakes[region] = &awsconfigtest.FakeSTS{AssumeRoleErr: tt.errs[region]}
+				if tt.preflight {
+					fakes[region] = &awsconfigtest.FakeSTS{CallerIdentityErr: tt.errs[region]}
+				}
+			}
+			stubRegionalSTS(t, fakes)
+			var warnings []error
+			opts := []Option{
+				WithSTSFallbackRegions("us-west-2", "us-east-2"),
+				WithIdentityCheckAttempts(1),
+				WithWarningHandler(func(err error) { warnings = append(warnings, err) }),
+			}
+			if !tt.preflight {
+				opts = append(opts, WithSkipIdentityCheck())
+			}
+
+			cfg, err := NewAssumeRoleConf(context.Background(), aws.Config{Region: "us-east-1"}, testRoleArn, opts...)
+			if err != nil {
+				t.Fatalf("NewAssumeRoleConf: %v", err)
+			}
+			calls := func(fake *awsconfigtest.FakeSTS) int { return fake.CallerIdentityCalls() }
+			if !tt.preflight {
+				creds, err := cfg.Credentials.Retrieve(context.Background())
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Retrieve error %v, want %v", err, tt.wantErr)
+				}
+				if err == nil && !strings.HasSuffix(creds.Source, ")@"+tt.wantRegion) {
+					t.Errorf("credentials Source %q does not end with region %s", creds.Source, tt.wantRegion)
+				}
+				calls = func(fake *awsconfigtest.FakeSTS) int { return len(fake.AssumeRoleInputs()) }
+			}
+			for region, fake := range fakes {
+				if got := calls(fake); got != tt.wantCalls[region] {
+					t.Errorf("%s called %d times, want %d", region, got, tt.wantCalls[region])
+				}
+			}
+			if failedOver := tt.wantErr == nil && len(tt.wantCalls) > 1; failedOver {
+				if len(warnings) != 1 || !errors.Is(warnings[0], ErrSTSFailover) {
+					t.Errorf("warnings %v, want one %v", warnings, ErrSTSFailover)
+				}
+			} else if len(warnings) != 0 {
+				t.Errorf("warnings %v, want none", warnings)
+			}
+		})
+	}
+}
+
+func TestWithSTSFallbackRegionsPartition(t *testing.T) {
+	tests := []struct {
+		name    string
+		regions []string
+		opts    []Option
+		wantErr error
+	}{
+		{name: "SamePartition", regions: []string{"us-west-2", "eu-west-1"}},
+		{name: "OtherPartition", regions: []string{"us-west-2", "cn-north-1"}, wantErr: ErrPartitionMismatch},
+		{name: "GovCloud", regions: []string{"us-gov-west-1"}, wantErr: ErrPartitionMismatch},
+		{name: "CheckSkipped", regions: []string{"cn-north-1"}, opts: []Option{WithoutPartitionCheck()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubSTSFromConfig(t, &awsconfigtest.FakeSTS{})
+			opts := append([]Option{WithSTSFallbackRegions(tt.regions...), WithSkipIdentityCheck()}, tt.opts...)
+			_, err := NewAssumeRoleConf(context.Background(), aws.Config{Region: "us-east-1"}, testRoleArn, opts...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewAssumeRoleConf error %v, want %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "fallback region") {
+				t.Errorf("NewAssumeRoleConf error %q does not name the fallback region", err)
+			}
+		})
+	}
+}
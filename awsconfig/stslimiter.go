@@ -0,0 +1,157 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// STSLimiter is a token bucket shared by any number of configs to bound the rate
+// of their STS calls. Waiters are served in the order they arrive. It is safe
+// for concurrent use.
+type STSLimiter struct {
+	interval  time.Duration
+	tolerance time.Duration
+
+	mu sync.Mutex
+	// tat is the theoretical arrival time of the next call at the steady rate
+	tat time.Time
+}
+
+// NewSTSLimiter returns an STSLimiter allowing rate calls per second on average,
+// with bursts of up to burst calls; a rate that isn't positive means no limit
+func NewSTSLimiter(rate float64, burst int) *STSLimiter {
+	var interval time.Duration
+	if rate > 0 {
+		interval = time.Duration(float64(time.Second) / rate)
+	}
+	return &STSLimiter{
+		interval:  interval,
+		tolerance: time.Duration(max(burst, 1)-1) * interval,
+	}
+}
+
+// Wait blocks until a call is allowed or ctx is done
+func (l *STSLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := timeNow()
+	tat := l.tat
+	if tat.Before(now) {
+		tat = now
+	}
+	delay := tat.Sub(now) - l.tolerance
+	l.tat = tat.Add(l.interval)
+	reserved := l.tat
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	if err := sleepContext(ctx, delay); err != nil {
+		// Hand back the slot unless later callers have reserved after it
+		l.mu.Lock()
+		if l.tat.Equal(reserved) {
+			l.tat = l.tat.Add(-l.interval)
+		}
+		l.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// WithSTSLimiter makes the package-internal STS calls, including the identity
+// preflight, wait on limiter; share one limiter across configs to bound their total rate
+func WithSTSLimiter(limiter *STSLimiter) ConfOption {
+	return func(c *confOptions) {
+		c.stsLimiter = limiter
+	}
+}
+
+// limitSTSClient wraps client to wait on the WithSTSLimiter limiter, if set
+func limitSTSClient(conf *confOptions, client STSClient) STSClient {
+	if conf.stsLimiter == nil {
+		return client
+	}
+	return &limitedSTSClient{client: client, limiter: conf.stsLimiter}
+}
+
+// limitedSTSClient is an STSClient whose calls wait on an STSLimiter
+type limitedSTSClient struct {
+	client  STSClient
+	limiter *STSLimiter
+}
+
+// AssumeRole implements the STSClient interface method
+func (c *limitedSTSClient) AssumeRole(
+	ctx context.Context,
+	params *sts.AssumeRoleInput,
+	optFns ...func(*sts.Options),
+) (*sts.AssumeRoleOutput, error) {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return c.client.AssumeRole(ctx, params, optFns...)
+}
+
+// GetCallerIdentity implements the STSClient interface method
+func (c *limitedSTSClient) GetCallerIdentity(
+	ctx context.Context,
+	params *sts.GetCallerIdentityInput,
+	optFns ...func(*sts.Options),
+) (*sts.GetCallerIdentityOutput, error) {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return c.client.GetCallerIdentity(ctx, params, optFns...)
+}
+
+// AssumeRoleWithSAML passes through to the wrapped client, if it supports the operation
+func (c *limitedSTSClient) AssumeRoleWithSAML(
+	ctx context.Context,
+	params *sts.AssumeRoleWithSAMLInput,
+	optFns ...func(*sts.Options),
+) (*sts.AssumeRoleWithSAMLOutput, error) {
+	samlClient, ok := c.client.(AssumeRoleWithSAMLAPIClient)
+	if !ok {
+		return nil, errors.New("STS client does not support AssumeRoleWithSAML")
+	}
+	if err := c.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return samlClient.AssumeRoleWithSAML(ctx, params, optFns...)
+}
+
+// GetFederationToken passes through to the wrapped client, if it supports the operation
+func (c *limitedSTSClient) GetFederationToken(
+	ctx context.Context,
+	params *sts.GetFederationTokenInput,
+	optFns ...func(*sts.Options),
+) (*sts.GetFederationTokenOutput, error) {
+	federationClient, ok := c.client.(GetFederationTokenAPIClient)
+	if !ok {
+		return nil, errors.New("STS client does not support GetFederationToken")
+	}
+	if err := c.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return federationClient.GetFederationToken(ctx, params, optFns...)
+}
+
+// DecodeAuthorizationMessage passes through to the wrapped client, if it supports the operation
+func (c *limitedSTSClient) DecodeAuthorizationMessage(
+	ctx context.Context,
+	params *sts.DecodeAuthorizationMessageInput,
+	optFns ...func(*sts.Options),
+) (*sts.DecodeAuthorizationMessageOutput, error) {
+	decoder, ok := c.client.(decodeAuthorizationMessageAPIClient)
+	if !ok {
+		return nil, errors.New("STS client does not support DecodeAuthorizationMessage")
+	}
+	if err := c.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return decoder.DecodeAuthorizationMessage(ctx, params, optFns...)
+}
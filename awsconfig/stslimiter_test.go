@@ -0,0 +1,155 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigtest"
+)
+
+func TestSTSLimiterBurst(t *testing.T) {
+	tests := []struct {
+		name      string
+		rate      float64
+		burst     int
+		wantCalls int
+	}{
+		{name: "Burst", rate: 1, burst: 3, wantCalls: 3},
+		{name: "NoBurst", rate: 1, burst: 0, wantCalls: 1},
+		{name: "Unlimited", rate: 0, burst: 1, wantCalls: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubClock(t)
+			limiter := NewSTSLimiter(tt.rate, tt.burst)
+			allowed := 0
+			for range 10 {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+				err := limiter.Wait(ctx)
+				cancel()
+				if err != nil {
+					if !errors.Is(err, context.DeadlineExceeded) {
+						t.Fatalf("Wait error %v, want %v", err, context.DeadlineExceeded)
+					}
+					break
+				}
+				allowed++
+			}
+			if allowed != tt.wantCalls {
+				t.Errorf("%d calls allowed without waiting, want %d", allowed, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestSTSLimiterCancelReturnsSlot(t *testing.T) {
+	advance := stubClock(t)
+	limiter := NewSTSLimiter(1, 1)
+	if err := limiter.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	waitErr := make(chan error, 1)
+	go func() { waitErr <- limiter.Wait(ctx) }()
+	cancel()
+	select {
+	case err := <-waitErr:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Wait error %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelling the context did not unblock Wait")
+	}
+
+	// The cancelled waiter's slot is free once the interval has passed
+	advance(time.Second)
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(ctx); err != nil {
+		t.Errorf("Wait after the interval: %v", err)
+	}
+}
+
+func TestSTSLimiterOrdering(t *testing.T) {
+	limiter := NewSTSLimiter(200, 1)
+	var mu sync.Mutex
+	var order []int
+	var wg sync.WaitGroup
+	for i := range 5 {
+		limiter.mu.Lock()
+		reserved := limiter.tat
+		limiter.mu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := limiter.Wait(context.Background()); err != nil {
+				t.Errorf("Wait: %v", err)
+			}
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+		}()
+
+		// Start the next waiter only once this one has reserved its slot
+		for {
+			limiter.mu.Lock()
+			moved := !limiter.tat.Equal(reserved)
+			limiter.mu.Unlock()
+			if moved {
+				break
+			}
+			time.Sleep(100 * time.Microsecond)
+		}
+	}
+	wg.Wait()
+	for i, got := range order {
+		if got != i {
+			t.Fatalf("waiters served in order %v, want arrival order", order)
+		}
+	}
+}
+
+func TestWithSTSLimiter(t *testing.T) {
+	tests := []struct {
+		name string
+		call func(ctx context.Context, fake *awsconfigtest.FakeSTS, limiter *STSLimiter) error
+	}{
+		{name: "Preflight", call: func(ctx context.Context, fake *awsconfigtest.FakeSTS, limiter *STSLimiter) error {
+			_, err := NewAssumeRoleConf(ctx, aws.Config{}, testRoleArn, WithSTSClient(fake), WithSTSLimiter(limiter))
+			return err
+		}},
+		{name: "AssumeRole", call: func(ctx context.Context, fake *awsconfigtest.FakeSTS, limiter *STSLimiter) error {
+			client := limitSTSClient(newConfOptions([]Option{WithSTSLimiter(limiter)}), fake)
+			_, err := client.AssumeRole(ctx, &sts.AssumeRoleInput{RoleArn: aws.String(testRoleArn)})
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubClock(t)
+			fake := &awsconfigtest.FakeSTS{}
+			// Another config sharing the limiter has taken the only token
+			limiter := NewSTSLimiter(1, 1)
+			if err := limiter.Wait(context.Background()); err != nil {
+				t.Fatalf("Wait: %v", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			if err := tt.call(ctx, fake, limiter); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("error %v, want %v", err, context.DeadlineExceeded)
+			}
+			if calls := fake.CallerIdentityCalls() + len(fake.AssumeRoleInputs()); calls != 0 {
+				t.Errorf("%d STS calls made while rate limited, want 0", calls)
+			}
+		})
+	}
+}
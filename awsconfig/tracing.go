@@ -0,0 +1,136 @@
+package awsconfig
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Names and attribute keys of the spans recorded by WithTracerProvider; they are stable
+const (
+	SpanAssumeRole     = "awsconfig.AssumeRole"
+	SpanCustomRetrieve = "awsconfig.CustomRetrieve"
+
+	SpanKeyRoleArn           = "awsconfig.role_arn"
+	SpanKeyRoleSessionName   = "awsconfig.role_session_name"
+	SpanKeyDurationRequested = "awsconfig.duration_requested"
+	SpanKeyFromCache         = "awsconfig.from_cache"
+	SpanKeyExpires           = "awsconfig.expires"
+
+	tracerName = "tkalus.dev/mostly-harmless/awsconfig"
+)
+
+// WithTracerProvider records an OpenTelemetry span for every credential refresh.
+// Retrievals answered from the in-memory credentials cache never reach the
+// provider and are not traced; refreshes answered by a WithCache backend, or
+// with previous credentials under WithStaleIfError after a failure, are traced
+// with from_cache set.
+func WithTracerProvider(tp trace.TracerProvider) ConfOption {
+	return func(c *confOptions) {
+		c.tracerProvider = tp
+	}
+}
+
+// confTracer returns the tracer of the WithTracerProvider provider, if set
+func confTracer(conf *confOptions) (trace.Tracer, bool) {
+	tp, ok := conf.tracerProvider.(trace.TracerProvider)
+	if !ok || tp == nil {
+		return nil, false
+	}
+	return tp.Tracer(tracerName), true
+}
+
+// withAssumeRoleTracing wraps provider to trace its refreshes, if WithTracerProvider is set
+func withAssumeRoleTracing(
+	conf *confOptions,
+	roleArn string,
+	assumeRoleOpts []func(*stscreds.AssumeRoleOptions),
+	provider aws.CredentialsProvider,
+) aws.CredentialsProvider {
+	tracer, ok := confTracer(conf)
+	if !ok {
+		return provider
+	}
+	o := stscreds.AssumeRoleOptions{RoleARN: roleArn}
+	for _, fn := range assumeRoleOpts {
+		fn(&o)
+	}
+	return &tracingProvider{
+		tracer:   tracer,
+		spanName: SpanAssumeRole,
+		attrs: []attribute.KeyValue{
+			attribute.String(SpanKeyRoleArn, roleArn),
+			attribute.String(SpanKeyRoleSessionName, o.RoleSessionName),
+			attribute.String(SpanKeyDurationRequested, o.Duration.String()),
+		},
+		provider: provider,
+	}
+}
+
+// withCustomRetrieveTracing wraps provider to trace its refreshes, if WithTracerProvider is set
+func withCustomRetrieveTracing(conf *confOptions, provider aws.CredentialsProvider) aws.CredentialsProvider {
+	tracer, ok := confTracer(conf)
+	if !ok {
+		return provider
+	}
+	return &tracingProvider{
+		tracer:   tracer,
+		spanName: SpanCustomRetrieve,
+		provider: provider,
+	}
+}
+
+// tracingProvider records a span around each refresh of its provider
+type tracingProvider struct {
+	tracer   trace.Tracer
+	spanName string
+	attrs    []attribute.KeyValue
+	provider aws.CredentialsProvider
+}
+
+// Retrieve implements the aws.CredentialsProvider interface method
+func (p *tracingProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	ctx, span := p.tracer.Start(ctx, p.spanName, trace.WithAttributes(p.attrs...))
+	defer span.End()
+
+	// Set first so traceServedStale and traceServedFromBackend can override it
+	// further down the stack
+	span.SetAttributes(attribute.Bool(SpanKeyFromCache, false))
+	creds, err := p.provider.Retrieve(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return creds, err
+	}
+	if creds.CanExpire {
+		span.SetAttributes(attribute.String(SpanKeyExpires, creds.Expires.UTC().Format(time.RFC3339)))
+	}
+	return creds, nil
+}
+
+// IsCredentialsProvider reports whether the wrapped provider is of the type of target
+func (p *tracingProvider) IsCredentialsProvider(target aws.CredentialsProvider) bool {
+	return aws.IsCredentialsProvider(p.provider, target)
+}
+
+// traceServedStale marks the refresh span of ctx, if any, as answered with
+// previous credentials after err
+func traceServedStale(ctx context.Context, err error) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	span.RecordError(err)
+	span.SetAttributes(attribute.Bool(SpanKeyFromCache, true))
+}
+
+// traceServedFromBackend marks the refresh span of ctx, if any, as answered by
+// a CredentialCache backend
+func traceServedFromBackend(ctx context.Context) {
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Bool(SpanKeyFromCache, true))
+}
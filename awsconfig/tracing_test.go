@@ -0,0 +1,151 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/smithy-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigtest"
+)
+
+func TestWithTracerProvider(t *testing.T) {
+	denied := &smithy.GenericAPIError{Code: "AccessDenied", Message: "denied"}
+	tests := []struct {
+		name      string
+		custom    bool
+		opts      []Option
+		assumeErr error
+		wantSpan  string
+		wantAttrs map[attribute.Key]attribute.Value
+	}{
+		{
+			name:     "AssumeRole",
+			opts:     []Option{WithRoleSessionName("traced"), WithDuration(time.Hour)},
+			wantSpan: SpanAssumeRole,
+			wantAttrs: map[attribute.Key]attribute.Value{
+				SpanKeyRoleArn:           attribute.StringValue(testRoleArn),
+				SpanKeyRoleSessionName:   attribute.StringValue("traced"),
+				SpanKeyDurationRequested: attribute.StringValue("1h0m0s"),
+				SpanKeyFromCache:         attribute.BoolValue(false),
+			},
+		},
+		{
+			name:      "AssumeRoleFailed",
+			assumeErr: denied,
+			wantSpan:  SpanAssumeRole,
+			wantAttrs: map[attribute.Key]attribute.Value{SpanKeyRoleArn: attribute.StringValue(testRoleArn)},
+		},
+		{
+			name:      "CustomRetrieve",
+			custom:    true,
+			wantSpan:  SpanCustomRetrieve,
+			wantAttrs: map[attribute.Key]attribute.Value{SpanKeyFromCache: attribute.BoolValue(false)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := tracetest.NewInMemoryExporter()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+			opts := append([]Option{WithTracerProvider(tp)}, tt.opts...)
+			var cfg aws.Config
+			var err error
+			if tt.custom {
+				retrieve, _ := countingRetrieve(time.Hour)
+				cfg, err = NewCustomFunctionConf(context.Background(), aws.Config{}, retrieve, opts...)
+			} else {
+				fake := &awsconfigtest.FakeSTS{AssumeRoleErr: tt.assumeErr}
+				cfg, err = NewAssumeRoleConf(context.Background(), aws.Config{}, testRoleArn,
+					append(opts, WithSTSClient(fake), WithSkipIdentityCheck())...)
+			}
+			if err != nil {
+				t.Fatalf("constructor: %v", err)
+			}
+			// The second Retrieve is served by the cache and is not traced
+			for range 2 {
+				if _, err := cfg.Credentials.Retrieve(context.Background()); !errors.Is(err, tt.assumeErr) {
+					t.Fatalf("Retrieve error %v, want %v", err, tt.assumeErr)
+				}
+				if tt.assumeErr != nil {
+					break
+				}
+			}
+
+			spans := exporter.GetSpans()
+			if len(spans) != 1 {
+				t.Fatalf("recorded %d spans, want 1", len(spans))
+			}
+			span := spans[0]
+			if span.Name != tt.wantSpan {
+				t.Errorf("span %q, want %q", span.Name, tt.wantSpan)
+			}
+			attrs := map[attribute.Key]attribute.Value{}
+			for _, kv := range span.Attributes {
+				attrs[kv.Key] = kv.Value
+			}
+			for key, want := range tt.wantAttrs {
+				if got := attrs[key]; got != want {
+					t.Errorf("span attribute %s = %v, want %v", key, got.Emit(), want.Emit())
+				}
+			}
+			if failed := tt.assumeErr != nil; failed != (span.Status.Code == codes.Error) {
+				t.Errorf("span status %v, want error %t", span.Status, failed)
+			}
+			if _, ok := attrs[SpanKeyExpires]; ok == (tt.assumeErr != nil) {
+				t.Errorf("span attribute %s set %t, want %t", SpanKeyExpires, ok, tt.assumeErr == nil)
+			}
+		})
+	}
+}
+
+func TestWithTracerProviderBackendHit(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	fake := &awsconfigtest.FakeSTS{Duration: time.Hour}
+	opts := []Option{WithTracerProvider(tp), WithSTSClient(fake), WithSkipIdentityCheck(), WithCache(NewMemoryCache())}
+
+	// The second config is answered by the backend the first one filled
+	var cfg aws.Config
+	for range 2 {
+		var err error
+		if cfg, err = NewAssumeRoleConf(context.Background(), aws.Config{}, testRoleArn, opts...); err != nil {
+			t.Fatalf("NewAssumeRoleConf: %v", err)
+		}
+		if _, err := cfg.Credentials.Retrieve(context.Background()); err != nil {
+			t.Fatalf("Retrieve: %v", err)
+		}
+	}
+	if got := len(fake.AssumeRoleInputs()); got != 1 {
+		t.Errorf("AssumeRole called %d times, want 1", got)
+	}
+
+	var fromCache []bool
+	for _, span := range exporter.GetSpans() {
+		for _, kv := range span.Attributes {
+			if kv.Key == SpanKeyFromCache {
+				fromCache = append(fromCache, kv.Value.AsBool())
+			}
+		}
+	}
+	if len(fromCache) != 2 || fromCache[0] || !fromCache[1] {
+		t.Errorf("spans recorded %s %v, want [false true]", SpanKeyFromCache, fromCache)
+	}
+
+	// The backend is still found behind the tracing
+	if err := InvalidateCredentials(cfg); err != nil {
+		t.Fatalf("InvalidateCredentials: %v", err)
+	}
+	if _, err := cfg.Credentials.Retrieve(context.Background()); err != nil {
+		t.Fatalf("Retrieve: %v", err)
+	}
+	if got := len(fake.AssumeRoleInputs()); got != 2 {
+		t.Errorf("AssumeRole called %d times after InvalidateCredentials, want 2", got)
+	}
+}
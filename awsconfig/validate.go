@@ -0,0 +1,148 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+)
+
+const (
+	// DefaultValidateTimeout bounds ValidateConfig unless WithValidateTimeout is used
+	DefaultValidateTimeout = 10 * time.Second
+)
+
+var (
+	// ErrNoCredentials is returned by ValidateConfig when the config has no credentials
+	ErrNoCredentials = errors.New(
+		"No credentials found, configure a profile, environment variables, SSO, or an instance or task role",
+	)
+	// ErrClockSkew is returned by ValidateConfig when AWS rejects the request signature
+	// because the local clock is off
+	ErrClockSkew = errors.New("Request signature rejected for clock skew, synchronize the system clock")
+	// ErrInvalidCredentials is returned by ValidateConfig when AWS rejects the access key or its signature
+	ErrInvalidCredentials = errors.New(
+		"Credentials rejected by AWS, the access key may be wrong, deleted, or inactive, or the secret key wrong",
+	)
+)
+
+// clockSkewErrorCodes are API error codes for requests signed with a skewed clock
+var clockSkewErrorCodes = map[string]bool{
+	"RequestExpired":       true,
+	"RequestTimeTooSkewed": true,
+}
+
+// invalidCredentialsErrorCodes are API error codes for unknown keys or bad signatures
+var invalidCredentialsErrorCodes = map[string]bool{
+	"InvalidClientTokenId":        true,
+	"SignatureDoesNotMatch":       true,
+	"UnrecognizedClientException": true,
+}
+
+// WithValidateTimeout bounds ValidateConfig, DefaultValidateTimeout by default
+func WithValidateTimeout(timeout time.Duration) ConfOption {
+	return func(c *confOptions) {
+		c.validateTimeout = timeout
+	}
+}
+
+// ValidateConfig checks the credentials of cfg, built by this package or by
+// config.LoadDefaultConfig, are usable by calling GetCallerIdentity with them,
+// and returns the identity they resolve to. Common failures are returned as
+// errors telling how to fix them: ErrNoCredentials, ErrCredentialsExpired,
+// ErrClockSkew, and ErrInvalidCredentials. Other failures wrap
+// ErrIdentityCheckFailed.
+func ValidateConfig(ctx context.Context, cfg aws.Config, opts ...Option) (CallerIdentity, error) {
+	conf := newConfOptions(opts)
+	timeout := DefaultValidateTimeout
+	if conf.validateTimeout > 0 {
+		timeout = conf.validateTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if cfg.Credentials == nil || aws.IsCredentialsProvider(cfg.Credentials, aws.AnonymousCredentials{}) {
+		return CallerIdentity{}, ErrNoCredentials
+	}
+	creds, err := cfg.Credentials.Retrieve(ctx)
+	switch {
+	case err != nil:
+		return CallerIdentity{}, fmt.Errorf("%w: %w", ErrRetrieveCredentials, err)
+	case !creds.HasKeys():
+		return CallerIdentity{}, ErrNoCredentials
+	case creds.Expired():
+		return CallerIdentity{}, fmt.Errorf("%w: expired at %s", ErrCredentialsExpired, creds.Expires)
+	}
+
+	var client getCallerIdentityAPIClient = conf.stsClient
+	if client == nil {
+		client = sts.NewFromConfig(cfg, conf.stsOpts...)
+	}
+	out, err := checkIdentity(ctx, client, conf)
+	if err != nil {
+		return CallerIdentity{}, classifyValidateError(err)
+	}
+	return parseCallerIdentity(out), nil
+}
+
+// classifyValidateError wraps a GetCallerIdentity failure with the error telling how to fix it
+func classifyValidateError(err error) error {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	code := apiErr.ErrorCode()
+	switch {
+	case IsExpiredCredentials(err):
+		return fmt.Errorf("%w: %w", ErrCredentialsExpired, err)
+	// STS reports skew as a signature mismatch mentioning the expired signature
+	case clockSkewErrorCodes[code] ||
+		(code == "SignatureDoesNotMatch" && strings.Contains(apiErr.ErrorMessage(), "Signature expired")):
+		return fmt.Errorf("%w: %w", ErrClockSkew, err)
+	case invalidCredentialsErrorCodes[code]:
+		return fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+	}
+	return err
+}
+
+// ValidateConfigs runs ValidateConfig on each of cfgs concurrently, as limited
+// by WithConcurrency, returning the identities of the names whose
+// credentials are usable and the errors of the others
+func ValidateConfigs(
+	ctx context.Context,
+	cfgs map[string]aws.Config,
+	opts ...Option,
+) (map[string]CallerIdentity, map[string]error) {
+	conf := newConfOptions(opts)
+
+	names := make([]string, 0, len(cfgs))
+	for name := range cfgs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	identities := make([]CallerIdentity, len(names))
+	errs := make([]error, len(names))
+	runBatch(ctx, len(names), conf.concurrency, func(i int) {
+		identities[i], errs[i] = ValidateConfig(ctx, cfgs[names[i]], opts...)
+	}, func(i int, err error) {
+		errs[i] = err
+	})
+
+	valid := make(map[string]CallerIdentity, len(names))
+	invalid := make(map[string]error)
+	for i, name := range names {
+		if errs[i] != nil {
+			invalid[name] = errs[i]
+			continue
+		}
+		valid[name] = identities[i]
+	}
+	return valid, invalid
+}
@@ -0,0 +1,178 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigtest"
+)
+
+// validIdentity is the assumed-role identity returned by the FakeSTS of the validate tests
+var validIdentity = sts.GetCallerIdentityOutput{
+	Account: aws.String("123456789012"),
+	Arn:     aws.String("arn:aws:sts::123456789012:assumed-role/Role/session"),
+	UserId:  aws.String("AROAFAKE:session"),
+}
+
+func TestValidateConfig(t *testing.T) {
+	static := credentials.NewStaticCredentialsProvider("AKIAEXAMPLE", "secret", "")
+	tests := []struct {
+		name        string
+		credentials aws.CredentialsProvider
+		stsErr      error
+		wantErr     []error
+		wantCalls   int
+	}{
+		{name: "Valid", credentials: static, wantCalls: 1},
+		{name: "NoCredentials", wantErr: []error{ErrNoCredentials}},
+		{name: "Anonymous", credentials: aws.AnonymousCredentials{}, wantErr: []error{ErrNoCredentials}},
+		{
+			name:        "EmptyKeys",
+			credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) { return aws.Credentials{}, nil }),
+			wantErr:     []error{ErrNoCredentials},
+		},
+		{
+			name: "RetrieveFails",
+			credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+				return aws.Credentials{}, errors.New("no profile")
+			}),
+			wantErr: []error{ErrRetrieveCredentials},
+		},
+		{
+			name: "ExpiredLocally",
+			credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+				return testCredentials(-time.Minute), nil
+			}),
+			wantErr: []error{ErrCredentialsExpired},
+		},
+		{
+			name:        "ExpiredToken",
+			credentials: static,
+			stsErr:      &smithy.GenericAPIError{Code: "ExpiredToken", Message: "The security token included in the request is expired"},
+			wantErr:     []error{ErrCredentialsExpired, ErrIdentityCheckFailed},
+			wantCalls:   1,
+		},
+		{
+			name:        "RequestExpired",
+			credentials: static,
+			stsErr:      &smithy.GenericAPIError{Code: "RequestExpired", Message: "Request has expired"},
+			wantErr:     []error{ErrClockSkew},
+			wantCalls:   1,
+		},
+		{
+			name:        "SignatureExpired",
+			credentials: static,
+			stsErr: &smithy.GenericAPIError{Code: "SignatureDoesNotMatch",
+				Message: "Signature expired: 20260101T000000Z is now earlier than 20260101T000500Z"},
+			wantErr:   []error{ErrClockSkew},
+			wantCalls: 1,
+		},
+		{
+			name:        "SignatureMismatch",
+			credentials: static,
+			stsErr: &smithy.GenericAPIError{Code: "SignatureDoesNotMatch",
+				Message: "The request signature we calculated does not match the signature you provided"},
+			wantErr:   []error{ErrInvalidCredentials},
+			wantCalls: 1,
+		},
+		{
+			name:        "InvalidClientTokenId",
+			credentials: static,
+			stsErr:      &smithy.GenericAPIError{Code: "InvalidClientTokenId", Message: "The security token included in the request is invalid"},
+			wantErr:     []error{ErrInvalidCredentials},
+			wantCalls:   1,
+		},
+		{
+			name:        "Other",
+			credentials: static,
+			stsErr:      &smithy.GenericAPIError{Code: "AccessDenied", Message: "denied"},
+			wantErr:     []error{ErrIdentityCheckFailed},
+			wantCalls:   1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &awsconfigtest.FakeSTS{Identity: validIdentity, CallerIdentityErr: tt.stsErr}
+			identity, err := ValidateConfig(context.Background(), aws.Config{Credentials: tt.credentials}, WithSTSClient(fake))
+			for _, want := range tt.wantErr {
+				if !errors.Is(err, want) {
+					t.Errorf("ValidateConfig error %v, want %v", err, want)
+				}
+			}
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("ValidateConfig: %v", err)
+				}
+				if identity.AccountID != "123456789012" || identity.RoleName != "Role" || identity.SessionName != "session" {
+					t.Errorf("ValidateConfig returned %+v", identity)
+				}
+			}
+			if fake.CallerIdentityCalls() != tt.wantCalls {
+				t.Errorf("GetCallerIdentity called %d times, want %d", fake.CallerIdentityCalls(), tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestValidateConfigTimeout(t *testing.T) {
+	cfg := aws.Config{Credentials: credentials.NewStaticCredentialsProvider("AKIAEXAMPLE", "secret", "")}
+	start := time.Now()
+	_, err := ValidateConfig(context.Background(), cfg,
+		WithSTSClient(&hangingSTS{FakeSTS: &awsconfigtest.FakeSTS{}}), WithValidateTimeout(10*time.Millisecond))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ValidateConfig error %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("ValidateConfig took %v, want it bounded by the timeout", elapsed)
+	}
+}
+
+func TestValidateConfigs(t *testing.T) {
+	fake := &awsconfigtest.FakeSTS{Identity: validIdentity}
+	cfgs := map[string]aws.Config{
+		"prod":    {Credentials: credentials.NewStaticCredentialsProvider("AKIAPROD", "secret", "")},
+		"staging": {Credentials: credentials.NewStaticCredentialsProvider("AKIASTAGING", "secret", "")},
+		"expired": {Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return testCredentials(-time.Minute), nil
+		})},
+		"missing": {},
+	}
+	valid, invalid := ValidateConfigs(context.Background(), cfgs, WithSTSClient(fake), WithConcurrency(2))
+
+	if len(valid) != 2 || valid["prod"].AccountID != "123456789012" || valid["staging"].AccountID != "123456789012" {
+		t.Errorf("valid configs %+v, want prod and staging", valid)
+	}
+	for name, want := range map[string]error{"expired": ErrCredentialsExpired, "missing": ErrNoCredentials} {
+		if !errors.Is(invalid[name], want) {
+			t.Errorf("invalid[%q] = %v, want %v", name, invalid[name], want)
+		}
+	}
+	if len(invalid) != 2 {
+		t.Errorf("invalid configs %v, want expired and missing", invalid)
+	}
+	if fake.CallerIdentityCalls() != 2 {
+		t.Errorf("GetCallerIdentity called %d times, want 2", fake.CallerIdentityCalls())
+	}
+}
+
+// hangingSTS holds GetCallerIdentity calls until their context is done
+type hangingSTS struct {
+	*awsconfigtest.FakeSTS
+}
+
+// GetCallerIdentity implements the STSClient interface method
+func (*hangingSTS) GetCallerIdentity(
+	ctx context.Context,
+	_ *sts.GetCallerIdentityInput,
+	_ ...func(*sts.Options),
+) (*sts.GetCallerIdentityOutput, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
@@ -0,0 +1,82 @@
+package awsconfig
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+var (
+	// ErrInvalidAccessKeyID is returned by ValidateCredentials for access key IDs of the wrong shape
+	ErrInvalidAccessKeyID = errors.New("Access key ID must be 16-128 upper case letters and digits with a known prefix")
+	// ErrInvalidSecretAccessKey is returned by ValidateCredentials for missing or short secret access keys
+	ErrInvalidSecretAccessKey = errors.New("Secret access key must be at least 40 characters")
+	// ErrSessionTokenMismatch is returned by ValidateCredentials when the presence of
+	// a session token doesn't match the kind of access key
+	ErrSessionTokenMismatch = errors.New("Session token does not match the kind of access key")
+)
+
+const (
+	// minSecretAccessKeyLen is the length of the secret access keys issued by IAM and STS
+	minSecretAccessKeyLen = 40
+)
+
+// Access key ID prefixes, by whether their keys come with a session token
+var (
+	temporaryAccessKeyPrefixes = []string{"ASIA"}
+	longTermAccessKeyPrefixes  = []string{"AKIA", "ABIA", "ACCA"}
+)
+
+// WithCredentialsValidation makes the custom function and HTTP credentials
+// providers check the credentials they return with ValidateCredentials
+func WithCredentialsValidation() ConfOption {
+	return func(c *confOptions) {
+		c.validateCredentials = true
+	}
+}
+
+// ValidateCredentials checks creds look like credentials issued by IAM or STS,
+// without calling AWS: the access key ID has a known prefix and length, the
+// secret access key is as long as AWS issues them, a session token is present
+// exactly when the access key is temporary (ASIA), and the credentials haven't
+// expired. Every problem found is returned, joined. Credentials of AWS
+// compatible services, such as LocalStack, may not pass.
+func ValidateCredentials(creds aws.Credentials) error {
+	var errs []error
+
+	id := creds.AccessKeyID
+	temporary, longTerm := hasAnyPrefix(id, temporaryAccessKeyPrefixes), hasAnyPrefix(id, longTermAccessKeyPrefixes)
+	if len(id) < 16 || len(id) > 128 || strings.IndexFunc(id, isNotAccessKeyRune) >= 0 || !(temporary || longTerm) {
+		errs = append(errs, fmt.Errorf("%w, got %d characters starting %q", ErrInvalidAccessKeyID, len(id), id[:min(4, len(id))]))
+	}
+	if len(creds.SecretAccessKey) < minSecretAccessKeyLen {
+		errs = append(errs, fmt.Errorf("%w, got %d", ErrInvalidSecretAccessKey, len(creds.SecretAccessKey)))
+	}
+	switch {
+	case temporary && creds.SessionToken == "":
+		errs = append(errs, fmt.Errorf("%w: temporary access key without session token", ErrSessionTokenMismatch))
+	case longTerm && creds.SessionToken != "":
+		errs = append(errs, fmt.Errorf("%w: long-term access key with session token", ErrSessionTokenMismatch))
+	}
+	if creds.CanExpire && !creds.Expires.After(timeNow()) {
+		errs = append(errs, fmt.Errorf("%w: expired at %s", ErrCredentialsExpired, creds.Expires))
+	}
+	return errors.Join(errs...)
+}
+
+// hasAnyPrefix reports whether s starts with one of prefixes
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isNotAccessKeyRune reports whether r cannot appear in an access key ID
+func isNotAccessKeyRune(r rune) bool {
+	return (r < 'A' || r > 'Z') && (r < '0' || r > '9')
+}
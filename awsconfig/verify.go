@@ -0,0 +1,67 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+var (
+	// ErrPostAssumeVerification is returned when the assumed credentials cannot be
+	// retrieved or used for GetCallerIdentity at construction
+	ErrPostAssumeVerification = errors.New("Cannot verify assumed role credentials")
+	// ErrUnexpectedAccount is returned when a role or assumed identity is not in an account passed to WithExpectedAccount
+	ErrUnexpectedAccount = errors.New("IAM Role is not in an expected account")
+)
+
+// WithPostAssumeVerification retrieves the assumed credentials and calls
+// GetCallerIdentity with them before the constructor returns, so credentials
+// that don't work fail construction instead of the first service call
+func WithPostAssumeVerification() ConfOption {
+	return func(c *confOptions) {
+		c.postAssumeVerify = true
+	}
+}
+
+// WithExpectedAccount restricts the accounts roles may be assumed in; it may be
+// passed several times to allow several accounts. With WithPostAssumeVerification
+// the account of the assumed identity is checked as well.
+func WithExpectedAccount(accountID string) ConfOption {
+	return func(c *confOptions) {
+		c.expectedAccounts = append(c.expectedAccounts, accountID)
+	}
+}
+
+// checkExpectedAccount verifies accountID is allowed by WithExpectedAccount, if set
+func checkExpectedAccount(conf *confOptions, accountID string) error {
+	if len(conf.expectedAccounts) == 0 || slices.Contains(conf.expectedAccounts, accountID) {
+		return nil
+	}
+	return fmt.Errorf("%w: expected %s, got %s", ErrUnexpectedAccount, strings.Join(conf.expectedAccounts, " or "), accountID)
+}
+
+// verifyAssumedCredentials retrieves credentials from provider, warming its cache,
+// and returns the caller identity they resolve to
+func verifyAssumedCredentials(
+	ctx context.Context,
+	client getCallerIdentityAPIClient,
+	provider aws.CredentialsProvider,
+) (CallerIdentity, error) {
+	if _, err := provider.Retrieve(ctx); err != nil {
+		return CallerIdentity{}, fmt.Errorf("%w: %w", ErrPostAssumeVerification, err)
+	}
+
+	// Sign with the assumed credentials rather than those the client was built with
+	out, err := client.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{}, func(o *sts.Options) {
+		o.Credentials = provider
+	})
+	if err != nil {
+		return CallerIdentity{}, fmt.Errorf("%w: GetCallerIdentity: %w", ErrPostAssumeVerification, err)
+	}
+	return parseCallerIdentity(out), nil
+}
@@ -0,0 +1,112 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+
+	"tkalus.dev/mostly-harmless/awsconfig/awsconfigtest"
+)
+
+func TestWithPostAssumeVerification(t *testing.T) {
+	denied := &smithy.GenericAPIError{Code: "AccessDenied", Message: "denied"}
+	tests := []struct {
+		name          string
+		assumeErr     error
+		identityErr   error
+		wantErr       error
+		wantAssumeErr error
+	}{
+		{name: "Success"},
+		{name: "AssumeRoleDenied", assumeErr: denied, wantErr: ErrPostAssumeVerification, wantAssumeErr: denied},
+		{name: "GetCallerIdentityBlocked", identityErr: denied, wantErr: ErrPostAssumeVerification, wantAssumeErr: denied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The preflight is skipped so GetCallerIdentity is only the verification call
+			fake := &awsconfigtest.FakeSTS{AssumeRoleErr: tt.assumeErr, CallerIdentityErr: tt.identityErr}
+			cfg, err := NewAssumeRoleConf(context.Background(), aws.Config{}, testRoleArn,
+				WithSTSClient(fake), WithSkipIdentityCheck(), WithPostAssumeVerification())
+			if !errors.Is(err, tt.wantErr) || !errors.Is(err, tt.wantAssumeErr) {
+				t.Fatalf("NewAssumeRoleConf error %v, want %v wrapping %v", err, tt.wantErr, tt.wantAssumeErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := fake.CallerIdentityCalls(); got != 1 {
+				t.Errorf("GetCallerIdentity called %d times, want 1", got)
+			}
+
+			// The verified credentials are served from the cache
+			if _, err := cfg.Credentials.Retrieve(context.Background()); err != nil {
+				t.Fatalf("Retrieve: %v", err)
+			}
+			if got := len(fake.AssumeRoleInputs()); got != 1 {
+				t.Errorf("AssumeRole called %d times, want 1", got)
+			}
+		})
+	}
+}
+
+func TestWithExpectedAccount(t *testing.T) {
+	const (
+		roleAccount = "123456789012"
+		staging     = "210987654321"
+		other       = "111122223333"
+	)
+	tests := []struct {
+		name           string
+		expected       []string
+		assumedAccount string
+		verify         bool
+		wantErr        error
+		wantInMsg      string
+	}{
+		{name: "NotSet"},
+		{name: "Matching", expected: []string{roleAccount}},
+		{name: "NotMatching", expected: []string{staging}, wantErr: ErrUnexpectedAccount, wantInMsg: "expected 210987654321, got 123456789012"},
+		{name: "MultipleAllowed", expected: []string{staging, roleAccount}},
+		{
+			name:      "MultipleNotMatching",
+			expected:  []string{staging, other},
+			wantErr:   ErrUnexpectedAccount,
+			wantInMsg: "expected 210987654321 or 111122223333, got 123456789012",
+		},
+		{name: "AssumedMatching", expected: []string{roleAccount}, assumedAccount: roleAccount, verify: true},
+		{
+			name:           "AssumedNotMatching",
+			expected:       []string{roleAccount},
+			assumedAccount: other,
+			verify:         true,
+			wantErr:        ErrUnexpectedAccount,
+			wantInMsg:      "expected 123456789012, got 111122223333",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &awsconfigtest.FakeSTS{Identity: sts.GetCallerIdentityOutput{
+				Account: aws.String(tt.assumedAccount),
+				Arn:     aws.String("arn:aws:sts::" + tt.assumedAccount + ":assumed-role/Target/session"),
+			}}
+			opts := []Option{WithSTSClient(fake), WithSkipIdentityCheck()}
+			for _, account := range tt.expected {
+				opts = append(opts, WithExpectedAccount(account))
+			}
+			if tt.verify {
+				opts = append(opts, WithPostAssumeVerification())
+			}
+			_, err := NewAssumeRoleConf(context.Background(), aws.Config{}, testRoleArn, opts...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewAssumeRoleConf error %v, want %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), tt.wantInMsg) {
+				t.Errorf("NewAssumeRoleConf error %q does not contain %q", err, tt.wantInMsg)
+			}
+		})
+	}
+}
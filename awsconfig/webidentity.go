@@ -0,0 +1,217 @@
+package awsconfig
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// WebIdentityProviderName prefixes the Source of credentials of NewWebIdentityConf
+// configs, followed by the role ARN
+const WebIdentityProviderName = SourcePrefix + "webidentity"
+
+var (
+	// ErrReadWebIdentityToken is returned when the web identity token file cannot be read
+	ErrReadWebIdentityToken = errors.New("Cannot read web identity token file")
+	// ErrEmptyWebIdentityToken is returned when the web identity token file is empty
+	ErrEmptyWebIdentityToken = errors.New("Web identity token file is empty")
+	// ErrWebIdentityTokenExpired is matched by the *WebIdentityTokenExpiredError
+	// returned when WithFailOnExpiredToken finds an expired token on disk
+	ErrWebIdentityTokenExpired = errors.New("Web identity token file holds an expired token")
+)
+
+// WebIdentityTokenExpiredError is returned when the token file read by a
+// WebIdentityTokenFile created WithFailOnExpiredToken holds an expired token,
+// usually because whatever rotates it, such as the kubelet, stopped doing so
+type WebIdentityTokenExpiredError struct {
+	// Path of the token file
+	Path string
+	// Expired is the exp claim of the token
+	Expired time.Time
+	// ModTime is when the token file was last written
+	ModTime time.Time
+}
+
+// Error implements the error interface method
+func (e *WebIdentityTokenExpiredError) Error() string {
+	return fmt.Sprintf("%s %q: expired at %s, file last written at %s",
+		ErrWebIdentityTokenExpired, e.Path, e.Expired.UTC().Format(time.RFC3339), e.ModTime.UTC().Format(time.RFC3339))
+}
+
+// Is reports whether target is ErrWebIdentityTokenExpired
+func (e *WebIdentityTokenExpiredError) Is(target error) bool {
+	return target == ErrWebIdentityTokenExpired
+}
+
+// NewWebIdentityConf returns an aws.Config configured to assume the given roleArn
+// with AssumeRoleWithWebIdentity, using the token found at tokenFilePath and
+// optional WebIdentityOptions and ConfOptions, such as WithSTSRegion or
+// WithSTSFIPSEndpoint.
+func NewWebIdentityConf(
+	_ context.Context,
+	cfg aws.Config,
+	roleArn string,
+	tokenFilePath string,
+	opts ...Option,
+) (aws.Config, error) {
+	conf := newConfOptions(opts)
+
+	// Validate role ARN
+	if _, err := parseRoleArn(roleArn); err != nil {
+		return aws.Config{}, err
+	}
+	if err := validateSTSEndpoint(conf); err != nil {
+		return aws.Config{}, err
+	}
+
+	// Fail early on a missing or empty token file, read as the provider will
+	tokenFile := NewWebIdentityTokenFile(tokenFilePath)
+	o := stscreds.WebIdentityRoleOptions{TokenRetriever: tokenFile}
+	for _, fn := range conf.webIdentityOpts {
+		fn(&o)
+	}
+	if _, err := o.TokenRetriever.GetIdentityToken(); err != nil {
+		return aws.Config{}, err
+	}
+
+	// Construct web-identity provider; the token is re-read on every Retrieve
+	var stsClient stscreds.AssumeRoleWithWebIdentityAPIClient
+	if webIdentityClient, ok := conf.stsClient.(stscreds.AssumeRoleWithWebIdentityAPIClient); ok {
+		stsClient = webIdentityClient
+	} else {
+		stsClient = sts.NewFromConfig(cfg, conf.stsOpts...)
+	}
+	provider := stscreds.NewWebIdentityRoleProvider(stsClient, roleArn, tokenFile, conf.webIdentityOpts...)
+
+	newCfg := cfg.Copy()
+	newCfg.Credentials = aws.NewCredentialsCache(
+		withSource(sourceLabel(WebIdentityProviderName, roleArn), withStats(provider)),
+		conf.cacheOpts...,
+	)
+	return newCfg, nil
+}
+
+// WebIdentityTokenFileOptions is the configurable options for WebIdentityTokenFile
+type WebIdentityTokenFileOptions struct {
+	// FailOnExpired fails token reads with a *WebIdentityTokenExpiredError when
+	// the exp claim of the token has passed, rather than sending it to STS
+	FailOnExpired bool
+}
+
+// WebIdentityTokenFile implements the stscreds.IdentityTokenRetriever interface
+// by reading a web identity token file, such as one projected by the kubelet
+// for IRSA, on every refresh so rotated tokens are picked up. The exp claim of
+// JWT tokens is parsed so the lifetime of the token is known.
+type WebIdentityTokenFile struct {
+	path    string
+	options WebIdentityTokenFileOptions
+
+	mu     sync.Mutex
+	expiry time.Time
+}
+
+// NewWebIdentityTokenFile initializes a new WebIdentityTokenFile reading path
+func NewWebIdentityTokenFile(path string, opts ...func(*WebIdentityTokenFileOptions)) *WebIdentityTokenFile {
+	f := &WebIdentityTokenFile{path: path}
+	for _, fn := range opts {
+		fn(&f.options)
+	}
+	return f
+}
+
+// GetIdentityToken implements the stscreds.IdentityTokenRetriever interface
+// method, rejecting missing and empty files
+func (f *WebIdentityTokenFile) GetIdentityToken() ([]byte, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %w", ErrReadWebIdentityToken, f.path, err)
+	}
+	b, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %w", ErrReadWebIdentityToken, f.path, err)
+	}
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 {
+		return nil, fmt.Errorf("%w: %q", ErrEmptyWebIdentityToken, f.path)
+	}
+
+	expiry, _ := jwtExpiry(b)
+	f.mu.Lock()
+	f.expiry = expiry
+	f.mu.Unlock()
+	if f.options.FailOnExpired && !expiry.IsZero() && !timeNow().Before(expiry) {
+		return nil, &WebIdentityTokenExpiredError{Path: f.path, Expired: expiry, ModTime: info.ModTime()}
+	}
+	return b, nil
+}
+
+// TokenExpiry returns the exp claim of the token last read, false before a
+// token is read or when it isn't a JWT with an exp claim
+func (f *WebIdentityTokenFile) TokenExpiry() (time.Time, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.expiry, !f.expiry.IsZero()
+}
+
+// WithFailOnExpiredToken makes a WebIdentityTokenFile fail reads of expired tokens
+func WithFailOnExpiredToken() func(*WebIdentityTokenFileOptions) {
+	return func(o *WebIdentityTokenFileOptions) {
+		o.FailOnExpired = true
+	}
+}
+
+// WithWebIdentityTokenFile makes NewWebIdentityConf read the token through f,
+// such as one created WithFailOnExpiredToken
+func WithWebIdentityTokenFile(f *WebIdentityTokenFile) WebIdentityOption {
+	return func(o *stscreds.WebIdentityRoleOptions) {
+		o.TokenRetriever = f
+	}
+}
+
+// jwtExpiry returns the exp claim of a JWT, without verifying its signature
+func jwtExpiry(token []byte) (time.Time, bool) {
+	parts := strings.Split(string(token), ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, false
+	}
+	var claims struct {
+		Exp *json.Number `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == nil {
+		return time.Time{}, false
+	}
+	exp, err := claims.Exp.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(exp), 0), true
+}
+
+// WithWebIdentitySessionName sets the web identity session name
+func WithWebIdentitySessionName(name string) WebIdentityOption {
+	return func(o *stscreds.WebIdentityRoleOptions) {
+		o.RoleSessionName = name
+	}
+}
+
+// WithWebIdentityDuration sets the web identity session duration
+func WithWebIdentityDuration(duration time.Duration) WebIdentityOption {
+	return func(o *stscreds.WebIdentityRoleOptions) {
+		o.Duration = duration
+	}
+}
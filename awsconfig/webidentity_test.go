@@ -0,0 +1,203 @@
+package awsconfig
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+const testWebIdentityRoleArn = "arn:aws:iam::123456789012:role/Web"
+
+func TestNewWebIdentityConf(t *testing.T) {
+	srv, cfg := newSTSServer(t)
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("first-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	newCfg, err := NewWebIdentityConf(context.Background(), cfg, testWebIdentityRoleArn, tokenFile,
+		WithWebIdentitySessionName("web-session"), WithWebIdentityDuration(30*time.Minute))
+	if err != nil {
+		t.Fatalf("NewWebIdentityConf: %v", err)
+	}
+	if _, err := newCfg.Credentials.Retrieve(context.Background()); err != nil {
+		t.Fatalf("Retrieve: %v", err)
+	}
+
+	// A rotated token is read on the next refresh
+	if err := os.WriteFile(tokenFile, []byte("second-token"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	newCfg.Credentials.(*aws.CredentialsCache).Invalidate()
+	if _, err := newCfg.Credentials.Retrieve(context.Background()); err != nil {
+		t.Fatalf("Retrieve after rotation: %v", err)
+	}
+
+	requests := srv.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("STS received %d requests, want 2", len(requests))
+	}
+	for i, want := range []string{"first-token", "second-token"} {
+		form := requests[i]
+		if got := form.Get("WebIdentityToken"); got != want {
+			t.Errorf("request %d sent token %q, want %q", i+1, got, want)
+		}
+		if got := form.Get("RoleSessionName"); got != "web-session" {
+			t.Errorf("request %d sent session name %q, want web-session", i+1, got)
+		}
+		if got := form.Get("DurationSeconds"); got != "1800" {
+			t.Errorf("request %d sent duration %q, want 1800", i+1, got)
+		}
+	}
+}
+
+func TestNewWebIdentityConfErrors(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty")
+	if err := os.WriteFile(empty, []byte(" \n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	valid := filepath.Join(dir, "valid")
+	if err := os.WriteFile(valid, []byte("token"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		roleArn   string
+		tokenFile string
+		wantErr   error
+	}{
+		{"MissingFile", testWebIdentityRoleArn, filepath.Join(dir, "missing"), ErrReadWebIdentityToken},
+		{"EmptyFile", testWebIdentityRoleArn, empty, ErrEmptyWebIdentityToken},
+		{"InvalidRoleArn", "role/Web", valid, ErrInvalidRoleArn},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, cfg := newSTSServer(t)
+			_, err := NewWebIdentityConf(context.Background(), cfg, tt.roleArn, tt.tokenFile)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("NewWebIdentityConf error %v, want %v", err, tt.wantErr)
+			}
+			if n := len(srv.Requests()); n != 0 {
+				t.Errorf("STS received %d requests, want none", n)
+			}
+		})
+	}
+}
+
+func TestWebIdentityTokenFileRotation(t *testing.T) {
+	advance := stubClock(t)
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	f := NewWebIdentityTokenFile(tokenFile, WithFailOnExpiredToken())
+	if _, ok := f.TokenExpiry(); ok {
+		t.Error("TokenExpiry reported an expiry before any read")
+	}
+
+	// The kubelet rotates the token well before it expires
+	for i := range 3 {
+		exp := timeNow().Add(time.Hour).Truncate(time.Second)
+		token := testJWT(t, fmt.Sprintf(`{"sub":"system:serviceaccount:default:app","exp":%d}`, exp.Unix()))
+		if err := os.WriteFile(tokenFile, []byte(token+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		got, err := f.GetIdentityToken()
+		if err != nil {
+			t.Fatalf("read %d: %v", i, err)
+		}
+		if string(got) != token {
+			t.Errorf("read %d returned %q, want the rotated token", i, got)
+		}
+		if expiry, ok := f.TokenExpiry(); !ok || !expiry.Equal(exp) {
+			t.Errorf("read %d TokenExpiry = %v, %t, want %v", i, expiry, ok, exp)
+		}
+		advance(48 * time.Minute)
+	}
+}
+
+func TestWebIdentityTokenFileExpired(t *testing.T) {
+	stubClock(t)
+	expired := timeNow().Add(-time.Minute).Truncate(time.Second)
+	valid := timeNow().Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		token   string
+		opts    []func(*WebIdentityTokenFileOptions)
+		wantErr bool
+	}{
+		{name: "Expired", token: testJWT(t, fmt.Sprintf(`{"exp":%d}`, expired.Unix())), opts: []func(*WebIdentityTokenFileOptions){WithFailOnExpiredToken()}, wantErr: true},
+		{name: "ExpiredNotChecked", token: testJWT(t, fmt.Sprintf(`{"exp":%d}`, expired.Unix()))},
+		{name: "Valid", token: testJWT(t, fmt.Sprintf(`{"exp":%d}`, valid.Unix())), opts: []func(*WebIdentityTokenFileOptions){WithFailOnExpiredToken()}},
+		{name: "NoExpClaim", token: testJWT(t, `{"sub":"app"}`), opts: []func(*WebIdentityTokenFileOptions){WithFailOnExpiredToken()}},
+		{name: "Opaque", token: "opaque-token", opts: []func(*WebIdentityTokenFileOptions){WithFailOnExpiredToken()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, cfg := newSTSServer(t)
+			tokenFile := filepath.Join(t.TempDir(), "token")
+			if err := os.WriteFile(tokenFile, []byte(tt.token), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := NewWebIdentityConf(context.Background(), cfg, testWebIdentityRoleArn, tokenFile,
+				WithWebIdentityTokenFile(NewWebIdentityTokenFile(tokenFile, tt.opts...)))
+			if !tt.wantErr {
+				if err != nil {
+					t.Errorf("NewWebIdentityConf: %v", err)
+				}
+				return
+			}
+
+			var expiredErr *WebIdentityTokenExpiredError
+			if !errors.Is(err, ErrWebIdentityTokenExpired) || !errors.As(err, &expiredErr) {
+				t.Fatalf("NewWebIdentityConf error %v, want %v", err, ErrWebIdentityTokenExpired)
+			}
+			if expiredErr.Path != tokenFile || !expiredErr.Expired.Equal(expired) || expiredErr.ModTime.IsZero() {
+				t.Errorf("error %+v", expiredErr)
+			}
+			if n := len(srv.Requests()); n != 0 {
+				t.Errorf("STS received %d requests, want none", n)
+			}
+		})
+	}
+}
+
+func TestJWTExpiry(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		want   time.Time
+		wantOK bool
+	}{
+		{name: "Exp", token: testJWT(t, `{"exp":1893456000}`), want: time.Unix(1893456000, 0), wantOK: true},
+		{name: "FractionalExp", token: testJWT(t, `{"exp":1893456000.5}`), want: time.Unix(1893456000, 0), wantOK: true},
+		{name: "PaddedPayload", token: "e30." + base64.URLEncoding.EncodeToString([]byte(`{"exp":1893456000}`)) + ".sig", want: time.Unix(1893456000, 0), wantOK: true},
+		{name: "NoExp", token: testJWT(t, `{"sub":"app"}`)},
+		{name: "StringExp", token: testJWT(t, `{"exp":"soon"}`)},
+		{name: "NotJSON", token: "e30." + base64.RawURLEncoding.EncodeToString([]byte("not json")) + ".sig"},
+		{name: "NotBase64", token: "e30.!!!.sig"},
+		{name: "TwoParts", token: "e30.e30"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := jwtExpiry([]byte(tt.token))
+			if ok != tt.wantOK || !got.Equal(tt.want) {
+				t.Errorf("jwtExpiry = %v, %t, want %v, %t", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+// testJWT returns an unsigned JWT with the claims JSON
+func testJWT(t *testing.T, claims string) string {
+	t.Helper()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	return header + "." + base64.RawURLEncoding.EncodeToString([]byte(claims)) + ".signature"
+}
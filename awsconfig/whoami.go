@@ -0,0 +1,80 @@
+package awsconfig
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// IdentityType classifies the principal behind a caller identity
+type IdentityType string
+
+const (
+	// IdentityTypeUnknown is an identity whose ARN could not be classified
+	IdentityTypeUnknown IdentityType = ""
+	// IdentityTypeUser is an IAM user
+	IdentityTypeUser IdentityType = "user"
+	// IdentityTypeAssumedRole is an assumed-role session
+	IdentityTypeAssumedRole IdentityType = "assumed-role"
+	// IdentityTypeFederatedUser is a GetFederationToken session
+	IdentityTypeFederatedUser IdentityType = "federated-user"
+	// IdentityTypeRoot is the account root user
+	IdentityTypeRoot IdentityType = "root"
+)
+
+// CallerIdentity is the result of GetCallerIdentity with fields derived from the ARN
+type CallerIdentity struct {
+	AccountID string
+	UserID    string
+	Arn       string
+
+	Type IdentityType
+	// UserName of an IAM or federated user
+	UserName string
+	// RoleName of an assumed-role session
+	RoleName string
+	// SessionName of an assumed-role session
+	SessionName string
+}
+
+// WhoAmI returns the parsed caller identity of the credentials of cfg
+func WhoAmI(ctx context.Context, cfg aws.Config) (CallerIdentity, error) {
+	out, err := checkIdentity(ctx, sts.NewFromConfig(cfg), newConfOptions(nil))
+	if err != nil {
+		return CallerIdentity{}, err
+	}
+	return parseCallerIdentity(out), nil
+}
+
+// parseCallerIdentity classifies a GetCallerIdentity result by its ARN
+func parseCallerIdentity(out *sts.GetCallerIdentityOutput) CallerIdentity {
+	identity := CallerIdentity{
+		AccountID: aws.ToString(out.Account),
+		UserID:    aws.ToString(out.UserId),
+		Arn:       aws.ToString(out.Arn),
+	}
+	parsed, err := arn.Parse(identity.Arn)
+	if err != nil {
+		return identity
+	}
+
+	// Resources are root, user/[path/]name, assumed-role/role/session, or federated-user/name
+	kind, rest, _ := strings.Cut(parsed.Resource, "/")
+	switch {
+	case kind == "root" && rest == "":
+		identity.Type = IdentityTypeRoot
+	case kind == "user" && rest != "":
+		identity.Type = IdentityTypeUser
+		identity.UserName = rest[strings.LastIndex(rest, "/")+1:]
+	case kind == "assumed-role" && strings.Contains(rest, "/"):
+		identity.Type = IdentityTypeAssumedRole
+		identity.RoleName, identity.SessionName, _ = strings.Cut(rest, "/")
+	case kind == "federated-user" && rest != "":
+		identity.Type = IdentityTypeFederatedUser
+		identity.UserName = rest
+	}
+	return identity
+}
@@ -0,0 +1,67 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+func TestParseCallerIdentity(t *testing.T) {
+	tests := []struct {
+		arn  string
+		want CallerIdentity
+	}{
+		{"arn:aws:iam::123456789012:user/Alice", CallerIdentity{Type: IdentityTypeUser, UserName: "Alice"}},
+		{"arn:aws:iam::123456789012:user/division/team/Alice", CallerIdentity{Type: IdentityTypeUser, UserName: "Alice"}},
+		{"arn:aws:sts::123456789012:assumed-role/Deploy/alice@laptop",
+			CallerIdentity{Type: IdentityTypeAssumedRole, RoleName: "Deploy", SessionName: "alice@laptop"}},
+		{"arn:aws-us-gov:sts::123456789012:assumed-role/Deploy/i-0abc",
+			CallerIdentity{Type: IdentityTypeAssumedRole, RoleName: "Deploy", SessionName: "i-0abc"}},
+		{"arn:aws:sts::123456789012:federated-user/plugin", CallerIdentity{Type: IdentityTypeFederatedUser, UserName: "plugin"}},
+		{"arn:aws:iam::123456789012:root", CallerIdentity{Type: IdentityTypeRoot}},
+		{"arn:aws:sts::123456789012:assumed-role/NoSession", CallerIdentity{}},
+		{"arn:aws:iam::123456789012:user/", CallerIdentity{}},
+		{"not-an-arn", CallerIdentity{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arn, func(t *testing.T) {
+			tt.want.AccountID, tt.want.UserID, tt.want.Arn = "123456789012", "AIDAFAKE", tt.arn
+			got := parseCallerIdentity(&sts.GetCallerIdentityOutput{
+				Account: aws.String("123456789012"),
+				UserId:  aws.String("AIDAFAKE"),
+				Arn:     aws.String(tt.arn),
+			})
+			if got != tt.want {
+				t.Errorf("parseCallerIdentity = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWhoAmI(t *testing.T) {
+	srv, cfg := newSTSServer(t)
+	srv.IdentityArn = "arn:aws:sts::123456789012:assumed-role/Deploy/ci"
+	got, err := WhoAmI(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("WhoAmI: %v", err)
+	}
+	want := CallerIdentity{
+		AccountID:   "123456789012",
+		UserID:      "AIDAFAKEUSERID",
+		Arn:         srv.IdentityArn,
+		Type:        IdentityTypeAssumedRole,
+		RoleName:    "Deploy",
+		SessionName: "ci",
+	}
+	if got != want {
+		t.Errorf("WhoAmI = %+v, want %+v", got, want)
+	}
+
+	srv.Errors = map[string]string{"GetCallerIdentity": "InvalidClientTokenId"}
+	if _, err := WhoAmI(context.Background(), cfg); !errors.Is(err, ErrIdentityCheckFailed) {
+		t.Errorf("WhoAmI error %v, want %v", err, ErrIdentityCheckFailed)
+	}
+}